@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/redis"
+	websocketmodule "egaldeutsch-be/modules/websocket"
+)
+
+const chatUsage = `usage: server chat <command>
+
+Commands:
+  flush-history (--room id | --all)
+            delete the stored chat history of one room or of every room`
+
+// runChat implements the "chat" subcommand.
+func runChat(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing chat command\n\n%s", chatUsage)
+	}
+
+	switch args[0] {
+	case "flush-history":
+		fs := flag.NewFlagSet("chat flush-history", flag.ContinueOnError)
+		room := fs.String("room", "", "room ID")
+		all := fs.Bool("all", false, "flush every room")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if (*room != "") == *all {
+			return fmt.Errorf("exactly one of --room and --all is required\n\n%s", chatUsage)
+		}
+
+		redisClient, err := redis.NewRedisClient(cfg.Redis)
+		if err != nil {
+			return fmt.Errorf("failed to initialize Redis client: %w", err)
+		}
+		defer redisClient.Close()
+
+		// The hub is never started; only its history store is used
+		chat := websocketmodule.NewModule(nil, redisClient, cfg.Jwt, nil, nil, nil, nil, nil)
+		flushed, err := chat.FlushHistory(ctx, *room)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Flushed the history of %d room(s)\n", flushed)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown chat command %q\n\n%s", args[0], chatUsage)
+	}
+}
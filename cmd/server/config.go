@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/database"
+	"egaldeutsch-be/internal/redis"
+)
+
+const configUsage = `usage: server config <command>
+
+Commands:
+  print               print the effective configuration with secrets redacted
+  check [--connect]   validate the configuration; --connect also connects to
+                      the database and Redis`
+
+// runConfig implements the "config" subcommand.
+func runConfig(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing config command\n\n%s", configUsage)
+	}
+
+	switch args[0] {
+	case "print":
+		out, err := cfg.MarshalRedactedYAML()
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(out)
+		return err
+
+	case "check":
+		fs := flag.NewFlagSet("config check", flag.ContinueOnError)
+		connect := fs.Bool("connect", false, "connect to the database and Redis")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		// Loading already validated the configuration
+		if *connect {
+			if err := checkConnections(cfg); err != nil {
+				return err
+			}
+		}
+		fmt.Println("configuration is valid")
+		return nil
+
+	default:
+		return fmt.Errorf("unknown config command %q\n\n%s", args[0], configUsage)
+	}
+}
+
+// checkConnections connects to the database and Redis; both constructors
+// ping the server.
+func checkConnections(cfg *config.Config) error {
+	db, err := database.NewDatabase(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("database: %w", err)
+	}
+	defer db.Close()
+
+	redisClient, err := redis.NewRedisClient(cfg.Redis)
+	if err != nil {
+		return fmt.Errorf("redis: %w", err)
+	}
+	return redisClient.Close()
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"egaldeutsch-be/internal/auth"
+	"egaldeutsch-be/internal/config"
+)
+
+const keysUsage = `usage: server keys <command>
+
+Commands:
+  generate [--alg EdDSA|RS256] [--dir dir] kid
+            write a new private key to <dir>/<kid>.pem; dir defaults to
+            jwt.keys_dir
+  retire [--dir dir] kid
+            replace the private key <dir>/<kid>.pem by its public key, so it
+            only verifies tokens issued before the rotation
+  list      list the configured keys and which one signs new tokens`
+
+// runKeys implements the "keys" subcommand.
+func runKeys(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing keys command\n\n%s", keysUsage)
+	}
+
+	fs := flag.NewFlagSet("keys "+args[0], flag.ContinueOnError)
+	dir := fs.String("dir", cfg.Jwt.KeysDir, "directory of the key files")
+
+	switch args[0] {
+	case "generate":
+		alg := fs.String("alg", config.JwtEdDSA, "key algorithm: EdDSA or RS256")
+		kid, err := parseKeyArgs(fs, args[1:], dir)
+		if err != nil {
+			return err
+		}
+		key, err := generateKey(*alg)
+		if err != nil {
+			return err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(*dir, kid+".pem")
+		if err := writePEM(path, "PRIVATE KEY", der, os.O_CREATE|os.O_EXCL); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s key %q to %s\n", *alg, kid, path)
+		return nil
+
+	case "retire":
+		kid, err := parseKeyArgs(fs, args[1:], dir)
+		if err != nil {
+			return err
+		}
+		if kid == cfg.Jwt.SigningKeyID {
+			return fmt.Errorf("key %q still signs new tokens; change jwt.signing_key_id first", kid)
+		}
+		path := filepath.Join(*dir, kid+".pem")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		block, _ := pem.Decode(data)
+		if block == nil || block.Type != "PRIVATE KEY" {
+			return fmt.Errorf("%s is not a PKCS #8 private key", path)
+		}
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return fmt.Errorf("%s holds an unsupported key type %T", path, key)
+		}
+		der, err := x509.MarshalPKIXPublicKey(signer.Public())
+		if err != nil {
+			return err
+		}
+		if err := writePEM(path, "PUBLIC KEY", der, os.O_TRUNC); err != nil {
+			return err
+		}
+		fmt.Printf("Key %q now only verifies tokens\n", kid)
+		return nil
+
+	case "list":
+		keys, err := auth.LoadKeySet(cfg.Jwt)
+		if err != nil {
+			return err
+		}
+		infos := keys.Keys()
+		if len(infos) == 0 {
+			fmt.Println("Tokens are signed with the HS256 shared secret; there are no keys to list")
+			return nil
+		}
+		for _, k := range infos {
+			use := "verify only"
+			if k.Signing {
+				use = "signs new tokens"
+			}
+			fmt.Printf("%-24s %-6s %s\n", k.ID, k.Algorithm, use)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown keys command %q\n\n%s", args[0], keysUsage)
+	}
+}
+
+// parseKeyArgs parses the flags and the kid argument of generate and retire.
+func parseKeyArgs(fs *flag.FlagSet, args []string, dir *string) (string, error) {
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+	if fs.NArg() != 1 {
+		return "", fmt.Errorf("expected exactly one key ID\n\n%s", keysUsage)
+	}
+	if *dir == "" {
+		return "", fmt.Errorf("--dir is required when jwt.keys_dir is not set\n\n%s", keysUsage)
+	}
+	kid := fs.Arg(0)
+	if kid != filepath.Base(kid) || kid == "." {
+		return "", fmt.Errorf("invalid key ID %q", kid)
+	}
+	return kid, nil
+}
+
+// generateKey creates a private key for alg.
+func generateKey(alg string) (crypto.Signer, error) {
+	switch alg {
+	case config.JwtEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	case config.JwtRS256:
+		return rsa.GenerateKey(rand.Reader, 3072)
+	default:
+		return nil, errors.New("--alg must be EdDSA or RS256")
+	}
+}
+
+// writePEM writes a PEM block readable only by the owner.
+func writePEM(path, blockType string, der []byte, flags int) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|flags, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := pem.Encode(f, &pem.Block{Type: blockType, Bytes: der}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
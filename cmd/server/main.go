@@ -1,30 +1,112 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
 
 	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/logging"
 	"egaldeutsch-be/internal/server"
 )
 
+const usage = `usage: server [--profile name] [command]
+
+Without a command the HTTP server is started.
+
+Commands:
+  serve     start the HTTP server
+  migrate   apply or revert database migrations (see "server migrate")
+  seed      load users and quiz questions from fixture files
+  user      create an admin or change a user's role (see "server user")
+  tokens    purge expired refresh and password reset tokens
+  keys      generate, retire or list JWT signing keys (see "server keys")
+  chat      flush stored chat history (see "server chat")
+  config    print or check the effective configuration
+
+Flags:
+`
+
 func main() {
+	profile := flag.String("profile", os.Getenv(config.ProfileEnv), "configuration profile, merges config.<profile>.yaml (env "+config.ProfileEnv+")")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	args := flag.Args()
+
+	command := "serve"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
 	// Load configuration
-	cfg, err := config.LoadConfig()
+	cfg, err := config.LoadWithOptions(config.LoadOptions{Profile: *profile})
 	if err != nil {
+		if command == "config" && len(args) > 0 && args[0] == "check" {
+			fmt.Fprintf(os.Stderr, "configuration is invalid: %v\n", err)
+			os.Exit(1)
+		}
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	fmt.Printf("Starting server at %s:%s\n", cfg.Server.Host, cfg.Server.Port)
+	if err := logging.Configure(cfg.Logging); err != nil {
+		log.Fatalf("Failed to configure logging: %v", err)
+	}
+
+	// Stop on SIGINT/SIGTERM; a second signal terminates immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 
+	switch command {
+	case "serve":
+		err = runServe(ctx, cfg)
+	case "migrate":
+		err = runMigrate(ctx, cfg, args)
+	case "seed":
+		err = runSeed(ctx, cfg, args)
+	case "user":
+		err = runUser(ctx, cfg, args)
+	case "tokens":
+		err = runTokens(ctx, cfg, args)
+	case "keys":
+		err = runKeys(ctx, cfg, args)
+	case "chat":
+		err = runChat(ctx, cfg, args)
+	case "config":
+		err = runConfig(ctx, cfg, args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		if command == "serve" {
+			logrus.WithError(err).Fatal("Server stopped with error")
+		}
+		fmt.Fprintf(os.Stderr, "%s: %v\n", command, err)
+		os.Exit(1)
+	}
+}
+
+// runServe starts the HTTP server and blocks until it has shut down.
+func runServe(ctx context.Context, cfg *config.Config) error {
 	// Create server (now returns error following Go philosophy)
 	srv, err := server.NewServer(cfg)
 	if err != nil {
-		log.Fatalf("Failed to create server: %v", err)
+		return fmt.Errorf("failed to create server: %w", err)
 	}
 
-	// Start server
-	if err := srv.Start(); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
-	}
+	return srv.Start(ctx)
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/database"
+)
+
+const migrateUsage = `usage: server migrate <command>
+
+Commands:
+  up        apply all pending migrations
+  down      revert the most recently applied migration
+  status    list migrations and whether they are applied
+  to N      migrate up or down to version N (0 reverts everything)`
+
+// runMigrate implements the "migrate" subcommand.
+func runMigrate(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate command\n\n%s", migrateUsage)
+	}
+
+	db, err := database.NewDatabase(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+
+	m, err := db.SchemaMigrator()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migration(s)\n", applied)
+		return nil
+
+	case "down":
+		return m.Down(ctx)
+
+	case "to":
+		if len(args) != 2 {
+			return fmt.Errorf("migrate to requires a version\n\n%s", migrateUsage)
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		return m.To(ctx, version)
+
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, st := range statuses {
+			state, appliedAt := "pending", ""
+			if st.Applied {
+				state = "applied"
+				appliedAt = st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if st.ChecksumMismatch {
+				state = "modified"
+			}
+			fmt.Fprintf(w, "%06d\t%s\t%s\t%s\n", st.Version, st.Name, state, appliedAt)
+		}
+		return w.Flush()
+
+	default:
+		return fmt.Errorf("unknown migrate command %q\n\n%s", args[0], migrateUsage)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/database"
+	"egaldeutsch-be/modules/quiz"
+	"egaldeutsch-be/modules/user"
+	"egaldeutsch-be/pkg/models"
+)
+
+const seedUsage = `usage: server seed [--fixtures dir]
+
+Loads users.json and questions.json from the fixtures directory; missing
+files are skipped. Users whose email already exists and questions whose
+text already exists are left unchanged, so seeding can be repeated.`
+
+// userFixture is one entry of users.json.
+type userFixture struct {
+	Name     string          `json:"name"`
+	Email    string          `json:"email"`
+	Password string          `json:"password"`
+	Role     models.UserRole `json:"role"`
+}
+
+// questionFixture is one entry of questions.json.
+type questionFixture struct {
+	QuestionText  string   `json:"question_text"`
+	Options       []string `json:"options"`
+	CorrectOption int      `json:"correct_option"`
+	Category      string   `json:"category"`
+}
+
+// runSeed implements the "seed" subcommand.
+func runSeed(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprintln(fs.Output(), seedUsage) }
+	dir := fs.String("fixtures", "fixtures", "directory containing the fixture files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var users []userFixture
+	if err := readFixture(filepath.Join(*dir, "users.json"), &users); err != nil {
+		return err
+	}
+	var questions []questionFixture
+	if err := readFixture(filepath.Join(*dir, "questions.json"), &questions); err != nil {
+		return err
+	}
+
+	db, err := database.NewDatabase(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+
+	userModule := user.NewModule(db.DB, cfg.Jwt, cfg.Password, nil, nil, nil, nil, nil)
+	created, skipped := 0, 0
+	for _, u := range users {
+		role := u.Role
+		if role == "" {
+			role = models.UserRoleLearner
+		}
+		_, err := userModule.CreateUser(ctx, u.Name, u.Email, u.Password, role)
+		switch {
+		case errors.Is(err, user.ErrUserExists):
+			skipped++
+		case err != nil:
+			return fmt.Errorf("seed user %s: %w", u.Email, err)
+		default:
+			created++
+		}
+	}
+
+	quizModule := quiz.NewModule(db.DB, cfg.Jwt, nil, nil, nil, nil, nil)
+	createdQuestions, skippedQuestions := 0, 0
+	for i, q := range questions {
+		err := quizModule.CreateQuestion(ctx, q.QuestionText, q.Options, q.CorrectOption, q.Category)
+		switch {
+		case errors.Is(err, quiz.ErrQuestionExists):
+			skippedQuestions++
+		case err != nil:
+			return fmt.Errorf("seed question %d: %w", i+1, err)
+		default:
+			createdQuestions++
+		}
+	}
+
+	fmt.Printf("Seeded %d user(s) (%d already existed) and %d question(s) (%d already existed)\n",
+		created, skipped, createdQuestions, skippedQuestions)
+	return nil
+}
+
+// readFixture decodes a JSON fixture file. A missing file is not an error.
+func readFixture(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/database"
+	authmodule "egaldeutsch-be/modules/auth"
+)
+
+const tokensUsage = `usage: server tokens <command>
+
+Commands:
+  purge-expired   delete expired refresh, password reset and email verification tokens`
+
+// runTokens implements the "tokens" subcommand.
+func runTokens(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing tokens command\n\n%s", tokensUsage)
+	}
+
+	switch args[0] {
+	case "purge-expired":
+		db, err := database.NewDatabase(cfg.Database)
+		if err != nil {
+			return fmt.Errorf("failed to initialize database: %w", err)
+		}
+		defer db.Close()
+
+		repo := authmodule.NewRepository(db.DB)
+		now := time.Now()
+
+		tokens, err := repo.PurgeExpiredRefreshTokens(ctx, now)
+		if err != nil {
+			return fmt.Errorf("purge refresh tokens: %w", err)
+		}
+		resets, err := repo.PurgeExpiredPasswordResets(ctx, now)
+		if err != nil {
+			return fmt.Errorf("purge password resets: %w", err)
+		}
+		verifications, err := repo.PurgeExpiredEmailVerifications(ctx, now)
+		if err != nil {
+			return fmt.Errorf("purge email verifications: %w", err)
+		}
+		fmt.Printf("Purged %d refresh token(s), %d password reset(s) and %d email verification(s)\n", tokens, resets, verifications)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown tokens command %q\n\n%s", args[0], tokensUsage)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/database"
+	"egaldeutsch-be/modules/user"
+	"egaldeutsch-be/pkg/models"
+)
+
+const userUsage = `usage: server user <command>
+
+Commands:
+  create-admin --email addr --name name [--password pw]
+            create an admin user; without --password the password is read
+            from the first line of standard input
+  set-role --email addr --role role
+            change the role of an existing user to learner, teacher,
+            moderator or admin`
+
+// runUser implements the "user" subcommand.
+func runUser(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing user command\n\n%s", userUsage)
+	}
+
+	fs := flag.NewFlagSet("user "+args[0], flag.ContinueOnError)
+	email := fs.String("email", "", "email address of the user")
+
+	switch args[0] {
+	case "create-admin":
+		name := fs.String("name", "", "display name")
+		password := fs.String("password", "", "password (read from stdin when empty)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *email == "" || *name == "" {
+			return fmt.Errorf("--email and --name are required\n\n%s", userUsage)
+		}
+		if *password == "" {
+			pw, err := readPassword()
+			if err != nil {
+				return err
+			}
+			*password = pw
+		}
+
+		users, closeDB, err := openUserModule(cfg)
+		if err != nil {
+			return err
+		}
+		defer closeDB()
+
+		id, err := users.CreateUser(ctx, *name, *email, *password, models.UserRoleAdmin)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created admin %s (%s)\n", *email, id)
+		return nil
+
+	case "set-role":
+		role := fs.String("role", "", "new role")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *email == "" || *role == "" {
+			return fmt.Errorf("--email and --role are required\n\n%s", userUsage)
+		}
+		if !models.UserRole(*role).Canonical().IsValid() {
+			return fmt.Errorf("unknown role %q", *role)
+		}
+
+		users, closeDB, err := openUserModule(cfg)
+		if err != nil {
+			return err
+		}
+		defer closeDB()
+
+		if err := users.SetRole(ctx, *email, models.UserRole(*role)); err != nil {
+			return err
+		}
+		fmt.Printf("Set role of %s to %s\n", *email, *role)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown user command %q\n\n%s", args[0], userUsage)
+	}
+}
+
+// openUserModule connects to the database and builds the user module.
+func openUserModule(cfg *config.Config) (*user.Module, func(), error) {
+	db, err := database.NewDatabase(cfg.Database)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	return user.NewModule(db.DB, cfg.Jwt, cfg.Password, nil, nil, nil, nil, nil), func() { db.Close() }, nil
+}
+
+// readPassword reads the password from the first line of standard input, so
+// it does not end up in the shell history.
+func readPassword() (string, error) {
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && (line == "" || !errors.Is(err, io.EOF)) {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
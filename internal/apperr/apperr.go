@@ -0,0 +1,119 @@
+// Package apperr defines the typed errors handlers report to clients. An
+// Error carries the HTTP status and a stable, machine-readable code; the
+// error middleware renders it as an RFC 7807 application/problem+json body.
+// Any other error reaching the middleware is treated as internal and its
+// message is never shown to the client.
+package apperr
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Generic codes. Modules define more specific ones, such as "user_not_found",
+// for errors clients are expected to handle.
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidation       = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodeRateLimited      = "rate_limited"
+	CodeInternal         = "internal_error"
+)
+
+// FieldError describes why one field of the request was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is an error that is safe to show to the client.
+type Error struct {
+	Status int
+	Code   string
+	Detail string
+	Fields []FieldError
+	cause  error
+	// sentinel is the error Wrap copied, so errors.Is matches the copy
+	sentinel *Error
+}
+
+// New returns an error with the given status, code and client-facing detail.
+func New(status int, code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+// BadRequest returns a 400 error.
+func BadRequest(code, detail string) *Error {
+	return New(http.StatusBadRequest, code, detail)
+}
+
+// Unauthorized returns a 401 error.
+func Unauthorized(code, detail string) *Error {
+	return New(http.StatusUnauthorized, code, detail)
+}
+
+// Forbidden returns a 403 error.
+func Forbidden(code, detail string) *Error {
+	return New(http.StatusForbidden, code, detail)
+}
+
+// NotFound returns a 404 error.
+func NotFound(code, detail string) *Error {
+	return New(http.StatusNotFound, code, detail)
+}
+
+// Conflict returns a 409 error.
+func Conflict(code, detail string) *Error {
+	return New(http.StatusConflict, code, detail)
+}
+
+// Validation returns a 400 error listing the rejected fields.
+func Validation(fields ...FieldError) *Error {
+	err := New(http.StatusBadRequest, CodeValidation, "The request has invalid fields.")
+	err.Fields = fields
+	return err
+}
+
+// Error returns the detail, followed by the cause if there is one, for logs.
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.Detail + ": " + e.cause.Error()
+	}
+	return e.Detail
+}
+
+// Unwrap returns the error Wrap copied and the cause it recorded, so
+// errors.Is matches a wrapped copy against its sentinel. Errors are otherwise
+// compared by identity: two errors with the same code are different errors.
+func (e *Error) Unwrap() []error {
+	var errs []error
+	if e.sentinel != nil {
+		errs = append(errs, e.sentinel)
+	}
+	if e.cause != nil {
+		errs = append(errs, e.cause)
+	}
+	return errs
+}
+
+// Wrap returns a copy of e that records cause for the logs. The cause is not
+// shown to the client.
+func (e *Error) Wrap(cause error) *Error {
+	c := *e
+	c.cause = cause
+	c.sentinel = e
+	return &c
+}
+
+// As returns the *Error in err's chain, or nil if there is none.
+func As(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorIs(t *testing.T) {
+	errRole := Validation(FieldError{Field: "role", Message: "is unknown"})
+	errID := Validation(FieldError{Field: "id", Message: "must be a UUID"})
+	errNotFound := NotFound("user_not_found", "The user does not exist.")
+
+	if errors.Is(errRole, errID) || errors.Is(errID, errRole) {
+		t.Fatalf("expected different validation errors not to match")
+	}
+	if errors.Is(Forbidden(CodeForbidden, "a"), Forbidden(CodeForbidden, "b")) {
+		t.Fatalf("expected different errors with the same code not to match")
+	}
+
+	cause := errors.New("record not found")
+	wrapped := fmt.Errorf("get user: %w", errNotFound.Wrap(cause))
+	if !errors.Is(wrapped, errNotFound) {
+		t.Fatalf("expected a wrapped copy to match its sentinel")
+	}
+	if !errors.Is(wrapped, cause) {
+		t.Fatalf("expected a wrapped copy to match its cause")
+	}
+	if errors.Is(wrapped, errID) {
+		t.Fatalf("expected a wrapped copy not to match another error")
+	}
+	if e := As(wrapped); e == nil || e.Code != "user_not_found" || e.Error() != "The user does not exist.: record not found" {
+		t.Fatalf("unexpected error %v", e)
+	}
+}
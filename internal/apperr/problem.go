@@ -0,0 +1,39 @@
+package apperr
+
+import "net/http"
+
+// ContentType is the media type of problem responses.
+const ContentType = "application/problem+json"
+
+// typePrefix turns a code into the problem type URI.
+const typePrefix = "urn:egaldeutsch:problem:"
+
+// Problem is an RFC 7807 problem details object. Code, RequestID and Errors
+// are extension members.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// ProblemFor describes err for the client. Errors without an *Error in
+// their chain become a generic 500 so internal messages do not leak.
+func ProblemFor(err error) Problem {
+	e := As(err)
+	if e == nil {
+		e = New(http.StatusInternalServerError, CodeInternal, "An unexpected error occurred.")
+	}
+	return Problem{
+		Type:   typePrefix + e.Code,
+		Title:  http.StatusText(e.Status),
+		Status: e.Status,
+		Detail: e.Detail,
+		Code:   e.Code,
+		Errors: e.Fields,
+	}
+}
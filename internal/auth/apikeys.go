@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/logging"
+)
+
+// APIKeyPrefix starts every API key, so that a leaked key is easy to
+// recognise, for example by secret scanners, and is never mistaken for an
+// access token.
+const APIKeyPrefix = "egd_"
+
+const (
+	// apiKeyPrefixLength is how much of a key, APIKeyPrefix included, is
+	// stored in plain text to tell keys apart
+	apiKeyPrefixLength = 12
+	// apiKeyTouchInterval is how often the last use of a key is recorded at
+	// most, so that busy clients do not write on every request
+	apiKeyTouchInterval = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey  = apperr.Unauthorized("invalid_api_key", "The API key is invalid, expired or revoked.")
+	ErrAPIKeyNotFound = apperr.NotFound("api_key_not_found", "The API key does not exist or has already been revoked.")
+	ErrTooManyAPIKeys = apperr.Conflict("too_many_api_keys", "You have as many API keys as allowed; revoke one first.")
+)
+
+// APIKey is a key a user created for a machine client, as shown to the user
+// and to admins. The key itself is only shown when it is created.
+type APIKey struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	// Prefix is the start of the key, to tell keys apart
+	Prefix string `json:"prefix"`
+	// Scopes are the permissions the key may use, as far as the role of its
+	// user has them
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt is nil for a key that does not expire
+	ExpiresAt *time.Time `json:"expires_at"`
+	// LastUsedAt is nil until the key is used; it is recorded at most once
+	// a minute
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// APIKeyPrincipal is the user an API key authenticates.
+type APIKeyPrincipal struct {
+	KeyID  string
+	UserID string
+	// Role is the current role of the user
+	Role       string
+	Scopes     []string
+	LastUsedAt *time.Time
+}
+
+// IsAPIKey reports whether a bearer token is an API key rather than an
+// access token.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// APIKeys authenticates requests presenting an API key instead of an access
+// token.
+type APIKeys struct {
+	repo APIKeyRepo
+}
+
+// NewAPIKeys returns an authenticator looking keys up in repo.
+func NewAPIKeys(repo APIKeyRepo) *APIKeys {
+	return &APIKeys{repo: repo}
+}
+
+// Verify returns the user of an unrevoked, unexpired key and records its
+// use; ErrInvalidAPIKey for any other key. Failing to record the use is only
+// logged.
+func (k *APIKeys) Verify(ctx context.Context, key string) (*APIKeyPrincipal, error) {
+	principal, err := k.repo.GetAPIKeyByHash(ctx, hashToken(key))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if principal.LastUsedAt == nil || now.Sub(*principal.LastUsedAt) >= apiKeyTouchInterval {
+		if err := k.repo.TouchAPIKey(ctx, principal.KeyID, now); err != nil {
+			logging.FromContext(ctx).WithError(err).WithField("api_key_id", principal.KeyID).Error("failed to record API key use")
+		}
+	}
+	return &principal, nil
+}
+
+func (s *service) CreateAPIKey(ctx context.Context, userID string, name string, scopes []string, ttlDays int) (APIKey, string, error) {
+	maxTTL := s.apiKeys.MaxTTLDays
+	if maxTTL > 0 && ttlDays > maxTTL {
+		return APIKey{}, "", apperr.Validation(apperr.FieldError{Field: "ttl_days", Message: fmt.Sprintf("must be at most %d", maxTTL)})
+	}
+	if ttlDays == 0 {
+		ttlDays = maxTTL
+	}
+
+	secret, err := genRandomToken(32)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	key := APIKeyPrefix + secret
+
+	apiKey := APIKey{
+		UserID: userID,
+		Name:   name,
+		Prefix: key[:apiKeyPrefixLength],
+		Scopes: scopes,
+	}
+	if ttlDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, ttlDays)
+		apiKey.ExpiresAt = &expiresAt
+	}
+	apiKey, err = s.repo.InsertAPIKey(ctx, apiKey, hashToken(key), s.apiKeys.MaxPerUser)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	return apiKey, key, nil
+}
+
+func (s *service) ListAPIKeys(ctx context.Context, userID string) ([]APIKey, error) {
+	return s.repo.ListAPIKeys(ctx, userID)
+}
+
+func (s *service) RevokeAPIKey(ctx context.Context, userID string, keyID string) error {
+	return s.repo.RevokeAPIKey(ctx, userID, keyID)
+}
+
+func (s *service) RevokeAllAPIKeys(ctx context.Context, userID string) error {
+	return s.repo.RevokeAllAPIKeys(ctx, userID)
+}
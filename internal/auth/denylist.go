@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	rawRedis "github.com/redis/go-redis/v9"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/redis"
+)
+
+const (
+	denylistTokenPrefix   = "auth:denylist:jti:"
+	denylistSessionPrefix = "auth:denylist:sid:"
+	denylistUserPrefix    = "auth:denylist:user:"
+)
+
+// ErrDenylistUnavailable is returned for an access token that could not be
+// checked against the denylist.
+var ErrDenylistUnavailable = apperr.New(http.StatusServiceUnavailable, "denylist_unavailable", "The access token cannot be checked right now; try again later.")
+
+// raiseCutoff sets a user's cutoff unless a later one is already stored, so
+// a revocation delivered late by the event bus cannot undo a newer one.
+var raiseCutoff = rawRedis.NewScript(`
+local current = tonumber(redis.call("GET", KEYS[1]))
+if current == nil or current < tonumber(ARGV[1]) then
+	redis.call("SET", KEYS[1], ARGV[1], "EX", ARGV[2])
+end
+return 0
+`)
+
+// Denylist revokes access tokens before they expire. It holds three kinds of
+// entries, each kept in Redis only as long as the tokens it revokes could
+// still be valid:
+//   - a token's jti, when that one token is revoked (logout)
+//   - a session ID, revoking every token issued to the session
+//   - a per-user cutoff, revoking every token issued before it (password
+//     reset, role change, deletion)
+//
+// A nil *Denylist revokes nothing, for deployments without Redis.
+type Denylist struct {
+	redis *redis.RedisClient
+	// tokenTTL is the lifetime of an access token, and so of a cutoff
+	tokenTTL time.Duration
+}
+
+// NewDenylist returns a denylist for access tokens valid for tokenTTL.
+func NewDenylist(client *redis.RedisClient, tokenTTL time.Duration) *Denylist {
+	return &Denylist{redis: client, tokenTTL: tokenTTL}
+}
+
+// RevokeToken revokes the token with the given claims until it expires.
+// Tokens without a jti or an expiry predate the denylist and are left alone.
+func (d *Denylist) RevokeToken(ctx context.Context, claims *Claims) error {
+	if d == nil || claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl <= 0 {
+		return nil
+	}
+	return d.redis.Set(ctx, denylistTokenPrefix+claims.ID, 1, ttl).Err()
+}
+
+// RevokeSession revokes every token issued to the session. Tokens are issued
+// to a session until its refresh token is revoked, so the entry outlives the
+// last of them.
+func (d *Denylist) RevokeSession(ctx context.Context, sessionID string) error {
+	if d == nil {
+		return nil
+	}
+	return d.redis.Set(ctx, denylistSessionPrefix+sessionID, 1, d.tokenTTL).Err()
+}
+
+// RevokeUser revokes every token of the user issued before at. The cutoff
+// is kept in milliseconds and compared with the iat_ms claim, so a token
+// issued moments before at is revoked, even in the same second, while a
+// login right after it, such as after a password reset, is not.
+func (d *Denylist) RevokeUser(ctx context.Context, userID string, at time.Time) error {
+	if d == nil {
+		return nil
+	}
+	ttl := int64(d.tokenTTL / time.Second)
+	return raiseCutoff.Run(ctx, d.redis, []string{denylistUserPrefix + userID}, at.UnixMilli(), ttl).Err()
+}
+
+// Check returns ErrTokenRevoked if the token with the given claims has been
+// revoked. It costs a single round trip to Redis.
+func (d *Denylist) Check(ctx context.Context, claims *Claims) error {
+	if d == nil {
+		return nil
+	}
+	// An empty jti or sid cannot be on the list; MGET still needs the key
+	values, err := d.redis.MGet(ctx,
+		denylistTokenPrefix+claims.ID,
+		denylistSessionPrefix+claims.SessionID,
+		denylistUserPrefix+claims.UserId,
+	).Result()
+	if err != nil {
+		return err
+	}
+	if claims.ID != "" && values[0] != nil {
+		return ErrTokenRevoked
+	}
+	if claims.SessionID != "" && values[1] != nil {
+		return ErrTokenRevoked
+	}
+	if values[2] == nil {
+		return nil
+	}
+	s, ok := values[2].(string)
+	if !ok {
+		return errors.New("unexpected denylist value")
+	}
+	cutoff, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	if issuedAt, ok := claims.IssuedAtMillis(); !ok || issuedAt < cutoff {
+		return ErrTokenRevoked
+	}
+	return nil
+}
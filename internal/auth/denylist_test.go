@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	rawRedis "github.com/redis/go-redis/v9"
+
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/redis"
+)
+
+func newTestDenylist(t *testing.T) (*Denylist, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	client := rawRedis.NewClient(&rawRedis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewDenylist(&redis.RedisClient{Client: client}, time.Hour), mr
+}
+
+func testClaims(userID string, issuedAt time.Time) *Claims {
+	return &Claims{
+		UserId:     userID,
+		IssuedAtMs: issuedAt.UnixMilli(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(time.Hour)),
+		},
+	}
+}
+
+func TestDenylistRevokeToken(t *testing.T) {
+	denylist, mr := newTestDenylist(t)
+	ctx := context.Background()
+	userID := uuid.NewString()
+
+	revoked, other := testClaims(userID, time.Now()), testClaims(userID, time.Now())
+	if err := denylist.RevokeToken(ctx, revoked); err != nil {
+		t.Fatalf("revoke token: %v", err)
+	}
+	if err := denylist.Check(ctx, revoked); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected the revoked token to be rejected, got %v", err)
+	}
+	if err := denylist.Check(ctx, other); err != nil {
+		t.Fatalf("expected another token of the user to stay valid, got %v", err)
+	}
+
+	// The entry lasts as long as the token
+	if ttl := mr.TTL(denylistTokenPrefix + revoked.ID); ttl <= 0 || ttl > time.Hour {
+		t.Fatalf("expected the entry to expire with the token, got TTL %v", ttl)
+	}
+	mr.FastForward(time.Hour + time.Second)
+	if mr.Exists(denylistTokenPrefix + revoked.ID) {
+		t.Fatalf("expected the entry to expire")
+	}
+}
+
+func TestDenylistRevokeSession(t *testing.T) {
+	denylist, mr := newTestDenylist(t)
+	ctx := context.Background()
+	userID := uuid.NewString()
+
+	first, second, other := testClaims(userID, time.Now()), testClaims(userID, time.Now()), testClaims(userID, time.Now())
+	first.SessionID, second.SessionID, other.SessionID = "s1", "s1", "s2"
+	if err := denylist.RevokeSession(ctx, "s1"); err != nil {
+		t.Fatalf("revoke session: %v", err)
+	}
+	for _, claims := range []*Claims{first, second} {
+		if err := denylist.Check(ctx, claims); !errors.Is(err, ErrTokenRevoked) {
+			t.Fatalf("expected every token of the session to be rejected, got %v", err)
+		}
+	}
+	if err := denylist.Check(ctx, other); err != nil {
+		t.Fatalf("expected other sessions to stay valid, got %v", err)
+	}
+	if ttl := mr.TTL(denylistSessionPrefix + "s1"); ttl != time.Hour {
+		t.Fatalf("expected the entry to last as long as a token, got TTL %v", ttl)
+	}
+}
+
+func TestDenylistRevokeUser(t *testing.T) {
+	denylist, mr := newTestDenylist(t)
+	ctx := context.Background()
+	userID := uuid.NewString()
+
+	changedAt := time.Now().Truncate(time.Second).Add(500 * time.Millisecond)
+	before := testClaims(userID, changedAt.Add(-time.Second))
+	sameSecond := testClaims(userID, changedAt.Add(-time.Millisecond))
+	after := testClaims(userID, changedAt)
+	otherUser := testClaims(uuid.NewString(), changedAt.Add(-time.Second))
+
+	if err := denylist.RevokeUser(ctx, userID, changedAt); err != nil {
+		t.Fatalf("revoke user: %v", err)
+	}
+	if err := denylist.Check(ctx, before); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected a token issued before the change to be rejected, got %v", err)
+	}
+	// Both tokens have the same iat; iat_ms tells them apart
+	if err := denylist.Check(ctx, sameSecond); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected a token issued earlier in the second of the change to be rejected, got %v", err)
+	}
+	if err := denylist.Check(ctx, after); err != nil {
+		t.Fatalf("expected a token issued at the change to stay valid, got %v", err)
+	}
+
+	// Tokens without iat_ms count from the start of their second
+	legacy := testClaims(userID, changedAt.Add(time.Millisecond))
+	legacy.IssuedAtMs = 0
+	if err := denylist.Check(ctx, legacy); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected a token without iat_ms from the second of the change to be rejected, got %v", err)
+	}
+
+	// A signed token carries its registered times in whole seconds and its
+	// issue time to the millisecond in iat_ms
+	signedUserID := uuid.NewString()
+	jwtService := NewJWTService(config.JwtConfig{
+		SecretKey:       "this-is-a-very-secure-secret-key-with-32-plus-characters",
+		Issuer:          "egaldeutsch",
+		ExpirationHours: 1,
+	})
+	token, err := jwtService.CreateAccessTokenFromStrings(signedUserID, "learner")
+	if err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[1])
+	if err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		t.Fatalf("decode claims: %v", err)
+	}
+	for _, claim := range []string{"iat", "exp", "auth_time", "iat_ms"} {
+		if _, err := strconv.ParseInt(string(raw[claim]), 10, 64); err != nil {
+			t.Fatalf("expected %s as an integer, got %s", claim, raw[claim])
+		}
+	}
+	parsed, err := jwtService.ParseToken(token)
+	if err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	if err := denylist.RevokeUser(ctx, signedUserID, time.UnixMilli(parsed.IssuedAtMs+1)); err != nil {
+		t.Fatalf("revoke user: %v", err)
+	}
+	if err := denylist.Check(ctx, parsed); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected a signed token issued a millisecond before the change to be rejected, got %v", err)
+	}
+	if err := denylist.Check(ctx, otherUser); err != nil {
+		t.Fatalf("expected other users to be unaffected, got %v", err)
+	}
+
+	// A late, earlier revocation does not lower the cutoff
+	if err := denylist.RevokeUser(ctx, userID, changedAt.Add(-time.Minute)); err != nil {
+		t.Fatalf("revoke user: %v", err)
+	}
+	if err := denylist.Check(ctx, before); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("expected the later cutoff to be kept, got %v", err)
+	}
+
+	// Once every token issued before it has expired the cutoff is dropped
+	mr.FastForward(time.Hour + time.Second)
+	if mr.Exists(denylistUserPrefix + userID) {
+		t.Fatalf("expected the cutoff to expire")
+	}
+}
+
+func TestNilDenylistRevokesNothing(t *testing.T) {
+	var denylist *Denylist
+	ctx := context.Background()
+	claims := testClaims(uuid.NewString(), time.Now())
+
+	if err := denylist.RevokeToken(ctx, claims); err != nil {
+		t.Fatalf("revoke token: %v", err)
+	}
+	if err := denylist.RevokeUser(ctx, claims.UserId, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("revoke user: %v", err)
+	}
+	if err := denylist.Check(ctx, claims); err != nil {
+		t.Fatalf("expected no token to be revoked, got %v", err)
+	}
+}
@@ -1,5 +1,10 @@
 package auth
 
+import (
+	"context"
+	"time"
+)
+
 // TokenValidator handles JWT token validation and parsing.
 // Small interface following Go philosophy: "interfaces should be small".
 type TokenValidator interface {
@@ -13,28 +18,143 @@ type TokenCreator interface {
 
 // RefreshTokenManager handles refresh token lifecycle operations.
 type RefreshTokenManager interface {
-	// CreateRefreshToken returns the plain (unhashed) refresh token to be given to the client
-	CreateRefreshToken(userID string, ip string, userAgent string) (string, error)
+	// CreateRefreshToken starts a session and returns its ID and the plain (unhashed)
+	// refresh token to be given to the client
+	CreateRefreshToken(ctx context.Context, userID string, ip string, userAgent string) (token string, sessionID string, err error)
 
 	// RefreshTokens rotates the provided refresh token and returns a new access + refresh token.
 	// The service will resolve the user's role from the repository as part of rotation, so
 	// callers only need to present the old refresh token and client metadata.
-	RefreshTokens(oldRefreshToken string, ip string, userAgent string) (newAccess string, newRefresh string, err error)
+	RefreshTokens(ctx context.Context, oldRefreshToken string, ip string, userAgent string) (newAccess string, newRefresh string, err error)
 
 	// RevokeRefreshToken revokes a single refresh token (by presenting the plain token)
-	RevokeRefreshToken(refreshToken string) error
+	RevokeRefreshToken(ctx context.Context, refreshToken string) error
 
 	// RevokeAllRefreshTokens revokes all refresh tokens for a user (logout everywhere)
-	RevokeAllRefreshTokens(userID string) error
+	RevokeAllRefreshTokens(ctx context.Context, userID string) error
+}
+
+// SessionManager lists and ends a user's sessions. A session is a login: the
+// chain of refresh tokens rotated from it and the access tokens issued to it.
+type SessionManager interface {
+	// ListSessions returns the active sessions of a user, newest first, marking
+	// currentSessionID as the current one
+	ListSessions(ctx context.Context, userID string, currentSessionID string) ([]Session, error)
+
+	// RevokeSession ends one session of a user; ErrSessionNotFound if it is
+	// not an active session of theirs
+	RevokeSession(ctx context.Context, userID string, sessionID string) error
+
+	// RevokeOtherSessions ends every session of a user except exceptSessionID
+	// (all of them if empty) and returns how many were ended
+	RevokeOtherSessions(ctx context.Context, userID string, exceptSessionID string) (int, error)
+}
+
+// MFAManager handles two-factor authentication with TOTP and recovery codes.
+// A login of a user with a second factor, or whose role requires one, is
+// only completed once a challenge is passed.
+type MFAManager interface {
+	// GetMFAStatus describes the second factor of a user with the given role
+	GetMFAStatus(ctx context.Context, userID string, role string) (MFAStatus, error)
+
+	// BeginTOTPEnrollment stores a new secret awaiting confirmation, replacing any
+	// earlier pending one; ErrMFAAlreadyEnabled if a second factor is enabled
+	BeginTOTPEnrollment(ctx context.Context, userID string, account string) (TOTPSetup, error)
+
+	// ConfirmTOTPEnrollment enables the pending secret once a code from it is
+	// presented and returns the plain recovery codes, which are not shown again
+	ConfirmTOTPEnrollment(ctx context.Context, userID string, code string) (recoveryCodes []string, err error)
+
+	// RegenerateRecoveryCodes replaces the recovery codes of a user, who proves
+	// the second factor with a TOTP or recovery code
+	RegenerateRecoveryCodes(ctx context.Context, userID string, code string) (recoveryCodes []string, err error)
+
+	// DisableMFA removes the second factor of a user, who proves it with a TOTP
+	// or recovery code; ErrMFARequired if the user's role requires one
+	DisableMFA(ctx context.Context, userID string, role string, code string) error
+
+	// ResetMFA removes the second factor of a user who lost it, on behalf of an admin
+	ResetMFA(ctx context.Context, userID string) error
+
+	// StartMFAChallenge returns the challenge a login must pass after the
+	// password, or nil if the password suffices
+	StartMFAChallenge(ctx context.Context, userID string, role string) (*MFAChallenge, error)
+
+	// GetMFAChallenge returns a pending challenge without counting an attempt;
+	// ErrInvalidMFAToken if it is expired, used or out of attempts
+	GetMFAChallenge(ctx context.Context, token string) (MFAChallenge, error)
+
+	// CompleteMFAChallenge counts an attempt at a challenge and passes it if code
+	// is a TOTP or recovery code of the user. An enrollment challenge confirms
+	// the enrollment instead and returns the new recovery codes.
+	CompleteMFAChallenge(ctx context.Context, token string, code string) (MFAChallenge, []string, error)
+
+	// MFARequiredRoles returns the roles that must use two-factor authentication
+	MFARequiredRoles(ctx context.Context) ([]string, error)
+
+	// SetMFARequiredRoles replaces the roles that must use two-factor authentication
+	SetMFARequiredRoles(ctx context.Context, roles []string) error
 }
 
 // PasswordResetManager handles password reset token operations.
 type PasswordResetManager interface {
+	// RequestPasswordReset publishes password.reset_link_requested for an
+	// email; the link is created and sent when the event is delivered
+	RequestPasswordReset(ctx context.Context, email string) error
+
 	// CreatePasswordResetForUser creates a single-use token for the given user ID and returns the plain token (to be emailed)
-	CreatePasswordResetForUser(userID string) (string, error)
+	CreatePasswordResetForUser(ctx context.Context, userID string) (string, error)
+
+	// GetPasswordResetUser returns the user of a valid password reset token
+	// without consuming it, so the new password can be checked first
+	GetPasswordResetUser(ctx context.Context, token string) (userID string, err error)
 
 	// VerifyPasswordResetToken verifies and consumes a password reset token, returning the user ID
-	VerifyPasswordResetToken(token string) (userID string, err error)
+	VerifyPasswordResetToken(ctx context.Context, token string) (userID string, err error)
+}
+
+// EmailVerificationManager handles email verification token operations.
+type EmailVerificationManager interface {
+	// CreateEmailVerification creates a single-use token, valid for ttl, that
+	// verifies email for the given user ID and returns the plain token (to be emailed)
+	CreateEmailVerification(ctx context.Context, userID string, email string, ttl time.Duration) (string, error)
+
+	// VerifyEmailToken verifies and consumes an email verification token,
+	// returning the user ID and the email address it verifies
+	VerifyEmailToken(ctx context.Context, token string) (userID string, email string, err error)
+}
+
+// LoginRecorder records authentication attempts for the audit log.
+type LoginRecorder interface {
+	// RecordFailedLogin publishes auth.login_failed for the presented email
+	// and the failures counted for it so far
+	RecordFailedLogin(ctx context.Context, email string, failures int) error
+
+	// RecordAccountLocked publishes auth.account_locked for the presented email
+	RecordAccountLocked(ctx context.Context, email string, until time.Time) error
+
+	// RecordAccountUnlocked publishes auth.account_unlocked for a user an admin unlocked
+	RecordAccountUnlocked(ctx context.Context, userID string, email string) error
+}
+
+// APIKeyManager handles the API keys users create for machine clients.
+type APIKeyManager interface {
+	// CreateAPIKey creates a key of a user, valid for ttlDays (0 for as long
+	// as allowed), and returns it with the plain key, which is not shown
+	// again. The scopes must have been checked against the user's role.
+	CreateAPIKey(ctx context.Context, userID string, name string, scopes []string, ttlDays int) (key APIKey, plain string, err error)
+
+	// ListAPIKeys returns the unrevoked keys of a user, or of every user if
+	// userID is empty, newest first
+	ListAPIKeys(ctx context.Context, userID string) ([]APIKey, error)
+
+	// RevokeAPIKey revokes an unrevoked key of a user, or of any user if
+	// userID is empty; ErrAPIKeyNotFound if there is none
+	RevokeAPIKey(ctx context.Context, userID string, keyID string) error
+
+	// RevokeAllAPIKeys revokes every key of a user, when their account is
+	// reset or deleted
+	RevokeAllAPIKeys(ctx context.Context, userID string) error
 }
 
 // AuthService combines all authentication-related operations.
@@ -43,37 +163,207 @@ type AuthService interface {
 	TokenValidator
 	TokenCreator
 	RefreshTokenManager
+	SessionManager
+	MFAManager
 	PasswordResetManager
+	EmailVerificationManager
+	LoginRecorder
+	APIKeyManager
 }
 
 // RefreshTokenRepo handles refresh token persistence operations.
 type RefreshTokenRepo interface {
-	// InsertRefreshToken creates a new refresh token record
-	InsertRefreshToken(tokenHash string, userID string, expiresAt int64, ip *string, userAgent *string) error
+	// InsertRefreshToken creates the refresh token record of a new login and
+	// publishes auth.login with it. It returns the ID of the new session.
+	InsertRefreshToken(ctx context.Context, tokenHash string, userID string, expiresAt int64, ip *string, userAgent *string) (sessionID string, err error)
 
 	// RotateRefreshToken atomically rotates the provided oldHash into a newHash.
-	// Returns: userID, role (from users table), reused (true if token was already revoked), error
-	RotateRefreshToken(oldHash, newHash string, newExpiresAt int64, ip *string, userAgent *string) (userID string, role string, reused bool, err error)
+	// Returns: the session (role from users table), reused (true if token was already rotated), error
+	// A token revoked otherwise, by logout or by ending its session, is ErrInvalidRefreshToken.
+	// On reuse every token of the user is revoked and auth.refresh_reuse_detected is published.
+	RotateRefreshToken(ctx context.Context, oldHash, newHash string, newExpiresAt int64, ip *string, userAgent *string) (session RefreshSession, reused bool, err error)
 
 	// RevokeRefreshTokenByHash marks a refresh token as revoked
-	RevokeRefreshTokenByHash(hash string, replacedBy *string) error
+	RevokeRefreshTokenByHash(ctx context.Context, hash string, replacedBy *string) error
 
 	// RevokeAllForUser revokes all refresh tokens for a specific user
-	RevokeAllForUser(userID string) error
+	RevokeAllForUser(ctx context.Context, userID string) error
+
+	// PurgeExpiredRefreshTokens deletes refresh tokens that expired before the given time
+	PurgeExpiredRefreshTokens(ctx context.Context, before time.Time) (int64, error)
+}
+
+// SessionRepo handles the sessions formed by chains of refresh tokens.
+type SessionRepo interface {
+	// ListSessions returns the sessions of a user with an unrevoked, unexpired refresh token
+	ListSessions(ctx context.Context, userID string) ([]Session, error)
+
+	// RevokeSession revokes the refresh token of an active session of a user and
+	// publishes auth.sessions_revoked; ErrSessionNotFound if there is none
+	RevokeSession(ctx context.Context, userID string, sessionID string) error
+
+	// RevokeOtherSessions revokes the refresh tokens of every active session of a user
+	// except exceptSessionID and publishes auth.sessions_revoked. It returns the IDs of
+	// the revoked sessions.
+	RevokeOtherSessions(ctx context.Context, userID string, exceptSessionID string) ([]string, error)
+}
+
+// RefreshSession describes the login a refresh token belongs to.
+type RefreshSession struct {
+	ID     string
+	UserID string
+	Role   string
+	// AuthTime is when the user logged in; rotation carries it over
+	AuthTime time.Time
+}
+
+// MFARepo handles persistence of second factors, login challenges and the
+// roles that require a second factor.
+type MFARepo interface {
+	// GetMFASecret returns the TOTP secret of a user, enabled or pending; nil if there is none
+	GetMFASecret(ctx context.Context, userID string) (*MFASecret, error)
+
+	// SavePendingMFASecret stores a secret awaiting confirmation, replacing a
+	// pending one; ErrMFAAlreadyEnabled if the user has an enabled one
+	SavePendingMFASecret(ctx context.Context, userID string, secret string) error
+
+	// EnableMFA enables the pending secret with step as its last used time step,
+	// stores the recovery code hashes and publishes auth.mfa_enabled
+	EnableMFA(ctx context.Context, userID string, step int64, recoveryCodeHashes []string) error
+
+	// UseTOTPStep records step as the last used time step; ErrInvalidMFACode
+	// unless it is later than the one recorded
+	UseTOTPStep(ctx context.Context, userID string, step int64) error
+
+	// UseRecoveryCode marks an unused recovery code as used and publishes
+	// auth.mfa_recovery_code_used; ErrInvalidMFACode if there is no such code
+	UseRecoveryCode(ctx context.Context, userID string, codeHash string) error
+
+	// ReplaceRecoveryCodes replaces every recovery code of a user and publishes
+	// auth.mfa_recovery_codes_regenerated
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error
+
+	// CountRecoveryCodes returns how many unused recovery codes a user has
+	CountRecoveryCodes(ctx context.Context, userID string) (int, error)
+
+	// DeleteMFA removes the secret and recovery codes of a user and publishes
+	// auth.mfa_disabled, or auth.mfa_reset if an admin reset it; ErrMFANotEnabled
+	// if the user has no enabled secret
+	DeleteMFA(ctx context.Context, userID string, reset bool) error
+
+	// InsertMFAChallenge creates a login challenge
+	InsertMFAChallenge(ctx context.Context, tokenHash string, userID string, enroll bool, expiresAt time.Time) error
+
+	// GetMFAChallenge returns a pending challenge; ErrInvalidMFAToken if it is
+	// expired, used or has had maxAttempts attempts
+	GetMFAChallenge(ctx context.Context, tokenHash string, maxAttempts int) (MFAChallenge, error)
+
+	// ClaimMFAAttempt atomically counts an attempt at a pending challenge and
+	// returns it with the user's role from the users table; ErrInvalidMFAToken
+	// if it is expired, used or out of attempts
+	ClaimMFAAttempt(ctx context.Context, tokenHash string, maxAttempts int) (MFAChallenge, error)
+
+	// ConsumeMFAChallenge marks a challenge as passed; ErrInvalidMFAToken if it
+	// already was
+	ConsumeMFAChallenge(ctx context.Context, tokenHash string) error
+
+	// PurgeExpiredMFAChallenges deletes challenges that expired before the given time
+	PurgeExpiredMFAChallenges(ctx context.Context, before time.Time) (int64, error)
+
+	// GetMFARequiredRoles returns the roles that must use two-factor authentication
+	GetMFARequiredRoles(ctx context.Context) ([]string, error)
+
+	// SetMFARequiredRoles replaces the roles that must use two-factor
+	// authentication and publishes auth.mfa_policy_changed
+	SetMFARequiredRoles(ctx context.Context, roles []string) error
+}
+
+// EmailVerificationRepo handles email verification token persistence operations.
+type EmailVerificationRepo interface {
+	// InsertEmailVerification stores a new email verification token
+	InsertEmailVerification(ctx context.Context, tokenHash string, userID string, email string, expiresAt time.Time) error
+
+	// VerifyAndMarkEmailVerification atomically verifies and marks a token as
+	// used, returning the user ID and email address it was issued for
+	VerifyAndMarkEmailVerification(ctx context.Context, tokenHash string) (userID string, email string, err error)
+
+	// PurgeExpiredEmailVerifications deletes tokens that expired before the given time
+	PurgeExpiredEmailVerifications(ctx context.Context, before time.Time) (int64, error)
 }
 
 // PasswordResetRepo handles password reset token persistence operations.
 type PasswordResetRepo interface {
-	// InsertPasswordReset creates a new password reset token record
-	InsertPasswordReset(tokenHash string, userID string, expiresAt int64) error
+	// RecordPasswordResetRequest publishes password.reset_link_requested
+	RecordPasswordResetRequest(ctx context.Context, email string) error
+
+	// InsertPasswordReset creates a new password reset token record and
+	// publishes password.reset_requested with it
+	InsertPasswordReset(ctx context.Context, tokenHash string, userID string, expiresAt int64) error
+
+	// GetPasswordReset returns the user of an unused, unexpired password
+	// reset token; ErrInvalidResetToken if there is none
+	GetPasswordReset(ctx context.Context, tokenHash string) (userID string, err error)
 
 	// VerifyAndMarkPasswordReset atomically verifies and marks a password reset token as used
-	VerifyAndMarkPasswordReset(tokenHash string) (userID string, err error)
+	VerifyAndMarkPasswordReset(ctx context.Context, tokenHash string) (userID string, err error)
+
+	// PurgeExpiredPasswordResets deletes password reset tokens that expired before the given time
+	PurgeExpiredPasswordResets(ctx context.Context, before time.Time) (int64, error)
+}
+
+// LoginAttemptRepo handles persistence of login attempts.
+type LoginAttemptRepo interface {
+	// RecordFailedLogin publishes auth.login_failed for the presented email
+	// and the failures counted for it so far
+	RecordFailedLogin(ctx context.Context, email string, failures int) error
+
+	// RecordAccountLocked publishes auth.account_locked for the presented email
+	RecordAccountLocked(ctx context.Context, email string, until time.Time) error
+
+	// RecordAccountUnlocked publishes auth.account_unlocked for a user
+	RecordAccountUnlocked(ctx context.Context, userID string, email string) error
+}
+
+// APIKeyRepo handles API key persistence operations. Only the hash of a key
+// is stored.
+type APIKeyRepo interface {
+	// InsertAPIKey stores a new key and publishes auth.api_key_created;
+	// ErrTooManyAPIKeys if the user already has maxPerUser unrevoked keys.
+	// It returns the key with its ID and creation time.
+	InsertAPIKey(ctx context.Context, key APIKey, keyHash string, maxPerUser int) (APIKey, error)
+
+	// GetAPIKeyByHash returns the unrevoked, unexpired key with the hash and
+	// the current role of its user; ErrInvalidAPIKey if there is none or the
+	// user has been deleted
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (APIKeyPrincipal, error)
+
+	// TouchAPIKey records that a key was used at the given time
+	TouchAPIKey(ctx context.Context, keyID string, at time.Time) error
+
+	// ListAPIKeys returns the unrevoked keys of a user, or of every user if
+	// userID is empty, newest first
+	ListAPIKeys(ctx context.Context, userID string) ([]APIKey, error)
+
+	// RevokeAPIKey revokes an unrevoked key of a user, or of any user if
+	// userID is empty, and publishes auth.api_key_revoked; ErrAPIKeyNotFound
+	// if there is none
+	RevokeAPIKey(ctx context.Context, userID string, keyID string) error
+
+	// RevokeAllAPIKeys revokes every key of a user
+	RevokeAllAPIKeys(ctx context.Context, userID string) error
+
+	// PurgeExpiredAPIKeys deletes keys that expired or were revoked before the given time
+	PurgeExpiredAPIKeys(ctx context.Context, before time.Time) (int64, error)
 }
 
 // AuthRepo combines all authentication repository operations.
 // Composed interface following Go's interface composition principle.
 type AuthRepo interface {
 	RefreshTokenRepo
+	SessionRepo
+	MFARepo
 	PasswordResetRepo
+	EmailVerificationRepo
+	LoginAttemptRepo
+	APIKeyRepo
 }
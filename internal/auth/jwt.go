@@ -67,35 +67,95 @@ func (r Role) String() string {
 	return r.value
 }
 
-// Claims represents JWT token claims with strong typing.
+// Claims represents JWT token claims with strong typing. The registered ID
+// (jti) identifies the token on the denylist.
 type Claims struct {
 	UserId string `json:"user_id"`
 	Role   string `json:"role"`
+	// SessionID is the session (login) the token was issued to; empty for
+	// tokens issued without one, such as on sign-up
+	SessionID string `json:"sid,omitempty"`
+	// AuthTime is when the user last entered their credentials; refreshed
+	// tokens keep the time of the original login.
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	// IssuedAtMs is the issue time in milliseconds since the epoch. The
+	// registered iat is kept in whole seconds, as most JWT libraries expect;
+	// revocation cutoffs (see Denylist.RevokeUser) need the finer time.
+	IssuedAtMs int64 `json:"iat_ms,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// IssuedAtMillis returns the issue time of the token in milliseconds since
+// the epoch, and false if it has none. Tokens without iat_ms count from the
+// start of the second in iat.
+func (c *Claims) IssuedAtMillis() (int64, bool) {
+	if c.IssuedAtMs > 0 {
+		return c.IssuedAtMs, true
+	}
+	if c.IssuedAt == nil {
+		return 0, false
+	}
+	return c.IssuedAt.Unix() * 1000, true
+}
+
 // JWTService encapsulates JWT operations with pre-validated configuration.
 // Following Go philosophy: encapsulate configuration, provide simple methods.
 type JWTService struct {
 	config config.JwtConfig
+	keys   *KeySet
+	// keysErr is returned by every operation when the key set is unusable
+	keysErr error
 }
 
 // NewJWTService creates a new JWT service with validated configuration.
-// The config is assumed to be pre-validated during application startup.
+// The config is assumed to be pre-validated during application startup; the
+// server also loads the key set then, so a broken key file stops startup
+// instead of failing here.
 func NewJWTService(jwtConfig config.JwtConfig) *JWTService {
+	keys, err := LoadKeySet(jwtConfig)
 	return &JWTService{
-		config: jwtConfig,
+		config:  jwtConfig,
+		keys:    keys,
+		keysErr: err,
 	}
 }
 
 // CreateAccessToken creates a JWT access token with strongly typed parameters.
 // Following Go philosophy: make invalid states unrepresentable, explicit types.
 func (j *JWTService) CreateAccessToken(userID UserID, role Role) (string, error) {
-	claims := j.createClaims(userID, role)
+	if j.keysErr != nil {
+		return "", fmt.Errorf("failed to load JWT keys: %w", j.keysErr)
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return j.sign(j.createClaims(userID, role, time.Now()))
+}
 
-	signedToken, err := token.SignedString([]byte(j.config.SecretKey))
+// CreateSessionAccessToken creates an access token for the session sessionID,
+// which the user logged in to at authTime: on login, and when the session's
+// refresh token is rotated.
+func (j *JWTService) CreateSessionAccessToken(userIDStr, roleStr, sessionID string, authTime time.Time) (string, error) {
+	if j.keysErr != nil {
+		return "", fmt.Errorf("failed to load JWT keys: %w", j.keysErr)
+	}
+
+	userID, err := NewUserID(userIDStr)
+	if err != nil {
+		return "", fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	role, err := NewRole(roleStr)
+	if err != nil {
+		return "", fmt.Errorf("invalid role: %w", err)
+	}
+
+	claims := j.createClaims(userID, role, authTime)
+	claims.SessionID = sessionID
+	return j.sign(claims)
+}
+
+// sign signs claims with the key set.
+func (j *JWTService) sign(claims Claims) (string, error) {
+	signedToken, err := j.keys.sign(claims)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign JWT token: %w", err)
 	}
@@ -125,14 +185,12 @@ func (j *JWTService) ParseToken(tokenString string) (*Claims, error) {
 	if tokenString == "" {
 		return nil, fmt.Errorf("token string cannot be empty")
 	}
+	if j.keysErr != nil {
+		return nil, fmt.Errorf("failed to load JWT keys: %w", j.keysErr)
+	}
 
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(t *jwt.Token) (interface{}, error) {
-		// Validate the signing method
-		if t.Method.Alg() != jwt.SigningMethodHS256.Alg() {
-			return nil, fmt.Errorf("unexpected signing method: %s", t.Method.Alg())
-		}
-		return []byte(j.config.SecretKey), nil
-	})
+	// The key set validates the signing method against the token's key
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, j.keys.verificationKey)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -152,14 +210,17 @@ func (j *JWTService) ParseToken(tokenString string) (*Claims, error) {
 
 // createClaims creates JWT claims from validated parameters.
 // Separated from CreateAccessToken following single responsibility principle.
-func (j *JWTService) createClaims(userID UserID, role Role) Claims {
+func (j *JWTService) createClaims(userID UserID, role Role, authTime time.Time) Claims {
 	now := time.Now()
 	expiresAt := now.Add(time.Duration(j.config.ExpirationHours) * time.Hour)
 
 	return Claims{
-		UserId: userID.String(),
-		Role:   role.String(),
+		UserId:     userID.String(),
+		Role:       role.String(),
+		AuthTime:   jwt.NewNumericDate(authTime),
+		IssuedAtMs: now.UnixMilli(),
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			Subject:   userID.String(),
 			Issuer:    j.config.Issuer,
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -0,0 +1,308 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+
+	"egaldeutsch-be/internal/config"
+)
+
+// minRSABits is the smallest RSA modulus accepted for RS256.
+const minRSABits = 2048
+
+// publicKey is a key that verifies tokens carrying its kid.
+type publicKey struct {
+	id     string
+	method jwt.SigningMethod
+	key    crypto.PublicKey
+	// signing is true for the key that signs new tokens
+	signing bool
+}
+
+// KeySet holds the keys of the configured algorithm: the key that signs new
+// access tokens and the keys that verify them. With HS256 the secret does
+// both. With RS256 or EdDSA tokens name their key in the kid header, so keys
+// can be rotated while tokens signed by retired keys stay valid.
+type KeySet struct {
+	method     jwt.SigningMethod
+	signingID  string // empty for HS256
+	signingKey any    // []byte for HS256, crypto.Signer otherwise
+	// secret verifies HS256 tokens; with an asymmetric algorithm it is set
+	// only to accept tokens issued before the switch, until secretUntil if
+	// that is not zero
+	secret      []byte
+	secretUntil time.Time
+	public      map[string]publicKey
+	// legacyLogged is when accepting a legacy HS256 token was last logged,
+	// in Unix seconds
+	legacyLogged atomic.Int64
+}
+
+// legacyLogInterval spaces out the warnings about accepted legacy tokens.
+const legacyLogInterval = time.Minute
+
+// keySets caches the key set per configuration, so that callers building a
+// JWTService per request do not read key files each time.
+var keySets sync.Map // config.JwtConfig -> *KeySet
+
+// LoadKeySet returns the key set described by cfg, reading and validating
+// the key files on first use. Later calls with the same configuration return
+// the same key set, so changed key files take effect on restart.
+func LoadKeySet(cfg config.JwtConfig) (*KeySet, error) {
+	if ks, ok := keySets.Load(cfg); ok {
+		return ks.(*KeySet), nil
+	}
+	ks, err := loadKeySet(cfg)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := keySets.LoadOrStore(cfg, ks)
+	return actual.(*KeySet), nil
+}
+
+func loadKeySet(cfg config.JwtConfig) (*KeySet, error) {
+	ks := &KeySet{public: map[string]publicKey{}}
+	if cfg.SecretKey != "" {
+		ks.secret = []byte(cfg.SecretKey)
+	}
+	if cfg.LegacyHS256Until != "" {
+		until, err := time.Parse(time.RFC3339, cfg.LegacyHS256Until)
+		if err != nil {
+			return nil, fmt.Errorf("legacy HS256 until: %w", err)
+		}
+		ks.secretUntil = until
+	}
+
+	switch cfg.Algorithm {
+	case "", config.JwtHS256:
+		if ks.secret == nil {
+			return nil, fmt.Errorf("HS256 requires a secret key")
+		}
+		ks.method = jwt.SigningMethodHS256
+		ks.signingKey = ks.secret
+		return ks, nil
+	case config.JwtRS256:
+		ks.method = jwt.SigningMethodRS256
+	case config.JwtEdDSA:
+		ks.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", cfg.Algorithm)
+	}
+
+	private := map[string]crypto.Signer{}
+	if cfg.KeysDir != "" {
+		if err := ks.loadDir(cfg.KeysDir, private); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.SigningKey != "" {
+		if err := ks.add(cfg.SigningKeyID, []byte(cfg.SigningKey), private); err != nil {
+			return nil, fmt.Errorf("signing key: %w", err)
+		}
+	}
+
+	signer, ok := private[cfg.SigningKeyID]
+	if !ok {
+		return nil, fmt.Errorf("no private key with ID %q to sign tokens", cfg.SigningKeyID)
+	}
+	key := ks.public[cfg.SigningKeyID]
+	if key.method != ks.method {
+		return nil, fmt.Errorf("signing key %q is for %s, but the algorithm is %s", cfg.SigningKeyID, key.method.Alg(), ks.method.Alg())
+	}
+	key.signing = true
+	ks.public[cfg.SigningKeyID] = key
+	ks.signingID = cfg.SigningKeyID
+	ks.signingKey = signer
+	return ks, nil
+}
+
+// loadDir adds every <kid>.pem file in dir.
+func (ks *KeySet) loadDir(dir string, private map[string]crypto.Signer) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("read JWT key: %w", err)
+		}
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+		if err := ks.add(kid, data, private); err != nil {
+			return fmt.Errorf("JWT key %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// add parses a PEM private or public key and adds it under kid.
+func (ks *KeySet) add(kid string, data []byte, private map[string]crypto.Signer) error {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return fmt.Errorf("no PEM data found")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return err
+	}
+
+	if signer, ok := parsed.(crypto.Signer); ok {
+		private[kid] = signer
+		parsed = signer.Public()
+	}
+
+	var method jwt.SigningMethod
+	switch pub := parsed.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSABits {
+			return fmt.Errorf("RSA key has %d bits, at least %d are required", pub.N.BitLen(), minRSABits)
+		}
+		method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return fmt.Errorf("unsupported key type %T", parsed)
+	}
+	ks.public[kid] = publicKey{id: kid, method: method, key: parsed}
+	return nil
+}
+
+// sign signs claims with the signing key, naming it in the kid header.
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.method, claims)
+	if ks.signingID != "" {
+		token.Header["kid"] = ks.signingID
+	}
+	return token.SignedString(ks.signingKey)
+}
+
+// verificationKey is the jwt.Keyfunc of the key set: it returns the key
+// named by the token's kid, provided the token's algorithm matches it.
+func (ks *KeySet) verificationKey(t *jwt.Token) (any, error) {
+	alg := t.Method.Alg()
+	if alg == jwt.SigningMethodHS256.Alg() {
+		if ks.secret == nil {
+			return nil, fmt.Errorf("unexpected signing method: %s", alg)
+		}
+		if ks.method != jwt.SigningMethodHS256 {
+			return ks.legacySecret()
+		}
+		return ks.secret, nil
+	}
+
+	kid, _ := t.Header["kid"].(string)
+	key, ok := ks.public[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+	if key.method.Alg() != alg {
+		return nil, fmt.Errorf("unexpected signing method %s for key %q", alg, kid)
+	}
+	return key.key, nil
+}
+
+// legacySecret returns the secret for an HS256 token issued before
+// switching to an asymmetric algorithm, unless it is retired. Accepted
+// tokens are logged, so operators can tell when none are left.
+func (ks *KeySet) legacySecret() (any, error) {
+	now := time.Now()
+	if !ks.secretUntil.IsZero() && !now.Before(ks.secretUntil) {
+		return nil, fmt.Errorf("HS256 tokens are no longer accepted since %s", ks.secretUntil.Format(time.RFC3339))
+	}
+	last := ks.legacyLogged.Load()
+	if now.Unix()-last >= int64(legacyLogInterval/time.Second) && ks.legacyLogged.CompareAndSwap(last, now.Unix()) {
+		logrus.WithField("legacy_hs256_until", ks.secretUntil).Warn("Accepted an access token signed with the legacy HS256 secret")
+	}
+	return ks.secret, nil
+}
+
+// KeyInfo describes a key of the set.
+type KeyInfo struct {
+	ID        string
+	Algorithm string
+	// Signing is true for the key that signs new tokens; the others only
+	// verify.
+	Signing bool
+}
+
+// Keys lists the asymmetric keys of the set by ID.
+func (ks *KeySet) Keys() []KeyInfo {
+	keys := make([]KeyInfo, 0, len(ks.public))
+	for _, k := range ks.public {
+		keys = append(keys, KeyInfo{ID: k.id, Algorithm: k.method.Alg(), Signing: k.signing})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 keys
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys that verify access tokens, for services that
+// validate tokens themselves. An HS256 secret is never published, so the set
+// is empty with HS256.
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, info := range ks.Keys() {
+		k := ks.public[info.ID]
+		jwk := JWK{Kid: k.id, Use: "sig", Alg: k.method.Alg()}
+		switch pub := k.key.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"egaldeutsch-be/internal/config"
+)
+
+// pemKey encodes the private key, or only its public half.
+func pemKey(t *testing.T, key crypto.Signer, publicOnly bool) []byte {
+	t.Helper()
+	var block *pem.Block
+	if publicOnly {
+		der, err := x509.MarshalPKIXPublicKey(key.Public())
+		if err != nil {
+			t.Fatalf("marshal public key: %v", err)
+		}
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	} else {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatalf("marshal private key: %v", err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+	return pem.EncodeToMemory(block)
+}
+
+func writeKey(t *testing.T, dir, kid string, key crypto.Signer, publicOnly bool) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), pemKey(t, key, publicOnly), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+}
+
+func newEd25519(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func newRSA(t *testing.T, bits int) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func asymmetricConfig(alg, dir, kid string) config.JwtConfig {
+	return config.JwtConfig{
+		Algorithm:                  alg,
+		KeysDir:                    dir,
+		SigningKeyID:               kid,
+		Issuer:                     "egaldeutsch",
+		ExpirationHours:            1,
+		RefreshTokenExpirationDays: 7,
+	}
+}
+
+func TestKeyRotationKeepsOldTokensValid(t *testing.T) {
+	oldKey, newKey := newEd25519(t), newRSA(t, 2048)
+	userID := uuid.NewString()
+
+	before := t.TempDir()
+	writeKey(t, before, "2026-01", oldKey, false)
+	oldToken, err := NewJWTService(asymmetricConfig(config.JwtEdDSA, before, "2026-01")).CreateAccessTokenFromStrings(userID, "learner")
+	if err != nil {
+		t.Fatalf("sign with the old key: %v", err)
+	}
+
+	// Rotate: the new key signs, the old one is kept as a public key
+	after := t.TempDir()
+	writeKey(t, after, "2026-01", oldKey, true)
+	writeKey(t, after, "2026-07", newKey, false)
+	rotated := NewJWTService(asymmetricConfig(config.JwtRS256, after, "2026-07"))
+
+	claims, err := rotated.ParseToken(oldToken)
+	if err != nil || claims.UserId != userID {
+		t.Fatalf("expected the old token to stay valid, got %+v (%v)", claims, err)
+	}
+	newToken, err := rotated.CreateAccessTokenFromStrings(userID, "learner")
+	if err != nil {
+		t.Fatalf("sign with the new key: %v", err)
+	}
+	if _, err := rotated.ParseToken(newToken); err != nil {
+		t.Fatalf("parse new token: %v", err)
+	}
+
+	keys, err := LoadKeySet(asymmetricConfig(config.JwtRS256, after, "2026-07"))
+	if err != nil {
+		t.Fatalf("load key set: %v", err)
+	}
+	jwks := keys.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("expected 2 published keys, got %+v", jwks)
+	}
+	if k := jwks.Keys[0]; k.Kid != "2026-01" || k.Kty != "OKP" || k.Crv != "Ed25519" || k.Alg != "EdDSA" || k.X == "" {
+		t.Fatalf("unexpected Ed25519 JWK %+v", k)
+	}
+	if k := jwks.Keys[1]; k.Kid != "2026-07" || k.Kty != "RSA" || k.Alg != "RS256" || k.N == "" || k.E != "AQAB" {
+		t.Fatalf("unexpected RSA JWK %+v", k)
+	}
+
+	// Once the old key is removed its tokens are rejected
+	removed := t.TempDir()
+	writeKey(t, removed, "2026-07", newKey, false)
+	if _, err := NewJWTService(asymmetricConfig(config.JwtRS256, removed, "2026-07")).ParseToken(oldToken); err == nil || !strings.Contains(err.Error(), "unknown key ID") {
+		t.Fatalf("expected unknown key ID, got %v", err)
+	}
+}
+
+func TestHS256TokensNeedTheSecret(t *testing.T) {
+	secret := "this-is-a-very-secure-secret-key-with-32-plus-characters"
+	hs := config.JwtConfig{SecretKey: secret, Issuer: "egaldeutsch", ExpirationHours: 1, RefreshTokenExpirationDays: 7}
+	token, err := CreateAccessTokenFromStrings(uuid.NewString(), "learner", hs)
+	if err != nil {
+		t.Fatalf("CreateAccessToken failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	writeKey(t, dir, "k1", newEd25519(t), false)
+	cfg := asymmetricConfig(config.JwtEdDSA, dir, "k1")
+	if _, err := ParseToken(token, cfg); err == nil {
+		t.Fatalf("expected an HS256 token to be rejected without a secret")
+	}
+
+	// Keeping the secret accepts tokens issued before switching algorithms
+	cfg.SecretKey = secret
+	if _, err := ParseToken(token, cfg); err != nil {
+		t.Fatalf("expected an HS256 token to be accepted with the secret: %v", err)
+	}
+
+	// until the secret is retired
+	cfg.LegacyHS256Until = time.Now().Add(time.Hour).Format(time.RFC3339)
+	if _, err := ParseToken(token, cfg); err != nil {
+		t.Fatalf("expected an HS256 token to be accepted before its retirement: %v", err)
+	}
+	cfg.LegacyHS256Until = time.Now().Add(-time.Second).Format(time.RFC3339)
+	if _, err := ParseToken(token, cfg); err == nil || !strings.Contains(err.Error(), "no longer accepted") {
+		t.Fatalf("expected an HS256 token to be rejected after its retirement, got %v", err)
+	}
+
+	// With HS256 itself the retirement does not apply
+	hs.LegacyHS256Until = cfg.LegacyHS256Until
+	if _, err := ParseToken(token, hs); err != nil {
+		t.Fatalf("expected an HS256 token to be accepted with HS256: %v", err)
+	}
+
+	keys, err := LoadKeySet(hs)
+	if err != nil {
+		t.Fatalf("load key set: %v", err)
+	}
+	if jwks := keys.JWKS(); len(jwks.Keys) != 0 {
+		t.Fatalf("expected the HS256 secret not to be published, got %+v", jwks)
+	}
+}
+
+func TestSigningKeyFromConfig(t *testing.T) {
+	cfg := asymmetricConfig(config.JwtEdDSA, "", "inline")
+	cfg.SigningKey = string(pemKey(t, newEd25519(t), false))
+
+	svc := NewJWTService(cfg)
+	token, err := svc.CreateAccessTokenFromStrings(uuid.NewString(), "learner")
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if _, err := svc.ParseToken(token); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+}
+
+func TestLoadKeySetRejectsUnusableKeys(t *testing.T) {
+	key := newEd25519(t)
+
+	publicOnly := t.TempDir()
+	writeKey(t, publicOnly, "k1", key, true)
+
+	wrongAlg := t.TempDir()
+	writeKey(t, wrongAlg, "k1", key, false)
+
+	weak := t.TempDir()
+	writeKey(t, weak, "k1", newRSA(t, 1024), false)
+
+	tests := []struct {
+		name string
+		cfg  config.JwtConfig
+		want string
+	}{
+		{"missing signing key", asymmetricConfig(config.JwtEdDSA, publicOnly, "k2"), "no private key"},
+		{"public signing key", asymmetricConfig(config.JwtEdDSA, publicOnly, "k1"), "no private key"},
+		{"algorithm mismatch", asymmetricConfig(config.JwtRS256, wrongAlg, "k1"), "is for EdDSA"},
+		{"weak RSA key", asymmetricConfig(config.JwtRS256, weak, "k1"), "at least 2048"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadKeySet(tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	rawRedis "github.com/redis/go-redis/v9"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/redis"
+)
+
+// ErrTooManyLoginAttempts is returned for every throttled login, whether the
+// account is waiting, locked or unknown, or the IP is blocked, so it reveals
+// nothing about the account.
+var ErrTooManyLoginAttempts = apperr.New(http.StatusTooManyRequests, "too_many_login_attempts", "Too many failed logins; try again later.")
+
+const (
+	lockoutAccountPrefix = "auth:lockout:account:"
+	lockoutIPPrefix      = "auth:lockout:ip:"
+)
+
+// recordLoginFailure counts a failure for an account and an IP.
+//
+// KEYS: account failures, account wait, account lock, IP failures, IP block,
+// account failures per IP
+// ARGV: window, lockout, free attempts, base delay, max delay (seconds),
+// account threshold, IP threshold, IP
+//
+// It returns whether the failure locked the account (1 or 0), the account's
+// failures so far and those of them from the IP. A count is forgotten a
+// window after the last failure, and reset once it locks or blocks; the
+// failures per IP only expire, so a lock does not make them count again.
+var recordLoginFailure = rawRedis.NewScript(`
+local failures = redis.call("INCR", KEYS[1])
+redis.call("EXPIRE", KEYS[1], ARGV[1])
+local locked = 0
+if failures >= tonumber(ARGV[6]) then
+	redis.call("SET", KEYS[3], 1, "EX", ARGV[2])
+	redis.call("DEL", KEYS[1], KEYS[2])
+	locked = 1
+elseif failures > tonumber(ARGV[3]) then
+	local delay = tonumber(ARGV[4]) * 2 ^ (failures - tonumber(ARGV[3]) - 1)
+	delay = math.min(delay, tonumber(ARGV[5]))
+	redis.call("SET", KEYS[2], 1, "EX", math.floor(delay))
+end
+local ipFailures = redis.call("INCR", KEYS[4])
+redis.call("EXPIRE", KEYS[4], ARGV[1])
+if ipFailures >= tonumber(ARGV[7]) then
+	redis.call("SET", KEYS[5], 1, "EX", ARGV[2])
+	redis.call("DEL", KEYS[4])
+end
+local sourceFailures = redis.call("HINCRBY", KEYS[6], ARGV[8], 1)
+redis.call("EXPIRE", KEYS[6], ARGV[1])
+return {locked, failures, sourceFailures}
+`)
+
+// LoginThrottle slows down password guessing. Failed logins are counted per
+// account and per client IP:
+//   - after cfg.FreeAttempts failures, each further one makes the account
+//     wait before its next attempt, twice as long as the one before
+//   - cfg.AccountThreshold failures lock the account for cfg.LockoutMinutes
+//   - cfg.IPThreshold failures from one IP, for any accounts, block the IP
+//     for as long
+//
+// Accounts are keyed by the email presented, so unknown addresses are
+// throttled exactly like real ones. A nil *LoginThrottle throttles nothing,
+// for deployments without Redis.
+type LoginThrottle struct {
+	redis *redis.RedisClient
+	cfg   config.LockoutConfig
+}
+
+// NewLoginThrottle returns a throttle with the thresholds in cfg.
+func NewLoginThrottle(client *redis.RedisClient, cfg config.LockoutConfig) *LoginThrottle {
+	return &LoginThrottle{redis: client, cfg: cfg}
+}
+
+// accountKey keys an account by the hash of its normalized email, keeping
+// addresses out of Redis.
+func accountKey(email string) string {
+	return lockoutAccountPrefix + hashToken(strings.ToLower(strings.TrimSpace(email)))
+}
+
+// Check returns how long a login for email from ip has to wait; zero if it
+// may be attempted now.
+func (t *LoginThrottle) Check(ctx context.Context, email string, ip string) (time.Duration, error) {
+	if t == nil {
+		return 0, nil
+	}
+	account := accountKey(email)
+	pipe := t.redis.Pipeline()
+	ttls := []*rawRedis.DurationCmd{
+		pipe.PTTL(ctx, account+":wait"),
+		pipe.PTTL(ctx, account+":locked"),
+		pipe.PTTL(ctx, lockoutIPPrefix+ip+":blocked"),
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	// Missing keys have a negative TTL
+	var wait time.Duration
+	for _, ttl := range ttls {
+		wait = max(wait, ttl.Val())
+	}
+	return wait, nil
+}
+
+// LoginFailure is what counting a failed login did to the account.
+type LoginFailure struct {
+	// Failures is the number of failures of the account in the current
+	// window, including this one; 0 if they are not counted
+	Failures int
+	// SourceFailures is the number of those failures that came from the
+	// IP of this one; 0 if they are not counted
+	SourceFailures int
+	// LockedUntil is when the account's lock ends if this failure locked
+	// it, and the zero time otherwise
+	LockedUntil time.Time
+}
+
+// Fail records a failed login for email from ip.
+func (t *LoginThrottle) Fail(ctx context.Context, email string, ip string) (LoginFailure, error) {
+	if t == nil {
+		return LoginFailure{}, nil
+	}
+	account := accountKey(email)
+	keys := []string{
+		account + ":failures", account + ":wait", account + ":locked",
+		lockoutIPPrefix + ip + ":failures", lockoutIPPrefix + ip + ":blocked",
+		account + ":sources",
+	}
+	lockout := time.Duration(t.cfg.LockoutMinutes) * time.Minute
+	res, err := recordLoginFailure.Run(ctx, t.redis, keys,
+		t.cfg.WindowMinutes*60, int(lockout/time.Second),
+		t.cfg.FreeAttempts, t.cfg.BaseDelaySeconds, t.cfg.MaxDelaySeconds,
+		t.cfg.AccountThreshold, t.cfg.IPThreshold, ip,
+	).Int64Slice()
+	if err != nil {
+		return LoginFailure{}, err
+	}
+	failure := LoginFailure{Failures: int(res[1]), SourceFailures: int(res[2])}
+	if res[0] == 1 {
+		failure.LockedUntil = time.Now().Add(lockout)
+	}
+	return failure, nil
+}
+
+// Succeed forgets the failures of an account once its password was given,
+// including where they came from.
+// Those of the IP are kept, as a stuffing attack succeeds now and then.
+func (t *LoginThrottle) Succeed(ctx context.Context, email string) error {
+	if t == nil {
+		return nil
+	}
+	account := accountKey(email)
+	return t.redis.Del(ctx, account+":failures", account+":wait", account+":sources").Err()
+}
+
+// Unlock lifts the lock of an account and forgets its failures.
+func (t *LoginThrottle) Unlock(ctx context.Context, email string) error {
+	if t == nil {
+		return nil
+	}
+	account := accountKey(email)
+	return t.redis.Del(ctx, account+":failures", account+":wait", account+":locked", account+":sources").Err()
+}
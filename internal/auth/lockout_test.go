@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	rawRedis "github.com/redis/go-redis/v9"
+
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/redis"
+)
+
+var testLockoutConfig = config.LockoutConfig{
+	Enabled:          true,
+	FreeAttempts:     2,
+	BaseDelaySeconds: 1,
+	MaxDelaySeconds:  2,
+	AccountThreshold: 5,
+	IPThreshold:      8,
+	WindowMinutes:    15,
+	LockoutMinutes:   10,
+}
+
+func newTestLoginThrottle(t *testing.T) (*LoginThrottle, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	client := rawRedis.NewClient(&rawRedis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewLoginThrottle(&redis.RedisClient{Client: client}, testLockoutConfig), mr
+}
+
+func TestLoginThrottleDelaysAndLocks(t *testing.T) {
+	throttle, mr := newTestLoginThrottle(t)
+	ctx := context.Background()
+	const email, ip = "anna@example.com", "10.0.0.1"
+
+	// fail records a failure and returns the wait it caused
+	fail := func() (time.Duration, LoginFailure) {
+		t.Helper()
+		failure, err := throttle.Fail(ctx, email, ip)
+		if err != nil {
+			t.Fatalf("fail: %v", err)
+		}
+		wait, err := throttle.Check(ctx, email, ip)
+		if err != nil {
+			t.Fatalf("check: %v", err)
+		}
+		mr.FastForward(wait)
+		return wait, failure
+	}
+
+	for i, want := range []time.Duration{0, 0, time.Second, 2 * time.Second} {
+		wait, failure := fail()
+		if wait != want || !failure.LockedUntil.IsZero() {
+			t.Fatalf("failure %d: waited %v (locked until %v), want %v", i+1, wait, failure.LockedUntil, want)
+		}
+		if failure.Failures != i+1 {
+			t.Fatalf("failure %d: counted %d failures", i+1, failure.Failures)
+		}
+	}
+	wait, failure := fail()
+	if wait != 10*time.Minute || failure.LockedUntil.IsZero() {
+		t.Fatalf("failure 5: waited %v (locked until %v), want a 10m lock", wait, failure.LockedUntil)
+	}
+
+	// The lock ends with a clean slate
+	if wait, failure := fail(); wait != 0 || failure.Failures != 1 {
+		t.Fatalf("failure after the lock: waited %v after %d failures", wait, failure.Failures)
+	}
+
+	// Case and whitespace do not make another account
+	if _, err := throttle.Fail(ctx, " Anna@Example.com", ip); err != nil {
+		t.Fatalf("fail: %v", err)
+	}
+	if _, err := throttle.Fail(ctx, "ANNA@example.com", ip); err != nil {
+		t.Fatalf("fail: %v", err)
+	}
+	if wait, _ := throttle.Check(ctx, email, "10.0.0.2"); wait != time.Second {
+		t.Fatalf("expected the third failure to make the account wait, got %v", wait)
+	}
+
+	if err := throttle.Succeed(ctx, email); err != nil {
+		t.Fatalf("succeed: %v", err)
+	}
+	if wait, _ := throttle.Check(ctx, email, "10.0.0.2"); wait != 0 {
+		t.Fatalf("expected a login to clear the wait, got %v", wait)
+	}
+}
+
+func TestLoginThrottleBlocksIP(t *testing.T) {
+	throttle, _ := newTestLoginThrottle(t)
+	ctx := context.Background()
+	const ip = "10.0.0.1"
+
+	// One failure each for many accounts, as in credential stuffing
+	emails := []string{"a@x.de", "b@x.de", "c@x.de", "d@x.de", "e@x.de", "f@x.de", "g@x.de", "h@x.de"}
+	for _, email := range emails {
+		if _, err := throttle.Fail(ctx, email, ip); err != nil {
+			t.Fatalf("fail: %v", err)
+		}
+	}
+	if wait, _ := throttle.Check(ctx, "new@x.de", ip); wait != 10*time.Minute {
+		t.Fatalf("expected the IP to be blocked for 10m, got %v", wait)
+	}
+	if wait, _ := throttle.Check(ctx, "new@x.de", "10.0.0.2"); wait != 0 {
+		t.Fatalf("expected other IPs to be unaffected, got %v", wait)
+	}
+}
+
+func TestLoginThrottleCountsFailuresPerSource(t *testing.T) {
+	throttle, _ := newTestLoginThrottle(t)
+	ctx := context.Background()
+	const email = "anna@example.com"
+
+	// fail records a failure from ip and returns the counts
+	fail := func(ip string) LoginFailure {
+		t.Helper()
+		failure, err := throttle.Fail(ctx, email, ip)
+		if err != nil {
+			t.Fatalf("fail: %v", err)
+		}
+		return failure
+	}
+
+	for i, c := range []struct {
+		ip             string
+		failures       int
+		sourceFailures int
+	}{
+		{"10.0.0.1", 1, 1},
+		{"10.0.0.1", 2, 2},
+		{"10.0.0.2", 3, 1},
+		{"10.0.0.1", 4, 3},
+	} {
+		if f := fail(c.ip); f.Failures != c.failures || f.SourceFailures != c.sourceFailures {
+			t.Fatalf("failure %d from %s: got %d failures, %d from the IP; want %d, %d", i+1, c.ip, f.Failures, f.SourceFailures, c.failures, c.sourceFailures)
+		}
+	}
+
+	// A lock keeps the failures per IP, a login forgets them
+	if f := fail("10.0.0.3"); f.LockedUntil.IsZero() || f.SourceFailures != 1 {
+		t.Fatalf("expected the fifth failure to lock the account, got %+v", f)
+	}
+	if f := fail("10.0.0.2"); f.Failures != 1 || f.SourceFailures != 2 {
+		t.Fatalf("expected the failures per IP to outlast the lock, got %+v", f)
+	}
+	if err := throttle.Succeed(ctx, email); err != nil {
+		t.Fatalf("succeed: %v", err)
+	}
+	if f := fail("10.0.0.2"); f.SourceFailures != 1 {
+		t.Fatalf("expected a login to forget the failures per IP, got %+v", f)
+	}
+}
+
+func TestLoginThrottleUnlock(t *testing.T) {
+	throttle, _ := newTestLoginThrottle(t)
+	ctx := context.Background()
+	const email = "anna@example.com"
+
+	for i := 0; i < testLockoutConfig.AccountThreshold; i++ {
+		if _, err := throttle.Fail(ctx, email, "10.0.0.1"); err != nil {
+			t.Fatalf("fail: %v", err)
+		}
+	}
+	if wait, _ := throttle.Check(ctx, email, "10.0.1.1"); wait == 0 {
+		t.Fatalf("expected the account to be locked")
+	}
+	if err := throttle.Unlock(ctx, email); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if wait, _ := throttle.Check(ctx, email, "10.0.1.1"); wait != 0 {
+		t.Fatalf("expected no wait after unlocking, got %v", wait)
+	}
+
+	var nilThrottle *LoginThrottle
+	if wait, err := nilThrottle.Check(ctx, email, "10.0.1.1"); wait != 0 || err != nil {
+		t.Fatalf("nil throttle: got %v, %v", wait, err)
+	}
+}
@@ -0,0 +1,353 @@
+package auth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"egaldeutsch-be/internal/apperr"
+)
+
+var (
+	ErrInvalidMFACode    = apperr.Unauthorized("invalid_mfa_code", "The authentication code is incorrect or was already used.")
+	ErrInvalidMFAToken   = apperr.Unauthorized("invalid_mfa_token", "The MFA token is invalid, expired or out of attempts; sign in again.")
+	ErrMFAAlreadyEnabled = apperr.Conflict("mfa_already_enabled", "Two-factor authentication is already enabled.")
+	ErrMFANotEnabled     = apperr.Conflict("mfa_not_enabled", "Two-factor authentication is not enabled.")
+	ErrMFASetupMissing   = apperr.Conflict("mfa_setup_missing", "Start the enrollment before confirming it.")
+	ErrMFARequired       = apperr.Forbidden("mfa_required", "Your role requires two-factor authentication; it cannot be disabled.")
+)
+
+const (
+	// RecoveryCodeCount is how many recovery codes a user gets at a time
+	RecoveryCodeCount = 10
+	// sealedPrefix marks a TOTP secret encrypted with the MFA encryption key
+	sealedPrefix = "enc:v1:"
+)
+
+// MFAStatus describes the second factor of a user.
+type MFAStatus struct {
+	Enabled   bool       `json:"enabled"`
+	EnabledAt *time.Time `json:"enabled_at,omitempty"`
+	// Required is set when the user's role must use two-factor authentication
+	Required          bool `json:"required"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// TOTPSetup is a pending enrollment: the secret to add to an authenticator
+// app, as text and as an otpauth URI for a QR code.
+type TOTPSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// MFAChallenge is a login waiting for its second factor.
+type MFAChallenge struct {
+	// Token is the plain challenge token; it is only set on creation
+	Token  string
+	UserID string
+	// Role is the user's current role; it is only set once an attempt is counted
+	Role string
+	// Enroll is set when the user's role requires two-factor authentication
+	// but the user has not enrolled. Confirming an enrollment completes the
+	// login.
+	Enroll    bool
+	ExpiresAt time.Time
+}
+
+// MFASecret is the stored TOTP secret of a user.
+type MFASecret struct {
+	// Secret is the base32 secret, encrypted if an encryption key is configured
+	Secret string
+	// EnabledAt is nil while the enrollment awaits confirmation
+	EnabledAt *time.Time
+	// LastUsedStep is the time step of the last accepted code; a code is only
+	// accepted once
+	LastUsedStep int64
+}
+
+func (s *service) GetMFAStatus(ctx context.Context, userID string, role string) (MFAStatus, error) {
+	var status MFAStatus
+	required, err := s.roleRequiresMFA(ctx, role)
+	if err != nil {
+		return status, err
+	}
+	status.Required = required
+
+	secret, err := s.repo.GetMFASecret(ctx, userID)
+	if err != nil {
+		return status, err
+	}
+	if secret == nil || secret.EnabledAt == nil {
+		return status, nil
+	}
+	status.Enabled, status.EnabledAt = true, secret.EnabledAt
+	status.RecoveryCodesLeft, err = s.repo.CountRecoveryCodes(ctx, userID)
+	return status, err
+}
+
+func (s *service) BeginTOTPEnrollment(ctx context.Context, userID string, account string) (TOTPSetup, error) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return TOTPSetup{}, err
+	}
+	sealed, err := s.sealSecret(secret)
+	if err != nil {
+		return TOTPSetup{}, err
+	}
+	if err := s.repo.SavePendingMFASecret(ctx, userID, sealed); err != nil {
+		return TOTPSetup{}, err
+	}
+	return TOTPSetup{Secret: secret, URI: TOTPURI(s.mfa.Issuer, account, secret)}, nil
+}
+
+func (s *service) ConfirmTOTPEnrollment(ctx context.Context, userID string, code string) ([]string, error) {
+	stored, err := s.repo.GetMFASecret(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, ErrMFASetupMissing
+	}
+	if stored.EnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	secret, err := s.openSecret(stored.Secret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := ValidateTOTP(secret, code, time.Now(), 0)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.EnableMFA(ctx, userID, step, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *service) RegenerateRecoveryCodes(ctx context.Context, userID string, code string) ([]string, error) {
+	if err := s.verifyMFACode(ctx, userID, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *service) DisableMFA(ctx context.Context, userID string, role string, code string) error {
+	required, err := s.roleRequiresMFA(ctx, role)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrMFARequired
+	}
+	if err := s.verifyMFACode(ctx, userID, code); err != nil {
+		return err
+	}
+	return s.repo.DeleteMFA(ctx, userID, false)
+}
+
+func (s *service) ResetMFA(ctx context.Context, userID string) error {
+	return s.repo.DeleteMFA(ctx, userID, true)
+}
+
+func (s *service) StartMFAChallenge(ctx context.Context, userID string, role string) (*MFAChallenge, error) {
+	secret, err := s.repo.GetMFASecret(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	enroll := secret == nil || secret.EnabledAt == nil
+	if enroll {
+		required, err := s.roleRequiresMFA(ctx, role)
+		if err != nil || !required {
+			return nil, err
+		}
+	}
+
+	token, err := genRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(time.Duration(s.mfa.ChallengeTTLSeconds) * time.Second)
+	if err := s.repo.InsertMFAChallenge(ctx, hashToken(token), userID, enroll, expiresAt); err != nil {
+		return nil, err
+	}
+	return &MFAChallenge{Token: token, UserID: userID, Role: role, Enroll: enroll, ExpiresAt: expiresAt}, nil
+}
+
+func (s *service) GetMFAChallenge(ctx context.Context, token string) (MFAChallenge, error) {
+	return s.repo.GetMFAChallenge(ctx, hashToken(token), s.mfa.MaxAttempts)
+}
+
+func (s *service) CompleteMFAChallenge(ctx context.Context, token string, code string) (MFAChallenge, []string, error) {
+	hash := hashToken(token)
+	// Counting the attempt first bounds the guesses per challenge even when
+	// they arrive concurrently
+	challenge, err := s.repo.ClaimMFAAttempt(ctx, hash, s.mfa.MaxAttempts)
+	if err != nil {
+		return MFAChallenge{}, nil, err
+	}
+
+	var recoveryCodes []string
+	if challenge.Enroll {
+		recoveryCodes, err = s.ConfirmTOTPEnrollment(ctx, challenge.UserID, code)
+	} else {
+		err = s.verifyMFACode(ctx, challenge.UserID, code)
+	}
+	if err != nil {
+		return MFAChallenge{}, nil, err
+	}
+
+	if err := s.repo.ConsumeMFAChallenge(ctx, hash); err != nil {
+		return MFAChallenge{}, nil, err
+	}
+	return challenge, recoveryCodes, nil
+}
+
+func (s *service) MFARequiredRoles(ctx context.Context) ([]string, error) {
+	return s.repo.GetMFARequiredRoles(ctx)
+}
+
+func (s *service) SetMFARequiredRoles(ctx context.Context, roles []string) error {
+	roles = append([]string{}, roles...)
+	slices.Sort(roles)
+	return s.repo.SetMFARequiredRoles(ctx, slices.Compact(roles))
+}
+
+func (s *service) roleRequiresMFA(ctx context.Context, role string) (bool, error) {
+	roles, err := s.repo.GetMFARequiredRoles(ctx)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(roles, role), nil
+}
+
+// verifyMFACode accepts a current TOTP code or an unused recovery code of a
+// user with two-factor authentication enabled, and uses it up.
+func (s *service) verifyMFACode(ctx context.Context, userID string, code string) error {
+	stored, err := s.repo.GetMFASecret(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if stored == nil || stored.EnabledAt == nil {
+		return ErrMFANotEnabled
+	}
+
+	if !isTOTPCode(code) {
+		return s.repo.UseRecoveryCode(ctx, userID, hashToken(normalizeRecoveryCode(code)))
+	}
+	secret, err := s.openSecret(stored.Secret)
+	if err != nil {
+		return err
+	}
+	step, ok := ValidateTOTP(secret, code, time.Now(), stored.LastUsedStep)
+	if !ok {
+		return ErrInvalidMFACode
+	}
+	// Records the step only if no concurrent request used it first
+	return s.repo.UseTOTPStep(ctx, userID, step)
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != totpDigits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// generateRecoveryCodes returns new recovery codes, formatted like
+// "k7fq2-m3xva", and their hashes.
+func generateRecoveryCodes() (codes []string, hashes []string, err error) {
+	for i := 0; i < RecoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(b))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashToken(code))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode ignores case, spaces and dashes, which users are apt
+// to get wrong when typing a code.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// sealSecret encrypts a TOTP secret with AES-256-GCM when an encryption key
+// is configured.
+func (s *service) sealSecret(secret string) (string, error) {
+	if s.mfa.EncryptionKey == "" {
+		return secret, nil
+	}
+	aead, err := s.secretCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(secret), nil)
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// openSecret reverses sealSecret. Secrets stored before a key was configured
+// are returned as they are.
+func (s *service) openSecret(stored string) (string, error) {
+	if !strings.HasPrefix(stored, sealedPrefix) {
+		return stored, nil
+	}
+	if s.mfa.EncryptionKey == "" {
+		return "", errors.New("TOTP secret is encrypted but no MFA encryption key is configured")
+	}
+	aead, err := s.secretCipher()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(stored, sealedPrefix))
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted TOTP secret")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", errors.New("cannot decrypt TOTP secret; was the MFA encryption key changed?")
+	}
+	return string(plain), nil
+}
+
+func (s *service) secretCipher() (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(s.mfa.EncryptionKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
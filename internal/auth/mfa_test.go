@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"egaldeutsch-be/internal/config"
+)
+
+func TestSealSecret(t *testing.T) {
+	secret, _ := GenerateTOTPSecret()
+	plain := &service{}
+	keyed := &service{mfa: config.MfaConfig{EncryptionKey: "mfa-test-key-with-at-least-32-characters"}}
+
+	sealed, err := keyed.sealSecret(secret)
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if !strings.HasPrefix(sealed, sealedPrefix) || strings.Contains(sealed, secret) {
+		t.Fatalf("expected an encrypted secret, got %q", sealed)
+	}
+	if opened, err := keyed.openSecret(sealed); err != nil || opened != secret {
+		t.Fatalf("expected %q back, got %q (%v)", secret, opened, err)
+	}
+
+	// Secrets stored before a key was configured are still read
+	if opened, err := keyed.openSecret(secret); err != nil || opened != secret {
+		t.Fatalf("expected a plain secret to be read, got %q (%v)", opened, err)
+	}
+	if _, err := plain.openSecret(sealed); err == nil {
+		t.Fatalf("expected an encrypted secret to need the key")
+	}
+	other := &service{mfa: config.MfaConfig{EncryptionKey: "another-key-with-at-least-32-characters!"}}
+	if _, err := other.openSecret(sealed); err == nil {
+		t.Fatalf("expected a different key to fail")
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if len(codes) != RecoveryCodeCount || len(hashes) != RecoveryCodeCount {
+		t.Fatalf("expected %d codes, got %d", RecoveryCodeCount, len(codes))
+	}
+	seen := map[string]bool{}
+	for i, code := range codes {
+		if len(code) != 11 || code[5] != '-' || isTOTPCode(code) {
+			t.Fatalf("unexpected code format %q", code)
+		}
+		// Typed in capitals and without the dash it is still the same code
+		if hashToken(normalizeRecoveryCode(strings.ToUpper(strings.Replace(code, "-", " ", 1)))) != hashes[i] {
+			t.Fatalf("expected %q to match its hash when retyped", code)
+		}
+		if seen[code] {
+			t.Fatalf("duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+}
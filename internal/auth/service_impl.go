@@ -1,26 +1,55 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"time"
 
+	"egaldeutsch-be/internal/apperr"
 	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/metrics"
 
 	"github.com/google/uuid"
 )
 
 var (
-	ErrInvalidRefreshToken = errors.New("invalid refresh token")
-	ErrRefreshTokenReuse   = errors.New("refresh token reuse detected")
+	// ErrInvalidCredentials is returned for an unknown email or a wrong password
+	ErrInvalidCredentials  = apperr.Unauthorized("invalid_credentials", "The email or password is incorrect.")
+	ErrMissingToken        = apperr.Unauthorized("missing_token", "A bearer token is required.")
+	ErrInvalidToken        = apperr.Unauthorized("invalid_token", "The access token is invalid or expired.")
+	ErrTokenRevoked        = apperr.Unauthorized("token_revoked", "The access token has been revoked; sign in again.")
+	ErrInvalidRefreshToken = apperr.Unauthorized("invalid_refresh_token", "The refresh token is invalid, expired or revoked.")
+	ErrRefreshTokenReuse   = apperr.Unauthorized("refresh_token_reused", "The refresh token was already used; all sessions have been revoked.")
+	ErrInvalidResetToken   = apperr.Unauthorized("invalid_reset_token", "The password reset token is invalid, expired or already used.")
+	ErrSessionNotFound     = apperr.NotFound("session_not_found", "The session does not exist or has already ended.")
+)
+
+// PasswordResetTTL is how long a password reset token stays valid.
+const PasswordResetTTL = time.Hour
+
+var (
+	refreshRotations = metrics.NewCounterVec(
+		"auth_refresh_token_rotations_total",
+		"Successful refresh token rotations.",
+	)
+	refreshReuseDetected = metrics.NewCounterVec(
+		"auth_refresh_token_reuse_detected_total",
+		"Refresh tokens presented again after rotation or revocation.",
+	)
 )
 
 type service struct {
-	cfg  config.JwtConfig
-	repo AuthRepo
+	cfg     config.JwtConfig
+	mfa     config.MfaConfig
+	apiKeys config.APIKeysConfig
+	repo    AuthRepo
+	// denylist revokes the access tokens of ended sessions; nil leaves them
+	// valid until they expire
+	denylist *Denylist
 }
 
-func NewService(cfg config.JwtConfig, repo AuthRepo) AuthService {
-	return &service{cfg: cfg, repo: repo}
+func NewService(cfg config.JwtConfig, mfa config.MfaConfig, apiKeys config.APIKeysConfig, repo AuthRepo, denylist *Denylist) AuthService {
+	return &service{cfg: cfg, mfa: mfa, apiKeys: apiKeys, repo: repo, denylist: denylist}
 }
 func (s *service) CreateAccessToken(userID string) (string, error) {
 	// service-level CreateAccessToken no longer resolves role; callers that
@@ -34,24 +63,25 @@ func (s *service) ParseToken(token string) (*Claims, error) {
 	return ParseToken(token, s.cfg)
 }
 
-func (s *service) CreateRefreshToken(userID string, ip string, userAgent string) (string, error) {
+func (s *service) CreateRefreshToken(ctx context.Context, userID string, ip string, userAgent string) (string, string, error) {
 	// generate plain token
 	token, err := genRandomToken(DefaultRefreshTokenBytes)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	hash := hashToken(token)
 
 	// Use repo interface to persist
 	expiresAtUnix := time.Now().Add(time.Duration(s.cfg.RefreshTokenExpirationDays*24) * time.Hour).Unix()
-	if err := s.repo.InsertRefreshToken(hash, userID, expiresAtUnix, &ip, &userAgent); err != nil {
-		return "", err
+	sessionID, err := s.repo.InsertRefreshToken(ctx, hash, userID, expiresAtUnix, &ip, &userAgent)
+	if err != nil {
+		return "", "", err
 	}
 
-	return token, nil
+	return token, sessionID, nil
 }
 
-func (s *service) RefreshTokens(oldRefreshToken string, ip string, userAgent string) (string, string, error) {
+func (s *service) RefreshTokens(ctx context.Context, oldRefreshToken string, ip string, userAgent string) (string, string, error) {
 	// hash provided token
 	oldHash := hashToken(oldRefreshToken)
 
@@ -63,15 +93,18 @@ func (s *service) RefreshTokens(oldRefreshToken string, ip string, userAgent str
 	newHash := hashToken(newToken)
 
 	expiresAtUnix := time.Now().Add(time.Duration(s.cfg.RefreshTokenExpirationDays*24) * time.Hour).Unix()
-	userID, role, reused, err := s.repo.RotateRefreshToken(oldHash, newHash, expiresAtUnix, &ip, &userAgent)
+	session, reused, err := s.repo.RotateRefreshToken(ctx, oldHash, newHash, expiresAtUnix, &ip, &userAgent)
 	if err != nil {
 		return "", "", err
 	}
 	if reused {
+		refreshReuseDetected.Inc()
 		return "", "", ErrRefreshTokenReuse
 	}
+	refreshRotations.Inc()
 
-	access, err := CreateAccessTokenFromStrings(userID, role, s.cfg)
+	// The new access token keeps the session and auth_time of the login
+	access, err := NewJWTService(s.cfg).CreateSessionAccessToken(session.UserID, session.Role, session.ID, session.AuthTime)
 	if err != nil {
 		return "", "", err
 	}
@@ -79,43 +112,94 @@ func (s *service) RefreshTokens(oldRefreshToken string, ip string, userAgent str
 	return access, newToken, nil
 }
 
-func (s *service) RevokeRefreshToken(refreshToken string) error {
+func (s *service) RevokeRefreshToken(ctx context.Context, refreshToken string) error {
 	hash := hashToken(refreshToken)
-	return s.repo.RevokeRefreshTokenByHash(hash, nil)
+	return s.repo.RevokeRefreshTokenByHash(ctx, hash, nil)
 }
 
-func (s *service) RevokeAllRefreshTokens(userID string) error {
+func (s *service) RevokeAllRefreshTokens(ctx context.Context, userID string) error {
 	uid, err := uuid.Parse(userID)
 	if err != nil {
 		return err
 	}
-	return s.repo.RevokeAllForUser(uid.String())
+	return s.repo.RevokeAllForUser(ctx, uid.String())
+}
+
+func (s *service) ListSessions(ctx context.Context, userID string, currentSessionID string) ([]Session, error) {
+	sessions, err := s.repo.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+func (s *service) RevokeSession(ctx context.Context, userID string, sessionID string) error {
+	if err := s.repo.RevokeSession(ctx, userID, sessionID); err != nil {
+		return err
+	}
+	return s.denylist.RevokeSession(ctx, sessionID)
 }
 
-func (s *service) CreatePasswordResetForEmail(email string) error {
+func (s *service) RevokeOtherSessions(ctx context.Context, userID string, exceptSessionID string) (int, error) {
+	revoked, err := s.repo.RevokeOtherSessions(ctx, userID, exceptSessionID)
+	if err != nil {
+		return 0, err
+	}
+	for _, sessionID := range revoked {
+		if err := s.denylist.RevokeSession(ctx, sessionID); err != nil {
+			return 0, err
+		}
+	}
+	return len(revoked), nil
+}
+
+func (s *service) CreatePasswordResetForEmail(ctx context.Context, email string) error {
 	return errors.New("not implemented")
 }
 
-func (s *service) VerifyPasswordResetToken(token string) (string, error) {
+func (s *service) GetPasswordResetUser(ctx context.Context, token string) (string, error) {
+	return s.repo.GetPasswordReset(ctx, hashToken(token))
+}
+
+func (s *service) VerifyPasswordResetToken(ctx context.Context, token string) (string, error) {
 	// Use repo to verify and mark token as used
 	tokenHash := hashToken(token)
-	userID, err := s.repo.VerifyAndMarkPasswordReset(tokenHash)
+	userID, err := s.repo.VerifyAndMarkPasswordReset(ctx, tokenHash)
 	if err != nil {
 		return "", err
 	}
 	return userID, nil
 }
 
-func (s *service) CreatePasswordResetForUser(userID string) (string, error) {
+func (s *service) RequestPasswordReset(ctx context.Context, email string) error {
+	return s.repo.RecordPasswordResetRequest(ctx, email)
+}
+
+func (s *service) CreatePasswordResetForUser(ctx context.Context, userID string) (string, error) {
 	// generate token
 	token, err := genRandomToken(32)
 	if err != nil {
 		return "", err
 	}
 	tokenHash := hashToken(token)
-	expiresAt := time.Now().Add(1 * time.Hour).Unix()
-	if err := s.repo.InsertPasswordReset(tokenHash, userID, expiresAt); err != nil {
+	expiresAt := time.Now().Add(PasswordResetTTL).Unix()
+	if err := s.repo.InsertPasswordReset(ctx, tokenHash, userID, expiresAt); err != nil {
 		return "", err
 	}
 	return token, nil
 }
+
+func (s *service) RecordFailedLogin(ctx context.Context, email string, failures int) error {
+	return s.repo.RecordFailedLogin(ctx, email, failures)
+}
+
+func (s *service) RecordAccountLocked(ctx context.Context, email string, until time.Time) error {
+	return s.repo.RecordAccountLocked(ctx, email, until)
+}
+
+func (s *service) RecordAccountUnlocked(ctx context.Context, userID string, email string) error {
+	return s.repo.RecordAccountUnlocked(ctx, userID, email)
+}
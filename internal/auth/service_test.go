@@ -1,39 +1,84 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"egaldeutsch-be/internal/config"
 
 	"github.com/google/uuid"
 )
 
-// fakeRepo implements AuthRepo for testing
+// fakeRepo implements AuthRepo for testing. Second factors, email
+// verification and API keys are covered by the repository tests; calling an
+// MFARepo, EmailVerificationRepo or APIKeyRepo method panics.
 type fakeRepo struct {
-	inserted         map[string]bool
-	nextRotateUserID string
-	nextRotateRole   string
-	nextRotateReused bool
-	rotateErr        error
+	MFARepo
+	EmailVerificationRepo
+	APIKeyRepo
+
+	inserted           map[string]bool
+	nextRotateUserID   string
+	nextRotateRole     string
+	nextRotateAuthTime time.Time
+	nextRotateReused   bool
+	rotateErr          error
+	sessions           []Session
 }
 
-func (f *fakeRepo) InsertRefreshToken(tokenHash string, userID string, expiresAt int64, ip *string, userAgent *string) error {
+func (f *fakeRepo) InsertRefreshToken(ctx context.Context, tokenHash string, userID string, expiresAt int64, ip *string, userAgent *string) (string, error) {
 	if f.inserted == nil {
 		f.inserted = map[string]bool{}
 	}
 	f.inserted[tokenHash] = true
+	return uuid.NewString(), nil
+}
+func (f *fakeRepo) RotateRefreshToken(ctx context.Context, oldHash, newHash string, newExpiresAt int64, ip *string, userAgent *string) (RefreshSession, bool, error) {
+	session := RefreshSession{UserID: f.nextRotateUserID, Role: f.nextRotateRole, AuthTime: f.nextRotateAuthTime}
+	return session, f.nextRotateReused, f.rotateErr
+}
+func (f *fakeRepo) RevokeRefreshTokenByHash(ctx context.Context, hash string, replacedBy *string) error {
+	return nil
+}
+func (f *fakeRepo) RevokeAllForUser(ctx context.Context, userID string) error { return nil }
+func (f *fakeRepo) ListSessions(ctx context.Context, userID string) ([]Session, error) {
+	return f.sessions, nil
+}
+func (f *fakeRepo) RevokeSession(ctx context.Context, userID string, sessionID string) error {
 	return nil
 }
-func (f *fakeRepo) RotateRefreshToken(oldHash, newHash string, newExpiresAt int64, ip *string, userAgent *string) (string, string, bool, error) {
-	return f.nextRotateUserID, f.nextRotateRole, f.nextRotateReused, f.rotateErr
+func (f *fakeRepo) RevokeOtherSessions(ctx context.Context, userID string, exceptSessionID string) ([]string, error) {
+	return nil, nil
 }
-func (f *fakeRepo) RevokeRefreshTokenByHash(hash string, replacedBy *string) error { return nil }
-func (f *fakeRepo) RevokeAllForUser(userID string) error                           { return nil }
-func (f *fakeRepo) InsertPasswordReset(tokenHash string, userID string, expiresAt int64) error {
+func (f *fakeRepo) PurgeExpiredRefreshTokens(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+func (f *fakeRepo) PurgeExpiredPasswordResets(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+func (f *fakeRepo) RecordPasswordResetRequest(ctx context.Context, email string) error {
+	return nil
+}
+func (f *fakeRepo) InsertPasswordReset(ctx context.Context, tokenHash string, userID string, expiresAt int64) error {
+	return nil
+}
+func (f *fakeRepo) GetPasswordReset(ctx context.Context, tokenHash string) (string, error) {
+	return "", nil
+}
+func (f *fakeRepo) VerifyAndMarkPasswordReset(ctx context.Context, tokenHash string) (string, error) {
+	return "", nil
+}
+func (f *fakeRepo) RecordFailedLogin(ctx context.Context, email string, failures int) error {
+	return nil
+}
+func (f *fakeRepo) RecordAccountLocked(ctx context.Context, email string, until time.Time) error {
+	return nil
+}
+func (f *fakeRepo) RecordAccountUnlocked(ctx context.Context, userID string, email string) error {
 	return nil
 }
-func (f *fakeRepo) VerifyAndMarkPasswordReset(tokenHash string) (string, error) { return "", nil }
 
 func TestCreateRefreshTokenAndRotateSuccess(t *testing.T) {
 	repo := &fakeRepo{}
@@ -43,30 +88,41 @@ func TestCreateRefreshTokenAndRotateSuccess(t *testing.T) {
 		ExpirationHours:            1,
 		RefreshTokenExpirationDays: 30,
 	}
-	svc := NewService(cfg, repo)
+	svc := NewService(cfg, config.MfaConfig{}, config.APIKeysConfig{}, repo, nil)
 
 	// CreateRefreshToken should call repo.InsertRefreshToken
 	userID := uuid.New().String() // Use proper UUID
-	plain, err := svc.CreateRefreshToken(userID, "1.2.3.4", "ua")
+	plain, sessionID, err := svc.CreateRefreshToken(context.Background(), userID, "1.2.3.4", "ua")
 	if err != nil {
 		t.Fatalf("CreateRefreshToken failed: %v", err)
 	}
-	if plain == "" {
-		t.Fatalf("expected plain token")
+	if plain == "" || sessionID == "" {
+		t.Fatalf("expected plain token and session ID")
 	}
 
 	// Rotate scenario: repo returns userID+role
 	repo.nextRotateUserID = userID // Use the same valid UUID
 	repo.nextRotateRole = "learner"
+	repo.nextRotateAuthTime = time.Now().Add(-time.Hour).Truncate(time.Second)
 	repo.nextRotateReused = false
 
-	access, newPlain, err := svc.RefreshTokens("oldtoken", "1.2.3.4", "ua")
+	access, newPlain, err := svc.RefreshTokens(context.Background(), "oldtoken", "1.2.3.4", "ua")
 	if err != nil {
 		t.Fatalf("RefreshTokens failed: %v", err)
 	}
 	if access == "" || newPlain == "" {
 		t.Fatalf("expected access and refresh tokens")
 	}
+	claims, err := ParseToken(access, cfg)
+	if err != nil {
+		t.Fatalf("parse access token: %v", err)
+	}
+	if claims.AuthTime == nil || !claims.AuthTime.Equal(repo.nextRotateAuthTime) {
+		t.Fatalf("expected auth_time of the login %v, got %v", repo.nextRotateAuthTime, claims.AuthTime)
+	}
+	if claims.ID == "" {
+		t.Fatalf("expected a jti")
+	}
 }
 
 func TestRefreshTokens_ReuseDetected(t *testing.T) {
@@ -77,10 +133,14 @@ func TestRefreshTokens_ReuseDetected(t *testing.T) {
 		ExpirationHours:            1,
 		RefreshTokenExpirationDays: 30,
 	}
-	svc := NewService(cfg, repo)
+	svc := NewService(cfg, config.MfaConfig{}, config.APIKeysConfig{}, repo, nil)
 
-	_, _, err := svc.RefreshTokens("oldtoken", "1.2.3.4", "ua")
+	before := refreshReuseDetected.Value()
+	_, _, err := svc.RefreshTokens(context.Background(), "oldtoken", "1.2.3.4", "ua")
 	if !errors.Is(err, ErrRefreshTokenReuse) {
 		t.Fatalf("expected ErrRefreshTokenReuse got %v", err)
 	}
+	if refreshReuseDetected.Value()-before != 1 {
+		t.Fatalf("expected reuse detection to be counted")
+	}
 }
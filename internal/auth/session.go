@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"strings"
+	"time"
+)
+
+// Session is an active login of a user, as shown to the user and to admins.
+type Session struct {
+	ID string `json:"id"`
+	// Device describes the browser and operating system, for example
+	// "Firefox on Windows"; it is derived from UserAgent
+	Device    string `json:"device"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	// CreatedAt is when the user logged in
+	CreatedAt time.Time `json:"created_at"`
+	// LastUsedAt is when the session's refresh token was last rotated, or
+	// the login if it has not been
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Current marks the session of the access token making the request
+	Current bool `json:"current"`
+}
+
+// uaBrowsers and uaSystems map user agent substrings to names, most specific
+// first: Edge and Opera also claim to be Chrome, and Chrome claims to be
+// Safari.
+var (
+	uaBrowsers = [][2]string{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"CriOS/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	}
+	uaSystems = [][2]string{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+)
+
+// DescribeDevice returns a short description of the client sending
+// userAgent, or "Unknown device" if it is not recognised.
+func DescribeDevice(userAgent string) string {
+	browser := uaMatch(userAgent, uaBrowsers)
+	system := uaMatch(userAgent, uaSystems)
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	default:
+		return "Unknown device"
+	}
+}
+
+func uaMatch(userAgent string, patterns [][2]string) string {
+	for _, p := range patterns {
+		if strings.Contains(userAgent, p[0]) {
+			return p[1]
+		}
+	}
+	return ""
+}
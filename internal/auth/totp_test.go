@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors.
+var rfc6238Secret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestHOTPMatchesRFC6238(t *testing.T) {
+	// The RFC lists 8-digit codes; the last 6 digits are the 6-digit code
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	key := []byte("12345678901234567890")
+	for _, v := range vectors {
+		step := totpStep(time.Unix(v.unix, 0))
+		if got := hotp(key, uint64(step), 8); got != v.code {
+			t.Errorf("T=%d: expected %s, got %s", v.unix, v.code, got)
+		}
+		code, err := TOTPCode(rfc6238Secret, time.Unix(v.unix, 0))
+		if err != nil || code != v.code[2:] {
+			t.Errorf("T=%d: expected %s, got %s (%v)", v.unix, v.code[2:], code, err)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := totpStep(now)
+	code, _ := TOTPCode(rfc6238Secret, now)
+
+	step, ok := ValidateTOTP(rfc6238Secret, code, now, 0)
+	if !ok || step != current {
+		t.Fatalf("expected the current code to be valid for step %d, got %d %v", current, step, ok)
+	}
+	// A used step cannot be used again
+	if _, ok := ValidateTOTP(rfc6238Secret, code, now, current); ok {
+		t.Fatalf("expected a replayed code to be rejected")
+	}
+
+	// Clocks one period apart are tolerated, two are not
+	previous, _ := TOTPCode(rfc6238Secret, now.Add(-totpPeriod))
+	if _, ok := ValidateTOTP(rfc6238Secret, previous, now, 0); !ok {
+		t.Fatalf("expected the previous period's code to be valid")
+	}
+	stale, _ := TOTPCode(rfc6238Secret, now.Add(-2*totpPeriod))
+	if _, ok := ValidateTOTP(rfc6238Secret, stale, now, 0); ok {
+		t.Fatalf("expected a code two periods old to be rejected")
+	}
+
+	for _, bad := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := ValidateTOTP(rfc6238Secret, bad, now, 0); ok {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestGenerateTOTPSecretAndURI(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("generate secret: %v", err)
+	}
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil || len(key) != totpSecretBytes {
+		t.Fatalf("expected a %d-byte base32 secret, got %q (%v)", totpSecretBytes, secret, err)
+	}
+
+	uri, err := url.Parse(TOTPURI("Egal Deutsch", "anna@example.com", secret))
+	if err != nil {
+		t.Fatalf("parse URI: %v", err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || !strings.HasPrefix(uri.Path, "/Egal Deutsch:anna@example.com") {
+		t.Fatalf("unexpected URI %s", uri)
+	}
+	q := uri.Query()
+	if q.Get("secret") != secret || q.Get("issuer") != "Egal Deutsch" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Fatalf("unexpected URI parameters %v", q)
+	}
+}
@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"egaldeutsch-be/internal/apperr"
+)
+
+var (
+	ErrInvalidVerificationToken = apperr.Unauthorized("invalid_verification_token", "The verification link is invalid, expired or already used.")
+	ErrEmailAlreadyVerified     = apperr.Conflict("email_already_verified", "Your email address is already verified.")
+)
+
+func (s *service) CreateEmailVerification(ctx context.Context, userID string, email string, ttl time.Duration) (string, error) {
+	token, err := genRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	if err := s.repo.InsertEmailVerification(ctx, hashToken(token), userID, email, time.Now().Add(ttl)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *service) VerifyEmailToken(ctx context.Context, token string) (string, string, error) {
+	return s.repo.VerifyAndMarkEmailVerification(ctx, hashToken(token))
+}
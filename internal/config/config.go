@@ -2,23 +2,55 @@ package config
 
 import (
 	"fmt"
-
-	"github.com/spf13/viper"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Jwt      JwtConfig      `mapstructure:"jwt"`
-	Redis    RedisConfig    `mapstructure:"redis"`
+	Server      ServerConfig      `mapstructure:"server"`
+	Database    DatabaseConfig    `mapstructure:"database"`
+	Jwt         JwtConfig         `mapstructure:"jwt"`
+	Redis       RedisConfig       `mapstructure:"redis"`
+	Modules     ModulesConfig     `mapstructure:"modules"`
+	Logging     LoggingConfig     `mapstructure:"logging"`
+	Scheduler   SchedulerConfig   `mapstructure:"scheduler"`
+	Events      EventsConfig      `mapstructure:"events"`
+	Idempotency IdempotencyConfig `mapstructure:"idempotency"`
+	Mfa         MfaConfig         `mapstructure:"mfa"`
+	Lockout     LockoutConfig     `mapstructure:"lockout"`
+	Password    PasswordConfig    `mapstructure:"password"`
+	RBAC        RBACConfig        `mapstructure:"rbac"`
+	APIKeys     APIKeysConfig     `mapstructure:"api_keys"`
+	Mail        MailConfig        `mapstructure:"mail"`
+
+	EmailVerification EmailVerificationConfig `mapstructure:"email_verification"`
 }
 
 type ServerConfig struct {
 	Port string `mapstructure:"port"`
 	Host string `mapstructure:"host"`
+	// MetricsPort serves /metrics on a listener of its own, so that it can be
+	// kept off the public network; empty serves no metrics.
+	MetricsPort string `mapstructure:"metrics_port"`
+
+	// ShutdownTimeout bounds how long in-flight requests are drained on shutdown.
+	ShutdownTimeout int `mapstructure:"shutdown_timeout"` // in seconds
+	// ShutdownDelay keeps serving after readiness starts failing, so load
+	// balancers stop routing new traffic before the listener closes.
+	ShutdownDelay int `mapstructure:"shutdown_delay"` // in seconds
 }
 
 type DatabaseConfig struct {
+	// Driver selects the database: postgres (default) or sqlite for local
+	// development and tests.
+	Driver string `mapstructure:"driver"`
+	// Path is the SQLite database file, or ":memory:" for a throwaway database.
+	Path string `mapstructure:"path"`
+
 	Host     string `mapstructure:"host"`
 	Port     int    `mapstructure:"port"`
 	User     string `mapstructure:"user"`
@@ -31,13 +63,46 @@ type DatabaseConfig struct {
 	MaxIdleConns    int `mapstructure:"max_idle_conns"`
 	ConnMaxLifetime int `mapstructure:"conn_max_lifetime"`  // in seconds
 	ConnMaxIdleTime int `mapstructure:"conn_max_idle_time"` // in seconds
+
+	// SlowQueryThreshold logs SQL statements slower than this as warnings.
+	SlowQueryThreshold int `mapstructure:"slow_query_threshold"` // in milliseconds
+
+	// Schema management
+	MigrateOnStart bool `mapstructure:"migrate_on_start"` // apply pending SQL migrations at startup
+	AutoMigrate    bool `mapstructure:"auto_migrate"`     // GORM AutoMigrate of module models, development only
 }
 
 type JwtConfig struct {
-	SecretKey                  string `mapstructure:"secret_key"` // must be at least 32 characters
+	// Algorithm signs access tokens: HS256 (default) with SecretKey, or RS256
+	// or EdDSA with the private key SigningKeyID.
+	Algorithm string `mapstructure:"algorithm"`
+	SecretKey string `mapstructure:"secret_key"` // must be at least 32 characters
+	// LegacyHS256Until retires SecretKey after switching to RS256 or EdDSA:
+	// from this RFC 3339 time on, HS256 tokens are rejected. Empty accepts
+	// them for as long as SecretKey is set.
+	LegacyHS256Until string `mapstructure:"legacy_hs256_until"`
+	// KeysDir holds the RS256/EdDSA key set, one PEM file named <kid>.pem per
+	// key. Private keys can sign; keys other than SigningKeyID only verify
+	// tokens until they expire, so they may be public keys.
+	KeysDir string `mapstructure:"keys_dir"`
+	// SigningKeyID is the kid of the key that signs new tokens.
+	SigningKeyID string `mapstructure:"signing_key_id"`
+	// SigningKey is the PEM private key of SigningKeyID, for deployments
+	// that pass it as a secret rather than as a file in KeysDir.
+	SigningKey string `mapstructure:"signing_key"`
+
 	Issuer                     string `mapstructure:"issuer"`
 	ExpirationHours            int    `mapstructure:"expiration_hours"`
 	RefreshTokenExpirationDays int    `mapstructure:"refresh_token_expiration_days"`
+
+	// RevocationEnabled keeps a Redis denylist of revoked access tokens, so
+	// logout, password resets, role changes and deletions take effect before
+	// the tokens expire. It needs Redis.
+	RevocationEnabled bool `mapstructure:"revocation_enabled"`
+	// RevocationFailOpen accepts access tokens when the denylist cannot be
+	// read, rather than rejecting them with 503, so a Redis outage does not
+	// lock everyone out. Revoked tokens then work until Redis is back.
+	RevocationFailOpen bool `mapstructure:"revocation_fail_open"`
 }
 
 type RedisConfig struct {
@@ -48,15 +113,600 @@ type RedisConfig struct {
 	MessageTTLHours int    `mapstructure:"message_ttl_hours"`
 }
 
+// ModulesConfig selects which feature modules the server runs.
+type ModulesConfig struct {
+	Enabled []string `mapstructure:"enabled"`
+}
+
+// redisModules lists the modules that keep their state in Redis.
+var redisModules = []string{"websocket"}
+
+// normalize trims the module names, which environment variables such as
+// EGAL_MODULES_ENABLED="user, auth" leave padded, and drops empty ones.
+func (m *ModulesConfig) normalize() {
+	enabled := m.Enabled[:0]
+	for _, name := range m.Enabled {
+		if name = strings.TrimSpace(name); name != "" {
+			enabled = append(enabled, name)
+		}
+	}
+	m.Enabled = enabled
+}
+
+// SchedulerConfig controls the background maintenance jobs.
+type SchedulerConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// StaleRoomDays deactivates chat rooms without activity for this long.
+	StaleRoomDays int `mapstructure:"stale_room_days"`
+	// DeletedUserRetentionDays permanently removes soft-deleted users after this long.
+	DeletedUserRetentionDays int `mapstructure:"deleted_user_retention_days"`
+}
+
+// Validate validates the scheduler configuration parameters.
+func (s SchedulerConfig) Validate() error {
+	if s.StaleRoomDays <= 0 {
+		return fmt.Errorf("scheduler stale room days must be positive, got %d", s.StaleRoomDays)
+	}
+
+	if s.DeletedUserRetentionDays <= 0 {
+		return fmt.Errorf("scheduler deleted user retention days must be positive, got %d", s.DeletedUserRetentionDays)
+	}
+
+	return nil
+}
+
+// EventsConfig controls delivery of domain events from the outbox.
+type EventsConfig struct {
+	// PollInterval is how often the relay looks for new events.
+	PollInterval int `mapstructure:"poll_interval"` // in milliseconds
+	// MaxAttempts dead-letters an event after this many failed deliveries.
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// RetentionDays deletes delivered events after this long.
+	RetentionDays int `mapstructure:"retention_days"`
+}
+
+// Validate validates the events configuration parameters.
+func (e EventsConfig) Validate() error {
+	if e.PollInterval <= 0 {
+		return fmt.Errorf("events poll interval must be positive, got %d", e.PollInterval)
+	}
+
+	if e.MaxAttempts <= 0 {
+		return fmt.Errorf("events max attempts must be positive, got %d", e.MaxAttempts)
+	}
+
+	if e.RetentionDays <= 0 {
+		return fmt.Errorf("events retention days must be positive, got %d", e.RetentionDays)
+	}
+
+	return nil
+}
+
+// IdempotencyConfig controls replaying of mutating requests sent with an
+// Idempotency-Key header. Stored responses live in Redis.
+type IdempotencyConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// TTLHours is how long a stored response is replayed for.
+	TTLHours int `mapstructure:"ttl_hours"`
+	// MaxBodyKB bounds the body of a request sent with a key, which is
+	// read into memory to fingerprint it; larger ones get a 413.
+	MaxBodyKB int `mapstructure:"max_body_kb"`
+}
+
+// Validate validates the idempotency configuration parameters.
+func (i IdempotencyConfig) Validate() error {
+	if i.Enabled && i.TTLHours <= 0 {
+		return fmt.Errorf("idempotency TTL hours must be positive, got %d", i.TTLHours)
+	}
+	if i.Enabled && i.MaxBodyKB <= 0 {
+		return fmt.Errorf("idempotency max body KB must be positive, got %d", i.MaxBodyKB)
+	}
+
+	return nil
+}
+
+// MfaConfig controls two-factor authentication with TOTP authenticator apps.
+type MfaConfig struct {
+	// Issuer names the account in authenticator apps.
+	Issuer string `mapstructure:"issuer"`
+	// ChallengeTTLSeconds is how long a login waits for the second factor.
+	ChallengeTTLSeconds int `mapstructure:"challenge_ttl_seconds"`
+	// MaxAttempts ends a login after this many wrong codes.
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// EncryptionKey encrypts TOTP secrets in the database. Without it they
+	// are stored in plain text.
+	EncryptionKey string `mapstructure:"encryption_key"` // at least 32 characters
+}
+
+// Validate validates the MFA configuration parameters.
+func (m MfaConfig) Validate() error {
+	if m.Issuer == "" {
+		return fmt.Errorf("MFA issuer cannot be empty")
+	}
+
+	if m.ChallengeTTLSeconds <= 0 {
+		return fmt.Errorf("MFA challenge TTL seconds must be positive, got %d", m.ChallengeTTLSeconds)
+	}
+
+	if m.MaxAttempts <= 0 {
+		return fmt.Errorf("MFA max attempts must be positive, got %d", m.MaxAttempts)
+	}
+
+	if m.EncryptionKey != "" && len(m.EncryptionKey) < 32 {
+		return fmt.Errorf("MFA encryption key must be at least 32 characters, got %d", len(m.EncryptionKey))
+	}
+
+	return nil
+}
+
+// LockoutConfig throttles password logins. Failures are counted in Redis
+// per account and per client IP; a count is forgotten WindowMinutes after
+// the last failure.
+type LockoutConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// FreeAttempts is how many failures an account may have before each
+	// further one makes the next attempt wait.
+	FreeAttempts int `mapstructure:"free_attempts"`
+	// BaseDelaySeconds is the first wait; it doubles with every further
+	// failure, up to MaxDelaySeconds.
+	BaseDelaySeconds int `mapstructure:"base_delay_seconds"`
+	MaxDelaySeconds  int `mapstructure:"max_delay_seconds"`
+	// AccountThreshold locks an account after this many failures.
+	AccountThreshold int `mapstructure:"account_threshold"`
+	// IPThreshold blocks logins from an IP after this many failures,
+	// whichever accounts they were for.
+	IPThreshold   int `mapstructure:"ip_threshold"`
+	WindowMinutes int `mapstructure:"window_minutes"`
+	// LockoutMinutes is how long a locked account or blocked IP stays so.
+	LockoutMinutes int `mapstructure:"lockout_minutes"`
+}
+
+// Validate validates the lockout configuration parameters.
+func (l LockoutConfig) Validate() error {
+	if !l.Enabled {
+		return nil
+	}
+
+	if l.FreeAttempts < 0 {
+		return fmt.Errorf("lockout free attempts must be non-negative, got %d", l.FreeAttempts)
+	}
+
+	if l.BaseDelaySeconds <= 0 {
+		return fmt.Errorf("lockout base delay seconds must be positive, got %d", l.BaseDelaySeconds)
+	}
+
+	if l.MaxDelaySeconds < l.BaseDelaySeconds {
+		return fmt.Errorf("lockout max delay seconds must be at least the base delay of %d, got %d", l.BaseDelaySeconds, l.MaxDelaySeconds)
+	}
+
+	if l.AccountThreshold <= l.FreeAttempts {
+		return fmt.Errorf("lockout account threshold must exceed the %d free attempts, got %d", l.FreeAttempts, l.AccountThreshold)
+	}
+
+	if l.IPThreshold <= 0 {
+		return fmt.Errorf("lockout IP threshold must be positive, got %d", l.IPThreshold)
+	}
+
+	if l.WindowMinutes <= 0 {
+		return fmt.Errorf("lockout window minutes must be positive, got %d", l.WindowMinutes)
+	}
+
+	if l.LockoutMinutes <= 0 {
+		return fmt.Errorf("lockout minutes must be positive, got %d", l.LockoutMinutes)
+	}
+
+	return nil
+}
+
+// Supported password hashing algorithms.
+const (
+	PasswordArgon2id = "argon2id"
+	PasswordBcrypt   = "bcrypt"
+)
+
+// PasswordConfig selects how passwords are hashed and which new passwords
+// are accepted. Hashes made with another algorithm or other parameters stay
+// valid and are replaced at the user's next login.
+type PasswordConfig struct {
+	// Algorithm hashes new passwords: argon2id or bcrypt.
+	Algorithm string `mapstructure:"algorithm"`
+	// Argon2id memory cost in KiB, passes and lanes.
+	Argon2Memory      int `mapstructure:"argon2_memory_kib"`
+	Argon2Iterations  int `mapstructure:"argon2_iterations"`
+	Argon2Parallelism int `mapstructure:"argon2_parallelism"`
+	BcryptCost        int `mapstructure:"bcrypt_cost"`
+	// MinLength is the minimum number of characters of a new password.
+	MinLength int `mapstructure:"min_length"`
+	// RejectCommon rejects passwords on the bundled list of common ones.
+	RejectCommon bool `mapstructure:"reject_common"`
+	// RejectPersonal rejects passwords containing the user's name or the
+	// local part of their email address.
+	RejectPersonal bool `mapstructure:"reject_personal"`
+	// RejectBreached looks new passwords up in the Pwned Passwords range API
+	// at BreachedAPIURL. Only the first five hex digits of the SHA-1 of a
+	// password are sent.
+	RejectBreached bool   `mapstructure:"reject_breached"`
+	BreachedAPIURL string `mapstructure:"breached_api_url"`
+}
+
+// Validate validates the password configuration parameters.
+func (p PasswordConfig) Validate() error {
+	if p.Algorithm != PasswordArgon2id && p.Algorithm != PasswordBcrypt {
+		return fmt.Errorf("password algorithm must be %s or %s, got %q", PasswordArgon2id, PasswordBcrypt, p.Algorithm)
+	}
+
+	if p.Argon2Parallelism < 1 || p.Argon2Parallelism > 255 {
+		return fmt.Errorf("password argon2 parallelism must be between 1 and 255, got %d", p.Argon2Parallelism)
+	}
+
+	// Argon2 needs at least 8 KiB per lane
+	if p.Argon2Memory < 8*p.Argon2Parallelism {
+		return fmt.Errorf("password argon2 memory must be at least %d KiB, got %d", 8*p.Argon2Parallelism, p.Argon2Memory)
+	}
+
+	if p.Argon2Iterations < 1 {
+		return fmt.Errorf("password argon2 iterations must be positive, got %d", p.Argon2Iterations)
+	}
+
+	if p.BcryptCost < 4 || p.BcryptCost > 31 {
+		return fmt.Errorf("password bcrypt cost must be between 4 and 31, got %d", p.BcryptCost)
+	}
+
+	if p.MinLength < 1 {
+		return fmt.Errorf("password min length must be positive, got %d", p.MinLength)
+	}
+
+	if p.RejectBreached && p.BreachedAPIURL == "" {
+		return fmt.Errorf("password breached API URL is required when reject_breached is enabled")
+	}
+
+	return nil
+}
+
+// RBACConfig is the authorization policy: what each role may do.
+type RBACConfig struct {
+	// Roles maps each role to the roles it inherits from and the
+	// permissions it adds to theirs. Configured roles replace the default
+	// policy as a whole.
+	Roles map[string]RoleConfig `mapstructure:"roles"`
+}
+
+// APIKeysConfig limits the API keys users create for machine clients.
+type APIKeysConfig struct {
+	// MaxTTLDays caps the lifetime of a key and is the lifetime of keys
+	// created without one; 0 allows keys that never expire.
+	MaxTTLDays int `mapstructure:"max_ttl_days"`
+	// MaxPerUser is how many unrevoked keys a user may have.
+	MaxPerUser int `mapstructure:"max_per_user"`
+}
+
+// Validate validates the API key configuration parameters.
+func (a APIKeysConfig) Validate() error {
+	if a.MaxTTLDays < 0 {
+		return fmt.Errorf("API key max TTL days cannot be negative, got %d", a.MaxTTLDays)
+	}
+
+	if a.MaxPerUser <= 0 {
+		return fmt.Errorf("API key max per user must be positive, got %d", a.MaxPerUser)
+	}
+
+	return nil
+}
+
+// RoleConfig defines one role of the authorization policy.
+type RoleConfig struct {
+	Inherits    []string `mapstructure:"inherits"`
+	Permissions []string `mapstructure:"permissions"`
+}
+
+// Validate validates the RBAC configuration parameters. The permission
+// names themselves are checked when the policy is built.
+func (r RBACConfig) Validate() error {
+	if len(r.Roles) == 0 {
+		return fmt.Errorf("rbac roles must not be empty")
+	}
+
+	for name, role := range r.Roles {
+		for _, parent := range role.Inherits {
+			if _, ok := r.Roles[parent]; !ok {
+				return fmt.Errorf("rbac role %q inherits from undefined role %q", name, parent)
+			}
+		}
+	}
+
+	// Depth-first search for a role that inherits from itself
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("rbac role %q inherits from itself", name)
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		for _, parent := range r.Roles[name].Inherits {
+			if err := visit(parent); err != nil {
+				return err
+			}
+		}
+		state[name] = done
+		return nil
+	}
+	for name := range r.Roles {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Supported mail drivers.
+const (
+	MailDriverSMTP    = "smtp"
+	MailDriverFile    = "file"
+	MailDriverConsole = "console"
+)
+
+// Supported SMTP transport security modes.
+const (
+	SMTPStartTLS = "starttls"
+	SMTPTLS      = "tls"
+	SMTPNone     = "none"
+)
+
+// MailConfig controls outgoing email.
+type MailConfig struct {
+	// Driver sends mail over SMTP, writes each message to a file in Dir or
+	// logs it (console). The file and console drivers are meant for
+	// development and tests.
+	Driver string `mapstructure:"driver"`
+	// From is the sender address, optionally with a display name.
+	From string `mapstructure:"from"`
+	// FrontendURL is the base URL of the web app that links in emails point to.
+	FrontendURL string `mapstructure:"frontend_url"`
+	// Dir receives one .eml file per message with the file driver.
+	Dir  string     `mapstructure:"dir"`
+	SMTP SMTPConfig `mapstructure:"smtp"`
+}
+
+// SMTPConfig configures the SMTP mail driver.
+type SMTPConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"` // empty disables authentication
+	Password string `mapstructure:"password"`
+	// TLS is starttls (default), tls for implicit TLS, usually on port 465,
+	// or none for local relays such as Mailpit.
+	TLS string `mapstructure:"tls"`
+}
+
+// Validate validates the mail configuration parameters.
+func (m MailConfig) Validate() error {
+	if _, err := mail.ParseAddress(m.From); err != nil {
+		return fmt.Errorf("mail from must be an email address, got %q", m.From)
+	}
+
+	u, err := url.Parse(m.FrontendURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("mail frontend URL must be an absolute http(s) URL, got %q", m.FrontendURL)
+	}
+
+	switch m.Driver {
+	case MailDriverConsole:
+	case MailDriverFile:
+		if m.Dir == "" {
+			return fmt.Errorf("mail dir is required for the file driver")
+		}
+	case MailDriverSMTP:
+		if m.SMTP.Host == "" {
+			return fmt.Errorf("SMTP host is required for the smtp driver")
+		}
+		if m.SMTP.Port <= 0 || m.SMTP.Port > 65535 {
+			return fmt.Errorf("SMTP port must be between 1 and 65535, got %d", m.SMTP.Port)
+		}
+		switch m.SMTP.TLS {
+		case SMTPStartTLS, SMTPTLS, SMTPNone:
+		default:
+			return fmt.Errorf("SMTP TLS must be starttls, tls or none, got %q", m.SMTP.TLS)
+		}
+	default:
+		return fmt.Errorf("mail driver must be smtp, file or console, got %q", m.Driver)
+	}
+
+	return nil
+}
+
+// Features an unverified email address can be blocked from.
+const (
+	FeatureChat = "chat"
+	FeatureQuiz = "quiz"
+)
+
+// EmailVerificationConfig controls the confirmation of email addresses.
+type EmailVerificationConfig struct {
+	// TokenTTLHours is how long a verification link stays valid.
+	TokenTTLHours int `mapstructure:"token_ttl_hours"`
+	// RequiredFor lists the features users cannot use before they verify
+	// their email address: chat and quiz.
+	RequiredFor []string `mapstructure:"required_for"`
+}
+
+// Requires reports whether feature is closed to unverified users.
+func (e EmailVerificationConfig) Requires(feature string) bool {
+	return slices.Contains(e.RequiredFor, feature)
+}
+
+// Validate validates the email verification configuration parameters.
+func (e EmailVerificationConfig) Validate() error {
+	if e.TokenTTLHours <= 0 {
+		return fmt.Errorf("email verification token TTL hours must be positive, got %d", e.TokenTTLHours)
+	}
+
+	for _, feature := range e.RequiredFor {
+		if feature != FeatureChat && feature != FeatureQuiz {
+			return fmt.Errorf("email verification can be required for chat or quiz, got %q", feature)
+		}
+	}
+
+	return nil
+}
+
+// Validate validates the server configuration parameters.
+func (s ServerConfig) Validate() error {
+	port, err := strconv.Atoi(s.Port)
+	if err != nil || port <= 0 || port > 65535 {
+		return fmt.Errorf("server port must be a number between 1 and 65535, got %q", s.Port)
+	}
+
+	if s.ShutdownTimeout < 0 {
+		return fmt.Errorf("server shutdown timeout must be non-negative, got %d", s.ShutdownTimeout)
+	}
+
+	if s.ShutdownDelay < 0 {
+		return fmt.Errorf("server shutdown delay must be non-negative, got %d", s.ShutdownDelay)
+	}
+
+	return nil
+}
+
+// Supported JWT signing algorithms.
+const (
+	JwtHS256 = "HS256"
+	JwtRS256 = "RS256"
+	JwtEdDSA = "EdDSA"
+)
+
+// Supported database drivers.
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// IsSQLite reports whether the SQLite driver is selected.
+func (d DatabaseConfig) IsSQLite() bool {
+	return d.Driver == DriverSQLite
+}
+
+// sslModes are the sslmode values accepted by PostgreSQL.
+var sslModes = map[string]bool{
+	"disable": true, "allow": true, "prefer": true,
+	"require": true, "verify-ca": true, "verify-full": true,
+}
+
+// Validate validates the database configuration parameters.
+func (d DatabaseConfig) Validate() error {
+	switch d.Driver {
+	case "", DriverPostgres:
+	case DriverSQLite:
+		if d.Path == "" {
+			return fmt.Errorf("database path is required for the sqlite driver")
+		}
+		return d.validatePool()
+	default:
+		return fmt.Errorf("database driver must be postgres or sqlite, got %q", d.Driver)
+	}
+
+	if d.Host == "" {
+		return fmt.Errorf("database host is required")
+	}
+
+	if d.Port <= 0 || d.Port > 65535 {
+		return fmt.Errorf("database port must be between 1 and 65535, got %d", d.Port)
+	}
+
+	if d.DBName == "" {
+		return fmt.Errorf("database name is required")
+	}
+
+	if d.User == "" {
+		return fmt.Errorf("database user is required")
+	}
+
+	if d.SSLMode != "" && !sslModes[d.SSLMode] {
+		return fmt.Errorf("database sslmode %q is not supported", d.SSLMode)
+	}
+
+	return d.validatePool()
+}
+
+// validatePool validates the driver-independent pool and logging settings.
+func (d DatabaseConfig) validatePool() error {
+	if d.MaxOpenConns < 0 || d.MaxIdleConns < 0 {
+		return fmt.Errorf("database connection pool sizes must be non-negative")
+	}
+
+	if d.MaxOpenConns > 0 && d.MaxIdleConns > d.MaxOpenConns {
+		return fmt.Errorf("database max idle connections (%d) cannot exceed max open connections (%d)", d.MaxIdleConns, d.MaxOpenConns)
+	}
+
+	if d.ConnMaxLifetime < 0 || d.ConnMaxIdleTime < 0 {
+		return fmt.Errorf("database connection lifetimes must be non-negative")
+	}
+
+	if d.SlowQueryThreshold < 0 {
+		return fmt.Errorf("database slow query threshold must be non-negative, got %d", d.SlowQueryThreshold)
+	}
+
+	return nil
+}
+
+// LoggingConfig controls the format and verbosity of the application logs.
+type LoggingConfig struct {
+	Level  string `mapstructure:"level"`  // debug, info, warn or error
+	Format string `mapstructure:"format"` // json or console
+}
+
+// Validate validates the logging configuration parameters.
+func (l LoggingConfig) Validate() error {
+	switch l.Format {
+	case "json", "console":
+	default:
+		return fmt.Errorf("logging format must be json or console, got %q", l.Format)
+	}
+
+	switch l.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("logging level must be debug, info, warn or error, got %q", l.Level)
+	}
+
+	return nil
+}
+
 // Validate validates the JWT configuration parameters.
 func (j JwtConfig) Validate() error {
-	if j.SecretKey == "" {
-		return fmt.Errorf("jwt secret key cannot be empty")
+	switch j.Algorithm {
+	case "", JwtHS256:
+		if j.SecretKey == "" {
+			return fmt.Errorf("jwt secret key cannot be empty")
+		}
+	case JwtRS256, JwtEdDSA:
+		if j.SigningKeyID == "" {
+			return fmt.Errorf("jwt signing key ID is required for %s", j.Algorithm)
+		}
+		if j.KeysDir == "" && j.SigningKey == "" {
+			return fmt.Errorf("jwt keys dir or signing key is required for %s", j.Algorithm)
+		}
+	default:
+		return fmt.Errorf("jwt algorithm must be %s, %s or %s, got %q", JwtHS256, JwtRS256, JwtEdDSA, j.Algorithm)
 	}
 
-	if len(j.SecretKey) < 32 {
+	// With an asymmetric algorithm the secret only verifies tokens issued
+	// before the switch
+	if j.SecretKey != "" && len(j.SecretKey) < 32 {
 		return fmt.Errorf("jwt secret key must be at least 32 characters for security, got %d", len(j.SecretKey))
 	}
+	if j.LegacyHS256Until != "" {
+		if _, err := time.Parse(time.RFC3339, j.LegacyHS256Until); err != nil {
+			return fmt.Errorf("jwt legacy HS256 until must be an RFC 3339 time: %w", err)
+		}
+	}
 
 	if j.ExpirationHours <= 0 {
 		return fmt.Errorf("jwt expiration hours must be positive, got %d", j.ExpirationHours)
@@ -93,29 +743,84 @@ func (r RedisConfig) Validate() error {
 	return nil
 }
 
-func LoadConfig() (*Config, error) {
-	viper.AddConfigPath(".")
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
+// NeedsRedis reports whether an enabled feature requires Redis: a module that
+// keeps its state there, idempotency, token revocation or login lockout.
+func (c *Config) NeedsRedis() bool {
+	for _, name := range c.Modules.Enabled {
+		if slices.Contains(redisModules, name) {
+			return true
+		}
+	}
+	return c.Idempotency.Enabled || c.Jwt.RevocationEnabled || c.Lockout.Enabled
+}
+
+// Validate validates every section of the configuration.
+func (c *Config) Validate() error {
+	if err := c.Server.Validate(); err != nil {
+		return fmt.Errorf("invalid server configuration: %w", err)
+	}
+
+	if err := c.Database.Validate(); err != nil {
+		return fmt.Errorf("invalid database configuration: %w", err)
+	}
 
-	if err := viper.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+	if err := c.Jwt.Validate(); err != nil {
+		return fmt.Errorf("invalid JWT configuration: %w", err)
 	}
 
-	var cfg Config
-	if err := viper.Unmarshal(&cfg); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	if c.NeedsRedis() {
+		if err := c.Redis.Validate(); err != nil {
+			return fmt.Errorf("invalid Redis configuration: %w", err)
+		}
 	}
 
-	// Validate JWT configuration
-	if err := cfg.Jwt.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid JWT configuration: %w", err)
+	if err := c.Logging.Validate(); err != nil {
+		return fmt.Errorf("invalid logging configuration: %w", err)
 	}
 
-	// Validate Redis configuration
-	if err := cfg.Redis.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid Redis configuration: %w", err)
+	if err := c.Scheduler.Validate(); err != nil {
+		return fmt.Errorf("invalid scheduler configuration: %w", err)
 	}
 
-	return &cfg, nil
+	if err := c.Events.Validate(); err != nil {
+		return fmt.Errorf("invalid events configuration: %w", err)
+	}
+
+	if err := c.Idempotency.Validate(); err != nil {
+		return fmt.Errorf("invalid idempotency configuration: %w", err)
+	}
+
+	if err := c.Mfa.Validate(); err != nil {
+		return fmt.Errorf("invalid MFA configuration: %w", err)
+	}
+
+	if err := c.Lockout.Validate(); err != nil {
+		return fmt.Errorf("invalid lockout configuration: %w", err)
+	}
+
+	if err := c.Password.Validate(); err != nil {
+		return fmt.Errorf("invalid password configuration: %w", err)
+	}
+
+	if err := c.RBAC.Validate(); err != nil {
+		return fmt.Errorf("invalid RBAC configuration: %w", err)
+	}
+
+	if err := c.APIKeys.Validate(); err != nil {
+		return fmt.Errorf("invalid API key configuration: %w", err)
+	}
+
+	if err := c.Mail.Validate(); err != nil {
+		return fmt.Errorf("invalid mail configuration: %w", err)
+	}
+
+	if err := c.EmailVerification.Validate(); err != nil {
+		return fmt.Errorf("invalid email verification configuration: %w", err)
+	}
+
+	if len(c.EmailVerification.RequiredFor) > 0 && !slices.Contains(c.Modules.Enabled, "user") {
+		return fmt.Errorf("invalid email verification configuration: requiring a verified email address needs the user module")
+	}
+
+	return nil
 }
@@ -32,6 +32,9 @@ jwt:
   issuer: egaldeutsch
   expiration_hours: 24
   refresh_token_expiration_days: 30
+redis:
+  host: localhost
+  port: 6379
 `
 	fpath := filepath.Join(dir, "config.yaml")
 	if err := ioutil.WriteFile(fpath, []byte(content), 0644); err != nil {
@@ -82,7 +85,7 @@ func TestJwtConfigValidation(t *testing.T) {
 				RefreshTokenExpirationDays: 30,
 			},
 			shouldErr: true,
-			errorMsg:  "JWT secret key cannot be empty",
+			errorMsg:  "jwt secret key cannot be empty",
 		},
 		{
 			name: "short secret key",
@@ -93,7 +96,19 @@ func TestJwtConfigValidation(t *testing.T) {
 				RefreshTokenExpirationDays: 30,
 			},
 			shouldErr: true,
-			errorMsg:  "JWT secret key must be at least 32 characters",
+			errorMsg:  "jwt secret key must be at least 32 characters",
+		},
+		{
+			name: "invalid legacy HS256 retirement",
+			config: cfgpkg.JwtConfig{
+				SecretKey:                  "this-is-a-very-secure-secret-key-with-32-plus-characters",
+				LegacyHS256Until:           "next week",
+				Issuer:                     "egaldeutsch",
+				ExpirationHours:            24,
+				RefreshTokenExpirationDays: 30,
+			},
+			shouldErr: true,
+			errorMsg:  "jwt legacy HS256 until must be an RFC 3339 time",
 		},
 		{
 			name: "empty issuer",
@@ -104,7 +119,7 @@ func TestJwtConfigValidation(t *testing.T) {
 				RefreshTokenExpirationDays: 30,
 			},
 			shouldErr: true,
-			errorMsg:  "JWT issuer cannot be empty",
+			errorMsg:  "jwt issuer cannot be empty",
 		},
 		{
 			name: "zero expiration hours",
@@ -115,7 +130,7 @@ func TestJwtConfigValidation(t *testing.T) {
 				RefreshTokenExpirationDays: 30,
 			},
 			shouldErr: true,
-			errorMsg:  "JWT expiration hours must be positive",
+			errorMsg:  "jwt expiration hours must be positive",
 		},
 		{
 			name: "too long expiration hours",
@@ -126,7 +141,43 @@ func TestJwtConfigValidation(t *testing.T) {
 				RefreshTokenExpirationDays: 30,
 			},
 			shouldErr: true,
-			errorMsg:  "JWT expiration hours too long",
+			errorMsg:  "jwt expiration hours too long",
+		},
+		{
+			name: "EdDSA without a secret",
+			config: cfgpkg.JwtConfig{
+				Algorithm:                  cfgpkg.JwtEdDSA,
+				KeysDir:                    "/run/secrets/jwt",
+				SigningKeyID:               "2026-10",
+				Issuer:                     "egaldeutsch",
+				ExpirationHours:            24,
+				RefreshTokenExpirationDays: 30,
+			},
+			shouldErr: false,
+		},
+		{
+			name: "RS256 without a signing key ID",
+			config: cfgpkg.JwtConfig{
+				Algorithm:                  cfgpkg.JwtRS256,
+				KeysDir:                    "/run/secrets/jwt",
+				Issuer:                     "egaldeutsch",
+				ExpirationHours:            24,
+				RefreshTokenExpirationDays: 30,
+			},
+			shouldErr: true,
+			errorMsg:  "jwt signing key ID is required",
+		},
+		{
+			name: "unknown algorithm",
+			config: cfgpkg.JwtConfig{
+				Algorithm:                  "HS512",
+				SecretKey:                  "this-is-a-very-secure-secret-key-with-32-plus-characters",
+				Issuer:                     "egaldeutsch",
+				ExpirationHours:            24,
+				RefreshTokenExpirationDays: 30,
+			},
+			shouldErr: true,
+			errorMsg:  "jwt algorithm must be",
 		},
 		{
 			name: "zero refresh token expiration days",
@@ -160,3 +211,197 @@ func TestJwtConfigValidation(t *testing.T) {
 		})
 	}
 }
+
+// writeConfigDir writes config files into a temporary directory.
+func writeConfigDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+const baseConfig = `server:
+  port: "8080"
+  host: localhost
+database:
+  host: localhost
+  port: 5432
+  user: postgres
+  dbname: egaldeutsch
+  sslmode: disable
+redis:
+  host: localhost
+  port: 6379
+jwt:
+  issuer: egaldeutsch
+  expiration_hours: 24
+  refresh_token_expiration_days: 30
+`
+
+func TestLoadWithOptionsProfileEnvAndSecretFiles(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{
+		"config.yaml": baseConfig,
+		"config.prod.yaml": `database:
+  sslmode: require
+logging:
+  format: json
+`,
+		"jwt_secret": "secret-from-a-docker-secret-file-0123456789\n",
+	})
+
+	t.Setenv(cfgpkg.ProfileEnv, "prod")
+	t.Setenv("EGAL_DATABASE_PASSWORD", "from-env")
+	t.Setenv("EGAL_SERVER_PORT", "9090")
+	t.Setenv("EGAL_JWT_SECRET_KEY_FILE", filepath.Join(dir, "jwt_secret"))
+	t.Setenv("EGAL_MODULES_ENABLED", "user,auth")
+
+	cfg, err := cfgpkg.LoadWithOptions(cfgpkg.LoadOptions{Dir: dir})
+	if err != nil {
+		t.Fatalf("LoadWithOptions failed: %v", err)
+	}
+
+	if cfg.Database.SSLMode != "require" || cfg.Logging.Format != "json" {
+		t.Fatalf("profile not merged: sslmode=%q format=%q", cfg.Database.SSLMode, cfg.Logging.Format)
+	}
+	if cfg.Database.Host != "localhost" {
+		t.Fatalf("base value lost after merge: %q", cfg.Database.Host)
+	}
+	if cfg.Database.Password != "from-env" || cfg.Server.Port != "9090" {
+		t.Fatalf("env overrides not applied: %+v %+v", cfg.Database, cfg.Server)
+	}
+	if cfg.Jwt.SecretKey != "secret-from-a-docker-secret-file-0123456789" {
+		t.Fatalf("secret file not applied or not trimmed: %q", cfg.Jwt.SecretKey)
+	}
+	if strings.Join(cfg.Modules.Enabled, ",") != "user,auth" {
+		t.Fatalf("expected list override got %v", cfg.Modules.Enabled)
+	}
+}
+
+func TestLoadWithOptionsUnknownProfile(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{"config.yaml": baseConfig})
+	t.Setenv("EGAL_JWT_SECRET_KEY", "this-is-a-very-secure-secret-key-with-32-plus-characters")
+
+	_, err := cfgpkg.LoadWithOptions(cfgpkg.LoadOptions{Dir: dir, Profile: "staging"})
+	if err == nil || !strings.Contains(err.Error(), `profile "staging"`) {
+		t.Fatalf("expected missing profile error got %v", err)
+	}
+}
+
+func TestRedisValidatedOnlyWhenNeeded(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{"config.yaml": baseConfig})
+	t.Setenv("EGAL_JWT_SECRET_KEY", "this-is-a-very-secure-secret-key-with-32-plus-characters")
+	t.Setenv("EGAL_REDIS_PORT", "0")
+	t.Setenv("EGAL_MODULES_ENABLED", "user,auth,quiz")
+	t.Setenv("EGAL_JWT_REVOCATION_ENABLED", "false")
+	t.Setenv("EGAL_LOCKOUT_ENABLED", "false")
+	t.Setenv("EGAL_IDEMPOTENCY_ENABLED", "false")
+
+	if _, err := cfgpkg.LoadWithOptions(cfgpkg.LoadOptions{Dir: dir}); err != nil {
+		t.Fatalf("expected Redis to be optional without features that need it, got %v", err)
+	}
+
+	for _, enabled := range []string{"user,auth,quiz,websocket", "user, auth, quiz, websocket"} {
+		t.Setenv("EGAL_MODULES_ENABLED", enabled)
+		_, err := cfgpkg.LoadWithOptions(cfgpkg.LoadOptions{Dir: dir})
+		if err == nil || !strings.Contains(err.Error(), "redis port must be positive") {
+			t.Fatalf("modules %q: expected Redis validation error got %v", enabled, err)
+		}
+	}
+}
+
+func TestServerAndDatabaseValidation(t *testing.T) {
+	validDB := cfgpkg.DatabaseConfig{Host: "localhost", Port: 5432, User: "postgres", DBName: "eg", SSLMode: "disable"}
+
+	tests := []struct {
+		name     string
+		validate func() error
+		errorMsg string
+	}{
+		{"valid server", cfgpkg.ServerConfig{Port: "8080"}.Validate, ""},
+		{"non numeric port", cfgpkg.ServerConfig{Port: "http"}.Validate, "server port must be a number"},
+		{"negative shutdown timeout", cfgpkg.ServerConfig{Port: "8080", ShutdownTimeout: -1}.Validate, "shutdown timeout"},
+		{"valid database", validDB.Validate, ""},
+		{"missing host", func() error { d := validDB; d.Host = ""; return d.Validate() }, "database host is required"},
+		{"bad sslmode", func() error { d := validDB; d.SSLMode = "on"; return d.Validate() }, "sslmode"},
+		{"idle above open", func() error { d := validDB; d.MaxOpenConns = 5; d.MaxIdleConns = 10; return d.Validate() }, "cannot exceed"},
+		{"negative lifetime", func() error { d := validDB; d.ConnMaxLifetime = -1; return d.Validate() }, "lifetimes"},
+		{"valid sqlite", cfgpkg.DatabaseConfig{Driver: cfgpkg.DriverSQLite, Path: ":memory:"}.Validate, ""},
+		{"sqlite without path", cfgpkg.DatabaseConfig{Driver: cfgpkg.DriverSQLite}.Validate, "database path is required"},
+		{"unknown driver", func() error { d := validDB; d.Driver = "mysql"; return d.Validate() }, "database driver must be"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.validate()
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Fatalf("expected error containing '%s', got %v", tt.errorMsg, err)
+			}
+		})
+	}
+}
+
+func TestMarshalRedactedYAML(t *testing.T) {
+	cfg := cfgpkg.Config{
+		Database: cfgpkg.DatabaseConfig{Host: "db", Password: "pg-secret"},
+		Jwt:      cfgpkg.JwtConfig{SecretKey: "jwt-secret"},
+	}
+
+	out, err := cfg.MarshalRedactedYAML()
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	s := string(out)
+	if strings.Contains(s, "pg-secret") || strings.Contains(s, "jwt-secret") {
+		t.Fatalf("secret leaked:\n%s", s)
+	}
+	if !strings.Contains(s, "secret_key: '[REDACTED]'") || !strings.Contains(s, "host: db") {
+		t.Fatalf("unexpected output:\n%s", s)
+	}
+	if cfg.Database.Password != "pg-secret" {
+		t.Fatalf("Redacted must not modify the original")
+	}
+}
+
+func TestLoadRBACReplacesDefaultRoles(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{
+		"config.yaml": baseConfig + `  secret_key: this-is-a-very-secure-secret-key-with-32-plus-characters
+rbac:
+  roles:
+    learner:
+      permissions: [chat:write]
+    admin:
+      inherits: [learner]
+      permissions: [quiz:write, users:read]
+`,
+	})
+
+	cfg, err := cfgpkg.LoadWithOptions(cfgpkg.LoadOptions{Dir: dir})
+	if err != nil {
+		t.Fatalf("LoadWithOptions failed: %v", err)
+	}
+	if len(cfg.RBAC.Roles) != 2 || strings.Join(cfg.RBAC.Roles["admin"].Permissions, ",") != "quiz:write,users:read" {
+		t.Fatalf("expected exactly the configured roles, got %+v", cfg.RBAC.Roles)
+	}
+
+	cycle := cfgpkg.RBACConfig{Roles: map[string]cfgpkg.RoleConfig{
+		"learner": {Inherits: []string{"admin"}},
+		"admin":   {Inherits: []string{"learner"}},
+	}}
+	if err := cycle.Validate(); err == nil || !strings.Contains(err.Error(), "inherits from itself") {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+	undefined := cfgpkg.RBACConfig{Roles: map[string]cfgpkg.RoleConfig{"admin": {Inherits: []string{"root"}}}}
+	if err := undefined.Validate(); err == nil {
+		t.Fatalf("expected an error for an undefined parent role")
+	}
+}
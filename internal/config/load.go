@@ -0,0 +1,224 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix prefixes every environment variable override, for example
+// EGAL_DATABASE_PASSWORD overrides database.password.
+const EnvPrefix = "EGAL"
+
+// ProfileEnv selects the profile when no profile is passed explicitly.
+const ProfileEnv = EnvPrefix + "_PROFILE"
+
+// fileSuffix marks an environment variable that holds the path of a file
+// containing the value, as used by Docker secrets: EGAL_JWT_SECRET_KEY_FILE.
+const fileSuffix = "_FILE"
+
+// LoadOptions controls where LoadWithOptions reads configuration from.
+type LoadOptions struct {
+	// Dir holds config.yaml and the profile files. Defaults to ".".
+	Dir string
+	// Profile selects config.<profile>.yaml, merged over config.yaml.
+	// Defaults to the EGAL_PROFILE environment variable.
+	Profile string
+}
+
+// LoadConfig loads ./config.yaml, the profile named by EGAL_PROFILE and the
+// environment overrides, and validates the result.
+func LoadConfig() (*Config, error) {
+	return LoadWithOptions(LoadOptions{})
+}
+
+// LoadWithOptions loads configuration in increasing order of precedence:
+// defaults, config.yaml, config.<profile>.yaml, EGAL_* environment variables
+// and EGAL_*_FILE secret files. The result is validated.
+func LoadWithOptions(opts LoadOptions) (*Config, error) {
+	if opts.Dir == "" {
+		opts.Dir = "."
+	}
+	if opts.Profile == "" {
+		opts.Profile = os.Getenv(ProfileEnv)
+	}
+
+	v := viper.New()
+	setDefaults(v)
+
+	v.SetConfigFile(filepath.Join(opts.Dir, "config.yaml"))
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if opts.Profile != "" {
+		profileFile := filepath.Join(opts.Dir, "config."+opts.Profile+".yaml")
+		v.SetConfigFile(profileFile)
+		if err := v.MergeInConfig(); err != nil {
+			return nil, fmt.Errorf("failed to read profile %q: %w", opts.Profile, err)
+		}
+	}
+
+	keys := configKeys(reflect.TypeOf(Config{}), "")
+	if err := bindEnv(v, keys); err != nil {
+		return nil, err
+	}
+	if err := applySecretFiles(v, keys); err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+	cfg.Modules.normalize()
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// setDefaults registers fallback values for optional settings.
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("server.metrics_port", "9090")
+	v.SetDefault("server.shutdown_timeout", 30)
+	v.SetDefault("server.shutdown_delay", 0)
+	v.SetDefault("database.driver", DriverPostgres)
+	v.SetDefault("database.migrate_on_start", true)
+	v.SetDefault("database.auto_migrate", false)
+	v.SetDefault("database.slow_query_threshold", 1000)
+	v.SetDefault("database.conn_max_lifetime", 300)
+	v.SetDefault("database.conn_max_idle_time", 60)
+	v.SetDefault("jwt.algorithm", JwtHS256)
+	v.SetDefault("jwt.revocation_enabled", true)
+	v.SetDefault("jwt.revocation_fail_open", false)
+	v.SetDefault("modules.enabled", []string{"user", "auth", "audit", "quiz", "websocket"})
+	v.SetDefault("logging.level", "info")
+	v.SetDefault("logging.format", "json")
+	v.SetDefault("scheduler.enabled", true)
+	v.SetDefault("scheduler.stale_room_days", 30)
+	v.SetDefault("scheduler.deleted_user_retention_days", 30)
+	v.SetDefault("events.poll_interval", 1000)
+	v.SetDefault("events.max_attempts", 10)
+	v.SetDefault("events.retention_days", 7)
+	v.SetDefault("idempotency.enabled", true)
+	v.SetDefault("idempotency.ttl_hours", 24)
+	v.SetDefault("idempotency.max_body_kb", 1024)
+	v.SetDefault("mfa.issuer", "EgalDeutsch")
+	v.SetDefault("mfa.challenge_ttl_seconds", 300)
+	v.SetDefault("mfa.max_attempts", 5)
+	v.SetDefault("lockout.enabled", true)
+	v.SetDefault("lockout.free_attempts", 3)
+	v.SetDefault("lockout.base_delay_seconds", 1)
+	v.SetDefault("lockout.max_delay_seconds", 30)
+	v.SetDefault("lockout.account_threshold", 10)
+	v.SetDefault("lockout.ip_threshold", 100)
+	v.SetDefault("lockout.window_minutes", 15)
+	v.SetDefault("lockout.lockout_minutes", 15)
+	v.SetDefault("password.algorithm", PasswordArgon2id)
+	v.SetDefault("password.argon2_memory_kib", 19456)
+	v.SetDefault("password.argon2_iterations", 2)
+	v.SetDefault("password.argon2_parallelism", 1)
+	v.SetDefault("password.bcrypt_cost", 12)
+	v.SetDefault("password.min_length", 10)
+	v.SetDefault("password.reject_common", true)
+	v.SetDefault("password.reject_personal", true)
+	v.SetDefault("password.reject_breached", false)
+	v.SetDefault("password.breached_api_url", "https://api.pwnedpasswords.com/range/")
+	v.SetDefault("rbac.roles", map[string]any{
+		"learner": map[string]any{
+			"permissions": []string{"chat:write"},
+		},
+		"teacher": map[string]any{
+			"inherits":    []string{"learner"},
+			"permissions": []string{"quiz:write"},
+		},
+		"moderator": map[string]any{
+			"inherits":    []string{"learner"},
+			"permissions": []string{"chat:moderate", "users:read"},
+		},
+		"admin": map[string]any{
+			"inherits":    []string{"teacher", "moderator"},
+			"permissions": []string{"users:write", "audit:read", "system:manage"},
+		},
+	})
+	v.SetDefault("api_keys.max_ttl_days", 365)
+	v.SetDefault("api_keys.max_per_user", 10)
+	v.SetDefault("mail.driver", MailDriverConsole)
+	v.SetDefault("mail.from", "EgalDeutsch <no-reply@localhost>")
+	v.SetDefault("mail.frontend_url", "http://localhost:3000")
+	v.SetDefault("mail.smtp.port", 587)
+	v.SetDefault("mail.smtp.tls", SMTPStartTLS)
+	v.SetDefault("email_verification.token_ttl_hours", 48)
+	v.SetDefault("email_verification.required_for", []string{})
+}
+
+// EnvVar returns the environment variable that overrides the given key,
+// for example "database.password" -> "EGAL_DATABASE_PASSWORD".
+func EnvVar(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// bindEnv binds every configuration key to its EGAL_* variable. Keys are
+// bound explicitly because viper's AutomaticEnv does not apply to Unmarshal
+// for keys missing from the config files.
+func bindEnv(v *viper.Viper, keys []string) error {
+	for _, key := range keys {
+		if err := v.BindEnv(key, EnvVar(key)); err != nil {
+			return fmt.Errorf("bind %s: %w", EnvVar(key), err)
+		}
+	}
+	return nil
+}
+
+// applySecretFiles reads values from files named by EGAL_*_FILE variables.
+// A file takes precedence over the plain variable; trailing newlines are
+// trimmed since secret files usually end with one.
+func applySecretFiles(v *viper.Viper, keys []string) error {
+	for _, key := range keys {
+		path := os.Getenv(EnvVar(key) + fileSuffix)
+		if path == "" {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", EnvVar(key)+fileSuffix, err)
+		}
+		value := strings.TrimRight(string(content), "\r\n")
+		if value == "" {
+			return errors.New(EnvVar(key) + fileSuffix + " points to an empty file")
+		}
+		v.Set(key, value)
+	}
+	return nil
+}
+
+// configKeys lists the dotted keys of all leaf fields, derived from the
+// mapstructure tags.
+func configKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("mapstructure"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		key := tag
+		if prefix != "" {
+			key = prefix + "." + tag
+		}
+		if f.Type.Kind() == reflect.Struct {
+			keys = append(keys, configKeys(f.Type, key)...)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
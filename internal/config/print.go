@@ -0,0 +1,79 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redactedValue replaces secrets in printed configuration.
+const redactedValue = "[REDACTED]"
+
+// Redacted returns a copy of the configuration with every secret replaced.
+// Empty secrets stay empty so a missing secret is still visible.
+func (c Config) Redacted() Config {
+	redact := func(s *string) {
+		if *s != "" {
+			*s = redactedValue
+		}
+	}
+	redact(&c.Database.Password)
+	redact(&c.Jwt.SecretKey)
+	redact(&c.Jwt.SigningKey)
+	redact(&c.Redis.Password)
+	redact(&c.Mfa.EncryptionKey)
+	redact(&c.Mail.SMTP.Password)
+	c.Modules.Enabled = append([]string(nil), c.Modules.Enabled...)
+	c.EmailVerification.RequiredFor = append([]string(nil), c.EmailVerification.RequiredFor...)
+	return c
+}
+
+// MarshalRedactedYAML renders the effective configuration as YAML with the
+// same keys as config.yaml, and with secrets redacted.
+func (c Config) MarshalRedactedYAML() ([]byte, error) {
+	node, err := toYAMLNode(reflect.ValueOf(c.Redacted()))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// toYAMLNode converts a config struct into a mapping node keyed by the
+// mapstructure tags, preserving field order.
+func toYAMLNode(v reflect.Value) (*yaml.Node, error) {
+	if v.Kind() != reflect.Struct {
+		node := &yaml.Node{}
+		if err := node.Encode(v.Interface()); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("mapstructure"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		value, err := toYAMLNode(v.Field(i))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", tag, err)
+		}
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: tag}, value)
+	}
+	return mapping, nil
+}
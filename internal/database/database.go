@@ -1,18 +1,26 @@
 package database
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
-	gormlogger "gorm.io/gorm/logger"
 
 	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/migrate"
+	"egaldeutsch-be/migrations"
 )
 
+// ErrSQLMigrationsUnsupported is returned by SchemaMigrator on SQLite; the
+// versioned migrations use PostgreSQL-only SQL, so SQLite schemas are created
+// with GORM AutoMigrate instead.
+var ErrSQLMigrationsUnsupported = errors.New("versioned SQL migrations are PostgreSQL-only; sqlite uses AutoMigrate")
+
 type Database struct {
 	*gorm.DB
 }
@@ -20,7 +28,7 @@ type Database struct {
 // NewDatabase creates a new database connection using the provided configuration.
 // It follows Go philosophy by being explicit about dependencies and failure modes.
 func NewDatabase(cfg config.DatabaseConfig) (*Database, error) {
-	if err := validateDatabaseConfig(cfg); err != nil {
+	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid database config: %w", err)
 	}
 
@@ -33,33 +41,60 @@ func NewDatabase(cfg config.DatabaseConfig) (*Database, error) {
 		return nil, fmt.Errorf("failed to configure connection pool: %w", err)
 	}
 
-	logrus.Info("Successfully connected to database")
+	logrus.WithField("driver", db.Dialector.Name()).Info("Successfully connected to database")
 	return &Database{db}, nil
 }
 
-// validateDatabaseConfig ensures required database configuration is present.
-func validateDatabaseConfig(cfg config.DatabaseConfig) error {
-	if cfg.Host == "" {
-		return fmt.Errorf("database host is required")
+// Close releases every connection held by the underlying pool.
+func (d *Database) Close() error {
+	sqlDB, err := d.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// Ping verifies that a connection to the database can be established.
+func (d *Database) Ping(ctx context.Context) error {
+	sqlDB, err := d.DB.DB()
+	if err != nil {
+		return err
 	}
-	if cfg.DBName == "" {
-		return fmt.Errorf("database name is required")
+	return sqlDB.PingContext(ctx)
+}
+
+// IsSQLite reports whether the connection uses the SQLite driver.
+func (d *Database) IsSQLite() bool {
+	return d.Dialector.Name() == config.DriverSQLite
+}
+
+// SchemaMigrator returns a migration runner for the embedded migrations/*.sql files.
+func (d *Database) SchemaMigrator() (*migrate.Migrator, error) {
+	if d.IsSQLite() {
+		return nil, ErrSQLMigrationsUnsupported
 	}
-	if cfg.User == "" {
-		return fmt.Errorf("database user is required")
+	sqlDB, err := d.DB.DB()
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return migrate.New(sqlDB, migrations.FS)
 }
 
 // openDatabase establishes the database connection with proper logging configuration.
 func openDatabase(cfg config.DatabaseConfig) (*gorm.DB, error) {
-	connStr := buildConnectionString(cfg)
-
 	gormConfig := &gorm.Config{
-		Logger: createGormLogger(),
+		Logger: newGormLogger(time.Duration(cfg.SlowQueryThreshold) * time.Millisecond),
+		// Report constraint violations as gorm.ErrDuplicatedKey and
+		// gorm.ErrForeignKeyViolated on every driver
+		TranslateError: true,
 	}
 
-	db, err := gorm.Open(postgres.Open(connStr), gormConfig)
+	dialector := postgres.Open(buildConnectionString(cfg))
+	if cfg.IsSQLite() {
+		dialector = sqlite.Open(buildSQLiteDSN(cfg.Path))
+	}
+
+	db, err := gorm.Open(dialector, gormConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -85,17 +120,10 @@ func buildConnectionString(cfg config.DatabaseConfig) string {
 	)
 }
 
-// createGormLogger creates a GORM logger that integrates with logrus.
-func createGormLogger() gormlogger.Interface {
-	return gormlogger.New(
-		newLogrusWriter(logrus.StandardLogger().Out),
-		gormlogger.Config{
-			SlowThreshold:             time.Second,
-			LogLevel:                  gormlogger.Warn,
-			IgnoreRecordNotFoundError: true,
-			Colorful:                  false,
-		},
-	)
+// buildSQLiteDSN enables foreign keys and a busy timeout. ":memory:" databases
+// are private to one connection, which the pool settings account for.
+func buildSQLiteDSN(path string) string {
+	return fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000", path)
 }
 
 // configureConnectionPool sets up database connection pool with the provided settings.
@@ -116,24 +144,27 @@ func configureConnectionPool(db *gorm.DB, cfg config.DatabaseConfig) error {
 		maxIdle = 10 // sensible default
 	}
 
-	sqlDB.SetMaxOpenConns(maxOpen)
-	sqlDB.SetMaxIdleConns(maxIdle)
-	sqlDB.SetConnMaxLifetime(5 * time.Minute)
-	sqlDB.SetConnMaxIdleTime(1 * time.Minute)
+	maxLifetime := time.Duration(cfg.ConnMaxLifetime) * time.Second
+	if maxLifetime <= 0 {
+		maxLifetime = 5 * time.Minute // sensible default
+	}
 
-	return nil
-}
+	maxIdleTime := time.Duration(cfg.ConnMaxIdleTime) * time.Second
+	if maxIdleTime <= 0 {
+		maxIdleTime = time.Minute // sensible default
+	}
 
-// newLogrusWriter adapts an io.Writer (logrus.Out) to gorm's logger writer expectations.
-type logrusWriter struct {
-	w io.Writer
-}
+	// SQLite allows a single writer; one connection serializes transactions
+	// (standing in for FOR UPDATE) and keeps a ":memory:" database alive
+	if cfg.IsSQLite() {
+		maxOpen, maxIdle = 1, 1
+		maxLifetime, maxIdleTime = 0, 0 // never recycle the only connection
+	}
 
-func newLogrusWriter(w io.Writer) *logrusWriter {
-	return &logrusWriter{w: w}
-}
+	sqlDB.SetMaxOpenConns(maxOpen)
+	sqlDB.SetMaxIdleConns(maxIdle)
+	sqlDB.SetConnMaxLifetime(maxLifetime)
+	sqlDB.SetConnMaxIdleTime(maxIdleTime)
 
-// Printf satisfies the logger writer interface used by gorm's logger.
-func (lw *logrusWriter) Printf(format string, args ...interface{}) {
-	logrus.Infof(format, args...)
+	return nil
 }
@@ -2,18 +2,105 @@ package database
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"egaldeutsch-be/internal/logging"
 )
 
-func TestLogrusWriterPrintf(t *testing.T) {
-	// Ensure Printf doesn't panic and logs via logrus
+// captureLogs redirects the standard logger to a buffer as JSON lines.
+func captureLogs(t *testing.T, level logrus.Level) *bytes.Buffer {
+	t.Helper()
 	var buf bytes.Buffer
-	logrus.SetOutput(&buf)
-	lw := newLogrusWriter(&buf)
-	lw.Printf("hello %s", "world")
-	if buf.Len() == 0 {
-		t.Fatalf("expected output in buffer")
+	logger := logrus.StandardLogger()
+	out, formatter, prev := logger.Out, logger.Formatter, logger.GetLevel()
+	t.Cleanup(func() {
+		logger.SetOutput(out)
+		logger.SetFormatter(formatter)
+		logger.SetLevel(prev)
+	})
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetLevel(level)
+	return &buf
+}
+
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var lines []map[string]interface{}
+	for _, raw := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if raw == "" {
+			continue
+		}
+		var line map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &line); err != nil {
+			t.Fatalf("invalid log line %q: %v", raw, err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func openTestDB(t *testing.T, slow time.Duration) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: newGormLogger(slow)})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	return db
+}
+
+func TestGormLoggerCarriesRequestID(t *testing.T) {
+	buf := captureLogs(t, logrus.DebugLevel)
+	db := openTestDB(t, time.Second)
+
+	ctx := logging.WithRequestID(context.Background(), "req-123", "/api/v1/users")
+	if err := db.WithContext(ctx).Exec("SELECT 1").Error; err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+
+	lines := logLines(t, buf)
+	if len(lines) != 1 {
+		t.Fatalf("expected one log line got %d: %s", len(lines), buf.String())
+	}
+	if lines[0]["level"] != "debug" || lines[0]["request_id"] != "req-123" || lines[0]["sql"] != "SELECT 1" {
+		t.Fatalf("unexpected log line: %v", lines[0])
+	}
+}
+
+func TestGormLoggerLevels(t *testing.T) {
+	buf := captureLogs(t, logrus.InfoLevel)
+	db := openTestDB(t, time.Nanosecond)
+
+	// Every query is slow with a 1ns threshold; info level hides debug SQL
+	db.Exec("SELECT 1")
+	db.Exec("SELECT * FROM missing_table")
+
+	lines := logLines(t, buf)
+	if len(lines) != 2 {
+		t.Fatalf("expected two log lines got %d: %s", len(lines), buf.String())
+	}
+	if lines[0]["level"] != "warning" {
+		t.Fatalf("expected slow query warning got %v", lines[0])
+	}
+	if lines[1]["level"] != "error" || lines[1]["error"] == nil {
+		t.Fatalf("expected SQL error got %v", lines[1])
+	}
+}
+
+func TestGormLoggerSkipsFastQueriesAboveDebug(t *testing.T) {
+	buf := captureLogs(t, logrus.InfoLevel)
+	db := openTestDB(t, time.Minute)
+
+	db.Exec("SELECT 1")
+	if buf.Len() != 0 {
+		t.Fatalf("expected no output got %s", buf.String())
 	}
 }
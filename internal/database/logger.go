@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"egaldeutsch-be/internal/logging"
+)
+
+// gormLogger writes GORM logs through logrus with the request fields of the
+// query context, so SQL lines correlate with the HTTP request that caused
+// them. Repositories must use db.WithContext(ctx) for the fields to appear.
+//
+// Errors are logged at error level, queries slower than slowThreshold at warn
+// level and every other statement at debug level.
+type gormLogger struct {
+	slowThreshold time.Duration
+	level         gormlogger.LogLevel
+}
+
+func newGormLogger(slowThreshold time.Duration) *gormLogger {
+	if slowThreshold <= 0 {
+		slowThreshold = time.Second // sensible default
+	}
+	return &gormLogger{slowThreshold: slowThreshold, level: gormlogger.Info}
+}
+
+// LogMode implements gormlogger.Interface.
+func (l *gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	clone := *l
+	clone.level = level
+	return &clone
+}
+
+// Info implements gormlogger.Interface.
+func (l *gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Info {
+		logging.FromContext(ctx).Infof(msg, args...)
+	}
+}
+
+// Warn implements gormlogger.Interface.
+func (l *gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		logging.FromContext(ctx).Warnf(msg, args...)
+	}
+}
+
+// Error implements gormlogger.Interface.
+func (l *gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Error {
+		logging.FromContext(ctx).Errorf(msg, args...)
+	}
+}
+
+// Trace implements gormlogger.Interface and is called once per statement.
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	isError := err != nil && !errors.Is(err, gorm.ErrRecordNotFound)
+	isSlow := elapsed > l.slowThreshold
+
+	// Avoid rendering the SQL when nothing will be logged
+	if !isError && !isSlow && !logrus.IsLevelEnabled(logrus.DebugLevel) {
+		return
+	}
+
+	sql, rows := fc()
+	entry := logging.FromContext(ctx).WithFields(logrus.Fields{
+		"sql":         sql,
+		"rows":        rows,
+		"duration_ms": float64(elapsed.Microseconds()) / 1000,
+	})
+
+	switch {
+	case isError && l.level >= gormlogger.Error:
+		entry.WithError(err).Error("SQL query failed")
+	case isSlow && l.level >= gormlogger.Warn:
+		entry.WithField("threshold_ms", l.slowThreshold.Milliseconds()).Warn(fmt.Sprintf("Slow SQL query (>%s)", l.slowThreshold))
+	case l.level >= gormlogger.Info:
+		entry.Debug("SQL query")
+	}
+}
@@ -0,0 +1,298 @@
+// Package events is the in-process domain event bus. A state change publishes
+// its events into the outbox table inside the same transaction; the relay
+// delivers committed events to the subscribers at least once, retrying
+// failures with backoff and dead-lettering events that keep failing.
+// Subscribers must therefore be idempotent.
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/metrics"
+)
+
+const (
+	// batchSize is how many due events the relay loads per query.
+	batchSize = 100
+	// claimTimeout hides a claimed event from other relays while it is
+	// delivered. A relay that dies mid-delivery releases it implicitly.
+	claimTimeout = 2 * time.Minute
+	// handlerTimeout bounds a single subscriber call.
+	handlerTimeout = 30 * time.Second
+	// maxBackoff caps the delay between delivery attempts.
+	maxBackoff = 10 * time.Minute
+)
+
+// ErrNotDead is returned by Retry for an event that is not dead-lettered.
+var ErrNotDead = errors.New("event is not dead-lettered")
+
+var deliveries = metrics.NewCounterVec(
+	"events_deliveries_total",
+	"Outbox delivery attempts by event type and result (delivered, retry, dead).",
+	"type", "result",
+)
+
+// Handler processes one event. Returning an error schedules a retry.
+type Handler func(ctx context.Context, event *Event) error
+
+type subscription struct {
+	name    string
+	types   map[string]bool
+	handler Handler
+}
+
+// Bus delivers outbox events to subscribers.
+type Bus struct {
+	db      *gorm.DB
+	cfg     config.EventsConfig
+	now     func() time.Time
+	backoff func(attempts int) time.Duration
+
+	mu   sync.RWMutex
+	subs []subscription
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+	started bool
+}
+
+// NewBus creates a bus that relays the outbox in db.
+func NewBus(db *gorm.DB, cfg config.EventsConfig) *Bus {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Bus{
+		db:      db,
+		cfg:     cfg,
+		now:     func() time.Time { return time.Now().UTC() },
+		backoff: backoff,
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+}
+
+// backoff doubles the delay with every failed attempt, starting at one second.
+func backoff(attempts int) time.Duration {
+	if attempts > 20 {
+		attempts = 20
+	}
+	return min(time.Second<<(attempts-1), maxBackoff)
+}
+
+// Subscribe registers handler for the given event types. The name identifies
+// the subscriber in the outbox and in logs, so it must be unique and stable
+// across releases. Subscribe must be called before Start.
+func (b *Bus) Subscribe(name string, handler Handler, types ...string) {
+	s := subscription{name: name, types: make(map[string]bool, len(types)), handler: handler}
+	for _, t := range types {
+		s.types[t] = true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs = append(b.subs, s)
+}
+
+// Start launches the relay. It does not block.
+func (b *Bus) Start() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.started {
+		return
+	}
+	b.started = true
+
+	go b.run()
+	logrus.WithField("subscribers", len(b.subs)).Info("Event relay started")
+}
+
+// Stop stops the relay after the current delivery, bounded by ctx.
+func (b *Bus) Stop(ctx context.Context) error {
+	b.cancel()
+
+	b.mu.RLock()
+	started := b.started
+	b.mu.RUnlock()
+	if !started {
+		return nil
+	}
+
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("event relay did not stop: %w", ctx.Err())
+	}
+}
+
+// DeadLetters returns the dead-lettered events, newest first.
+func (b *Bus) DeadLetters(ctx context.Context, limit int) ([]Event, error) {
+	var dead []Event
+	err := b.db.WithContext(ctx).Where("status = ?", StatusDead).Order("occurred_at DESC").Limit(limit).Find(&dead).Error
+	return dead, err
+}
+
+// Retry moves a dead-lettered event back into the outbox. Subscribers that
+// already handled it are skipped.
+func (b *Bus) Retry(ctx context.Context, id uuid.UUID) error {
+	res := b.db.WithContext(ctx).Model(&Event{}).
+		Where("id = ? AND status = ?", id, StatusDead).
+		Updates(map[string]interface{}{"status": StatusPending, "attempts": 0, "next_attempt_at": b.now()})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotDead
+	}
+	return nil
+}
+
+// PurgeDelivered deletes events delivered before the given time.
+func (b *Bus) PurgeDelivered(ctx context.Context, before time.Time) (int64, error) {
+	res := b.db.WithContext(ctx).Where("status = ? AND delivered_at < ?", StatusDelivered, before).Delete(&Event{})
+	return res.RowsAffected, res.Error
+}
+
+// run polls the outbox until Stop is called.
+func (b *Bus) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(time.Duration(b.cfg.PollInterval) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		b.dispatch()
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch delivers every due event.
+func (b *Bus) dispatch() {
+	for b.ctx.Err() == nil {
+		now := b.now()
+		var due []Event
+		err := b.db.WithContext(b.ctx).
+			Where("status = ? AND next_attempt_at <= ?", StatusPending, now).
+			Order("occurred_at").Limit(batchSize).Find(&due).Error
+		if err != nil {
+			if b.ctx.Err() == nil {
+				logrus.WithError(err).Error("Failed to load outbox events")
+			}
+			return
+		}
+
+		for i := range due {
+			if b.ctx.Err() != nil {
+				return
+			}
+			claimed, err := b.claim(&due[i], now)
+			if err != nil {
+				logrus.WithError(err).WithField("event_id", due[i].ID).Error("Failed to claim outbox event")
+				continue
+			}
+			if claimed {
+				b.deliver(&due[i])
+			}
+		}
+
+		if len(due) < batchSize {
+			return
+		}
+	}
+}
+
+// claim hides the event from other relays for claimTimeout. It fails if
+// another relay claimed the event first.
+func (b *Bus) claim(event *Event, now time.Time) (bool, error) {
+	res := b.db.WithContext(b.ctx).Model(&Event{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", event.ID, StatusPending, now).
+		Update("next_attempt_at", now.Add(claimTimeout))
+	return res.RowsAffected == 1, res.Error
+}
+
+// deliver hands the event to every subscriber that has not handled it yet and
+// records the outcome.
+func (b *Bus) deliver(event *Event) {
+	log := logrus.WithFields(logrus.Fields{"event_id": event.ID, "event_type": event.Type})
+
+	var errs []error
+	for _, s := range b.subscribers(event.Type) {
+		if event.deliveredTo(s.name) {
+			continue
+		}
+		if err := b.call(s, event); err != nil {
+			log.WithError(err).WithField("subscriber", s.name).Warn("Event subscriber failed")
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+			continue
+		}
+		event.markDelivered(s.name)
+	}
+
+	now := b.now()
+	event.Attempts++
+	updates := map[string]interface{}{"attempts": event.Attempts, "delivered_to": event.DeliveredTo}
+	switch {
+	case len(errs) == 0:
+		event.Status = StatusDelivered
+		updates["status"] = StatusDelivered
+		updates["delivered_at"] = now
+		updates["last_error"] = ""
+		deliveries.Inc(event.Type, "delivered")
+	case event.Attempts >= b.cfg.MaxAttempts:
+		event.Status = StatusDead
+		updates["status"] = StatusDead
+		updates["last_error"] = errors.Join(errs...).Error()
+		deliveries.Inc(event.Type, "dead")
+		log.WithField("attempts", event.Attempts).Error("Event dead-lettered")
+	default:
+		updates["next_attempt_at"] = now.Add(b.backoff(event.Attempts))
+		updates["last_error"] = errors.Join(errs...).Error()
+		deliveries.Inc(event.Type, "retry")
+	}
+
+	// Record the outcome even when the relay is stopping
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := b.db.WithContext(ctx).Model(&Event{}).Where("id = ?", event.ID).Updates(updates).Error; err != nil {
+		log.WithError(err).Error("Failed to record event delivery")
+	}
+}
+
+// subscribers returns the subscriptions for an event type.
+func (b *Bus) subscribers(eventType string) []subscription {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var subs []subscription
+	for _, s := range b.subs {
+		if s.types[eventType] {
+			subs = append(subs, s)
+		}
+	}
+	return subs
+}
+
+// call runs one subscriber, bounded by handlerTimeout. A panic fails the call.
+func (b *Bus) call(s subscription, event *Event) (err error) {
+	ctx, cancel := context.WithTimeout(b.ctx, handlerTimeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("subscriber panicked: %v", r)
+		}
+	}()
+	return s.handler(ctx, event)
+}
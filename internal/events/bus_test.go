@@ -0,0 +1,234 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/logging"
+)
+
+func setupDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test db: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(Models()...); err != nil {
+		t.Fatalf("failed to automigrate: %v", err)
+	}
+	return db
+}
+
+func newBus(t *testing.T, db *gorm.DB, maxAttempts int) *Bus {
+	t.Helper()
+	b := NewBus(db, config.EventsConfig{PollInterval: 10, MaxAttempts: maxAttempts, RetentionDays: 1})
+	b.backoff = func(int) time.Duration { return 0 }
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := b.Stop(ctx); err != nil {
+			t.Errorf("stop: %v", err)
+		}
+	})
+	return b
+}
+
+// waitForStatus polls the outbox until the only event has the given status.
+func waitForStatus(t *testing.T, db *gorm.DB, status string) Event {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var e Event
+		if err := db.First(&e).Error; err != nil {
+			t.Fatalf("load event: %v", err)
+		}
+		if e.Status == status {
+			return e
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("event did not reach status %s", status)
+	return Event{}
+}
+
+// recorder counts calls per user ID and fails the first failures calls.
+type recorder struct {
+	mu       sync.Mutex
+	calls    int
+	failures int
+	users    []string
+}
+
+func (r *recorder) handle(ctx context.Context, e *Event) error {
+	var p UserCreatedPayload
+	if err := e.Decode(&p); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	if r.calls <= r.failures {
+		return errors.New("temporary failure")
+	}
+	r.users = append(r.users, p.UserID)
+	return nil
+}
+
+func (r *recorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls
+}
+
+func TestPublishIsPartOfTransaction(t *testing.T) {
+	db := setupDB(t)
+
+	rollback := errors.New("rollback")
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := Publish(tx, UserCreated, UserCreatedPayload{UserID: "u1"}); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("expected rollback, got %v", err)
+	}
+
+	var count int64
+	db.Model(&Event{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected no event after rollback, got %d", count)
+	}
+}
+
+func TestPublishTruncatesUserAgent(t *testing.T) {
+	db := setupDB(t)
+
+	// 127 two-byte characters and a three-byte one crossing byte 255
+	userAgent := strings.Repeat("ä", 127) + "€ rest"
+	ctx := logging.WithClient(context.Background(), "10.0.0.1", userAgent)
+	if err := Publish(db.WithContext(ctx), UserCreated, UserCreatedPayload{UserID: "u1"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	var e Event
+	if err := db.First(&e).Error; err != nil {
+		t.Fatalf("load event: %v", err)
+	}
+	if e.UserAgent != strings.Repeat("ä", 127) || !utf8.ValidString(e.UserAgent) {
+		t.Fatalf("expected the user agent cut before the split character, got %q", e.UserAgent)
+	}
+	if e.IP != "10.0.0.1" {
+		t.Fatalf("expected the client IP to be kept, got %q", e.IP)
+	}
+}
+
+func TestBusDeliversEvents(t *testing.T) {
+	db := setupDB(t)
+	b := newBus(t, db, 3)
+
+	rec := &recorder{}
+	b.Subscribe("test.recorder", rec.handle, UserCreated)
+	other := &recorder{}
+	b.Subscribe("test.other", other.handle, UserDeleted)
+	b.Start()
+
+	if err := Publish(db, UserCreated, UserCreatedPayload{UserID: "u1"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	e := waitForStatus(t, db, StatusDelivered)
+	if e.Attempts != 1 || e.DeliveredAt == nil {
+		t.Fatalf("unexpected delivery record: %+v", e)
+	}
+	if len(rec.users) != 1 || rec.users[0] != "u1" {
+		t.Fatalf("expected u1 to be delivered once, got %v", rec.users)
+	}
+	if other.count() != 0 {
+		t.Fatalf("subscriber of another type was called")
+	}
+}
+
+func TestBusRetriesOnlyFailedSubscribers(t *testing.T) {
+	db := setupDB(t)
+	b := newBus(t, db, 5)
+
+	ok := &recorder{}
+	flaky := &recorder{failures: 2}
+	b.Subscribe("test.ok", ok.handle, UserCreated)
+	b.Subscribe("test.flaky", flaky.handle, UserCreated)
+	b.Start()
+
+	if err := Publish(db, UserCreated, UserCreatedPayload{UserID: "u1"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	e := waitForStatus(t, db, StatusDelivered)
+	if e.Attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", e.Attempts)
+	}
+	if ok.count() != 1 {
+		t.Fatalf("expected the healthy subscriber to be called once, got %d", ok.count())
+	}
+	if flaky.count() != 3 {
+		t.Fatalf("expected the flaky subscriber to be called 3 times, got %d", flaky.count())
+	}
+}
+
+func TestBusDeadLettersAndRetry(t *testing.T) {
+	db := setupDB(t)
+	b := newBus(t, db, 2)
+
+	failing := &recorder{failures: 2}
+	b.Subscribe("test.failing", failing.handle, UserCreated)
+	b.Start()
+
+	if err := Publish(db, UserCreated, UserCreatedPayload{UserID: "u1"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	e := waitForStatus(t, db, StatusDead)
+	if e.LastError == "" {
+		t.Fatalf("expected the last error to be recorded")
+	}
+
+	dead, err := b.DeadLetters(context.Background(), 10)
+	if err != nil || len(dead) != 1 {
+		t.Fatalf("expected 1 dead letter, got %d (%v)", len(dead), err)
+	}
+
+	if err := b.Retry(context.Background(), e.ID); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	waitForStatus(t, db, StatusDelivered)
+
+	if err := b.Retry(context.Background(), e.ID); !errors.Is(err, ErrNotDead) {
+		t.Fatalf("expected ErrNotDead for a delivered event, got %v", err)
+	}
+}
+
+func TestBusRecoversPanickingSubscriber(t *testing.T) {
+	db := setupDB(t)
+	b := newBus(t, db, 1)
+
+	b.Subscribe("test.panic", func(ctx context.Context, e *Event) error {
+		panic("boom")
+	}, UserCreated)
+	b.Start()
+
+	if err := Publish(db, UserCreated, UserCreatedPayload{UserID: "u1"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	waitForStatus(t, db, StatusDead)
+}
@@ -0,0 +1,201 @@
+package events
+
+import "time"
+
+// Event types. A type is "<module>.<what happened>", in the past tense.
+const (
+	UserCreated                     = "user.created"
+	UserRoleChanged                 = "user.role_changed"
+	UserDeleted                     = "user.deleted"
+	UserEmailVerified               = "user.email_verified"
+	UserPasswordChanged             = "user.password_changed"
+	AuthLogin                       = "auth.login"
+	AuthLoginFailed                 = "auth.login_failed"
+	AuthAccountLocked               = "auth.account_locked"
+	AuthAccountUnlocked             = "auth.account_unlocked"
+	AuthRefreshReuseDetected        = "auth.refresh_reuse_detected"
+	AuthSessionsRevoked             = "auth.sessions_revoked"
+	AuthMFAEnabled                  = "auth.mfa_enabled"
+	AuthMFADisabled                 = "auth.mfa_disabled"
+	AuthMFAReset                    = "auth.mfa_reset"
+	AuthMFARecoveryCodeUsed         = "auth.mfa_recovery_code_used"
+	AuthMFARecoveryCodesRegenerated = "auth.mfa_recovery_codes_regenerated"
+	AuthMFAPolicyChanged            = "auth.mfa_policy_changed"
+	AuthAPIKeyCreated               = "auth.api_key_created"
+	AuthAPIKeyRevoked               = "auth.api_key_revoked"
+	PasswordResetLinkRequested      = "password.reset_link_requested"
+	PasswordResetRequested          = "password.reset_requested"
+	PasswordResetCompleted          = "password.reset_completed"
+	QuizQuestionCreated             = "quiz.question_created"
+	ChatRoomCreated                 = "chat.room_created"
+)
+
+// Payloads of the event types. They live here rather than in the publishing
+// module so subscribers in other modules can decode them.
+
+// UserCreatedPayload is the payload of UserCreated.
+type UserCreatedPayload struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+}
+
+// UserRoleChangedPayload is the payload of UserRoleChanged.
+type UserRoleChangedPayload struct {
+	UserID  string `json:"user_id"`
+	OldRole string `json:"old_role"`
+	NewRole string `json:"new_role"`
+}
+
+// UserDeletedPayload is the payload of UserDeleted. Email and Role describe
+// the user as it was before the deletion.
+type UserDeletedPayload struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+}
+
+// UserEmailVerifiedPayload is the payload of UserEmailVerified.
+type UserEmailVerifiedPayload struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+// UserPasswordChangedPayload is the payload of UserPasswordChanged, published
+// when a user changes their own password. SessionID is the session they
+// changed it from, which stays signed in; it is empty for access tokens
+// issued outside a session.
+type UserPasswordChangedPayload struct {
+	UserID    string `json:"user_id"`
+	SessionID string `json:"session_id"`
+}
+
+// AuthLoginPayload is the payload of AuthLogin.
+type AuthLoginPayload struct {
+	UserID    string `json:"user_id"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+}
+
+// AuthLoginFailedPayload is the payload of AuthLoginFailed. The email is the
+// one presented, which need not belong to a user. Failures is the number of
+// failed logins for it in the lockout window so far; 0 if they are not
+// counted.
+type AuthLoginFailedPayload struct {
+	Email    string `json:"email"`
+	Failures int    `json:"failures,omitempty"`
+}
+
+// AuthAccountLockedPayload is the payload of AuthAccountLocked, published
+// when failed logins lock an account. Like AuthLoginFailed it names the email
+// presented, which need not belong to a user.
+type AuthAccountLockedPayload struct {
+	Email       string    `json:"email"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// AuthAccountUnlockedPayload is the payload of AuthAccountUnlocked,
+// published when an admin unlocks an account.
+type AuthAccountUnlockedPayload struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+// AuthRefreshReuseDetectedPayload is the payload of AuthRefreshReuseDetected.
+// Every refresh token of the user has been revoked when it is published.
+type AuthRefreshReuseDetectedPayload struct {
+	UserID    string `json:"user_id"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+}
+
+// AuthSessionsRevokedPayload is the payload of AuthSessionsRevoked, published
+// when a user or an admin ends sessions of the user.
+type AuthSessionsRevokedPayload struct {
+	UserID     string   `json:"user_id"`
+	SessionIDs []string `json:"session_ids"`
+}
+
+// AuthMFAEnabledPayload is the payload of AuthMFAEnabled.
+type AuthMFAEnabledPayload struct {
+	UserID string `json:"user_id"`
+}
+
+// AuthMFADisabledPayload is the payload of AuthMFADisabled, published when
+// a user turns off their own second factor.
+type AuthMFADisabledPayload struct {
+	UserID string `json:"user_id"`
+}
+
+// AuthMFAResetPayload is the payload of AuthMFAReset, published when an
+// admin removes the second factor of a user.
+type AuthMFAResetPayload struct {
+	UserID string `json:"user_id"`
+}
+
+// AuthMFARecoveryCodeUsedPayload is the payload of AuthMFARecoveryCodeUsed.
+type AuthMFARecoveryCodeUsedPayload struct {
+	UserID string `json:"user_id"`
+	// Remaining is the number of unused recovery codes left
+	Remaining int `json:"remaining"`
+}
+
+// AuthMFARecoveryCodesRegeneratedPayload is the payload of AuthMFARecoveryCodesRegenerated.
+type AuthMFARecoveryCodesRegeneratedPayload struct {
+	UserID string `json:"user_id"`
+}
+
+// AuthMFAPolicyChangedPayload is the payload of AuthMFAPolicyChanged.
+type AuthMFAPolicyChangedPayload struct {
+	OldRoles []string `json:"old_roles"`
+	NewRoles []string `json:"new_roles"`
+}
+
+// AuthAPIKeyCreatedPayload is the payload of AuthAPIKeyCreated.
+type AuthAPIKeyCreatedPayload struct {
+	UserID string   `json:"user_id"`
+	KeyID  string   `json:"key_id"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// ExpiresAt is nil for a key that does not expire
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// AuthAPIKeyRevokedPayload is the payload of AuthAPIKeyRevoked, published
+// when a user or an admin revokes a key of the user.
+type AuthAPIKeyRevokedPayload struct {
+	UserID string `json:"user_id"`
+	KeyID  string `json:"key_id"`
+	Name   string `json:"name"`
+}
+
+// PasswordResetLinkRequestedPayload is the payload of
+// PasswordResetLinkRequested, published for every request of a reset link
+// before anyone looks the email up, so it need not belong to a user.
+type PasswordResetLinkRequestedPayload struct {
+	Email string `json:"email"`
+}
+
+// PasswordResetRequestedPayload is the payload of PasswordResetRequested.
+type PasswordResetRequestedPayload struct {
+	UserID string `json:"user_id"`
+}
+
+// PasswordResetCompletedPayload is the payload of PasswordResetCompleted.
+type PasswordResetCompletedPayload struct {
+	UserID string `json:"user_id"`
+}
+
+// QuizQuestionCreatedPayload is the payload of QuizQuestionCreated.
+type QuizQuestionCreatedPayload struct {
+	QuestionID string `json:"question_id"`
+	Category   string `json:"category"`
+}
+
+// ChatRoomCreatedPayload is the payload of ChatRoomCreated.
+type ChatRoomCreatedPayload struct {
+	RoomID    string `json:"room_id"`
+	Name      string `json:"name"`
+	CreatedBy string `json:"created_by"`
+}
@@ -0,0 +1,168 @@
+package events
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"egaldeutsch-be/internal/logging"
+)
+
+// Outbox statuses.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+)
+
+// Payload is the JSON encoded event payload.
+type Payload json.RawMessage
+
+// Value implements the driver.Valuer interface.
+func (p Payload) Value() (driver.Value, error) {
+	if len(p) == 0 {
+		return "null", nil
+	}
+	return string(p), nil
+}
+
+// Scan implements the sql.Scanner interface. PostgreSQL returns jsonb as
+// bytes, SQLite may return text.
+func (p *Payload) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		*p = append((*p)[:0], v...)
+	case string:
+		*p = Payload(v)
+	default:
+		return errors.New(fmt.Sprint("Failed to scan event payload:", value))
+	}
+	return nil
+}
+
+// MarshalJSON embeds the payload as JSON instead of base64.
+func (p Payload) MarshalJSON() ([]byte, error) {
+	if len(p) == 0 {
+		return []byte("null"), nil
+	}
+	return p, nil
+}
+
+// GormDBDataType stores the payload as jsonb on PostgreSQL and as JSON text
+// elsewhere.
+func (Payload) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	if db.Dialector.Name() == "postgres" {
+		return "jsonb"
+	}
+	return "text"
+}
+
+// Event is a domain event and its row in the outbox. The delivery fields are
+// maintained by the relay.
+type Event struct {
+	ID         uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	Type       string    `json:"type" gorm:"size:100;not null;index"`
+	Payload    Payload   `json:"payload" gorm:"not null"`
+	OccurredAt time.Time `json:"occurred_at" gorm:"not null"`
+
+	// Request metadata captured from the publishing context; empty for
+	// events published outside an HTTP request
+	ActorID   string `json:"actor_id,omitempty" gorm:"size:36"`
+	RequestID string `json:"request_id,omitempty" gorm:"size:128"`
+	IP        string `json:"ip,omitempty" gorm:"size:45"`
+	UserAgent string `json:"user_agent,omitempty" gorm:"size:255"`
+
+	Status        string     `json:"status" gorm:"size:20;not null;index:idx_outbox_events_due,priority:1"`
+	Attempts      int        `json:"attempts" gorm:"not null"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"not null;index:idx_outbox_events_due,priority:2"`
+	LastError     string     `json:"last_error,omitempty"`
+	DeliveredTo   string     `json:"delivered_to,omitempty"` // comma separated subscribers that succeeded
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+}
+
+// TableName specifies the table name for the Event model
+func (Event) TableName() string {
+	return "outbox_events"
+}
+
+// Decode unmarshals the payload into v.
+func (e *Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+// deliveredTo reports whether the subscriber has already handled the event.
+func (e *Event) deliveredTo(subscriber string) bool {
+	for _, name := range strings.Split(e.DeliveredTo, ",") {
+		if name == subscriber {
+			return true
+		}
+	}
+	return false
+}
+
+// markDelivered records that the subscriber has handled the event.
+func (e *Event) markDelivered(subscriber string) {
+	if e.DeliveredTo == "" {
+		e.DeliveredTo = subscriber
+		return
+	}
+	e.DeliveredTo += "," + subscriber
+}
+
+// Models returns the outbox table for GORM AutoMigrate.
+func Models() []interface{} {
+	return []interface{}{&Event{}}
+}
+
+// Publish writes an event to the outbox using tx, which should be the
+// transaction that makes the state change, so the event is delivered if and
+// only if the change commits. The acting user, request ID and client are
+// taken from the context tx was created with.
+func Publish(tx *gorm.DB, eventType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encode %s payload: %w", eventType, err)
+	}
+
+	now := time.Now().UTC()
+	event := &Event{
+		ID:            uuid.New(),
+		Type:          eventType,
+		Payload:       data,
+		OccurredAt:    now,
+		Status:        StatusPending,
+		NextAttemptAt: now,
+	}
+	if ctx := tx.Statement.Context; ctx != nil {
+		event.ActorID = logging.UserID(ctx)
+		event.RequestID = logging.RequestID(ctx)
+		var userAgent string
+		event.IP, userAgent = logging.Client(ctx)
+		event.UserAgent = truncateUTF8(userAgent, 255)
+	}
+	if err := tx.Create(event).Error; err != nil {
+		return fmt.Errorf("write %s to outbox: %w", eventType, err)
+	}
+	return nil
+}
+
+// truncateUTF8 cuts s to at most n bytes of valid UTF-8, so that a long
+// header is not split inside a character and then rejected by the database.
+func truncateUTF8(s string, n int) string {
+	s = strings.ToValidUTF8(s, "")
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,147 @@
+// Package health runs dependency probes for the liveness and readiness endpoints.
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// ErrDraining is reported by readiness while the server shuts down.
+var ErrDraining = errors.New("server is draining connections")
+
+// Probe checks one dependency and returns an error when it is unhealthy.
+type Probe func(ctx context.Context) error
+
+// Result is the outcome of a single probe.
+type Result struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report aggregates the results of a set of probes.
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Healthy reports whether every probe succeeded.
+func (r Report) Healthy() bool {
+	return r.Status == StatusUp
+}
+
+type check struct {
+	name  string
+	probe Probe
+}
+
+// Checker holds the liveness and readiness probes of the server.
+// Liveness covers the process itself (for example background loops);
+// readiness additionally covers external dependencies such as the database.
+type Checker struct {
+	timeout   time.Duration
+	liveness  []check
+	readiness []check
+	draining  atomic.Bool
+}
+
+// NewChecker creates a Checker that bounds every probe by timeout.
+func NewChecker(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = 2 * time.Second // sensible default
+	}
+	return &Checker{timeout: timeout}
+}
+
+// AddLiveness registers a probe used by both liveness and readiness.
+func (c *Checker) AddLiveness(name string, probe Probe) {
+	c.liveness = append(c.liveness, check{name: name, probe: probe})
+}
+
+// AddReadiness registers a probe used by readiness only.
+func (c *Checker) AddReadiness(name string, probe Probe) {
+	c.readiness = append(c.readiness, check{name: name, probe: probe})
+}
+
+// SetDraining marks the server as shutting down so readiness fails.
+func (c *Checker) SetDraining() {
+	c.draining.Store(true)
+}
+
+// Draining reports whether SetDraining has been called.
+func (c *Checker) Draining() bool {
+	return c.draining.Load()
+}
+
+// Liveness runs the liveness probes.
+func (c *Checker) Liveness(ctx context.Context) Report {
+	return c.run(ctx, c.liveness)
+}
+
+// Readiness runs the liveness and readiness probes. It always fails while the
+// server is draining.
+func (c *Checker) Readiness(ctx context.Context) Report {
+	checks := make([]check, 0, len(c.liveness)+len(c.readiness)+1)
+	checks = append(checks, check{name: "shutdown", probe: func(context.Context) error {
+		if c.Draining() {
+			return ErrDraining
+		}
+		return nil
+	}})
+	checks = append(checks, c.liveness...)
+	checks = append(checks, c.readiness...)
+	return c.run(ctx, checks)
+}
+
+// run executes probes concurrently and returns results in registration order.
+func (c *Checker) run(ctx context.Context, checks []check) Report {
+	results := make([]Result, len(checks))
+
+	var wg sync.WaitGroup
+	for i, chk := range checks {
+		wg.Add(1)
+		go func(i int, chk check) {
+			defer wg.Done()
+			results[i] = c.probe(ctx, chk)
+		}(i, chk)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Checks: results}
+	for _, r := range results {
+		if r.Status != StatusUp {
+			report.Status = StatusDown
+			break
+		}
+	}
+	return report
+}
+
+func (c *Checker) probe(ctx context.Context, chk check) Result {
+	probeCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := chk.probe(probeCtx)
+	latency := time.Since(start)
+
+	result := Result{
+		Name:      chk.name,
+		Status:    StatusUp,
+		LatencyMs: float64(latency.Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,69 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReadinessReportsFailingDependency(t *testing.T) {
+	c := NewChecker(time.Second)
+	c.AddLiveness("hub", func(context.Context) error { return nil })
+	c.AddReadiness("database", func(context.Context) error { return errors.New("connection refused") })
+
+	live := c.Liveness(context.Background())
+	if !live.Healthy() {
+		t.Fatalf("expected liveness to be healthy: %+v", live)
+	}
+
+	ready := c.Readiness(context.Background())
+	if ready.Healthy() {
+		t.Fatalf("expected readiness to fail")
+	}
+	if len(ready.Checks) != 3 {
+		t.Fatalf("expected shutdown, hub and database checks got %+v", ready.Checks)
+	}
+	db := ready.Checks[2]
+	if db.Name != "database" || db.Status != StatusDown || db.Error != "connection refused" {
+		t.Fatalf("unexpected database result: %+v", db)
+	}
+}
+
+func TestReadinessFailsWhileDraining(t *testing.T) {
+	c := NewChecker(time.Second)
+	c.AddReadiness("database", func(context.Context) error { return nil })
+
+	if !c.Readiness(context.Background()).Healthy() {
+		t.Fatalf("expected readiness before draining")
+	}
+
+	c.SetDraining()
+	report := c.Readiness(context.Background())
+	if report.Healthy() {
+		t.Fatalf("expected readiness to fail while draining")
+	}
+	if report.Checks[0].Error != ErrDraining.Error() {
+		t.Fatalf("expected draining error got %+v", report.Checks[0])
+	}
+	if !c.Liveness(context.Background()).Healthy() {
+		t.Fatalf("liveness must not depend on draining")
+	}
+}
+
+func TestProbeIsBoundedByTimeout(t *testing.T) {
+	c := NewChecker(20 * time.Millisecond)
+	c.AddReadiness("redis", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	start := time.Now()
+	report := c.Readiness(context.Background())
+	if time.Since(start) > time.Second {
+		t.Fatalf("probe was not bounded by the timeout")
+	}
+	if report.Healthy() {
+		t.Fatalf("expected timed out probe to be down")
+	}
+}
@@ -0,0 +1,175 @@
+// Package logging configures the process-wide logrus logger and carries
+// request-scoped fields (request ID, user ID, route) through context.Context
+// so that handler, SQL and WebSocket log lines of one request correlate.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"egaldeutsch-be/internal/config"
+)
+
+// Field names shared by every log line.
+const (
+	FieldRequestID = "request_id"
+	FieldUserID    = "user_id"
+	FieldRoute     = "route"
+)
+
+// Configure sets the level and format of the standard logrus logger and
+// installs the PII redaction hook.
+func Configure(cfg config.LoggingConfig) error {
+	level, err := logrus.ParseLevel(cfg.Level)
+	if err != nil {
+		return fmt.Errorf("invalid log level: %w", err)
+	}
+
+	logger := logrus.StandardLogger()
+	logger.SetLevel(level)
+	logger.SetOutput(os.Stdout)
+
+	switch cfg.Format {
+	case "json":
+		logger.SetFormatter(&logrus.JSONFormatter{
+			FieldMap: logrus.FieldMap{logrus.FieldKeyMsg: "message"},
+		})
+	case "console":
+		logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	default:
+		return fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+
+	logger.ReplaceHooks(make(logrus.LevelHooks))
+	logger.AddHook(RedactHook{})
+	return nil
+}
+
+type contextKey struct{}
+
+// requestFields are the request-scoped values attached to every log line.
+type requestFields struct {
+	requestID string
+	userID    string
+	route     string
+
+	// client details are not logged on every line, but recorded with
+	// domain events and audit entries
+	clientIP  string
+	userAgent string
+}
+
+func fieldsFrom(ctx context.Context) requestFields {
+	if ctx == nil {
+		return requestFields{}
+	}
+	f, _ := ctx.Value(contextKey{}).(requestFields)
+	return f
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID and route template.
+func WithRequestID(ctx context.Context, requestID, route string) context.Context {
+	f := fieldsFrom(ctx)
+	f.requestID = requestID
+	f.route = route
+	return context.WithValue(ctx, contextKey{}, f)
+}
+
+// WithUserID returns a copy of ctx carrying the authenticated user ID.
+func WithUserID(ctx context.Context, userID string) context.Context {
+	f := fieldsFrom(ctx)
+	f.userID = userID
+	return context.WithValue(ctx, contextKey{}, f)
+}
+
+// WithClient returns a copy of ctx carrying the client IP and user agent.
+func WithClient(ctx context.Context, clientIP, userAgent string) context.Context {
+	f := fieldsFrom(ctx)
+	f.clientIP = clientIP
+	f.userAgent = userAgent
+	return context.WithValue(ctx, contextKey{}, f)
+}
+
+// RequestID returns the request ID stored in ctx, if any.
+func RequestID(ctx context.Context) string {
+	return fieldsFrom(ctx).requestID
+}
+
+// UserID returns the authenticated user ID stored in ctx, if any.
+func UserID(ctx context.Context) string {
+	return fieldsFrom(ctx).userID
+}
+
+// Client returns the client IP and user agent stored in ctx, if any.
+func Client(ctx context.Context) (clientIP, userAgent string) {
+	f := fieldsFrom(ctx)
+	return f.clientIP, f.userAgent
+}
+
+// FromContext returns a log entry pre-populated with the request fields in ctx.
+func FromContext(ctx context.Context) *logrus.Entry {
+	entry := logrus.NewEntry(logrus.StandardLogger())
+	f := fieldsFrom(ctx)
+
+	fields := logrus.Fields{}
+	if f.requestID != "" {
+		fields[FieldRequestID] = f.requestID
+	}
+	if f.userID != "" {
+		fields[FieldUserID] = f.userID
+	}
+	if f.route != "" {
+		fields[FieldRoute] = f.route
+	}
+	if len(fields) == 0 {
+		return entry
+	}
+	return entry.WithFields(fields)
+}
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// RedactEmail masks the local part of an email address, keeping its first
+// character and the domain: "jane.doe@example.com" becomes "j***@example.com".
+func RedactEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return "***"
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+// Redact masks every email address in s.
+func Redact(s string) string {
+	return emailPattern.ReplaceAllStringFunc(s, RedactEmail)
+}
+
+// RedactHook masks email addresses in log messages and string fields so
+// that no call site can leak them by accident.
+type RedactHook struct{}
+
+// Levels implements logrus.Hook.
+func (RedactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook.
+func (RedactHook) Fire(entry *logrus.Entry) error {
+	entry.Message = Redact(entry.Message)
+	for k, v := range entry.Data {
+		switch val := v.(type) {
+		case string:
+			entry.Data[k] = Redact(val)
+		case error:
+			if msg := val.Error(); emailPattern.MatchString(msg) {
+				entry.Data[k] = Redact(msg)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func captureJSON(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	logger := logrus.StandardLogger()
+	out, formatter, hooks := logger.Out, logger.Formatter, logger.Hooks
+	t.Cleanup(func() {
+		logger.SetOutput(out)
+		logger.SetFormatter(formatter)
+		logger.ReplaceHooks(hooks)
+	})
+
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.ReplaceHooks(make(logrus.LevelHooks))
+	logger.AddHook(RedactHook{})
+	return &buf
+}
+
+func TestFromContextCarriesRequestFields(t *testing.T) {
+	buf := captureJSON(t)
+
+	ctx := WithRequestID(context.Background(), "req-1", "/api/v1/users/:id")
+	ctx = WithUserID(ctx, "user-42")
+	FromContext(ctx).Info("hello")
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("invalid JSON log line: %v", err)
+	}
+	if line[FieldRequestID] != "req-1" || line[FieldUserID] != "user-42" || line[FieldRoute] != "/api/v1/users/:id" {
+		t.Fatalf("missing request fields: %v", line)
+	}
+	if RequestID(ctx) != "req-1" {
+		t.Fatalf("expected RequestID to return req-1")
+	}
+}
+
+func TestRedactHookMasksEmails(t *testing.T) {
+	buf := captureJSON(t)
+
+	logrus.WithField("email", "jane.doe@example.com").
+		WithError(errors.New("duplicate key jane.doe@example.com")).
+		Info("reset requested by jane.doe@example.com")
+
+	out := buf.String()
+	if bytes.Contains(buf.Bytes(), []byte("jane.doe")) {
+		t.Fatalf("email leaked into log: %s", out)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("j***@example.com")) {
+		t.Fatalf("expected redacted email in log: %s", out)
+	}
+}
+
+func TestRedactEmail(t *testing.T) {
+	tests := map[string]string{
+		"jane@example.com": "j***@example.com",
+		"x@y.de":           "x***@y.de",
+		"not-an-email":     "***",
+	}
+	for in, want := range tests {
+		if got := RedactEmail(in); got != want {
+			t.Fatalf("RedactEmail(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"egaldeutsch-be/internal/logging"
+)
+
+// FileMailer writes each message to its own .eml file, which mail clients
+// open directly. It is meant for development and tests.
+type FileMailer struct {
+	dir string
+}
+
+// NewFileMailer creates a mailer writing to dir, creating it if needed.
+func NewFileMailer(dir string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create mail dir: %w", err)
+	}
+	return &FileMailer{dir: dir}, nil
+}
+
+// Send writes msg to <dir>/<time>-<random>.eml, so the files sort in the
+// order they were sent.
+func (m *FileMailer) Send(ctx context.Context, msg *Message) error {
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+	return os.WriteFile(filepath.Join(m.dir, name), body, 0o600)
+}
+
+// ConsoleMailer logs the text body of each message instead of sending it.
+// Links in emails carry tokens, so it must not be used in production.
+type ConsoleMailer struct{}
+
+// Send logs msg at info level.
+func (ConsoleMailer) Send(ctx context.Context, msg *Message) error {
+	logging.FromContext(ctx).
+		WithField("to", logging.RedactEmail(msg.To)).
+		WithField("subject", msg.Subject).
+		Infof("email not sent (console mail driver):\n%s", msg.Text)
+	return nil
+}
@@ -0,0 +1,112 @@
+// Package mail sends the application's emails. A Mailer delivers messages
+// over SMTP, or writes or logs them during development; a Sender renders the
+// German and English templates into messages for a Mailer.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"egaldeutsch-be/internal/config"
+)
+
+// Message is a rendered email with a plain text and an HTML body.
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// NewMailer returns the Mailer selected by cfg.Driver.
+func NewMailer(cfg config.MailConfig) (Mailer, error) {
+	switch cfg.Driver {
+	case config.MailDriverSMTP:
+		return NewSMTPMailer(cfg.SMTP), nil
+	case config.MailDriverFile:
+		return NewFileMailer(cfg.Dir)
+	case config.MailDriverConsole:
+		return ConsoleMailer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
+
+// Recipient is the person an email is addressed to.
+type Recipient struct {
+	Email string
+	Name  string
+	// Language selects the templates; unsupported languages get DefaultLanguage
+	Language string
+}
+
+// Sender renders templated emails and sends them with a Mailer.
+type Sender struct {
+	mailer      Mailer
+	from        string
+	frontendURL string
+	templates   templates
+}
+
+// NewSender creates a Sender for cfg with the Mailer its driver selects.
+func NewSender(cfg config.MailConfig) (*Sender, error) {
+	mailer, err := NewMailer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewSenderWithMailer(cfg, mailer)
+}
+
+// NewSenderWithMailer creates a Sender that sends with mailer.
+func NewSenderWithMailer(cfg config.MailConfig, mailer Mailer) (*Sender, error) {
+	tmpl, err := parseTemplates()
+	if err != nil {
+		return nil, err
+	}
+	return &Sender{
+		mailer:      mailer,
+		from:        cfg.From,
+		frontendURL: strings.TrimRight(cfg.FrontendURL, "/"),
+		templates:   tmpl,
+	}, nil
+}
+
+// Send renders the template in the recipient's language and sends it. The
+// recipient's name is available to the template as .Name, in addition to
+// data.
+func (s *Sender) Send(ctx context.Context, to Recipient, template string, data map[string]any) error {
+	values := map[string]any{"Name": to.Name}
+	for k, v := range data {
+		values[k] = v
+	}
+	msg, err := s.templates.render(template, to.Language, values)
+	if err != nil {
+		return err
+	}
+	msg.From = s.from
+	msg.To = to.Email
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("send %s email: %w", template, err)
+	}
+	return nil
+}
+
+// URL returns the frontend URL of path, for example
+// https://app.example/forgot-password.
+func (s *Sender) URL(path string) string {
+	return s.frontendURL + path
+}
+
+// Link returns the frontend URL of path carrying token as a query
+// parameter, for example https://app.example/verify-email?token=...
+func (s *Sender) Link(path string, token string) string {
+	return s.URL(path) + "?" + url.Values{"token": {token}}.Encode()
+}
@@ -0,0 +1,256 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"egaldeutsch-be/internal/config"
+)
+
+type recorder struct {
+	sent []*Message
+}
+
+func (r *recorder) Send(ctx context.Context, msg *Message) error {
+	r.sent = append(r.sent, msg)
+	return nil
+}
+
+var testConfig = config.MailConfig{
+	Driver:      config.MailDriverConsole,
+	From:        "EgalDeutsch <no-reply@example.com>",
+	FrontendURL: "https://app.example.com/",
+}
+
+func TestSenderRendersEveryTemplate(t *testing.T) {
+	rec := &recorder{}
+	s, err := NewSenderWithMailer(testConfig, rec)
+	if err != nil {
+		t.Fatalf("NewSenderWithMailer: %v", err)
+	}
+	link := s.Link("/verify-email", "tok-123")
+	if link != "https://app.example.com/verify-email?token=tok-123" {
+		t.Fatalf("link = %q", link)
+	}
+
+	subjects := map[string]bool{}
+	for _, lang := range Languages {
+		for _, name := range templateNames {
+			to := Recipient{Email: "anna@example.com", Name: "Anna <b>", Language: lang}
+			data := map[string]any{"Link": link, "ValidHours": 48, "ValidMinutes": 60, "LockedMinutes": 15}
+			if err := s.Send(context.Background(), to, name, data); err != nil {
+				t.Fatalf("send %s/%s: %v", lang, name, err)
+			}
+			msg := rec.sent[len(rec.sent)-1]
+			if msg.To != "anna@example.com" || msg.From != testConfig.From {
+				t.Fatalf("%s/%s: from %q to %q", lang, name, msg.From, msg.To)
+			}
+			if msg.Subject == "" || subjects[msg.Subject] {
+				t.Fatalf("%s/%s: subject %q is empty or not translated", lang, name, msg.Subject)
+			}
+			subjects[msg.Subject] = true
+			if !strings.Contains(msg.Text, link) || !strings.Contains(msg.Text, "Anna <b>") {
+				t.Fatalf("%s/%s: text misses the link or name:\n%s", lang, name, msg.Text)
+			}
+			if !strings.Contains(msg.HTML, `href="`+link+`"`) || !strings.Contains(msg.HTML, "Anna &lt;b&gt;") {
+				t.Fatalf("%s/%s: HTML misses the link or escaping:\n%s", lang, name, msg.HTML)
+			}
+		}
+	}
+}
+
+func TestSenderFallsBackToDefaultLanguage(t *testing.T) {
+	rec := &recorder{}
+	s, err := NewSenderWithMailer(testConfig, rec)
+	if err != nil {
+		t.Fatalf("NewSenderWithMailer: %v", err)
+	}
+	data := map[string]any{"Link": "https://x", "ValidHours": 1}
+	if err := s.Send(context.Background(), Recipient{Email: "a@example.com", Language: "fr"}, TemplateVerifyEmail, data); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if err := s.Send(context.Background(), Recipient{Email: "a@example.com", Language: DefaultLanguage}, TemplateVerifyEmail, data); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if rec.sent[0].Subject != rec.sent[1].Subject {
+		t.Fatalf("subject %q, want the %s one %q", rec.sent[0].Subject, DefaultLanguage, rec.sent[1].Subject)
+	}
+
+	if err := s.Send(context.Background(), Recipient{Email: "a@example.com"}, TemplateVerifyEmail, nil); err == nil {
+		t.Fatalf("expected an error for missing template data")
+	}
+}
+
+// parseMessage returns the text and HTML bodies of an encoded message.
+func parseMessage(t *testing.T, r io.Reader) (*mail.Message, string, string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("content type: %v", err)
+	}
+	var text, html string
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next part: %v", err)
+		}
+		b, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("read part: %v", err)
+		}
+		if strings.HasPrefix(part.Header.Get("Content-Type"), "text/html") {
+			html = string(b)
+		} else {
+			text = string(b)
+		}
+	}
+	return msg, text, html
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := NewFileMailer(dir)
+	if err != nil {
+		t.Fatalf("NewFileMailer: %v", err)
+	}
+	longLink := "https://app.example.com/verify-email?token=" + strings.Repeat("x", 80)
+	err = m.Send(context.Background(), &Message{
+		From:    testConfig.From,
+		To:      "bob@example.com",
+		Subject: "Bitte bestätige deine E-Mail-Adresse",
+		Text:    "Öffne " + longLink + "\n",
+		HTML:    "<p>Öffne</p>",
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %v", files)
+	}
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	msg, text, html := parseMessage(t, f)
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Bitte bestätige deine E-Mail-Adresse" {
+		t.Fatalf("subject = %q, %v", subject, err)
+	}
+	if msg.Header.Get("To") != "bob@example.com" || msg.Header.Get("Message-ID") == "" {
+		t.Fatalf("unexpected headers: %v", msg.Header)
+	}
+	if !strings.Contains(text, longLink) || html != "<p>Öffne</p>" {
+		t.Fatalf("bodies not preserved: %q %q", text, html)
+	}
+}
+
+// fakeSMTPServer accepts one message without TLS or authentication and
+// returns the DATA it received.
+func fakeSMTPServer(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				received <- data.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func TestSMTPMailer(t *testing.T) {
+	addr, received := fakeSMTPServer(t)
+	host, port, _ := net.SplitHostPort(addr)
+	portNum, _ := strconv.Atoi(port)
+
+	m := NewSMTPMailer(config.SMTPConfig{Host: host, Port: portNum, TLS: config.SMTPNone})
+	err := m.Send(context.Background(), &Message{
+		From:    testConfig.From,
+		To:      "carla@example.com",
+		Subject: "Hello",
+		Text:    "text body\n",
+		HTML:    "<p>html body</p>",
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	// SMTP turns line endings into CRLF
+	_, text, html := parseMessage(t, strings.NewReader(<-received))
+	if text != "text body\r\n" || html != "<p>html body</p>" {
+		t.Fatalf("unexpected bodies %q %q", text, html)
+	}
+
+	addr, _ = fakeSMTPServer(t)
+	_, port, _ = net.SplitHostPort(addr)
+	portNum, _ = strconv.Atoi(port)
+	starttls := NewSMTPMailer(config.SMTPConfig{Host: host, Port: portNum, TLS: config.SMTPStartTLS})
+	if err := starttls.Send(context.Background(), &Message{From: testConfig.From, To: "carla@example.com"}); err == nil {
+		t.Fatalf("expected an error from a server without STARTTLS")
+	}
+}
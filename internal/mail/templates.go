@@ -0,0 +1,93 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Templates, each available in every language.
+const (
+	TemplateVerifyEmail   = "verify_email"
+	TemplatePasswordReset = "password_reset"
+	TemplateAccountLocked = "account_locked"
+)
+
+// Languages lists the languages emails are written in.
+var Languages = []string{"de", "en"}
+
+// DefaultLanguage is used for recipients without a supported language.
+const DefaultLanguage = "de"
+
+var templateNames = []string{TemplateVerifyEmail, TemplatePasswordReset, TemplateAccountLocked}
+
+// Each template is a <name>.txt file defining "subject" besides the text
+// body, and a <name>.html file defining the "content" of layout.html.
+//
+//go:embed templates
+var templateFS embed.FS
+
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// templates holds the parsed templates by language and name.
+type templates map[string]map[string]emailTemplate
+
+func parseTemplates() (templates, error) {
+	t := templates{}
+	for _, lang := range Languages {
+		t[lang] = map[string]emailTemplate{}
+		for _, name := range templateNames {
+			text, err := texttemplate.New(name+".txt").Option("missingkey=error").
+				ParseFS(templateFS, "templates/"+lang+"/"+name+".txt")
+			if err != nil {
+				return nil, fmt.Errorf("parse mail template %s/%s.txt: %w", lang, name, err)
+			}
+			if text.Lookup("subject") == nil {
+				return nil, fmt.Errorf("mail template %s/%s.txt does not define a subject", lang, name)
+			}
+			html, err := htmltemplate.New("layout.html").Option("missingkey=error").
+				ParseFS(templateFS, "templates/layout.html", "templates/"+lang+"/"+name+".html")
+			if err != nil {
+				return nil, fmt.Errorf("parse mail template %s/%s.html: %w", lang, name, err)
+			}
+			t[lang][name] = emailTemplate{text: text, html: html}
+		}
+	}
+	return t, nil
+}
+
+// render executes a template in lang, or in DefaultLanguage if lang is not
+// supported.
+func (t templates) render(name string, lang string, data map[string]any) (*Message, error) {
+	if !slices.Contains(Languages, lang) {
+		lang = DefaultLanguage
+	}
+	tmpl, ok := t[lang][name]
+	if !ok {
+		return nil, fmt.Errorf("unknown mail template %q", name)
+	}
+	data["Language"] = lang
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("render %s/%s subject: %w", lang, name, err)
+	}
+	if err := tmpl.text.Execute(&text, data); err != nil {
+		return nil, fmt.Errorf("render %s/%s.txt: %w", lang, name, err)
+	}
+	if err := tmpl.html.Execute(&html, data); err != nil {
+		return nil, fmt.Errorf("render %s/%s.html: %w", lang, name, err)
+	}
+	return &Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
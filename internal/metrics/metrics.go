@@ -0,0 +1,354 @@
+// Package metrics implements the small subset of the Prometheus text
+// exposition format the service needs: counters, histograms and gauges that
+// are computed at scrape time. It has no dependencies outside the standard
+// library.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are latency buckets in seconds suited for HTTP handlers.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Sample is a single value with its label values, as returned by gauge functions.
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// family is one metric name with its HELP and TYPE lines.
+type family interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry holds metric families and renders them for scraping.
+type Registry struct {
+	mu       sync.RWMutex
+	families map[string]family
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]family)}
+}
+
+// Default is the process-wide registry served on /metrics.
+var Default = NewRegistry()
+
+// register adds f, replacing any family with the same name. Replacing keeps
+// re-created components (for example in tests) from failing registration.
+func (r *Registry) register(f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.families[f.name()] = f
+}
+
+// NewCounterVec registers a counter with the given label names.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: desc{n: name, help: help, labels: labels}, values: make(map[string]*series)}
+	r.register(c)
+	return c
+}
+
+// NewHistogramVec registers a histogram with the given buckets and label names.
+// Buckets must be sorted in increasing order; nil selects DefaultBuckets.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	h := &HistogramVec{desc: desc{n: name, help: help, labels: labels}, buckets: buckets, values: make(map[string]*histSeries)}
+	r.register(h)
+	return h
+}
+
+// NewGaugeFunc registers a gauge whose samples are computed by fn at scrape time.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() []Sample, labels ...string) {
+	r.register(&funcFamily{desc: desc{n: name, help: help, labels: labels}, typ: "gauge", fn: fn})
+}
+
+// NewCounterFunc registers a counter whose samples are computed by fn at
+// scrape time, for monotonic values owned by another package such as sql.DBStats.
+func (r *Registry) NewCounterFunc(name, help string, fn func() []Sample, labels ...string) {
+	r.register(&funcFamily{desc: desc{n: name, help: help, labels: labels}, typ: "counter", fn: fn})
+}
+
+// Unregister removes the family with the given name.
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.families, name)
+}
+
+// WriteTo renders all families in the text exposition format, sorted by name.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	families := make([]family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(families, func(i, j int) bool { return families[i].name() < families[j].name() })
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, f := range families {
+		f.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if _, err := r.WriteTo(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// NewCounterVec registers a counter on the Default registry.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return Default.NewCounterVec(name, help, labels...)
+}
+
+// NewHistogramVec registers a histogram on the Default registry.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return Default.NewHistogramVec(name, help, buckets, labels...)
+}
+
+// Handler serves the Default registry.
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+type desc struct {
+	n      string
+	help   string
+	labels []string
+}
+
+func (d desc) name() string { return d.n }
+
+func (d desc) writeHeader(w *bufio.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.n, escapeHelp(d.help), d.n, typ)
+}
+
+// key joins label values into a map key. The separator cannot appear in
+// label values produced by this service.
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.n, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// CounterVec is a monotonically increasing counter partitioned by labels.
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+}
+
+// Inc increments the counter for the given label values by one.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments the counter for the given label values by v, which must not be negative.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	k := c.key(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.values[k]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		c.values[k] = s
+	}
+	s.value += v
+}
+
+// Value returns the current value for the given label values.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	k := c.key(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.values[k]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.writeHeader(w, "counter")
+
+	c.mu.Lock()
+	samples := make([]Sample, 0, len(c.values))
+	for _, s := range c.values {
+		samples = append(samples, Sample{LabelValues: s.labelValues, Value: s.value})
+	}
+	c.mu.Unlock()
+
+	// Label-less counters are always exported so they show up as 0
+	if len(c.labels) == 0 && len(samples) == 0 {
+		samples = append(samples, Sample{})
+	}
+	writeSamples(w, c.n, c.labels, samples)
+}
+
+// HistogramVec counts observations into buckets, partitioned by labels.
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histSeries
+}
+
+type histSeries struct {
+	labelValues []string
+	counts      []uint64 // per bucket, not cumulative
+	count       uint64
+	sum         float64
+}
+
+// Observe records v for the given label values.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	k := h.key(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.values[k]
+	if !ok {
+		s = &histSeries{labelValues: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.values[k] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.writeHeader(w, "histogram")
+
+	h.mu.Lock()
+	all := make([]histSeries, 0, len(h.values))
+	for _, s := range h.values {
+		cp := *s
+		cp.counts = append([]uint64(nil), s.counts...)
+		all = append(all, cp)
+	}
+	h.mu.Unlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		return strings.Join(all[i].labelValues, "\xff") < strings.Join(all[j].labelValues, "\xff")
+	})
+
+	bucketLabels := append(append([]string(nil), h.labels...), "le")
+	for _, s := range all {
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			writeSample(w, h.n+"_bucket", bucketLabels, append(append([]string(nil), s.labelValues...), formatFloat(upper)), float64(cumulative))
+		}
+		writeSample(w, h.n+"_bucket", bucketLabels, append(append([]string(nil), s.labelValues...), "+Inf"), float64(s.count))
+		writeSample(w, h.n+"_sum", h.labels, s.labelValues, s.sum)
+		writeSample(w, h.n+"_count", h.labels, s.labelValues, float64(s.count))
+	}
+}
+
+// funcFamily computes its samples at scrape time.
+type funcFamily struct {
+	desc
+	typ string
+	fn  func() []Sample
+}
+
+func (f *funcFamily) write(w *bufio.Writer) {
+	f.writeHeader(w, f.typ)
+	writeSamples(w, f.n, f.labels, f.fn())
+}
+
+func writeSamples(w *bufio.Writer, name string, labels []string, samples []Sample) {
+	sort.Slice(samples, func(i, j int) bool {
+		return strings.Join(samples[i].LabelValues, "\xff") < strings.Join(samples[j].LabelValues, "\xff")
+	})
+	for _, s := range samples {
+		writeSample(w, name, labels, s.LabelValues, s.Value)
+	}
+}
+
+func writeSample(w *bufio.Writer, name string, labels, values []string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			val := ""
+			if i < len(values) {
+				val = values[i]
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", l, escapeLabel(val))
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
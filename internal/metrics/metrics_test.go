@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func render(t *testing.T, r *Registry) string {
+	t.Helper()
+	var sb strings.Builder
+	if _, err := r.WriteTo(&sb); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	return sb.String()
+}
+
+func TestCounterExposition(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("requests_total", "Total requests.", "route", "status")
+	c.Inc("/users/:id", "200")
+	c.Inc("/users/:id", "200")
+	c.Add(3, "/say \"hi\"", "500")
+
+	out := render(t, r)
+	for _, want := range []string{
+		"# HELP requests_total Total requests.\n",
+		"# TYPE requests_total counter\n",
+		`requests_total{route="/users/:id",status="200"} 2` + "\n",
+		`requests_total{route="/say \"hi\"",status="500"} 3` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestLabelLessCounterStartsAtZero(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("reuse_total", "Reuse detections.")
+
+	if out := render(t, r); !strings.Contains(out, "reuse_total 0\n") {
+		t.Fatalf("expected zero sample, got:\n%s", out)
+	}
+}
+
+func TestHistogramBucketsAreCumulative(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	h.Observe(0.05, "/a")
+	h.Observe(0.5, "/a")
+	h.Observe(5, "/a")
+
+	out := render(t, r)
+	for _, want := range []string{
+		`latency_seconds_bucket{route="/a",le="0.1"} 1`,
+		`latency_seconds_bucket{route="/a",le="1"} 2`,
+		`latency_seconds_bucket{route="/a",le="+Inf"} 3`,
+		`latency_seconds_sum{route="/a"} 5.55`,
+		`latency_seconds_count{route="/a"} 3`,
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestGaugeFuncIsEvaluatedAtScrape(t *testing.T) {
+	r := NewRegistry()
+	clients := 1
+	r.NewGaugeFunc("room_clients", "Clients per room.", func() []Sample {
+		return []Sample{{LabelValues: []string{"lobby"}, Value: float64(clients)}}
+	}, "room")
+
+	clients = 4
+	if out := render(t, r); !strings.Contains(out, `room_clients{room="lobby"} 4`+"\n") {
+		t.Fatalf("expected current gauge value, got:\n%s", out)
+	}
+}
@@ -1,11 +1,12 @@
 package middleware
 
 import (
-	"net/http"
+	"errors"
 	"strings"
 
 	"egaldeutsch-be/internal/auth"
 	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/logging"
 	"egaldeutsch-be/pkg/models"
 
 	"github.com/gin-gonic/gin"
@@ -13,20 +14,54 @@ import (
 
 // AuthMiddleware validates the Authorization: Bearer <token> header using the JWT config
 // and stores the user id in the request context under the key "user_id".
-func AuthMiddleware(jwtCfg config.JwtConfig) gin.HandlerFunc {
+// Tokens on the denylist are rejected; a nil denylist disables the check. When
+// Redis fails the request is rejected with 503, or with
+// jwtCfg.RevocationFailOpen the token is accepted; either way the failure is
+// counted in auth_denylist_errors_total.
+// With apiKeys the bearer token may also be an API key, which authenticates its
+// user with their current role, limited to the key's scopes; nil accepts access
+// tokens only.
+func AuthMiddleware(jwtCfg config.JwtConfig, denylist *auth.Denylist, apiKeys *auth.APIKeys) gin.HandlerFunc {
+	jwtService := auth.NewJWTService(jwtCfg)
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if len(authHeader) < 8 || !strings.HasPrefix(authHeader, "Bearer ") {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing or invalid authorization header"})
+			abortWithError(c, auth.ErrMissingToken)
 			return
 		}
 		token := authHeader[7:]
-		claims, err := auth.ParseToken(token, jwtCfg)
+		if apiKeys != nil && auth.IsAPIKey(token) {
+			key, err := apiKeys.Verify(c.Request.Context(), token)
+			if err != nil {
+				abortWithError(c, err)
+				return
+			}
+			c.Set("user_id", key.UserID)
+			c.Set("user_role", models.UserRole(key.Role))
+			c.Set("api_key_scopes", key.Scopes)
+			c.Request = c.Request.WithContext(logging.WithUserID(c.Request.Context(), key.UserID))
+			c.Next()
+			return
+		}
+		claims, err := jwtService.ParseToken(token)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			abortWithError(c, auth.ErrInvalidToken.Wrap(err))
+			return
+		}
+		if err := denylist.Check(c.Request.Context(), claims); errors.Is(err, auth.ErrTokenRevoked) {
+			abortWithError(c, err)
 			return
+		} else if err != nil {
+			logging.FromContext(c.Request.Context()).WithError(err).Error("token denylist unavailable")
+			denylistErrors.Inc()
+			if !jwtCfg.RevocationFailOpen {
+				abortWithError(c, auth.ErrDenylistUnavailable.Wrap(err))
+				return
+			}
 		}
 		c.Set("user_id", claims.UserId)
+		c.Set("session_id", claims.SessionID)
+		c.Request = c.Request.WithContext(logging.WithUserID(c.Request.Context(), claims.UserId))
 		// also set role from token claims (if present)
 		if claims.Role != "" {
 			c.Set("user_role", models.UserRole(claims.Role))
@@ -44,3 +79,28 @@ func GetUserIDFromContext(c *gin.Context) (string, bool) {
 	s, ok := v.(string)
 	return s, ok
 }
+
+// GetSessionIDFromContext returns the session the request's access token was
+// issued to; it is empty for tokens issued without a session.
+func GetSessionIDFromContext(c *gin.Context) string {
+	return c.GetString("session_id")
+}
+
+// GetUserRoleFromContext returns the role claimed by the request's access
+// token; it is empty for tokens without one.
+func GetUserRoleFromContext(c *gin.Context) models.UserRole {
+	role, _ := c.Get("user_role")
+	r, _ := role.(models.UserRole)
+	return r
+}
+
+// getAPIKeyScopesFromContext returns the scopes of the API key the request
+// authenticated with; ok is false for requests with an access token.
+func getAPIKeyScopesFromContext(c *gin.Context) (scopes []string, ok bool) {
+	v, ok := c.Get("api_key_scopes")
+	if !ok {
+		return nil, false
+	}
+	scopes, ok = v.([]string)
+	return scopes, ok
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/logging"
+)
+
+// Errors returns a gin middleware that renders the last error added with
+// c.Error as an application/problem+json response. Handlers report a failure
+// by calling c.Error and returning without writing a body. Errors that are
+// not an *apperr.Error are rendered as a generic 500, and the access log
+// records the full error either way.
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		writeProblem(c, c.Errors.Last().Err)
+	}
+}
+
+// NoRoute is the handler for requests that match no route.
+func NoRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_ = c.Error(apperr.NotFound(apperr.CodeNotFound, "No resource exists at this path."))
+	}
+}
+
+// abortWithError stops the chain and writes err as a problem response
+// immediately, so middleware does not depend on Errors being installed. The
+// error is still recorded for the access log.
+func abortWithError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+	writeProblem(c, err)
+}
+
+// writeProblem writes err as a problem response.
+func writeProblem(c *gin.Context, err error) {
+	problem := apperr.ProblemFor(err)
+	problem.Instance = c.Request.URL.Path
+	problem.RequestID = logging.RequestID(c.Request.Context())
+
+	c.Header("Content-Type", apperr.ContentType)
+	c.JSON(problem.Status, problem)
+}
@@ -0,0 +1,263 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	rawRedis "github.com/redis/go-redis/v9"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/logging"
+	"egaldeutsch-be/internal/redis"
+)
+
+// IdempotencyKeyHeader is the header clients set to make a request safe to
+// retry.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader marks a response replayed from the store.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+const (
+	// maxIdempotencyKeyLength bounds client-supplied keys.
+	maxIdempotencyKeyLength = 255
+	// idempotencyLockTTL bounds how long a key stays locked when the request
+	// holding it never completes, for example because the process died.
+	idempotencyLockTTL = time.Minute
+	// idempotencyKeyPrefix namespaces the keys in Redis.
+	idempotencyKeyPrefix = "idempotency:"
+)
+
+var (
+	errInvalidIdempotencyKey = apperr.BadRequest("invalid_idempotency_key",
+		fmt.Sprintf("The %s header must be 1 to %d printable ASCII characters.", IdempotencyKeyHeader, maxIdempotencyKeyLength))
+	errIdempotencyKeyReused = apperr.Conflict("idempotency_key_reused",
+		"The Idempotency-Key was already used for a different request.")
+	errIdempotencyKeyInProgress = apperr.Conflict("idempotency_key_in_progress",
+		"A request with this Idempotency-Key is still being processed; retry later.")
+	errIdempotentBodyTooLarge = apperr.New(http.StatusRequestEntityTooLarge, "request_too_large",
+		"The request body is too large to be sent with an Idempotency-Key.")
+)
+
+// storedResponse is what the store keeps per key. Status is zero while the
+// first request is still being processed.
+type storedResponse struct {
+	Fingerprint string              `json:"fingerprint"`
+	Status      int                 `json:"status,omitempty"`
+	Header      map[string][]string `json:"header,omitempty"`
+	Body        []byte              `json:"body,omitempty"`
+}
+
+// Idempotency replays the response of a request when it is retried with the
+// same Idempotency-Key header. Keys are scoped to the authenticated user, or
+// to the client IP for anonymous requests, and responses are kept in Redis.
+type Idempotency struct {
+	redis   *redis.RedisClient
+	ttl     time.Duration
+	maxBody int64
+}
+
+// NewIdempotency creates the idempotency middleware factory. Responses are
+// replayed for ttl after the first request completed. Requests with a key
+// and a body over maxBody bytes are rejected, as the body is held in memory
+// to fingerprint it.
+func NewIdempotency(redisClient *redis.RedisClient, ttl time.Duration, maxBody int64) *Idempotency {
+	return &Idempotency{redis: redisClient, ttl: ttl, maxBody: maxBody}
+}
+
+// Middleware returns a gin middleware for one route; routes opt in by adding
+// it when they are registered, after any authentication middleware. Requests
+// without the header pass through unchanged. On a nil *Idempotency, when the
+// feature is disabled, every request passes through.
+//
+// The first request with a key is processed and its response stored, unless
+// it failed with a 5xx so that a retry can succeed. A retry with the same
+// method, path and body gets the stored response with an
+// Idempotent-Replayed header, a retry with a different one a 409, and a retry
+// while the first is still running a 409 as well. A body over the limit gets
+// a 413 before anything is stored. If Redis is unavailable
+// requests are processed without idempotency rather than rejected.
+func (i *Idempotency) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if i == nil || key == "" {
+			c.Next()
+			return
+		}
+		if !printableASCII(key, maxIdempotencyKeyLength) {
+			abortWithError(c, errInvalidIdempotencyKey)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, i.maxBody))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			abortWithError(c, errIdempotentBodyTooLarge)
+			return
+		}
+		if err != nil {
+			abortWithError(c, apperr.BadRequest(apperr.CodeBadRequest, "The request body could not be read.").Wrap(err))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		storeKey := idempotencyKeyPrefix + idempotencyScope(c) + ":" + key
+		fingerprint := requestFingerprint(c.Request.Method, c.Request.URL.Path, body)
+
+		acquired, err := i.lock(ctx, storeKey, fingerprint)
+		if err != nil {
+			logging.FromContext(ctx).WithError(err).Error("Idempotency store unavailable; processing request without it")
+			c.Next()
+			return
+		}
+		if !acquired {
+			i.replay(c, storeKey, fingerprint)
+			return
+		}
+
+		w := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		// Render a pending error here rather than in Errors so that the
+		// problem response is captured as well
+		if len(c.Errors) > 0 && !c.Writer.Written() {
+			writeProblem(c, c.Errors.Last().Err)
+		}
+		c.Writer = w.ResponseWriter
+
+		// A request that did not complete is not stored so it can be retried
+		if w.Status() >= http.StatusInternalServerError {
+			i.unlock(context.WithoutCancel(ctx), storeKey)
+			return
+		}
+		i.store(context.WithoutCancel(ctx), storeKey, storedResponse{
+			Fingerprint: fingerprint,
+			Status:      w.Status(),
+			Header:      replayableHeader(w.Header()),
+			Body:        w.body.Bytes(),
+		})
+	}
+}
+
+// lock marks key as in progress unless it already exists.
+func (i *Idempotency) lock(ctx context.Context, key, fingerprint string) (bool, error) {
+	data, err := json.Marshal(storedResponse{Fingerprint: fingerprint})
+	if err != nil {
+		return false, err
+	}
+	return i.redis.SetNX(ctx, key, data, idempotencyLockTTL).Result()
+}
+
+// unlock deletes key so the request can be retried.
+func (i *Idempotency) unlock(ctx context.Context, key string) {
+	if err := i.redis.Del(ctx, key).Err(); err != nil {
+		logging.FromContext(ctx).WithError(err).Warn("Failed to release idempotency key")
+	}
+}
+
+// store saves the completed response under key.
+func (i *Idempotency) store(ctx context.Context, key string, resp storedResponse) {
+	data, err := json.Marshal(resp)
+	if err == nil {
+		err = i.redis.Set(ctx, key, data, i.ttl).Err()
+	}
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).Warn("Failed to store idempotent response")
+	}
+}
+
+// replay answers a request whose key is already taken: with the stored
+// response, or with a conflict.
+func (i *Idempotency) replay(c *gin.Context, key, fingerprint string) {
+	ctx := c.Request.Context()
+	data, err := i.redis.Get(ctx, key).Bytes()
+	if errors.Is(err, rawRedis.Nil) {
+		// Released or expired in the meantime; the client may simply retry
+		abortWithError(c, errIdempotencyKeyInProgress)
+		return
+	}
+	if err != nil {
+		abortWithError(c, fmt.Errorf("load idempotent response: %w", err))
+		return
+	}
+
+	var resp storedResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		abortWithError(c, fmt.Errorf("decode idempotent response: %w", err))
+		return
+	}
+	switch {
+	case resp.Fingerprint != fingerprint:
+		abortWithError(c, errIdempotencyKeyReused)
+	case resp.Status == 0:
+		abortWithError(c, errIdempotencyKeyInProgress)
+	default:
+		idempotentReplays.Inc(routeLabel(c))
+		for name, values := range resp.Header {
+			c.Writer.Header()[name] = values
+		}
+		c.Header(IdempotentReplayedHeader, "true")
+		c.Abort()
+		c.Status(resp.Status)
+		_, _ = c.Writer.Write(resp.Body)
+	}
+}
+
+// idempotencyScope returns the namespace of the request's keys: the user, or
+// the client IP when the route is public.
+func idempotencyScope(c *gin.Context) string {
+	if userID, ok := GetUserIDFromContext(c); ok && userID != "" {
+		return "user:" + userID
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// requestFingerprint identifies the payload a key was first used with. The
+// path includes the route parameters, so the same body sent to another
+// resource does not count as a retry.
+func requestFingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replayableHeader returns the response headers worth replaying; the request
+// ID belongs to the retry, not to the original request.
+func replayableHeader(h http.Header) map[string][]string {
+	out := make(map[string][]string, len(h))
+	for name, values := range h {
+		if name == http.CanonicalHeaderKey(RequestIDHeader) {
+			continue
+		}
+		out[name] = values
+	}
+	return out
+}
+
+// capturingWriter keeps a copy of the response body.
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
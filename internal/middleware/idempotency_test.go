@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	rawRedis "github.com/redis/go-redis/v9"
+
+	"egaldeutsch-be/internal/redis"
+)
+
+func newTestIdempotency(t *testing.T) *Idempotency {
+	mr := miniredis.RunT(t)
+	client := rawRedis.NewClient(&rawRedis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewIdempotency(&redis.RedisClient{Client: client}, time.Hour, 1024)
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	idem := newTestIdempotency(t)
+
+	calls := 0
+	r := gin.New()
+	r.Use(Errors())
+	r.POST("/things", func(c *gin.Context) {
+		c.Set("user_id", c.GetHeader("X-User"))
+	}, idem.Middleware(), func(c *gin.Context) {
+		calls++
+		c.Header("Location", "/things/1")
+		c.JSON(http.StatusCreated, gin.H{"call": calls})
+	})
+
+	send := func(user, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/things", strings.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, key)
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send("u1", "k1", `{"a":1}`)
+	if first.Code != http.StatusCreated || first.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Fatalf("unexpected first response %d %v", first.Code, first.Header())
+	}
+
+	retry := send("u1", "k1", `{"a":1}`)
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Fatalf("expected replay of %q got %d %q", first.Body.String(), retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get(IdempotentReplayedHeader) != "true" || retry.Header().Get("Location") != "/things/1" {
+		t.Fatalf("expected replayed headers got %v", retry.Header())
+	}
+	if calls != 1 {
+		t.Fatalf("expected the handler to run once got %d", calls)
+	}
+
+	if w := send("u1", "k1", `{"a":2}`); w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "idempotency_key_reused") {
+		t.Fatalf("expected 409 for a different payload got %d %s", w.Code, w.Body.String())
+	}
+
+	// Keys are scoped per user
+	if w := send("u2", "k1", `{"a":2}`); w.Code != http.StatusCreated || calls != 2 {
+		t.Fatalf("expected another user's key to be independent got %d", w.Code)
+	}
+
+	if w := send("u1", "bad key", `{}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid key got %d", w.Code)
+	}
+}
+
+func TestIdempotencyKeyReusedForAnotherResource(t *testing.T) {
+	idem := newTestIdempotency(t)
+
+	r := gin.New()
+	r.Use(Errors())
+	r.PUT("/x/:id", idem.Middleware(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"id": c.Param("id")})
+	})
+
+	send := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, path, strings.NewReader(`{"a":1}`))
+		req.Header.Set(IdempotencyKeyHeader, "k1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send("/x/1"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 got %d %s", w.Code, w.Body.String())
+	}
+	if w := send("/x/2"); w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "idempotency_key_reused") {
+		t.Fatalf("expected 409 for the same key on another resource got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	idem := newTestIdempotency(t)
+
+	fail := true
+	r := gin.New()
+	r.Use(Errors())
+	r.POST("/things", idem.Middleware(), func(c *gin.Context) {
+		if fail {
+			_ = c.Error(errors.New("database is down"))
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	send := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/things", nil)
+		req.Header.Set(IdempotencyKeyHeader, "k1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := send(); code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 got %d", code)
+	}
+	fail = false
+	if code := send(); code != http.StatusNoContent {
+		t.Fatalf("expected the retry to be processed got %d", code)
+	}
+}
+
+func TestIdempotencyRejectsLargeBodies(t *testing.T) {
+	idem := newTestIdempotency(t)
+
+	calls := 0
+	r := gin.New()
+	r.Use(Errors())
+	r.POST("/things", idem.Middleware(), func(c *gin.Context) {
+		calls++
+		c.Status(http.StatusNoContent)
+	})
+
+	send := func(key, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/things", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	large := strings.Repeat("a", 1025)
+	if code := send("k1", large); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 got %d", code)
+	}
+	if calls != 0 {
+		t.Fatalf("expected the handler not to run, ran %d times", calls)
+	}
+	// Nothing was stored, so the key can still be used
+	if code := send("k1", strings.Repeat("a", 1024)); code != http.StatusNoContent {
+		t.Fatalf("expected a body at the limit to be processed got %d", code)
+	}
+	// Without a key the limit does not apply
+	if code := send("", large); code != http.StatusNoContent {
+		t.Fatalf("expected a request without a key to pass through got %d", code)
+	}
+}
+
+func TestIdempotencyDisabledPassesThrough(t *testing.T) {
+	var idem *Idempotency
+
+	r := gin.New()
+	r.POST("/things", idem.Middleware(), func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	req := httptest.NewRequest(http.MethodPost, "/things", nil)
+	req.Header.Set(IdempotencyKeyHeader, "k1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 got %d", w.Code)
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"egaldeutsch-be/internal/metrics"
+)
+
+var (
+	httpRequests = metrics.NewCounterVec(
+		"http_requests_total",
+		"Total HTTP requests by method, route template and status.",
+		"method", "route", "status",
+	)
+	httpDuration = metrics.NewHistogramVec(
+		"http_request_duration_seconds",
+		"HTTP request latency by method, route template and status.",
+		nil, "method", "route", "status",
+	)
+	rateLimitRejections = metrics.NewCounterVec(
+		"rate_limit_rejections_total",
+		"Requests rejected by the rate limiter by route template.",
+		"route",
+	)
+	idempotentReplays = metrics.NewCounterVec(
+		"idempotent_replays_total",
+		"Responses replayed for a retried Idempotency-Key by route template.",
+		"route",
+	)
+	denylistErrors = metrics.NewCounterVec(
+		"auth_denylist_errors_total",
+		"Access token checks that could not read the denylist.",
+	)
+)
+
+// Metrics returns a gin middleware that records request counts and latency.
+// Routes are labelled by their template (for example /api/v1/users/:id) to
+// keep the number of series bounded.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := routeLabel(c)
+		status := strconv.Itoa(c.Writer.Status())
+		httpRequests.Inc(c.Request.Method, route, status)
+		httpDuration.Observe(time.Since(start).Seconds(), c.Request.Method, route, status)
+	}
+}
+
+// routeLabel returns the matched route template, or a fixed value for
+// requests that did not match any route.
+func routeLabel(c *gin.Context) string {
+	if route := c.FullPath(); route != "" {
+		return route
+	}
+	return "unmatched"
+}
@@ -2,22 +2,113 @@ package middleware
 
 import (
 	"fmt"
+	"io"
+	"runtime/debug"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"egaldeutsch-be/internal/logging"
 )
 
-// Logger returns a gin middleware for logging HTTP requests
-func Logger() gin.HandlerFunc {
-	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		return fmt.Sprintf("[%s] %s %s %d %s %s\n",
-			param.TimeStamp.Format(time.RFC822),
-			param.Method,
-			param.Path,
-			param.StatusCode,
-			param.Latency,
-			param.ClientIP,
-		)
+// RequestIDHeader is the header used to propagate request IDs between services.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client-supplied request IDs.
+const maxRequestIDLength = 128
+
+// RequestID returns a gin middleware that propagates the X-Request-ID header,
+// or generates one, echoes it on the response and stores it together with the
+// route template in the request context for logging. The client IP and user
+// agent are stored as well, for domain events and the audit log.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+
+		c.Set("request_id", id)
+		c.Header(RequestIDHeader, id)
+		ctx := logging.WithRequestID(c.Request.Context(), id, c.FullPath())
+		ctx = logging.WithClient(ctx, c.ClientIP(), c.Request.UserAgent())
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
+
+// validRequestID accepts short, printable ASCII IDs so that clients cannot
+// inject arbitrary data into the logs.
+func validRequestID(id string) bool {
+	return printableASCII(id, maxRequestIDLength)
+}
+
+// printableASCII reports whether s is 1 to max printable ASCII characters
+// without spaces.
+func printableASCII(s string, max int) bool {
+	if s == "" || len(s) > max {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x21 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// Logger returns a gin middleware that writes one structured access log line
+// per request. It must run after RequestID; the user ID is picked up from the
+// request context once authentication middleware has run. Successful requests
+// to quietRoutes (such as probes and metric scrapes) are logged at debug level.
+func Logger(quietRoutes ...string) gin.HandlerFunc {
+	quiet := make(map[string]bool, len(quietRoutes))
+	for _, r := range quietRoutes {
+		quiet[r] = true
+	}
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		entry := logging.FromContext(c.Request.Context()).WithFields(logrus.Fields{
+			"method":     c.Request.Method,
+			"path":       c.Request.URL.Path,
+			"status":     c.Writer.Status(),
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"client_ip":  c.ClientIP(),
+			"bytes":      c.Writer.Size(),
+		})
+		if len(c.Errors) > 0 {
+			entry = entry.WithField("errors", c.Errors.String())
+		}
+
+		switch status := c.Writer.Status(); {
+		case status >= 500:
+			entry.Error("HTTP request")
+		case status >= 400:
+			entry.Warn("HTTP request")
+		case quiet[c.FullPath()]:
+			entry.Debug("HTTP request")
+		default:
+			entry.Info("HTTP request")
+		}
+	}
+}
+
+// Recovery returns a gin middleware that turns panics into a 500 problem and
+// logs them as structured errors with the request fields.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		logging.FromContext(c.Request.Context()).
+			WithField("panic", fmt.Sprint(recovered)).
+			WithField("stack", string(debug.Stack())).
+			Error("Recovered from panic")
+		c.Abort()
+		writeProblem(c, fmt.Errorf("panic: %v", recovered))
 	})
 }
 
@@ -25,8 +116,9 @@ func Logger() gin.HandlerFunc {
 func CORS() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Request-ID, Idempotency-Key")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID, Idempotent-Replayed")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
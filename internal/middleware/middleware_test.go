@@ -1,10 +1,26 @@
 package middleware
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/gin-gonic/gin"
+	rawRedis "github.com/redis/go-redis/v9"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/auth"
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/logging"
+	"egaldeutsch-be/internal/rbac"
+	"egaldeutsch-be/internal/redis"
+	"egaldeutsch-be/pkg/models"
 )
 
 func TestCORS(t *testing.T) {
@@ -22,3 +38,282 @@ func TestCORS(t *testing.T) {
 		t.Fatalf("missing CORS header")
 	}
 }
+
+func TestCORSPreflightAllowsPatch(t *testing.T) {
+	r := gin.New()
+	r.Use(CORS())
+	r.PATCH("/users/me", func(c *gin.Context) { c.String(200, "ok") })
+
+	req := httptest.NewRequest("OPTIONS", "/users/me", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	req.Header.Set("Access-Control-Request-Method", "PATCH")
+	req.Header.Set("Access-Control-Request-Headers", "Authorization, Content-Type")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != 204 {
+		t.Fatalf("expected 204 got %d", w.Code)
+	}
+	methods := strings.Split(w.Header().Get("Access-Control-Allow-Methods"), ", ")
+	if !slices.Contains(methods, "PATCH") {
+		t.Fatalf("expected PATCH in the allowed methods, got %v", methods)
+	}
+}
+
+func TestMetricsLabelsByRouteTemplate(t *testing.T) {
+	r := gin.New()
+	r.Use(Metrics())
+	r.GET("/users/:id", func(c *gin.Context) { c.String(200, "ok") })
+
+	before := httpRequests.Value("GET", "/users/:id", "200")
+	for _, id := range []string{"1", "2"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/"+id, nil))
+	}
+	if got := httpRequests.Value("GET", "/users/:id", "200") - before; got != 2 {
+		t.Fatalf("expected 2 requests on the route template got %v", got)
+	}
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/nope", nil))
+	if httpRequests.Value("GET", "unmatched", "404") == 0 {
+		t.Fatalf("expected unmatched 404 to be counted")
+	}
+}
+
+func TestRateLimitRejectionsAreCounted(t *testing.T) {
+	limiter := NewRateLimiter()
+	defer limiter.Close()
+
+	r := gin.New()
+	r.GET("/limited", limiter.Middleware(1), func(c *gin.Context) { c.String(200, "ok") })
+
+	before := rateLimitRejections.Value("/limited")
+	for range 3 {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/limited", nil))
+	}
+	if got := rateLimitRejections.Value("/limited") - before; got != 2 {
+		t.Fatalf("expected 2 rejections got %v", got)
+	}
+}
+
+func TestRequestIDPropagatesOrGenerates(t *testing.T) {
+	r := gin.New()
+	r.Use(RequestID())
+	var seen string
+	r.GET("/users/:id", func(c *gin.Context) {
+		seen = logging.RequestID(c.Request.Context())
+		c.Status(200)
+	})
+
+	req := httptest.NewRequest("GET", "/users/1", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if seen != "abc-123" || w.Header().Get(RequestIDHeader) != "abc-123" {
+		t.Fatalf("expected propagated id, got ctx=%q header=%q", seen, w.Header().Get(RequestIDHeader))
+	}
+
+	// Invalid IDs are replaced rather than echoed into logs
+	req = httptest.NewRequest("GET", "/users/1", nil)
+	req.Header.Set(RequestIDHeader, "bad id\n")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if seen == "" || seen == "bad id\n" || w.Header().Get(RequestIDHeader) != seen {
+		t.Fatalf("expected generated id, got ctx=%q header=%q", seen, w.Header().Get(RequestIDHeader))
+	}
+}
+
+func TestErrorsRendersProblems(t *testing.T) {
+	r := gin.New()
+	r.Use(RequestID(), Errors())
+	r.GET("/missing", func(c *gin.Context) {
+		_ = c.Error(apperr.NotFound("thing_not_found", "Thing not found."))
+	})
+	r.GET("/internal", func(c *gin.Context) {
+		_ = c.Error(errors.New("pq: connection refused"))
+	})
+	type input struct {
+		Email string `json:"email" binding:"required,email"`
+		Age   int    `json:"age" binding:"min=18"`
+	}
+	r.POST("/validate", func(c *gin.Context) {
+		var in input
+		if err := c.ShouldBindJSON(&in); err != nil {
+			_ = c.Error(apperr.FromBinding(err))
+		}
+	})
+
+	decode := func(w *httptest.ResponseRecorder) apperr.Problem {
+		t.Helper()
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, apperr.ContentType) {
+			t.Fatalf("expected %s got %q", apperr.ContentType, ct)
+		}
+		var p apperr.Problem
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatalf("decode problem: %v", err)
+		}
+		return p
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/missing", nil))
+	p := decode(w)
+	if w.Code != 404 || p.Status != 404 || p.Code != "thing_not_found" || p.Instance != "/missing" || p.RequestID == "" {
+		t.Fatalf("unexpected not found problem %d %+v", w.Code, p)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/internal", nil))
+	p = decode(w)
+	if w.Code != 500 || p.Code != apperr.CodeInternal || strings.Contains(w.Body.String(), "connection refused") {
+		t.Fatalf("internal error leaked or wrong status %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/validate", strings.NewReader(`{"email":"nope","age":3}`)))
+	p = decode(w)
+	if w.Code != 400 || p.Code != apperr.CodeValidation || len(p.Errors) != 2 {
+		t.Fatalf("unexpected validation problem %d %+v", w.Code, p)
+	}
+	if p.Errors[0].Field != "email" || p.Errors[1].Field != "age" || p.Errors[1].Message != "must be at least 18" {
+		t.Fatalf("unexpected field errors %+v", p.Errors)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/validate", strings.NewReader(`{"email":`)))
+	if p = decode(w); w.Code != 400 || p.Code != apperr.CodeBadRequest {
+		t.Fatalf("unexpected malformed body problem %d %+v", w.Code, p)
+	}
+}
+
+type verifierFunc func(userID string) (bool, error)
+
+func (f verifierFunc) IsEmailVerified(ctx context.Context, userID string) (bool, error) {
+	return f(userID)
+}
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	verifier := verifierFunc(func(userID string) (bool, error) {
+		if userID == "broken" {
+			return false, errors.New("db down")
+		}
+		return userID == "verified", nil
+	})
+
+	tests := []struct {
+		name     string
+		verifier EmailVerifier
+		userID   string
+		want     int
+	}{
+		{"verified", verifier, "verified", 200},
+		{"unverified", verifier, "new", 403},
+		{"no user", verifier, "", 403},
+		{"lookup fails", verifier, "broken", 500},
+		{"policy off", nil, "new", 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				if tt.userID != "" {
+					c.Set("user_id", tt.userID)
+				}
+			}, RequireVerifiedEmail(tt.verifier))
+			r.GET("/chat", func(c *gin.Context) { c.String(200, "ok") })
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "/chat", nil))
+			if w.Code != tt.want {
+				t.Fatalf("expected %d got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequireOwnerOrPermission(t *testing.T) {
+	policy, err := rbac.NewPolicy(config.RBACConfig{Roles: map[string]config.RoleConfig{
+		"learner": {},
+		"admin":   {Permissions: []string{string(rbac.UsersRead)}},
+	}})
+	if err != nil {
+		t.Fatalf("policy: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		policy *rbac.Policy
+		userID string
+		role   models.UserRole
+		scopes []string // nil for an access token
+		want   int
+	}{
+		{"owner", policy, "u1", models.UserRoleLearner, nil, 200},
+		{"other user", policy, "u2", models.UserRoleLearner, nil, 403},
+		{"permission", policy, "u2", models.UserRoleAdmin, nil, 200},
+		{"no user", policy, "", "", nil, 403},
+		{"no policy", nil, "u2", models.UserRoleAdmin, nil, 403},
+		{"api key of owner", policy, "u1", models.UserRoleLearner, []string{string(rbac.UsersRead)}, 403},
+		{"api key in scope", policy, "u2", models.UserRoleAdmin, []string{string(rbac.UsersRead)}, 200},
+		{"api key out of scope", policy, "u2", models.UserRoleAdmin, []string{string(rbac.QuizWrite)}, 403},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				if tt.userID != "" {
+					c.Set("user_id", tt.userID)
+					c.Set("user_role", tt.role)
+				}
+				if tt.scopes != nil {
+					c.Set("api_key_scopes", tt.scopes)
+				}
+			})
+			r.GET("/users/:id", RequireOwnerOrPermission(tt.policy, rbac.UsersRead, "id"), func(c *gin.Context) { c.String(200, "ok") })
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "/users/u1", nil))
+			if w.Code != tt.want {
+				t.Fatalf("expected %d got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthDenylistUnavailable(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := rawRedis.NewClient(&rawRedis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	denylist := auth.NewDenylist(&redis.RedisClient{Client: client}, time.Hour)
+	mr.Close()
+
+	cfg := config.JwtConfig{
+		SecretKey:       "this-is-a-very-secure-secret-key-with-32-plus-characters",
+		Issuer:          "egaldeutsch",
+		ExpirationHours: 1,
+	}
+	token, err := auth.NewJWTService(cfg).CreateAccessTokenFromStrings("0b6c1f1e-9c44-4c1e-8a45-2f3a4b5c6d7e", "learner")
+	if err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+
+	request := func(failOpen bool) int {
+		cfg.RevocationFailOpen = failOpen
+		r := gin.New()
+		r.GET("/me", AuthMiddleware(cfg, denylist, nil), func(c *gin.Context) { c.String(200, "ok") })
+		req := httptest.NewRequest("GET", "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	before := denylistErrors.Value()
+	if code := request(false); code != 503 {
+		t.Fatalf("expected 503 while the denylist is unavailable got %d", code)
+	}
+	if code := request(true); code != 200 {
+		t.Fatalf("expected fail-open to accept the token got %d", code)
+	}
+	if got := denylistErrors.Value() - before; got != 2 {
+		t.Fatalf("expected 2 denylist errors to be counted got %v", got)
+	}
+}
@@ -6,14 +6,19 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"egaldeutsch-be/internal/apperr"
 )
 
+var errRateLimited = apperr.New(http.StatusTooManyRequests, apperr.CodeRateLimited, "Too many requests; try again later.")
+
 // RateLimiter handles rate limiting logic.
 // Following Go philosophy: explicit dependencies, no global state.
 type RateLimiter struct {
 	mu       sync.Mutex
 	requests map[string]map[string][]time.Time // Endpoint -> Client IP -> Requests
 	cleanup  chan struct{}                     // Channel to signal cleanup goroutine shutdown
+	closed   sync.Once
 }
 
 // NewRateLimiter creates a new rate limiter instance.
@@ -28,8 +33,9 @@ func NewRateLimiter() *RateLimiter {
 }
 
 // Close stops the cleanup goroutine and releases resources.
+// It is safe to call Close more than once.
 func (rl *RateLimiter) Close() {
-	close(rl.cleanup)
+	rl.closed.Do(func() { close(rl.cleanup) })
 }
 
 // Middleware returns a Gin middleware function that enforces rate limiting.
@@ -43,7 +49,8 @@ func (rl *RateLimiter) Middleware(requestsPerMinute int) gin.HandlerFunc {
 		route := c.Request.URL.Path
 
 		if !rl.Allow(clientIP, route, requestsPerMinute) {
-			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+			rateLimitRejections.Inc(routeLabel(c))
+			abortWithError(c, errRateLimited)
 			return
 		}
 
@@ -138,3 +145,9 @@ var defaultRateLimiter = NewRateLimiter()
 func RateLimit(limit int) gin.HandlerFunc {
 	return defaultRateLimiter.Middleware(limit)
 }
+
+// CloseDefaultRateLimiter stops the cleanup goroutine of the global limiter.
+// The server calls it during shutdown.
+func CloseDefaultRateLimiter() {
+	defaultRateLimiter.Close()
+}
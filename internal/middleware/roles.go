@@ -1,34 +1,58 @@
 package middleware
 
 import (
-	"net/http"
+	"slices"
 
-	"egaldeutsch-be/pkg/models"
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/rbac"
 
 	"github.com/gin-gonic/gin"
 )
 
-// RequireRole returns a middleware that allows only users with the given role.
-// It expects `user_id` to already be set in the context by AuthMiddleware and
-// the user role to be present in the user view stored on the context under
-// "user_role" (if you prefer resolving user role lazily, adapt accordingly).
-func RequireRole(role models.UserRole) gin.HandlerFunc {
+var errForbidden = apperr.Forbidden(apperr.CodeForbidden, "You are not allowed to perform this action.")
+
+// HasPermission reports whether the request may use perm: the role stored
+// on the context by AuthMiddleware must have it under policy, and an API
+// key must be scoped to it as well.
+func HasPermission(c *gin.Context, policy *rbac.Policy, perm rbac.Permission) bool {
+	if !policy.Can(GetUserRoleFromContext(c), perm) {
+		return false
+	}
+	if scopes, ok := getAPIKeyScopesFromContext(c); ok {
+		return slices.Contains(scopes, string(perm))
+	}
+	return true
+}
+
+// RequirePermission returns a middleware that allows only requests that
+// have perm (see HasPermission).
+func RequirePermission(policy *rbac.Policy, perm rbac.Permission) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// The simplest approach: expect the role to be present on the context
-		v, ok := c.Get("user_role")
-		if !ok {
-			//log
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		if !HasPermission(c, policy, perm) {
+			abortWithError(c, errForbidden)
 			return
 		}
-		if r, ok := v.(models.UserRole); ok {
-			if r != role {
-				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
-				return
-			}
+		c.Next()
+	}
+}
+
+// RequireOwnerOrPermission returns a middleware that allows the user named
+// by the path parameter param, and requests that have perm (see
+// HasPermission). It expects AuthMiddleware to have stored the user of the
+// access token on the context. API keys act only by their scopes, so their
+// user needs perm as well.
+func RequireOwnerOrPermission(policy *rbac.Policy, perm rbac.Permission, param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := GetUserIDFromContext(c)
+		_, apiKey := getAPIKeyScopesFromContext(c)
+		if ok && !apiKey && userID == c.Param(param) {
 			c.Next()
 			return
 		}
-		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		if !HasPermission(c, policy, perm) {
+			abortWithError(c, errForbidden)
+			return
+		}
+		c.Next()
 	}
 }
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"egaldeutsch-be/internal/apperr"
+)
+
+var errEmailNotVerified = apperr.Forbidden("email_not_verified", "Verify your email address first; POST /auth/verify-email/resend sends a new link.")
+
+// EmailVerifier reports whether a user has verified their email address.
+type EmailVerifier interface {
+	IsEmailVerified(ctx context.Context, userID string) (bool, error)
+}
+
+// RequireVerifiedEmail returns a middleware that allows only users who
+// verified their email address. It expects AuthMiddleware to have run. A nil
+// verifier lets every request through, for features the email verification
+// policy does not cover.
+func RequireVerifiedEmail(verifier EmailVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if verifier == nil {
+			c.Next()
+			return
+		}
+		userID, ok := GetUserIDFromContext(c)
+		if !ok {
+			abortWithError(c, errForbidden)
+			return
+		}
+		verified, err := verifier.IsEmailVerified(c.Request.Context(), userID)
+		if err != nil {
+			abortWithError(c, fmt.Errorf("check email verification: %w", err))
+			return
+		}
+		if !verified {
+			abortWithError(c, errEmailNotVerified)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,440 @@
+// Package migrate applies the numbered SQL files in migrations/ to PostgreSQL.
+//
+// Applied versions are recorded in the schema_migrations table together with a
+// checksum of their up script, so edits to an already applied file are
+// detected. Every operation holds a session-level advisory lock, which lets
+// several replicas start at the same time without racing each other.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// nothingApplied is the migrateTo target that reverts every migration. It
+// is below 0, the lowest version.
+const nothingApplied int64 = -1
+
+// advisoryLockID identifies the migration lock; any constant shared by all
+// replicas works.
+const advisoryLockID int64 = 4_820_117_365
+
+var (
+	ErrChecksumMismatch = errors.New("applied migration checksum mismatch")
+	ErrUnknownVersion   = errors.New("unknown migration version")
+	ErrNoDownMigration  = errors.New("migration has no down script")
+)
+
+var filePattern = regexp.MustCompile(`^(\d+)_([A-Za-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is a single versioned schema change.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	HasDown  bool
+	Checksum string // sha256 of Up
+}
+
+// Status describes one migration and whether it has been applied.
+type Status struct {
+	Version          int64
+	Name             string
+	Applied          bool
+	AppliedAt        *time.Time
+	ChecksumMismatch bool
+}
+
+// Load reads and pairs the migration files found at the root of fsys.
+// Files that do not follow the NNNNNN_name.(up|down).sql pattern are ignored.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	hasUp := make(map[int64]bool)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := filePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", entry.Name(), err)
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+
+		switch match[3] {
+		case "up":
+			if hasUp[version] {
+				return nil, fmt.Errorf("duplicate up migration for version %d", version)
+			}
+			hasUp[version] = true
+			m.Up = string(body)
+		case "down":
+			if m.HasDown {
+				return nil, fmt.Errorf("duplicate down migration for version %d", version)
+			}
+			m.HasDown = true
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for version, m := range byVersion {
+		if !hasUp[version] {
+			return nil, fmt.Errorf("migration %d (%s) has no up script", version, m.Name)
+		}
+		sum := sha256.Sum256([]byte(m.Up))
+		m.Checksum = hex.EncodeToString(sum[:])
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Migrator applies and reverts migrations against a PostgreSQL database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New loads the migrations from fsys and returns a Migrator for db.
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Latest returns the highest known migration version, or 0 if there are none.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Pending reports how many known migrations are not applied yet. It does not
+// take the advisory lock, so it is cheap enough for readiness probes.
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return 0, fmt.Errorf("query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]bool)
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return 0, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		applied[version] = true
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, mig := range m.migrations {
+		if !applied[mig.Version] {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// Up applies every pending migration and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	var count int
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		var err error
+		count, err = m.migrateTo(ctx, conn, m.Latest())
+		return err
+	})
+	return count, err
+}
+
+// Down reverts the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		found := false
+		target := nothingApplied
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			if _, ok := applied[m.migrations[i].Version]; !ok {
+				continue
+			}
+			// target is the previous known version, if any
+			found = true
+			if i > 0 {
+				target = m.migrations[i-1].Version
+			}
+			break
+		}
+		if !found {
+			logrus.Info("No applied migrations to revert")
+			return nil
+		}
+
+		_, err = m.migrateTo(ctx, conn, target)
+		return err
+	})
+}
+
+// To migrates up or down until exactly the migrations up to version are
+// applied. Version 0 reverts everything, migration 0 included.
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	target := version
+	if version == 0 {
+		target = nothingApplied
+	}
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		_, err := m.migrateTo(ctx, conn, target)
+		return err
+	})
+}
+
+// Status reports every known migration and whether it is applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			st := Status{Version: mig.Version, Name: mig.Name}
+			if a, ok := applied[mig.Version]; ok {
+				appliedAt := a.appliedAt
+				st.Applied = true
+				st.AppliedAt = &appliedAt
+				st.ChecksumMismatch = a.checksum != mig.Checksum
+			}
+			statuses = append(statuses, st)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// migrateTo applies pending migrations up to target and reverts applied
+// migrations above it. It must run while the advisory lock is held.
+func (m *Migrator) migrateTo(ctx context.Context, conn *sql.Conn, target int64) (int, error) {
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, mig := range m.migrations {
+		if a, ok := applied[mig.Version]; ok && a.checksum != mig.Checksum {
+			return 0, fmt.Errorf("%w: %06d_%s was modified after it was applied", ErrChecksumMismatch, mig.Version, mig.Name)
+		}
+	}
+
+	count := 0
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok || mig.Version <= target {
+			continue
+		}
+		if err := revert(ctx, conn, mig); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok || mig.Version > target {
+			continue
+		}
+		if err := apply(ctx, conn, mig); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// withLock runs fn on a dedicated connection holding the migration advisory
+// lock. Advisory locks belong to a session, so every statement of fn must use
+// the same connection.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", advisoryLockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		// Use a fresh context so the lock is released even if ctx was cancelled
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", advisoryLockID); err != nil {
+			logrus.WithError(err).Warn("Failed to release migration lock")
+		}
+	}()
+
+	if err := ensureTable(ctx, conn); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// ensureTable creates schema_migrations. A table left behind by the
+// golang-migrate CLI has a different layout and is moved aside; every
+// migration in this repository is idempotent so re-running them is safe.
+func ensureTable(ctx context.Context, conn *sql.Conn) error {
+	var legacy bool
+	err := conn.QueryRowContext(ctx, `SELECT EXISTS (
+		SELECT 1 FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = 'schema_migrations' AND column_name = 'dirty'
+	)`).Scan(&legacy)
+	if err != nil {
+		return fmt.Errorf("inspect schema_migrations: %w", err)
+	}
+	if legacy {
+		logrus.Warn("Renaming golang-migrate schema_migrations table to schema_migrations_legacy")
+		if _, err := conn.ExecContext(ctx, "ALTER TABLE schema_migrations RENAME TO schema_migrations_legacy"); err != nil {
+			return fmt.Errorf("rename legacy schema_migrations: %w", err)
+		}
+	}
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+type appliedMigration struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (map[int64]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]appliedMigration)
+	for rows.Next() {
+		var version int64
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// apply runs the up script and records the version in a single transaction.
+func apply(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+		return fmt.Errorf("apply %06d_%s: %w", mig.Version, mig.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)",
+		mig.Version, mig.Name, mig.Checksum,
+	); err != nil {
+		return fmt.Errorf("record %06d_%s: %w", mig.Version, mig.Name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	logrus.Infof("Applied migration %06d_%s", mig.Version, mig.Name)
+	return nil
+}
+
+// revert runs the down script and removes the version in a single transaction.
+func revert(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	if !mig.HasDown {
+		return fmt.Errorf("%w: %06d_%s", ErrNoDownMigration, mig.Version, mig.Name)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+		return fmt.Errorf("revert %06d_%s: %w", mig.Version, mig.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", mig.Version); err != nil {
+		return fmt.Errorf("unrecord %06d_%s: %w", mig.Version, mig.Name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	logrus.Infof("Reverted migration %06d_%s", mig.Version, mig.Name)
+	return nil
+}
@@ -0,0 +1,90 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"egaldeutsch-be/migrations"
+)
+
+func TestLoadPairsAndSortsMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000002_add_email.up.sql":   {Data: []byte("ALTER TABLE users ADD COLUMN email text;")},
+		"000002_add_email.down.sql": {Data: []byte("ALTER TABLE users DROP COLUMN email;")},
+		"000001_init.up.sql":        {Data: []byte("CREATE TABLE users (id uuid);")},
+		"README.md":                 {Data: []byte("ignored")},
+	}
+
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("expected 2 migrations got %d", len(migrations))
+	}
+	if migrations[0].Version != 1 || migrations[1].Version != 2 {
+		t.Fatalf("expected versions 1,2 got %d,%d", migrations[0].Version, migrations[1].Version)
+	}
+	if migrations[0].HasDown {
+		t.Fatalf("expected migration 1 to have no down script")
+	}
+	if !migrations[1].HasDown || migrations[1].Name != "add_email" {
+		t.Fatalf("unexpected migration 2: %+v", migrations[1])
+	}
+	if migrations[0].Checksum == "" || migrations[0].Checksum == migrations[1].Checksum {
+		t.Fatalf("expected distinct checksums")
+	}
+}
+
+func TestLoadRejectsInvalidSets(t *testing.T) {
+	tests := []struct {
+		name     string
+		fsys     fstest.MapFS
+		errorMsg string
+	}{
+		{
+			name: "down without up",
+			fsys: fstest.MapFS{
+				"000001_init.down.sql": {Data: []byte("DROP TABLE users;")},
+			},
+			errorMsg: "has no up script",
+		},
+		{
+			name: "conflicting names",
+			fsys: fstest.MapFS{
+				"000001_init.up.sql":  {Data: []byte("SELECT 1;")},
+				"000001_other.up.sql": {Data: []byte("SELECT 2;")},
+			},
+			errorMsg: "conflicting names",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.fsys)
+			if err == nil {
+				t.Fatalf("expected error containing '%s', got nil", tt.errorMsg)
+			}
+			if !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Fatalf("expected error containing '%s', got '%s'", tt.errorMsg, err.Error())
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	loaded, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("embedded migrations failed to load: %v", err)
+	}
+	// Versions count up from 0, which creates the users table
+	for i, m := range loaded {
+		if m.Version != int64(i) {
+			t.Fatalf("expected contiguous versions, got %d at position %d", m.Version, i)
+		}
+		if !m.HasDown {
+			t.Fatalf("migration %06d_%s has no down script", m.Version, m.Name)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package migrate_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"egaldeutsch-be/internal/migrate"
+	"egaldeutsch-be/internal/testsupport"
+	"egaldeutsch-be/migrations"
+	"egaldeutsch-be/modules/user"
+	"egaldeutsch-be/pkg/models"
+)
+
+func TestExistingUsersStayVerified(t *testing.T) {
+	loaded, err := migrate.Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	backfill := loaded[19]
+	if backfill.Name != "verify_existing_users" {
+		t.Fatalf("expected migration 19 to verify existing users, got %s", backfill.Name)
+	}
+
+	cfg := testsupport.Config()
+	db := testsupport.Database(t)
+	users := user.NewModule(db, cfg.Jwt, cfg.Password, nil, nil, nil, nil, testsupport.Policy(t, cfg))
+	testsupport.Migrate(t, db, users.GetModelsForMigration()...)
+	ctx := context.Background()
+
+	// A teacher from before 000015, and a learner who signed up after it
+	// without verifying their address yet
+	verificationAdded := time.Now().UTC().Add(-24 * time.Hour)
+	teacher, err := users.CreateUser(ctx, "Old Teacher", "teacher@example.com", "secret123", models.UserRoleTeacher)
+	if err != nil {
+		t.Fatalf("create teacher: %v", err)
+	}
+	learner, err := users.CreateUser(ctx, "New Learner", "learner@example.com", "secret123", models.UserRoleLearner)
+	if err != nil {
+		t.Fatalf("create learner: %v", err)
+	}
+	for id, createdAt := range map[string]time.Time{
+		teacher: verificationAdded.Add(-time.Hour),
+		learner: verificationAdded.Add(time.Hour),
+	} {
+		if err := db.Exec("UPDATE users SET email_verified_at = NULL, created_at = ? WHERE id = ?", createdAt, id).Error; err != nil {
+			t.Fatalf("reset user: %v", err)
+		}
+	}
+	if err := db.Exec("CREATE TABLE schema_migrations (version BIGINT PRIMARY KEY, name TEXT NOT NULL, checksum TEXT NOT NULL, applied_at TIMESTAMP NOT NULL)").Error; err != nil {
+		t.Fatalf("create schema_migrations: %v", err)
+	}
+	if err := db.Exec("INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (15, 'add_email_verification', '', ?)", verificationAdded).Error; err != nil {
+		t.Fatalf("record migration 15: %v", err)
+	}
+
+	if err := db.Exec(backfill.Up).Error; err != nil {
+		t.Fatalf("apply %s: %v", backfill.Name, err)
+	}
+
+	if verified, err := users.Service.IsEmailVerified(ctx, teacher); err != nil || !verified {
+		t.Fatalf("expected the existing teacher to keep access, got %v (%v)", verified, err)
+	}
+	if verified, err := users.Service.IsEmailVerified(ctx, learner); err != nil || verified {
+		t.Fatalf("expected the learner who signed up since to verify as usual, got %v (%v)", verified, err)
+	}
+}
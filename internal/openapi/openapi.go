@@ -0,0 +1,335 @@
+// Package openapi builds the OpenAPI 3.1 document of the API from the routes
+// registered on the gin engine and the operations the modules describe.
+// Request and response schemas are derived from the Go types by reflection,
+// including the constraints in their binding tags, so the document follows
+// the DTOs without being edited by hand.
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/rbac"
+)
+
+// Access is the authentication an operation requires.
+type Access int
+
+const (
+	// Public operations need no token.
+	Public Access = iota
+	// Bearer operations need a valid access token.
+	Bearer
+)
+
+// Operation documents one route. Params, Body and Response are values of the
+// Go types the handler binds or writes; only their types are used.
+type Operation struct {
+	Method  string
+	Path    string // gin syntax, e.g. /api/v1/users/:id
+	Tags    []string
+	Summary string
+	// Description is optional longer text, CommonMark allowed.
+	Description string
+	Access      Access
+	// Permission is the permission the role of the access token needs, if
+	// any; it implies Bearer.
+	Permission rbac.Permission
+
+	// Params is a struct whose uri and form tagged fields are the path and
+	// query parameters. Path parameters it does not declare are documented
+	// as strings.
+	Params any
+	// Body is the JSON request body.
+	Body any
+	// Idempotent marks routes that honour the Idempotency-Key header.
+	Idempotent bool
+
+	// Status is the success status, 200 if zero.
+	Status int
+	// Response is the success body; nil for none.
+	Response any
+	// ContentTypes are the media types of the success body, application/json
+	// if empty.
+	ContentTypes []string
+	// Errors lists error statuses besides the ones implied by Access and by
+	// the presence of Params or Body, which are added automatically.
+	Errors []int
+}
+
+// key identifies the route an operation documents.
+func (o Operation) key() string {
+	return o.Method + " " + o.Path
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Document is an OpenAPI 3.1 document.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+	Tags       []Tag                `json:"tags,omitempty"`
+}
+
+// Tag groups operations in the docs page.
+type Tag struct {
+	Name string `json:"name"`
+}
+
+// Components holds the reusable schemas and security schemes.
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme is an OpenAPI security scheme.
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of one path by lower-case method.
+type PathItem map[string]*OperationObject
+
+// OperationObject is an OpenAPI operation.
+type OperationObject struct {
+	OperationID string                `json:"operationId"`
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path or query parameter.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is an OpenAPI request body.
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is an OpenAPI response.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of one content type.
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// bearerScheme is the name of the security scheme of access tokens.
+const bearerScheme = "bearerAuth"
+
+// problemSchema is the component name of the error body.
+const problemSchema = "Problem"
+
+// Build documents the routes that have an operation in ops. Routes without
+// one are left out; see Undocumented.
+func Build(info Info, routes gin.RoutesInfo, ops []Operation) *Document {
+	g := newGenerator()
+	doc := &Document{
+		OpenAPI: "3.1.0",
+		Info:    info,
+		Paths:   map[string]*PathItem{},
+		Components: Components{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]SecurityScheme{
+				bearerScheme: {
+					Type:         "http",
+					Scheme:       "bearer",
+					BearerFormat: "JWT",
+					Description:  "Access token from POST /api/v1/auth/login",
+				},
+			},
+		},
+	}
+	g.named(problemSchema, reflect.TypeOf(apperr.Problem{}))
+
+	byKey := make(map[string]Operation, len(ops))
+	for _, op := range ops {
+		byKey[op.key()] = op
+	}
+
+	tags := map[string]bool{}
+	for _, r := range routes {
+		op, ok := byKey[r.Method+" "+r.Path]
+		if !ok {
+			continue
+		}
+		path, pathParams := convertPath(op.Path)
+		item := doc.Paths[path]
+		if item == nil {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+		(*item)[strings.ToLower(op.Method)] = g.operation(op, pathParams)
+		for _, t := range op.Tags {
+			tags[t] = true
+		}
+	}
+
+	for t := range tags {
+		doc.Tags = append(doc.Tags, Tag{Name: t})
+	}
+	sort.Slice(doc.Tags, func(i, j int) bool { return doc.Tags[i].Name < doc.Tags[j].Name })
+	return doc
+}
+
+// Undocumented returns the routes, as "METHOD /path", that have no operation
+// in ops.
+func Undocumented(routes gin.RoutesInfo, ops []Operation) []string {
+	documented := make(map[string]bool, len(ops))
+	for _, op := range ops {
+		documented[op.key()] = true
+	}
+	var missing []string
+	for _, r := range routes {
+		if key := r.Method + " " + r.Path; !documented[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// convertPath turns gin path parameters (:id, *path) into OpenAPI templates
+// and returns their names.
+func convertPath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			params = append(params, s[1:])
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operation builds the OpenAPI operation of op.
+func (g *generator) operation(op Operation, pathParams []string) *OperationObject {
+	o := &OperationObject{
+		OperationID: operationID(op),
+		Tags:        op.Tags,
+		Summary:     op.Summary,
+		Description: op.Description,
+		Responses:   map[string]Response{},
+	}
+
+	declared := map[string]bool{}
+	if op.Params != nil {
+		for _, p := range g.parameters(reflect.TypeOf(op.Params)) {
+			declared[p.Name] = true
+			o.Parameters = append(o.Parameters, p)
+		}
+	}
+	for _, name := range pathParams {
+		if !declared[name] {
+			o.Parameters = append(o.Parameters, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+
+	if op.Idempotent {
+		maxLength := 255
+		o.Parameters = append(o.Parameters, Parameter{
+			Name:        "Idempotency-Key",
+			In:          "header",
+			Description: "Makes the request safe to retry: a retry with the same key and body replays the first response with an Idempotent-Replayed header; a different body gets a 409.",
+			Schema:      &Schema{Type: "string", MaxLength: &maxLength},
+		})
+	}
+
+	if op.Body != nil {
+		o.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: g.schema(reflect.TypeOf(op.Body))}},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := Response{Description: http.StatusText(status)}
+	if op.Response != nil {
+		types := op.ContentTypes
+		if len(types) == 0 {
+			types = []string{"application/json"}
+		}
+		success.Content = map[string]MediaType{}
+		for _, ct := range types {
+			mt := MediaType{}
+			if strings.Contains(ct, "json") {
+				mt.Schema = g.schema(reflect.TypeOf(op.Response))
+			} else {
+				mt.Schema = &Schema{Type: "string"}
+			}
+			success.Content[ct] = mt
+		}
+	}
+	o.Responses[fmt.Sprint(status)] = success
+
+	errs := append([]int{}, op.Errors...)
+	if op.Idempotent {
+		errs = append(errs, http.StatusConflict)
+	}
+	if op.Params != nil || op.Body != nil || op.Idempotent {
+		errs = append(errs, http.StatusBadRequest)
+	}
+	if op.Access >= Bearer || op.Permission != "" {
+		errs = append(errs, http.StatusUnauthorized)
+		// OpenAPI 3.1 lists the roles a non-OAuth scheme needs here
+		o.Security = []map[string][]string{{bearerScheme: {}}}
+	}
+	if op.Permission != "" {
+		errs = append(errs, http.StatusForbidden)
+		o.Security[0][bearerScheme] = []string{string(op.Permission)}
+	}
+	errs = append(errs, http.StatusInternalServerError)
+	for _, code := range errs {
+		o.Responses[fmt.Sprint(code)] = Response{
+			Description: http.StatusText(code),
+			Content:     map[string]MediaType{apperr.ContentType: {Schema: ref(problemSchema)}},
+		}
+	}
+	return o
+}
+
+// operationID derives a stable operation ID such as getApiV1UsersId.
+func operationID(op Operation) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(op.Method))
+	for _, part := range strings.FieldsFunc(op.Path, func(r rune) bool {
+		return r == '/' || r == ':' || r == '*' || r == '-' || r == '.' || r == '_'
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
@@ -0,0 +1,92 @@
+package openapi
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type itemParams struct {
+	ID     string `uri:"id" binding:"required,uuid"`
+	Expand bool   `form:"expand"`
+}
+
+type createItem struct {
+	Name  string   `json:"name" binding:"required,max=50"`
+	Tags  []string `json:"tags" binding:"omitempty,max=5,dive,min=2"`
+	Level int      `json:"level" binding:"oneof=1 2 3"`
+	Note  *string  `json:"note"`
+}
+
+type item struct {
+	ID    string  `json:"id"`
+	Note  *string `json:"note"`
+	Extra string  `json:"extra,omitempty"`
+}
+
+func TestBuildDerivesSchemasAndErrors(t *testing.T) {
+	routes := gin.RoutesInfo{
+		{Method: http.MethodPut, Path: "/items/:id"},
+		{Method: http.MethodGet, Path: "/other"},
+	}
+	ops := []Operation{{
+		Method: http.MethodPut, Path: "/items/:id", Permission: "items:write",
+		Params: itemParams{}, Body: createItem{}, Response: item{},
+	}}
+
+	doc := Build(Info{Title: "test"}, routes, ops)
+
+	op := (*doc.Paths["/items/{id}"])["put"]
+	if op == nil {
+		t.Fatalf("expected PUT /items/{id}, got paths %v", doc.Paths)
+	}
+	if op.OperationID != "putItemsId" {
+		t.Fatalf("unexpected operation ID %q", op.OperationID)
+	}
+	if len(op.Parameters) != 2 || op.Parameters[0].In != "path" || op.Parameters[0].Schema.Format != "uuid" || op.Parameters[1].In != "query" {
+		t.Fatalf("unexpected parameters %+v", op.Parameters)
+	}
+	for _, code := range []string{"200", "400", "401", "403", "500"} {
+		if _, ok := op.Responses[code]; !ok {
+			t.Fatalf("expected a %s response, got %v", code, op.Responses)
+		}
+	}
+	if len(op.Security) != 1 || len(op.Security[0][bearerScheme]) != 1 || op.Security[0][bearerScheme][0] != "items:write" {
+		t.Fatalf("expected bearer security with the permission, got %v", op.Security)
+	}
+
+	body := doc.Components.Schemas["createItem"]
+	if body == nil {
+		t.Fatalf("expected createItem schema, got %v", doc.Components.Schemas)
+	}
+	if len(body.Required) != 1 || body.Required[0] != "name" {
+		t.Fatalf("expected only name to be required, got %v", body.Required)
+	}
+	if *body.Properties["name"].MaxLength != 50 {
+		t.Fatalf("expected name max length 50")
+	}
+	tags := body.Properties["tags"]
+	if *tags.MaxItems != 5 || *tags.Items.MinLength != 2 {
+		t.Fatalf("expected tags max items 5 and item min length 2: %+v", tags)
+	}
+	if len(body.Properties["level"].Enum) != 3 {
+		t.Fatalf("expected level enum, got %+v", body.Properties["level"])
+	}
+
+	resp := doc.Components.Schemas["item"]
+	if len(resp.Required) != 1 || resp.Required[0] != "id" {
+		t.Fatalf("expected only id to be required in the response, got %v", resp.Required)
+	}
+	if types, ok := resp.Properties["note"].Type.([]any); !ok || len(types) != 2 {
+		t.Fatalf("expected nullable note, got %+v", resp.Properties["note"])
+	}
+	if doc.Components.Schemas[problemSchema] == nil {
+		t.Fatalf("expected the problem schema")
+	}
+
+	missing := Undocumented(routes, ops)
+	if len(missing) != 1 || missing[0] != "GET /other" {
+		t.Fatalf("expected GET /other to be undocumented, got %v", missing)
+	}
+}
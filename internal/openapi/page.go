@@ -0,0 +1,18 @@
+package openapi
+
+import _ "embed"
+
+// Page documents the body of paginated list responses.
+type Page[T any] struct {
+	Items      []T   `json:"items"`
+	Page       int   `json:"page"`
+	PerPage    int   `json:"per_page"`
+	TotalItems int64 `json:"total_items"`
+	TotalPages int   `json:"total_pages"`
+}
+
+// DocsPage is a self-contained HTML page that renders the document served
+// next to it at openapi.json.
+//
+//go:embed docs.html
+var DocsPage []byte
@@ -0,0 +1,287 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Schema is a JSON Schema (2020-12) object as used by OpenAPI 3.1.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"` // a type name, or a list to allow null
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+}
+
+// ref returns a reference to a component schema.
+func ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	uuidType      = reflect.TypeOf(uuid.UUID{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// generator derives schemas from Go types. Named struct types become
+// component schemas, referenced by their type name.
+type generator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func newGenerator() *generator {
+	return &generator{schemas: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+// schema returns the schema of t, registering named structs as components.
+func (g *generator) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			if t.Implements(marshalerType) {
+				return &Schema{} // raw JSON such as json.RawMessage
+			}
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" || strings.Contains(t.Name(), "[") {
+			return g.object(t) // anonymous and generic structs are inlined
+		}
+		return g.named(t.Name(), t)
+	default:
+		return &Schema{}
+	}
+}
+
+// named registers t as a component schema and returns a reference to it.
+// A different type with a name already taken is qualified by its package.
+func (g *generator) named(name string, t reflect.Type) *Schema {
+	if n, ok := g.names[t]; ok {
+		return ref(n)
+	}
+	if _, taken := g.schemas[name]; taken {
+		pkg := t.PkgPath()
+		pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	g.names[t] = name
+	g.schemas[name] = &Schema{} // placeholder for recursive types
+	g.schemas[name] = g.object(t)
+	return ref(name)
+}
+
+// object returns the inline object schema of struct t.
+func (g *generator) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.addFields(s, t)
+	return s
+}
+
+// addFields adds the JSON fields of struct t to s, flattening embedded
+// structs the way encoding/json does.
+func (g *generator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(s, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		binding := f.Tag.Get("binding")
+		prop := g.schema(f.Type)
+		if f.Type.Kind() == reflect.Pointer && prop.Ref == "" && prop.Type != nil {
+			prop.Type = []any{prop.Type, "null"}
+		}
+		applyRules(prop, f.Type, binding)
+		s.Properties[name] = prop
+
+		// Request fields are required when their binding says so; response
+		// fields are always present unless omitempty
+		omitempty := strings.Contains(opts, "omitempty")
+		if hasRule(binding, "required") || (binding == "" && !omitempty && f.Type.Kind() != reflect.Pointer) {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// parameters returns the path (uri tag) and query (form tag) parameters of
+// struct t. Embedded structs contribute their parameters, so a path and a
+// query DTO can be documented together.
+func (g *generator) parameters(t reflect.Type) []Parameter {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var params []Parameter
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			params = append(params, g.parameters(f.Type)...)
+			continue
+		}
+		in, name := "path", f.Tag.Get("uri")
+		if name == "" {
+			in, name = "query", f.Tag.Get("form")
+		}
+		name, _, _ = strings.Cut(name, ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		binding := f.Tag.Get("binding")
+		schema := g.schema(f.Type)
+		applyRules(schema, f.Type, binding)
+		params = append(params, Parameter{
+			Name:     name,
+			In:       in,
+			Required: in == "path" || hasRule(binding, "required"),
+			Schema:   schema,
+		})
+	}
+	return params
+}
+
+// hasRule reports whether the binding tag contains rule before any dive.
+func hasRule(binding, rule string) bool {
+	for _, r := range strings.Split(binding, ",") {
+		if r == "dive" {
+			return false
+		}
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// applyRules adds the constraints of validator rules to s. Rules after dive
+// apply to the items of a slice.
+func applyRules(s *Schema, t reflect.Type, binding string) {
+	if binding == "" || s.Ref != "" {
+		return
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	rules := strings.Split(binding, ",")
+	for i, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "dive":
+			if s.Items != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+				applyRules(s.Items, t.Elem(), strings.Join(rules[i+1:], ","))
+			}
+			return
+		case "email":
+			s.Format = "email"
+		case "uuid", "uuid4":
+			s.Format = "uuid"
+		case "url":
+			s.Format = "uri"
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				if n, err := strconv.ParseFloat(v, 64); err == nil && t.Kind() != reflect.String {
+					s.Enum = append(s.Enum, n)
+				} else {
+					s.Enum = append(s.Enum, v)
+				}
+			}
+		case "min", "gte":
+			setBound(s, t, param, true)
+		case "max", "lte":
+			setBound(s, t, param, false)
+		case "len":
+			setBound(s, t, param, true)
+			setBound(s, t, param, false)
+		case "eqfield":
+			s.Description = "Must equal " + param + "."
+		}
+	}
+}
+
+// setBound sets the lower or upper bound matching the kind of t: length for
+// strings, item count for slices and value for numbers.
+func setBound(s *Schema, t reflect.Type, param string, lower bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	i := int(n)
+	switch t.Kind() {
+	case reflect.String:
+		if lower {
+			s.MinLength = &i
+		} else {
+			s.MaxLength = &i
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if lower {
+			s.MinItems = &i
+		} else {
+			s.MaxItems = &i
+		}
+	default:
+		if lower {
+			s.Minimum = &n
+		} else {
+			s.Maximum = &n
+		}
+	}
+}
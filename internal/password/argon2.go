@@ -0,0 +1,100 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// Argon2id hashes passwords with Argon2id (RFC 9106). Hashes are encoded in
+// the PHC string format:
+//
+//	$argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>
+type Argon2id struct {
+	// Memory is the memory cost in KiB
+	Memory uint32
+	// Iterations is the number of passes over the memory
+	Iterations uint32
+	// Parallelism is the number of lanes
+	Parallelism uint8
+}
+
+// argon2Params are the parameters decoded from a hash.
+type argon2Params struct {
+	version     int
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt, key   []byte
+}
+
+func (a *Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.Memory, a.Iterations, a.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify uses the parameters stored in encoded, not those of a.
+func (a *Argon2id) Verify(encoded string, password string) (bool, error) {
+	p, err := decodeArgon2(encoded)
+	if err != nil {
+		return false, err
+	}
+	key := argon2.IDKey([]byte(password), p.salt, p.iterations, p.memory, p.parallelism, uint32(len(p.key)))
+	return subtle.ConstantTimeCompare(key, p.key) == 1, nil
+}
+
+func (a *Argon2id) NeedsRehash(encoded string) bool {
+	p, err := decodeArgon2(encoded)
+	if err != nil {
+		return true
+	}
+	return p.version != argon2.Version || p.memory != a.Memory || p.iterations != a.Iterations ||
+		p.parallelism != a.Parallelism || len(p.key) != argon2KeyLength
+}
+
+func (a *Argon2id) recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func decodeArgon2(encoded string) (*argon2Params, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, ErrUnknownHash
+	}
+	var p argon2Params
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &p.version); err != nil {
+		return nil, fmt.Errorf("argon2id hash version: %w", err)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism); err != nil {
+		return nil, fmt.Errorf("argon2id hash parameters: %w", err)
+	}
+	var err error
+	if p.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("argon2id hash salt: %w", err)
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, fmt.Errorf("argon2id hash key: %w", err)
+	}
+	if len(p.key) == 0 {
+		return nil, fmt.Errorf("argon2id hash has no key")
+	}
+	return &p, nil
+}
@@ -0,0 +1,59 @@
+package password
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// breachedTimeout bounds a lookup, which runs while the user waits.
+const breachedTimeout = 3 * time.Second
+
+// breachChecker looks passwords up in a Pwned Passwords range API with
+// k-anonymity: only the first five hex digits of the password's SHA-1 are
+// sent, and the matching suffixes are compared here.
+type breachChecker struct {
+	client *http.Client
+	url    string
+}
+
+func newBreachChecker(url string) *breachChecker {
+	return &breachChecker{client: &http.Client{Timeout: breachedTimeout}, url: url}
+}
+
+// Breached reports whether password appears in a known data breach.
+func (b *breachChecker) Breached(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url+prefix, nil)
+	if err != nil {
+		return false, err
+	}
+	// Padding hides how many suffixes share the prefix from on-path observers
+	req.Header.Set("Add-Padding", "true")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("breached password lookup: status %d", resp.StatusCode)
+	}
+
+	// Each line is SUFFIX:COUNT; padding lines have a count of 0
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		s, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if ok && s == suffix && count != "0" {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
@@ -0,0 +1,74 @@
+// Package password hashes and verifies passwords and checks new ones against
+// the password policy. Hashes name their algorithm and parameters, so they
+// can be verified after the configuration changes and replaced by hashes
+// with the new parameters at the user's next login.
+package password
+
+import (
+	"errors"
+
+	"egaldeutsch-be/internal/config"
+)
+
+// ErrUnknownHash is returned for a stored hash of no supported algorithm.
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+// PasswordHasher hashes passwords and verifies them against stored hashes.
+type PasswordHasher interface {
+	// Hash returns the encoded hash of password, naming the algorithm and
+	// its parameters.
+	Hash(password string) (string, error)
+
+	// Verify reports whether password matches the encoded hash.
+	Verify(encoded string, password string) (bool, error)
+
+	// NeedsRehash reports whether encoded was made with another algorithm or
+	// other parameters than Hash uses now.
+	NeedsRehash(encoded string) bool
+}
+
+// algorithm is a PasswordHasher that recognizes its own hashes.
+type algorithm interface {
+	PasswordHasher
+	recognizes(encoded string) bool
+}
+
+// NewHasher returns the hasher configured by cfg. It hashes with
+// cfg.Algorithm and verifies the hashes of every supported algorithm, so
+// switching algorithms leaves existing passwords valid.
+func NewHasher(cfg config.PasswordConfig) PasswordHasher {
+	argon := &Argon2id{
+		Memory:      uint32(cfg.Argon2Memory),
+		Iterations:  uint32(cfg.Argon2Iterations),
+		Parallelism: uint8(cfg.Argon2Parallelism),
+	}
+	bc := &Bcrypt{Cost: cfg.BcryptCost}
+	if cfg.Algorithm == config.PasswordBcrypt {
+		return &hasher{current: bc, known: []algorithm{bc, argon}}
+	}
+	return &hasher{current: argon, known: []algorithm{argon, bc}}
+}
+
+// hasher hashes with current and verifies with whichever known algorithm
+// made a hash.
+type hasher struct {
+	current algorithm
+	known   []algorithm
+}
+
+func (h *hasher) Hash(password string) (string, error) {
+	return h.current.Hash(password)
+}
+
+func (h *hasher) Verify(encoded string, password string) (bool, error) {
+	for _, a := range h.known {
+		if a.recognizes(encoded) {
+			return a.Verify(encoded, password)
+		}
+	}
+	return false, ErrUnknownHash
+}
+
+func (h *hasher) NeedsRehash(encoded string) bool {
+	return !h.current.recognizes(encoded) || h.current.NeedsRehash(encoded)
+}
@@ -0,0 +1,167 @@
+package password
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/config"
+)
+
+var testConfig = config.PasswordConfig{
+	Algorithm:         config.PasswordArgon2id,
+	Argon2Memory:      64,
+	Argon2Iterations:  1,
+	Argon2Parallelism: 1,
+	BcryptCost:        4,
+	MinLength:         10,
+	RejectCommon:      true,
+	RejectPersonal:    true,
+}
+
+func TestHasherRoundTrip(t *testing.T) {
+	h := NewHasher(testConfig)
+	hash, err := h.Hash("korrekt Pferd Batterie")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Fatalf("unexpected encoding %q", hash)
+	}
+	again, _ := h.Hash("korrekt Pferd Batterie")
+	if again == hash {
+		t.Fatalf("expected a random salt per hash")
+	}
+	if ok, err := h.Verify(hash, "korrekt Pferd Batterie"); err != nil || !ok {
+		t.Fatalf("expected the password to verify, got %v (%v)", ok, err)
+	}
+	if ok, err := h.Verify(hash, "korrekt Pferd batterie"); err != nil || ok {
+		t.Fatalf("expected a wrong password to fail, got %v (%v)", ok, err)
+	}
+	if h.NeedsRehash(hash) {
+		t.Fatalf("expected a current hash not to need rehashing")
+	}
+	if _, err := h.Verify("plaintext", "plaintext"); err != ErrUnknownHash {
+		t.Fatalf("expected ErrUnknownHash, got %v", err)
+	}
+}
+
+func TestHasherRehashesOutdatedHashes(t *testing.T) {
+	bcryptCfg := testConfig
+	bcryptCfg.Algorithm = config.PasswordBcrypt
+	legacy, err := NewHasher(bcryptCfg).Hash("alte Schreibmaschine")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+
+	h := NewHasher(testConfig)
+	if ok, err := h.Verify(legacy, "alte Schreibmaschine"); err != nil || !ok {
+		t.Fatalf("expected a bcrypt hash to verify, got %v (%v)", ok, err)
+	}
+	if !h.NeedsRehash(legacy) {
+		t.Fatalf("expected a bcrypt hash to need rehashing")
+	}
+
+	stronger := testConfig
+	stronger.Argon2Iterations = 2
+	current, _ := h.Hash("alte Schreibmaschine")
+	if !NewHasher(stronger).NeedsRehash(current) {
+		t.Fatalf("expected a hash with fewer iterations to need rehashing")
+	}
+	if ok, err := NewHasher(stronger).Verify(current, "alte Schreibmaschine"); err != nil || !ok {
+		t.Fatalf("expected the old parameters to verify, got %v (%v)", ok, err)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	p := NewPolicy(testConfig)
+	cases := []struct {
+		password string
+		ok       bool
+	}{
+		{"kurz", false},
+		{"Password123", false},
+		{"passwort123", false},
+		{"ich-bin-Johanna!", false},
+		{"mueller-1985x", false},
+		{"jm.example-99", true},
+		{"Lernen macht Spaß", true},
+		{strings.Repeat("x", MaxLength+1), false},
+	}
+	for _, c := range cases {
+		err := p.Check(context.Background(), c.password, "Johanna Meier", "mueller-1985@example.com")
+		if c.ok && err != nil {
+			t.Errorf("%q: unexpected error %v", c.password, err)
+		}
+		if !c.ok {
+			if e := apperr.As(err); e == nil || len(e.Fields) != 1 || e.Fields[0].Field != "password" {
+				t.Errorf("%q: expected a validation error on password, got %v", c.password, err)
+			}
+		}
+	}
+
+	lenient := testConfig
+	lenient.RejectCommon, lenient.RejectPersonal = false, false
+	if err := NewPolicy(lenient).Check(context.Background(), "password123", "Password", "password@example.com"); err != nil {
+		t.Fatalf("expected the disabled rules to pass, got %v", err)
+	}
+
+	bcryptCfg := testConfig
+	bcryptCfg.Algorithm = config.PasswordBcrypt
+	if err := NewPolicy(bcryptCfg).Check(context.Background(), strings.Repeat("ü", 40), "", ""); err == nil {
+		t.Fatalf("expected bcrypt's 72 byte limit to be enforced")
+	}
+}
+
+func TestPolicyRejectsBreachedPasswords(t *testing.T) {
+	// SHA-1 of "Lernen macht Spaß", which the fake API reports as breached
+	sum := sha1.Sum([]byte("Lernen macht Spaß"))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	var prefixes []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := strings.TrimPrefix(r.URL.Path, "/range/")
+		prefixes = append(prefixes, prefix)
+		if r.Header.Get("Add-Padding") != "true" {
+			t.Errorf("expected a padded request")
+		}
+		fmt.Fprintf(w, "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n")
+		if prefix == hash[:5] {
+			fmt.Fprintf(w, "%s:42\r\n", hash[5:])
+		}
+	}))
+	defer api.Close()
+
+	cfg := testConfig
+	cfg.RejectBreached, cfg.BreachedAPIURL = true, api.URL+"/range/"
+	p := NewPolicy(cfg)
+	ctx := context.Background()
+
+	err := p.Check(ctx, "Lernen macht Spaß", "", "")
+	if e := apperr.As(err); e == nil || len(e.Fields) != 1 || e.Fields[0].Field != "password" {
+		t.Fatalf("expected the breached password to be rejected, got %v", err)
+	}
+	if len(prefixes) != 1 || prefixes[0] != hash[:5] {
+		t.Fatalf("expected only the hash prefix to be sent, got %v", prefixes)
+	}
+	if err := p.Check(ctx, "jm.example-99", "", ""); err != nil {
+		t.Fatalf("expected a password missing from the range to pass, got %v", err)
+	}
+
+	// A password that breaks a local rule is not sent
+	if err := p.Check(ctx, "kurz", "", ""); err == nil || len(prefixes) != 2 {
+		t.Fatalf("expected a short password to be rejected without a lookup, got %v after %d lookups", err, len(prefixes))
+	}
+
+	// The API being down does not stop anyone from setting a password
+	api.Close()
+	if err := p.Check(ctx, "Lernen macht Spaß", "", ""); err != nil {
+		t.Fatalf("expected the password to be accepted while the API is down, got %v", err)
+	}
+}
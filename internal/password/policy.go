@@ -0,0 +1,122 @@
+package password
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/logging"
+)
+
+// MaxLength bounds passwords hashed with Argon2id, which has no limit of its
+// own, so a huge password cannot tie up the server.
+const MaxLength = 256
+
+// minPersonalLength is the shortest name part or email local part the
+// policy looks for in passwords; shorter ones match too much by chance.
+const minPersonalLength = 4
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+// commonPasswords holds the bundled list, in lower case.
+var commonPasswords = func() map[string]struct{} {
+	set := map[string]struct{}{}
+	scanner := bufio.NewScanner(strings.NewReader(commonPasswordList))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			set[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	return set
+}()
+
+// Policy decides which new passwords are acceptable. Passwords already set
+// are not checked again.
+type Policy struct {
+	cfg config.PasswordConfig
+	// breached is nil unless cfg.RejectBreached
+	breached *breachChecker
+}
+
+// NewPolicy returns the policy configured by cfg.
+func NewPolicy(cfg config.PasswordConfig) *Policy {
+	p := &Policy{cfg: cfg}
+	if cfg.RejectBreached {
+		p.breached = newBreachChecker(cfg.BreachedAPIURL)
+	}
+	return p
+}
+
+// Check returns a validation error for the password field if password breaks
+// the policy for the user with the given name and email address. If the
+// breached password lookup fails, the error is logged and the password
+// accepted, so an outage of the API does not stop sign-ups.
+func (p *Policy) Check(ctx context.Context, password string, name string, email string) error {
+	reason := p.violation(password, name, email)
+	if reason == "" && p.breached != nil {
+		breached, err := p.breached.Breached(ctx, password)
+		if err != nil {
+			logging.FromContext(ctx).WithError(err).Warn("breached password lookup failed; password not checked")
+		} else if breached {
+			reason = "appeared in a data breach; choose another one"
+		}
+	}
+	if reason != "" {
+		return apperr.Validation(apperr.FieldError{Field: "password", Message: reason})
+	}
+	return nil
+}
+
+func (p *Policy) violation(password string, name string, email string) string {
+	if utf8.RuneCountInString(password) < p.cfg.MinLength {
+		return fmt.Sprintf("must be at least %d characters long", p.cfg.MinLength)
+	}
+	if p.cfg.Algorithm == config.PasswordBcrypt && len(password) > BcryptMaxBytes {
+		return fmt.Sprintf("must be at most %d bytes long", BcryptMaxBytes)
+	}
+	if utf8.RuneCountInString(password) > MaxLength {
+		return fmt.Sprintf("must be at most %d characters long", MaxLength)
+	}
+
+	lower := strings.ToLower(password)
+	if p.cfg.RejectCommon {
+		if _, ok := commonPasswords[lower]; ok {
+			return "is too common; choose one that is harder to guess"
+		}
+	}
+	if p.cfg.RejectPersonal {
+		for _, part := range personalParts(name, email) {
+			if strings.Contains(lower, part) {
+				return "must not contain your name or email address"
+			}
+		}
+	}
+	return ""
+}
+
+// personalParts returns the words of name and of the local part of email,
+// and that local part as a whole, in lower case and long enough to look for
+// in a password.
+func personalParts(name string, email string) []string {
+	notAlnum := func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }
+	words := strings.FieldsFunc(strings.ToLower(name), notAlnum)
+	if local, _, ok := strings.Cut(strings.ToLower(email), "@"); ok {
+		words = append(words, local)
+		words = append(words, strings.FieldsFunc(local, notAlnum)...)
+	}
+	parts := words[:0]
+	for _, w := range words {
+		if utf8.RuneCountInString(w) >= minPersonalLength {
+			parts = append(parts, w)
+		}
+	}
+	return parts
+}
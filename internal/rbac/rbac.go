@@ -0,0 +1,114 @@
+// Package rbac decides what users may do. Permissions, named
+// resource:action, are granted to roles; a role also has every permission
+// of the roles it inherits from. The policy comes from the rbac section of
+// the configuration.
+package rbac
+
+import (
+	"fmt"
+	"slices"
+
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/pkg/models"
+)
+
+// Permission allows an action on a kind of resource.
+type Permission string
+
+// Permissions checked by the API.
+const (
+	// ChatWrite joins chat rooms, reads their history and creates rooms
+	ChatWrite Permission = "chat:write"
+	// ChatModerate clears the history of chat rooms
+	ChatModerate Permission = "chat:moderate"
+	// QuizWrite creates quiz questions
+	QuizWrite Permission = "quiz:write"
+	// UsersRead lists and views any user and their sessions
+	UsersRead Permission = "users:read"
+	// UsersWrite changes and deletes any user, ends their sessions, resets
+	// their two-factor authentication and unlocks their login
+	UsersWrite Permission = "users:write"
+	// AuditRead reads, exports and verifies the audit log
+	AuditRead Permission = "audit:read"
+	// SystemManage manages background jobs, the event outbox and the
+	// two-factor authentication policy
+	SystemManage Permission = "system:manage"
+)
+
+// Permissions lists every permission the API checks.
+var Permissions = []Permission{
+	ChatWrite, ChatModerate, QuizWrite, UsersRead, UsersWrite, AuditRead, SystemManage,
+}
+
+// Policy maps roles to their permissions, inherited ones included.
+type Policy struct {
+	grants map[models.UserRole][]Permission
+}
+
+// NewPolicy resolves the roles of cfg. It fails for roles users cannot
+// have, permissions the API does not check and roles inheriting from
+// themselves.
+func NewPolicy(cfg config.RBACConfig) (*Policy, error) {
+	for name, role := range cfg.Roles {
+		if !models.UserRole(name).IsValid() {
+			return nil, fmt.Errorf("unknown role %q", name)
+		}
+		for _, perm := range role.Permissions {
+			if !slices.Contains(Permissions, Permission(perm)) {
+				return nil, fmt.Errorf("role %q: unknown permission %q", name, perm)
+			}
+		}
+	}
+
+	p := &Policy{grants: map[models.UserRole][]Permission{}}
+	resolving := map[string]bool{}
+	var resolve func(name string) ([]Permission, error)
+	resolve = func(name string) ([]Permission, error) {
+		if perms, ok := p.grants[models.UserRole(name)]; ok {
+			return perms, nil
+		}
+		if resolving[name] {
+			return nil, fmt.Errorf("role %q inherits from itself", name)
+		}
+		role, ok := cfg.Roles[name]
+		if !ok {
+			return nil, fmt.Errorf("undefined role %q", name)
+		}
+		resolving[name] = true
+		var perms []Permission
+		for _, parent := range role.Inherits {
+			inherited, err := resolve(parent)
+			if err != nil {
+				return nil, err
+			}
+			perms = append(perms, inherited...)
+		}
+		for _, perm := range role.Permissions {
+			perms = append(perms, Permission(perm))
+		}
+		slices.Sort(perms)
+		perms = slices.Compact(perms)
+		p.grants[models.UserRole(name)] = perms
+		return perms, nil
+	}
+	for name := range cfg.Roles {
+		if _, err := resolve(name); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Can reports whether role has perm. The legacy role "user" is treated as
+// learner. A nil policy grants nothing.
+func (p *Policy) Can(role models.UserRole, perm Permission) bool {
+	return slices.Contains(p.Permissions(role), perm)
+}
+
+// Permissions returns the permissions of role, sorted.
+func (p *Policy) Permissions(role models.UserRole) []Permission {
+	if p == nil {
+		return nil
+	}
+	return p.grants[role.Canonical()]
+}
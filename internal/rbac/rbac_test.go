@@ -0,0 +1,59 @@
+package rbac
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/pkg/models"
+)
+
+var testConfig = config.RBACConfig{Roles: map[string]config.RoleConfig{
+	"learner":   {Permissions: []string{"chat:write"}},
+	"teacher":   {Inherits: []string{"learner"}, Permissions: []string{"quiz:write"}},
+	"moderator": {Inherits: []string{"learner"}, Permissions: []string{"chat:moderate", "users:read"}},
+	"admin":     {Inherits: []string{"teacher", "moderator"}, Permissions: []string{"users:write"}},
+}}
+
+func TestPolicyInheritance(t *testing.T) {
+	p, err := NewPolicy(testConfig)
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+
+	want := []Permission{ChatModerate, ChatWrite, QuizWrite, UsersRead, UsersWrite}
+	if got := p.Permissions(models.UserRoleAdmin); !slices.Equal(got, want) {
+		t.Fatalf("admin permissions = %v, want %v", got, want)
+	}
+	if !p.Can(models.UserRoleTeacher, ChatWrite) || p.Can(models.UserRoleTeacher, ChatModerate) {
+		t.Fatalf("teacher should inherit from learner only")
+	}
+	if !p.Can(models.UserRoleUser, ChatWrite) || p.Can(models.UserRoleUser, QuizWrite) {
+		t.Fatalf("the legacy user role should have the permissions of learner")
+	}
+	if p.Can(models.UserRole("root"), ChatWrite) || p.Can("", ChatWrite) {
+		t.Fatalf("unknown roles should have no permissions")
+	}
+
+	var none *Policy
+	if none.Can(models.UserRoleAdmin, UsersRead) {
+		t.Fatalf("a nil policy should grant nothing")
+	}
+}
+
+func TestNewPolicyRejectsUnknownNames(t *testing.T) {
+	for _, c := range []struct {
+		roles map[string]config.RoleConfig
+		want  string
+	}{
+		{map[string]config.RoleConfig{"admin": {Permissions: []string{"quiz:delete"}}}, `unknown permission "quiz:delete"`},
+		{map[string]config.RoleConfig{"superuser": {}}, `unknown role "superuser"`},
+		{map[string]config.RoleConfig{"admin": {Inherits: []string{"teacher"}}, "teacher": {Inherits: []string{"admin"}}}, "inherits from itself"},
+	} {
+		_, err := NewPolicy(config.RBACConfig{Roles: c.roles})
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("expected an error containing %q, got %v", c.want, err)
+		}
+	}
+}
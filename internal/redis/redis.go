@@ -4,10 +4,10 @@ import (
 	"context"
 	"egaldeutsch-be/internal/config"
 	"fmt"
-	"log/slog"
 	"time"
 
 	rawRedis "github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
 )
 
 type RedisClient struct {
@@ -28,7 +28,7 @@ func NewRedisClient(redisConfig config.RedisConfig) (*RedisClient, error) {
 	if err := client.Ping(ctx).Err(); err != nil {
 		return nil, fmt.Errorf("connect to Redis fail: %w", err)
 	}
-	slog.Info("Connected to Redis successfully")
+	logrus.WithField("addr", client.Options().Addr).Info("Connected to Redis successfully")
 	return &RedisClient{Client: client}, nil
 }
 
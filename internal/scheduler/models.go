@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Run statuses recorded in the job history.
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Triggers recorded in the job history.
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// Lease gives one replica the exclusive right to run a job until ExpiresAt.
+// LastSlot is the scheduled time of the last run, so a replica whose timer
+// fires late does not run the same slot again.
+type Lease struct {
+	Name      string    `json:"name" gorm:"primaryKey;size:100"`
+	Owner     string    `json:"owner" gorm:"size:100;not null"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null"`
+	LastSlot  time.Time `json:"last_slot" gorm:"not null"`
+}
+
+// TableName specifies the table name for the Lease model
+func (Lease) TableName() string {
+	return "job_leases"
+}
+
+// Run is one execution of a job.
+type Run struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	Job        string     `json:"job" gorm:"size:100;not null;index:idx_job_runs_job_started,priority:1"`
+	Trigger    string     `json:"trigger" gorm:"size:20;not null"`
+	Status     string     `json:"status" gorm:"size:20;not null"`
+	Attempts   int        `json:"attempts" gorm:"not null"`
+	Error      string     `json:"error,omitempty"`
+	Instance   string     `json:"instance" gorm:"size:100;not null"`
+	StartedAt  time.Time  `json:"started_at" gorm:"not null;index:idx_job_runs_job_started,priority:2,sort:desc"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// TableName specifies the table name for the Run model
+func (Run) TableName() string {
+	return "job_runs"
+}
+
+// BeforeCreate generates the run ID.
+func (r *Run) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// Models returns the scheduler tables for GORM AutoMigrate.
+func Models() []interface{} {
+	return []interface{}{&Lease{}, &Run{}}
+}
@@ -0,0 +1,402 @@
+// Package scheduler runs periodic maintenance jobs in-process. Every replica
+// runs the scheduler; a lease row in the database makes sure each scheduled
+// run happens on one replica only. Runs are recorded in the job_runs table.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"egaldeutsch-be/internal/metrics"
+)
+
+// Defaults applied to jobs that leave the field at zero.
+const (
+	DefaultTimeout = 10 * time.Minute
+	DefaultBackoff = 10 * time.Second
+)
+
+var (
+	// ErrUnknownJob is returned for a job name that is not registered.
+	ErrUnknownJob = errors.New("unknown job")
+	// ErrJobRunning is returned by Trigger while any replica holds the lease.
+	ErrJobRunning = errors.New("job is already running")
+)
+
+var (
+	jobRuns = metrics.NewCounterVec(
+		"scheduler_job_runs_total",
+		"Finished job runs by job and status.",
+		"job", "status",
+	)
+	jobDuration = metrics.NewHistogramVec(
+		"scheduler_job_duration_seconds",
+		"Duration of job runs including retries.",
+		metrics.DefaultBuckets,
+		"job",
+	)
+)
+
+// Job is a unit of periodic work.
+type Job struct {
+	Name        string
+	Description string
+	Schedule    Schedule
+	Run         func(ctx context.Context) error
+
+	// Timeout bounds a single attempt.
+	Timeout time.Duration
+	// MaxRetries is how often a failed attempt is retried.
+	MaxRetries int
+	// Backoff is the delay before the first retry; it doubles for every
+	// further retry.
+	Backoff time.Duration
+}
+
+// leaseTTL covers every attempt and backoff of a run, plus some slack.
+func (j *Job) leaseTTL() time.Duration {
+	backoff := j.Backoff * time.Duration((1<<j.MaxRetries)-1)
+	return time.Duration(j.MaxRetries+1)*j.Timeout + backoff + time.Minute
+}
+
+// JobStatus describes a registered job for listings.
+type JobStatus struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Schedule    string     `json:"schedule"`
+	NextRun     *time.Time `json:"next_run,omitempty"`
+	LastRun     *Run       `json:"last_run,omitempty"`
+}
+
+// Scheduler runs registered jobs on their schedules.
+type Scheduler struct {
+	db       *gorm.DB
+	instance string
+	now      func() time.Time
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+	next map[string]time.Time
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	started bool
+}
+
+// New creates a scheduler that stores leases and history in db.
+func New(db *gorm.DB) *Scheduler {
+	hostname, _ := os.Hostname()
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		db:       db,
+		instance: fmt.Sprintf("%s-%s", hostname, uuid.NewString()[:8]),
+		now:      func() time.Time { return time.Now().UTC() },
+		jobs:     make(map[string]*Job),
+		next:     make(map[string]time.Time),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Register adds a job. It must be called before Start.
+func (s *Scheduler) Register(job Job) error {
+	if job.Name == "" || job.Schedule == nil || job.Run == nil {
+		return fmt.Errorf("job %q needs a name, a schedule and a run function", job.Name)
+	}
+	if job.Timeout <= 0 {
+		job.Timeout = DefaultTimeout
+	}
+	if job.Backoff <= 0 {
+		job.Backoff = DefaultBackoff
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.jobs[job.Name]; exists {
+		return fmt.Errorf("job %q is already registered", job.Name)
+	}
+	s.jobs[job.Name] = &job
+	return nil
+}
+
+// Start launches one goroutine per job. It does not block.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(job)
+	}
+	logrus.WithField("jobs", len(s.jobs)).Info("Scheduler started")
+}
+
+// Stop cancels running jobs and waits for them to record their result,
+// bounded by ctx.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("scheduler did not stop: %w", ctx.Err())
+	}
+}
+
+// Jobs lists the registered jobs with their next and last run.
+func (s *Scheduler) Jobs(ctx context.Context) ([]JobStatus, error) {
+	s.mu.Lock()
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for name, job := range s.jobs {
+		st := JobStatus{Name: name, Description: job.Description, Schedule: job.Schedule.String()}
+		if next, ok := s.next[name]; ok {
+			st.NextRun = &next
+		}
+		statuses = append(statuses, st)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+
+	for i := range statuses {
+		runs, err := s.History(ctx, statuses[i].Name, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(runs) > 0 {
+			statuses[i].LastRun = &runs[0]
+		}
+	}
+	return statuses, nil
+}
+
+// History returns the most recent runs of a job, newest first.
+func (s *Scheduler) History(ctx context.Context, name string, limit int) ([]Run, error) {
+	if !s.known(name) {
+		return nil, ErrUnknownJob
+	}
+	var runs []Run
+	err := s.db.WithContext(ctx).Where("job = ?", name).Order("started_at DESC").Limit(limit).Find(&runs).Error
+	return runs, err
+}
+
+// Trigger runs a job now, in the background, and returns the run ID. It fails
+// with ErrJobRunning while any replica is running the job.
+func (s *Scheduler) Trigger(ctx context.Context, name string) (uuid.UUID, error) {
+	s.mu.Lock()
+	job, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return uuid.Nil, ErrUnknownJob
+	}
+
+	owner, acquired, err := s.acquire(ctx, job, time.Time{})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !acquired {
+		return uuid.Nil, ErrJobRunning
+	}
+
+	run, err := s.startRun(ctx, job, TriggerManual)
+	if err != nil {
+		s.release(job, owner)
+		return uuid.Nil, err
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.execute(job, run, owner)
+	}()
+	return run.ID, nil
+}
+
+func (s *Scheduler) known(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.jobs[name]
+	return ok
+}
+
+// loop waits for each scheduled slot of a job and runs it if this replica
+// wins the lease.
+func (s *Scheduler) loop(job *Job) {
+	defer s.wg.Done()
+	log := logrus.WithField("job", job.Name)
+
+	for {
+		slot := job.Schedule.Next(s.now())
+		if slot.IsZero() {
+			log.Warn("Job schedule has no future run time")
+			return
+		}
+		s.mu.Lock()
+		s.next[job.Name] = slot
+		s.mu.Unlock()
+
+		timer := time.NewTimer(slot.Sub(s.now()))
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		owner, acquired, err := s.acquire(s.ctx, job, slot)
+		if err != nil {
+			log.WithError(err).Error("Failed to acquire job lease")
+			continue
+		}
+		if !acquired {
+			log.Debug("Job is run by another replica")
+			continue
+		}
+
+		run, err := s.startRun(s.ctx, job, TriggerSchedule)
+		if err != nil {
+			log.WithError(err).Error("Failed to record job run")
+			s.release(job, owner)
+			continue
+		}
+		s.execute(job, run, owner)
+	}
+}
+
+// acquire takes the job's lease. A zero slot is a manual run, which only
+// needs the lease to be free; a scheduled run also needs the slot to be newer
+// than the last one, so every slot runs once across replicas.
+func (s *Scheduler) acquire(ctx context.Context, job *Job, slot time.Time) (string, bool, error) {
+	db := s.db.WithContext(ctx)
+	epoch := time.Unix(0, 0).UTC()
+
+	err := db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&Lease{Name: job.Name, Owner: "", ExpiresAt: epoch, LastSlot: epoch}).Error
+	if err != nil {
+		return "", false, fmt.Errorf("create lease: %w", err)
+	}
+
+	now := s.now()
+	owner := s.instance + "/" + uuid.NewString()[:8]
+	updates := map[string]interface{}{"owner": owner, "expires_at": now.Add(job.leaseTTL())}
+	query := db.Model(&Lease{}).Where("name = ? AND expires_at < ?", job.Name, now)
+	if !slot.IsZero() {
+		query = query.Where("last_slot < ?", slot)
+		updates["last_slot"] = slot
+	}
+
+	res := query.Updates(updates)
+	if res.Error != nil {
+		return "", false, fmt.Errorf("take lease: %w", res.Error)
+	}
+	return owner, res.RowsAffected == 1, nil
+}
+
+// release frees the lease if this run still owns it.
+func (s *Scheduler) release(job *Job, owner string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := s.db.WithContext(ctx).Model(&Lease{}).
+		Where("name = ? AND owner = ?", job.Name, owner).
+		Update("expires_at", s.now()).Error
+	if err != nil {
+		logrus.WithError(err).WithField("job", job.Name).Error("Failed to release job lease")
+	}
+}
+
+// startRun records a new run in the history.
+func (s *Scheduler) startRun(ctx context.Context, job *Job, trigger string) (*Run, error) {
+	run := &Run{
+		Job:       job.Name,
+		Trigger:   trigger,
+		Status:    StatusRunning,
+		Instance:  s.instance,
+		StartedAt: s.now(),
+	}
+	if err := s.db.WithContext(ctx).Create(run).Error; err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// execute runs a job with retries, records the result and releases the lease.
+func (s *Scheduler) execute(job *Job, run *Run, owner string) {
+	defer s.release(job, owner)
+	log := logrus.WithFields(logrus.Fields{"job": job.Name, "run_id": run.ID, "trigger": run.Trigger})
+	log.Info("Job started")
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		run.Attempts = attempt
+		err = s.attempt(job)
+		if err == nil || attempt > job.MaxRetries || s.ctx.Err() != nil {
+			break
+		}
+
+		delay := job.Backoff << (attempt - 1)
+		log.WithError(err).WithField("attempt", attempt).Warnf("Job failed, retrying in %s", delay)
+		select {
+		case <-s.ctx.Done():
+		case <-time.After(delay):
+		}
+		if s.ctx.Err() != nil {
+			break
+		}
+	}
+
+	finished := s.now()
+	run.FinishedAt = &finished
+	run.Status = StatusSucceeded
+	if err != nil {
+		run.Status = StatusFailed
+		run.Error = err.Error()
+		log.WithError(err).WithField("attempts", run.Attempts).Error("Job failed")
+	} else {
+		log.WithField("attempts", run.Attempts).Info("Job finished")
+	}
+	jobRuns.Inc(job.Name, run.Status)
+	jobDuration.Observe(finished.Sub(run.StartedAt).Seconds(), job.Name)
+
+	// Record the result even when the scheduler is stopping
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.db.WithContext(ctx).Save(run).Error; err != nil {
+		log.WithError(err).Error("Failed to record job result")
+	}
+}
+
+// attempt runs the job once, bounded by its timeout. A panic fails the attempt.
+func (s *Scheduler) attempt(job *Job) (err error) {
+	ctx, cancel := context.WithTimeout(s.ctx, job.Timeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
@@ -0,0 +1,192 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test db: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(Models()...); err != nil {
+		t.Fatalf("failed to automigrate: %v", err)
+	}
+	return db
+}
+
+func newScheduler(t *testing.T, db *gorm.DB) *Scheduler {
+	t.Helper()
+	s := New(db)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.Stop(ctx); err != nil {
+			t.Errorf("stop: %v", err)
+		}
+	})
+	return s
+}
+
+// waitForRun polls the history until the run has finished.
+func waitForRun(t *testing.T, s *Scheduler, job string, id uuid.UUID) Run {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		runs, err := s.History(context.Background(), job, 10)
+		if err != nil {
+			t.Fatalf("history: %v", err)
+		}
+		for _, r := range runs {
+			if r.ID == id && r.Status != StatusRunning {
+				return r
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("run %s of %s did not finish", id, job)
+	return Run{}
+}
+
+func TestTriggerRecordsHistory(t *testing.T) {
+	s := newScheduler(t, setupDB(t))
+
+	release := make(chan struct{})
+	err := s.Register(Job{
+		Name:     "purge",
+		Schedule: MustParseCron("@daily"),
+		Run: func(ctx context.Context) error {
+			<-release
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	id, err := s.Trigger(context.Background(), "purge")
+	if err != nil {
+		t.Fatalf("trigger: %v", err)
+	}
+	if _, err := s.Trigger(context.Background(), "purge"); !errors.Is(err, ErrJobRunning) {
+		t.Fatalf("expected ErrJobRunning while the job runs, got %v", err)
+	}
+	close(release)
+
+	run := waitForRun(t, s, "purge", id)
+	if run.Status != StatusSucceeded || run.Trigger != TriggerManual || run.Attempts != 1 || run.FinishedAt == nil {
+		t.Fatalf("unexpected run: %+v", run)
+	}
+
+	// The lease is released after the run
+	if _, err := s.Trigger(context.Background(), "purge"); err != nil {
+		t.Fatalf("second trigger: %v", err)
+	}
+
+	if _, err := s.Trigger(context.Background(), "missing"); !errors.Is(err, ErrUnknownJob) {
+		t.Fatalf("expected ErrUnknownJob, got %v", err)
+	}
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	s := newScheduler(t, setupDB(t))
+
+	var calls atomic.Int32
+	s.Register(Job{
+		Name:       "flaky",
+		Schedule:   Every(time.Hour),
+		MaxRetries: 2,
+		Backoff:    time.Millisecond,
+		Run: func(ctx context.Context) error {
+			if calls.Add(1) < 3 {
+				return errors.New("temporary failure")
+			}
+			return nil
+		},
+	})
+	s.Register(Job{
+		Name:       "broken",
+		Schedule:   Every(time.Hour),
+		MaxRetries: 1,
+		Backoff:    time.Millisecond,
+		Run:        func(ctx context.Context) error { panic("boom") },
+	})
+
+	id, _ := s.Trigger(context.Background(), "flaky")
+	if run := waitForRun(t, s, "flaky", id); run.Status != StatusSucceeded || run.Attempts != 3 {
+		t.Fatalf("expected success after 3 attempts, got %+v", run)
+	}
+
+	id, _ = s.Trigger(context.Background(), "broken")
+	run := waitForRun(t, s, "broken", id)
+	if run.Status != StatusFailed || run.Attempts != 2 || run.Error == "" {
+		t.Fatalf("expected failure after 2 attempts, got %+v", run)
+	}
+}
+
+func TestLeaseRunsEachSlotOnce(t *testing.T) {
+	db := setupDB(t)
+	a, b := New(db), New(db)
+	job := &Job{Name: "rooms", Schedule: Every(time.Minute), Timeout: time.Minute}
+	slot := time.Now().UTC().Truncate(time.Minute)
+	ctx := context.Background()
+
+	ownerA, ok, err := a.acquire(ctx, job, slot)
+	if err != nil || !ok {
+		t.Fatalf("replica a should win the lease: %v", err)
+	}
+	if _, ok, _ := b.acquire(ctx, job, slot); ok {
+		t.Fatalf("replica b must not run while a holds the lease")
+	}
+
+	a.release(job, ownerA)
+	if _, ok, _ := b.acquire(ctx, job, slot); ok {
+		t.Fatalf("replica b must not run a slot that already ran")
+	}
+	if _, ok, _ := b.acquire(ctx, job, slot.Add(time.Minute)); !ok {
+		t.Fatalf("replica b should run the next slot")
+	}
+}
+
+func TestScheduledRun(t *testing.T) {
+	s := newScheduler(t, setupDB(t))
+
+	ran := make(chan struct{}, 1)
+	s.Register(Job{
+		Name:     "tick",
+		Schedule: Every(time.Second),
+		Run: func(ctx context.Context) error {
+			select {
+			case ran <- struct{}{}:
+			default:
+			}
+			return nil
+		},
+	})
+	s.Start()
+
+	select {
+	case <-ran:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("scheduled job did not run")
+	}
+
+	jobs, err := s.Jobs(context.Background())
+	if err != nil {
+		t.Fatalf("jobs: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].Schedule != "@every 1s" || jobs[0].NextRun == nil {
+		t.Fatalf("unexpected job listing: %+v", jobs)
+	}
+}
@@ -0,0 +1,326 @@
+package server_test
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+func TestE2E_AuthFlow(t *testing.T) {
+	srv := newTestServer(t)
+	tokens := signUp(t, srv, "learner@example.com", "learner")
+
+	var me struct {
+		User struct {
+			Email string `json:"email"`
+		} `json:"user"`
+	}
+	if status := doJSON(t, http.MethodGet, srv.URL+"/api/v1/auth/me", tokens.AccessToken, nil, &me); status != http.StatusOK {
+		t.Fatalf("me: expected 200, got %d", status)
+	}
+	if me.User.Email != "learner@example.com" {
+		t.Fatalf("me: expected learner@example.com, got %q", me.User.Email)
+	}
+
+	var rotated tokenPair
+	refresh := map[string]string{"refresh_token": tokens.RefreshToken}
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/auth/refresh", "", refresh, &rotated); status != http.StatusOK {
+		t.Fatalf("refresh: expected 200, got %d", status)
+	}
+	if rotated.RefreshToken == "" || rotated.RefreshToken == tokens.RefreshToken {
+		t.Fatalf("refresh: expected a new refresh token")
+	}
+
+	// Replaying the rotated token is reuse and revokes the whole family
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/auth/refresh", "", refresh, nil); status != http.StatusUnauthorized {
+		t.Fatalf("reuse: expected 401, got %d", status)
+	}
+	again := map[string]string{"refresh_token": rotated.RefreshToken}
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/auth/refresh", "", again, nil); status != http.StatusUnauthorized {
+		t.Fatalf("refresh after reuse: expected 401, got %d", status)
+	}
+}
+
+func TestE2E_QuizQuestions(t *testing.T) {
+	srv := newTestServer(t)
+	learner := signUp(t, srv, "learner@example.com", "learner")
+	teacher := signUp(t, srv, "teacher@example.com", "teacher")
+
+	question := map[string]any{
+		"question_text":  "Der, die oder das Haus?",
+		"options":        []string{"der", "die", "das"},
+		"correct_option": 2,
+		"category":       "articles",
+	}
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/quiz/questions", "", question, nil); status != http.StatusUnauthorized {
+		t.Fatalf("create question anonymously: expected 401, got %d", status)
+	}
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/quiz/questions", learner.AccessToken, question, nil); status != http.StatusForbidden {
+		t.Fatalf("create question as learner: expected 403, got %d", status)
+	}
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/quiz/questions", teacher.AccessToken, question, nil); status != http.StatusCreated {
+		t.Fatalf("create question: expected 201, got %d", status)
+	}
+
+	var questions []struct {
+		QuestionText string   `json:"question_text"`
+		Options      []string `json:"options"`
+	}
+	if status := doJSON(t, http.MethodGet, srv.URL+"/api/v1/quiz/questions", "", nil, &questions); status != http.StatusOK {
+		t.Fatalf("list questions: expected 200, got %d", status)
+	}
+	if len(questions) != 1 {
+		t.Fatalf("expected 1 question, got %d", len(questions))
+	}
+	if got := questions[0].Options; len(got) != 3 || got[2] != "das" {
+		t.Fatalf("options did not round-trip: %v", got)
+	}
+
+	// Machine clients create questions with an API key from the auth module
+	var apiKey struct {
+		Key string `json:"key"`
+	}
+	create := map[string]any{"name": "quiz importer", "scopes": []string{"quiz:write"}}
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/auth/api-keys", teacher.AccessToken, create, &apiKey); status != http.StatusCreated {
+		t.Fatalf("create API key: expected 201, got %d", status)
+	}
+	question["question_text"] = "Der, die oder das Auto?"
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/quiz/questions", apiKey.Key, question, nil); status != http.StatusCreated {
+		t.Fatalf("create question with the key: expected 201, got %d", status)
+	}
+}
+
+func TestE2E_ChatRoom(t *testing.T) {
+	srv := newTestServer(t)
+	tokens := signUp(t, srv, "chatter@example.com", "learner")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, srv.WebSocketURL("/api/v1/ws/chat/lobby"), &websocket.DialOptions{
+		Subprotocols: []string{"chat"},
+		HTTPHeader:   http.Header{"Authorization": []string{"Bearer " + tokens.AccessToken}},
+	})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close(websocket.StatusNormalClosure, "")
+
+	type message struct {
+		Type    string `json:"type"`
+		Content string `json:"content"`
+	}
+	read := func() message {
+		t.Helper()
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		var msg message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("decode message %s: %v", data, err)
+		}
+		return msg
+	}
+
+	if msg := read(); msg.Type != "join" {
+		t.Fatalf("expected join message, got %q", msg.Type)
+	}
+	if msg := read(); msg.Type != "room_info" {
+		t.Fatalf("expected room_info message, got %q", msg.Type)
+	}
+
+	if err := conn.Write(ctx, websocket.MessageText, []byte(`{"type":"chat","content":"Hallo!"}`)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if msg := read(); msg.Type != "chat" || msg.Content != "Hallo!" {
+		t.Fatalf("expected chat broadcast, got %+v", msg)
+	}
+
+	var history struct {
+		Count int `json:"count"`
+	}
+	if status := doJSON(t, http.MethodGet, srv.URL+"/api/v1/ws/chat/lobby/history", tokens.AccessToken, nil, &history); status != http.StatusOK {
+		t.Fatalf("history: expected 200, got %d", status)
+	}
+	if history.Count != 1 {
+		t.Fatalf("expected 1 message in history, got %d", history.Count)
+	}
+
+	// Only moderators clear the history
+	if status := doJSON(t, http.MethodDelete, srv.URL+"/api/v1/ws/chat/lobby/history", tokens.AccessToken, nil, nil); status != http.StatusForbidden {
+		t.Fatalf("clear history as learner: expected 403, got %d", status)
+	}
+	moderator := signUp(t, srv, "moderator@example.com", "moderator")
+	if status := doJSON(t, http.MethodDelete, srv.URL+"/api/v1/ws/chat/lobby/history", moderator.AccessToken, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("clear history as moderator: expected 204, got %d", status)
+	}
+}
+
+func TestE2E_DeletedUserSessionsRevoked(t *testing.T) {
+	srv := newTestServer(t)
+	admin := signUp(t, srv, "admin@example.com", "admin")
+	learner := signUp(t, srv, "learner@example.com", "learner")
+
+	var me struct {
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+	}
+	if status := doJSON(t, http.MethodGet, srv.URL+"/api/v1/auth/me", learner.AccessToken, nil, &me); status != http.StatusOK {
+		t.Fatalf("me: expected 200, got %d", status)
+	}
+	if status := doJSON(t, http.MethodDelete, srv.URL+"/api/v1/users/"+me.User.ID, admin.AccessToken, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("delete user: expected 204, got %d", status)
+	}
+
+	// user.deleted is delivered asynchronously; refresh works until the
+	// auth module has revoked the tokens
+	refreshToken := learner.RefreshToken
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var rotated tokenPair
+		status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/auth/refresh", "", map[string]string{"refresh_token": refreshToken}, &rotated)
+		if status == http.StatusUnauthorized {
+			return
+		}
+		if status != http.StatusOK {
+			t.Fatalf("refresh: expected 200 or 401, got %d", status)
+		}
+		refreshToken = rotated.RefreshToken
+		if time.Now().After(deadline) {
+			t.Fatalf("refresh tokens of the deleted user were not revoked")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestE2E_AuditLog(t *testing.T) {
+	srv := newTestServer(t)
+	admin := signUp(t, srv, "admin@example.com", "admin")
+	learner := signUp(t, srv, "learner@example.com", "learner")
+
+	var adminMe, learnerMe struct {
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+	}
+	doJSON(t, http.MethodGet, srv.URL+"/api/v1/auth/me", admin.AccessToken, nil, &adminMe)
+	doJSON(t, http.MethodGet, srv.URL+"/api/v1/auth/me", learner.AccessToken, nil, &learnerMe)
+
+	if status := doJSON(t, http.MethodGet, srv.URL+"/api/v1/admin/audit", learner.AccessToken, nil, nil); status != http.StatusForbidden {
+		t.Fatalf("audit log as learner: expected 403, got %d", status)
+	}
+	moderator := signUp(t, srv, "moderator@example.com", "moderator")
+	if status := doJSON(t, http.MethodGet, srv.URL+"/api/v1/admin/audit", moderator.AccessToken, nil, nil); status != http.StatusForbidden {
+		t.Fatalf("audit log as moderator: expected 403, got %d", status)
+	}
+	promote := map[string]string{"role": "admin"}
+	if status := doJSON(t, http.MethodPut, srv.URL+"/api/v1/users/"+learnerMe.User.ID, admin.AccessToken, promote, nil); status != http.StatusOK {
+		t.Fatalf("promote user: expected 200, got %d", status)
+	}
+	// The role change revokes the tokens issued moments before it
+	if status := doJSON(t, http.MethodGet, srv.URL+"/api/v1/admin/audit", learner.AccessToken, nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("audit log with a token from before the role change: expected 401, got %d", status)
+	}
+
+	// Entries are written when the event relay delivers the role change
+	type entry struct {
+		Action  string            `json:"action"`
+		ActorID string            `json:"actor_id"`
+		Target  string            `json:"target_id"`
+		After   map[string]string `json:"after"`
+	}
+	var found entry
+	deadline := time.Now().Add(5 * time.Second)
+	for found.Action == "" {
+		var page struct {
+			Items []entry `json:"items"`
+		}
+		url := srv.URL + "/api/v1/admin/audit?action=user.role_changed&target_id=" + learnerMe.User.ID
+		if status := doJSON(t, http.MethodGet, url, admin.AccessToken, nil, &page); status != http.StatusOK {
+			t.Fatalf("audit log: expected 200, got %d", status)
+		}
+		if len(page.Items) > 0 {
+			found = page.Items[0]
+		} else if time.Now().After(deadline) {
+			t.Fatalf("role change was not audited")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if found.ActorID != adminMe.User.ID || found.After["role"] != "admin" {
+		t.Fatalf("unexpected audit entry: %+v", found)
+	}
+
+	var verify struct {
+		Valid   bool `json:"valid"`
+		Entries int  `json:"entries"`
+	}
+	if status := doJSON(t, http.MethodGet, srv.URL+"/api/v1/admin/audit/verify", admin.AccessToken, nil, &verify); status != http.StatusOK {
+		t.Fatalf("verify: expected 200, got %d", status)
+	}
+	if !verify.Valid || verify.Entries == 0 {
+		t.Fatalf("expected a valid, non-empty chain, got %+v", verify)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/v1/admin/audit/export?format=csv", nil)
+	req.Header.Set("Authorization", "Bearer "+admin.AccessToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	defer resp.Body.Close()
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("parse csv: %v", err)
+	}
+	if len(records) != verify.Entries+1 || records[0][0] != "seq" {
+		t.Fatalf("expected a header and %d rows, got %d records", verify.Entries, len(records))
+	}
+}
+
+func TestE2E_PasswordChangeEndsOtherSessions(t *testing.T) {
+	srv := newTestServer(t)
+	laptop := signUp(t, srv, "learner@example.com", "learner")
+
+	var phone tokenPair
+	login := map[string]string{"email": "learner@example.com", "password": "secret123"}
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/auth/login", "", login, &phone); status != http.StatusOK {
+		t.Fatalf("login: expected 200, got %d", status)
+	}
+
+	change := map[string]string{"current_password": "secret123", "new_password": "Neues-Passwort-7"}
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/users/me/password", laptop.AccessToken, change, nil); status != http.StatusNoContent {
+		t.Fatalf("change password: expected 204, got %d", status)
+	}
+
+	// The session the password was changed from stays signed in
+	refresh := map[string]string{"refresh_token": laptop.RefreshToken}
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/auth/refresh", "", refresh, &laptop); status != http.StatusOK {
+		t.Fatalf("refresh current session: expected 200, got %d", status)
+	}
+
+	// user.password_changed is delivered asynchronously; the other session
+	// ends once the auth module has handled it
+	deadline := time.Now().Add(5 * time.Second)
+	for doJSON(t, http.MethodGet, srv.URL+"/api/v1/users/me", phone.AccessToken, nil, nil) != http.StatusUnauthorized {
+		if time.Now().After(deadline) {
+			t.Fatalf("the other session was not ended")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	refresh = map[string]string{"refresh_token": phone.RefreshToken}
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/auth/refresh", "", refresh, nil); status != http.StatusUnauthorized {
+		t.Fatalf("refresh other session: expected 401, got %d", status)
+	}
+
+	login["password"] = "Neues-Passwort-7"
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/auth/login", "", login, nil); status != http.StatusOK {
+		t.Fatalf("login with the new password: expected 200, got %d", status)
+	}
+}
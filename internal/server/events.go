@@ -0,0 +1,65 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/events"
+)
+
+var (
+	errInvalidLimit   = apperr.Validation(apperr.FieldError{Field: "limit", Message: "must be between 1 and 100"})
+	errInvalidEventID = apperr.Validation(apperr.FieldError{Field: "id", Message: "must be a UUID"})
+	errEventNotDead   = apperr.NotFound("event_not_dead", "No dead-lettered event has this ID.")
+)
+
+// registerEventRoutes mounts the admin endpoints for the event outbox on rg.
+func registerEventRoutes(rg *gin.RouterGroup, bus *events.Bus) {
+	rg.GET("/events/dead", deadEventsHandler(bus))
+	rg.POST("/events/:id/retry", retryEventHandler(bus))
+}
+
+// deadEventsHandler returns the dead-lettered events (?limit=, default 20, max 100).
+func deadEventsHandler(bus *events.Bus) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		if err != nil || limit < 1 || limit > 100 {
+			_ = c.Error(errInvalidLimit)
+			return
+		}
+
+		dead, err := bus.DeadLetters(c.Request.Context(), limit)
+		if err != nil {
+			_ = c.Error(fmt.Errorf("list dead-lettered events: %w", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"events": dead})
+	}
+}
+
+// retryEventHandler puts a dead-lettered event back into the outbox.
+func retryEventHandler(bus *events.Bus) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			_ = c.Error(errInvalidEventID)
+			return
+		}
+
+		err = bus.Retry(c.Request.Context(), id)
+		switch {
+		case errors.Is(err, events.ErrNotDead):
+			_ = c.Error(errEventNotDead)
+		case err != nil:
+			_ = c.Error(fmt.Errorf("retry event: %w", err))
+		default:
+			c.Status(http.StatusAccepted)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/database"
+	"egaldeutsch-be/internal/health"
+	"egaldeutsch-be/internal/logging"
+	"egaldeutsch-be/internal/redis"
+)
+
+// errMigrationsPending and errMigrationsUnknown are all the migrations probe
+// reports; the details are logged, not served.
+var (
+	errMigrationsPending = errors.New("migrations pending")
+	errMigrationsUnknown = errors.New("schema version unknown")
+)
+
+// newHealthChecker registers the probes for every dependency of the server.
+// Module health (for example the WebSocket hub loop) counts towards liveness;
+// the database, Redis and the schema version count towards readiness.
+func newHealthChecker(db *database.Database, dbCfg config.DatabaseConfig, redisClient *redis.RedisClient, modules *Registry) *health.Checker {
+	checker := health.NewChecker(2 * time.Second)
+
+	for _, m := range modules.Modules() {
+		checker.AddLiveness("module:"+m.Name(), m.Health)
+	}
+
+	checker.AddReadiness("database", db.Ping)
+
+	if redisClient != nil {
+		checker.AddReadiness("redis", func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		})
+	}
+
+	// SQLite schemas, and those only created with AutoMigrate, carry no
+	// version to compare
+	if db.IsSQLite() || (dbCfg.AutoMigrate && !dbCfg.MigrateOnStart) {
+		return checker
+	}
+	m, err := db.SchemaMigrator()
+	if err != nil {
+		logrus.WithError(err).Warn("Schema version is not checked for readiness")
+		return checker
+	}
+
+	checker.AddReadiness("migrations", func(ctx context.Context) error {
+		pending, err := m.Pending(ctx)
+		if err != nil {
+			logging.FromContext(ctx).WithError(err).Error("failed to read the schema version")
+			return errMigrationsUnknown
+		}
+		if pending > 0 {
+			logging.FromContext(ctx).WithField("pending", pending).Warn("migrations pending")
+			return errMigrationsPending
+		}
+		return nil
+	})
+
+	return checker
+}
+
+// livezHandler reports whether the process is alive. Add ?verbose=1 for
+// per-check status and latency.
+func livezHandler(checker *health.Checker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		writeHealthReport(c, checker.Liveness(c.Request.Context()))
+	}
+}
+
+// readyzHandler reports whether the server can take traffic: all dependencies
+// are reachable and the server is not shutting down. Add ?verbose=1 for
+// per-check status and latency.
+func readyzHandler(checker *health.Checker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		writeHealthReport(c, checker.Readiness(c.Request.Context()))
+	}
+}
+
+func writeHealthReport(c *gin.Context, report health.Report) {
+	status := http.StatusOK
+	if !report.Healthy() {
+		status = http.StatusServiceUnavailable
+	}
+
+	if verbose, _ := strconv.ParseBool(c.Query("verbose")); verbose {
+		c.JSON(status, gin.H{
+			"status":  report.Status,
+			"service": "egaldeutsch-be",
+			"checks":  report.Checks,
+		})
+		return
+	}
+
+	// Terse mode still names what failed so probes are easy to debug
+	var failing []string
+	for _, r := range report.Checks {
+		if r.Status != health.StatusUp {
+			failing = append(failing, r.Name)
+		}
+	}
+	body := gin.H{"status": report.Status}
+	if len(failing) > 0 {
+		body["failing"] = failing
+	}
+	c.JSON(status, body)
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/database"
+	"egaldeutsch-be/internal/events"
+	"egaldeutsch-be/internal/scheduler"
+)
+
+// newScheduler registers the jobs of every module and the server's own jobs.
+func newScheduler(db *database.Database, cfg *config.Config, modules *Registry, bus *events.Bus) (*scheduler.Scheduler, error) {
+	sched := scheduler.New(db.DB)
+	jobs := append(modules.Jobs(cfg.Scheduler), purgeEventsJob(bus, cfg.Events.RetentionDays))
+	for _, job := range jobs {
+		if err := sched.Register(job); err != nil {
+			return nil, err
+		}
+	}
+	return sched, nil
+}
+
+// purgeEventsJob deletes delivered outbox events after the retention period.
+// Dead-lettered events are kept until they are retried.
+func purgeEventsJob(bus *events.Bus, retentionDays int) scheduler.Job {
+	return scheduler.Job{
+		Name:        "events.purge-delivered",
+		Description: "Delete delivered outbox events past the retention period",
+		Schedule:    scheduler.MustParseCron("45 4 * * *"),
+		MaxRetries:  3,
+		Run: func(ctx context.Context) error {
+			n, err := bus.PurgeDelivered(ctx, time.Now().AddDate(0, 0, -retentionDays))
+			if err != nil {
+				return err
+			}
+			logrus.WithField("events", n).Info("Purged delivered outbox events")
+			return nil
+		},
+	}
+}
+
+var (
+	errJobNotFound = apperr.NotFound("job_not_found", "No job has this name.")
+	errJobRunning  = apperr.Conflict("job_running", "The job is already running.")
+)
+
+// registerJobRoutes mounts the admin endpoints for the scheduler on rg.
+func registerJobRoutes(rg *gin.RouterGroup, sched *scheduler.Scheduler) {
+	rg.GET("/jobs", listJobsHandler(sched))
+	rg.GET("/jobs/:name/runs", jobRunsHandler(sched))
+	rg.POST("/jobs/:name/run", triggerJobHandler(sched))
+}
+
+// listJobsHandler returns every job with its schedule, next and last run.
+func listJobsHandler(sched *scheduler.Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		jobs, err := sched.Jobs(c.Request.Context())
+		if err != nil {
+			_ = c.Error(fmt.Errorf("list jobs: %w", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+	}
+}
+
+// jobRunsHandler returns the most recent runs of a job (?limit=, default 20, max 100).
+func jobRunsHandler(sched *scheduler.Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		if err != nil || limit < 1 || limit > 100 {
+			_ = c.Error(errInvalidLimit)
+			return
+		}
+
+		runs, err := sched.History(c.Request.Context(), c.Param("name"), limit)
+		if errors.Is(err, scheduler.ErrUnknownJob) {
+			_ = c.Error(errJobNotFound)
+			return
+		}
+		if err != nil {
+			_ = c.Error(fmt.Errorf("load job history: %w", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"runs": runs})
+	}
+}
+
+// triggerJobHandler starts a job immediately and returns the run ID.
+func triggerJobHandler(sched *scheduler.Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		runID, err := sched.Trigger(c.Request.Context(), c.Param("name"))
+		switch {
+		case errors.Is(err, scheduler.ErrUnknownJob):
+			_ = c.Error(errJobNotFound)
+		case errors.Is(err, scheduler.ErrJobRunning):
+			_ = c.Error(errJobRunning)
+		case err != nil:
+			_ = c.Error(fmt.Errorf("trigger job: %w", err))
+		default:
+			c.JSON(http.StatusAccepted, gin.H{"run_id": runID})
+		}
+	}
+}
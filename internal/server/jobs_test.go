@@ -0,0 +1,67 @@
+package server_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestJobRoutes(t *testing.T) {
+	srv := newTestServer(t)
+	admin := signUp(t, srv, "admin@example.com", "admin")
+	learner := signUp(t, srv, "learner@example.com", "learner")
+	moderator := signUp(t, srv, "moderator@example.com", "moderator")
+
+	for name, token := range map[string]string{"learner": learner.AccessToken, "moderator": moderator.AccessToken} {
+		if status := doJSON(t, http.MethodGet, srv.URL+"/api/v1/admin/jobs", token, nil, nil); status != http.StatusForbidden {
+			t.Fatalf("list jobs as %s: expected 403, got %d", name, status)
+		}
+	}
+
+	var list struct {
+		Jobs []struct {
+			Name     string `json:"name"`
+			Schedule string `json:"schedule"`
+		} `json:"jobs"`
+	}
+	if status := doJSON(t, http.MethodGet, srv.URL+"/api/v1/admin/jobs", admin.AccessToken, nil, &list); status != http.StatusOK {
+		t.Fatalf("list jobs: expected 200, got %d", status)
+	}
+	names := map[string]bool{}
+	for _, j := range list.Jobs {
+		names[j.Name] = true
+	}
+	for _, want := range []string{"auth.purge-expired-tokens", "chat.deactivate-stale-rooms", "user.purge-deleted"} {
+		if !names[want] {
+			t.Fatalf("job %s missing from %+v", want, list.Jobs)
+		}
+	}
+
+	var triggered struct {
+		RunID string `json:"run_id"`
+	}
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/admin/jobs/auth.purge-expired-tokens/run", admin.AccessToken, nil, &triggered); status != http.StatusAccepted {
+		t.Fatalf("trigger job: expected 202, got %d", status)
+	}
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/admin/jobs/missing/run", admin.AccessToken, nil, nil); status != http.StatusNotFound {
+		t.Fatalf("trigger unknown job: expected 404, got %d", status)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var history struct {
+			Runs []struct {
+				ID     string `json:"id"`
+				Status string `json:"status"`
+			} `json:"runs"`
+		}
+		doJSON(t, http.MethodGet, srv.URL+"/api/v1/admin/jobs/auth.purge-expired-tokens/runs", admin.AccessToken, nil, &history)
+		if len(history.Runs) > 0 && history.Runs[0].ID == triggered.RunID && history.Runs[0].Status == "succeeded" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job run did not succeed: %+v", history.Runs)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
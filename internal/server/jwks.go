@@ -0,0 +1,22 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"egaldeutsch-be/internal/auth"
+)
+
+// jwksPath is where services that verify access tokens find the public keys.
+const jwksPath = "/.well-known/jwks.json"
+
+// jwksHandler publishes the public keys of the key set. Verifiers may cache
+// the set for a few minutes, which a key rotation has to allow for.
+func jwksHandler(keys *auth.KeySet) gin.HandlerFunc {
+	jwks := keys.JWKS()
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, jwks)
+	}
+}
@@ -0,0 +1,56 @@
+package server_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"egaldeutsch-be/internal/config"
+)
+
+func TestAsymmetricTokensAndJWKS(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "2026-10.pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+
+	srv := newTestServer(t, func(cfg *config.Config) {
+		cfg.Jwt.Algorithm = config.JwtEdDSA
+		cfg.Jwt.SecretKey = ""
+		cfg.Jwt.KeysDir = dir
+		cfg.Jwt.SigningKeyID = "2026-10"
+	})
+
+	tokens := signUp(t, srv, "eddsa@example.com", "learner")
+	if status := doJSON(t, http.MethodGet, srv.URL+"/api/v1/ws/rooms", tokens.AccessToken, nil, nil); status != http.StatusOK {
+		t.Fatalf("expected the EdDSA token to be accepted, got %d", status)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Alg string `json:"alg"`
+			X   string `json:"x"`
+		} `json:"keys"`
+	}
+	if status := doJSON(t, http.MethodGet, srv.URL+"/.well-known/jwks.json", "", nil, &jwks); status != http.StatusOK {
+		t.Fatalf("jwks: expected 200, got %d", status)
+	}
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != "2026-10" || jwks.Keys[0].Alg != "EdDSA" || jwks.Keys[0].X == "" {
+		t.Fatalf("unexpected key set %+v", jwks)
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"database/sql"
+
+	"egaldeutsch-be/internal/database"
+	"egaldeutsch-be/internal/metrics"
+)
+
+// registerDBMetrics exports sql.DBStats of the connection pool on /metrics.
+func registerDBMetrics(db *database.Database) error {
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		return err
+	}
+
+	gauge := func(name, help string, value func(sql.DBStats) float64) {
+		metrics.Default.NewGaugeFunc(name, help, func() []metrics.Sample {
+			return []metrics.Sample{{Value: value(sqlDB.Stats())}}
+		})
+	}
+	counter := func(name, help string, value func(sql.DBStats) float64) {
+		metrics.Default.NewCounterFunc(name, help, func() []metrics.Sample {
+			return []metrics.Sample{{Value: value(sqlDB.Stats())}}
+		})
+	}
+
+	gauge("db_max_open_connections", "Maximum number of open connections to the database.",
+		func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) })
+	gauge("db_open_connections", "Established connections, both in use and idle.",
+		func(s sql.DBStats) float64 { return float64(s.OpenConnections) })
+	gauge("db_in_use_connections", "Connections currently in use.",
+		func(s sql.DBStats) float64 { return float64(s.InUse) })
+	gauge("db_idle_connections", "Idle connections.",
+		func(s sql.DBStats) float64 { return float64(s.Idle) })
+	counter("db_wait_count_total", "Connections waited for because the pool was exhausted.",
+		func(s sql.DBStats) float64 { return float64(s.WaitCount) })
+	counter("db_wait_duration_seconds_total", "Total time blocked waiting for a new connection.",
+		func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() })
+	counter("db_max_idle_closed_total", "Connections closed due to max_idle_conns.",
+		func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) })
+	counter("db_max_idle_time_closed_total", "Connections closed due to conn_max_idle_time.",
+		func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) })
+	counter("db_max_lifetime_closed_total", "Connections closed due to conn_max_lifetime.",
+		func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) })
+
+	return nil
+}
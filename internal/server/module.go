@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/events"
+	"egaldeutsch-be/internal/openapi"
+	"egaldeutsch-be/internal/scheduler"
+)
+
+// Module is the contract every feature module fulfils so the server can wire
+// it without knowing its concrete type. Modules receive their dependencies in
+// their constructor; see modules.go for how each one is built.
+type Module interface {
+	// Name identifies the module in config.yaml and in logs.
+	Name() string
+
+	// GetModelsForMigration returns the models for GORM AutoMigrate.
+	// Production schemas come from migrations/*.sql instead.
+	GetModelsForMigration() []interface{}
+
+	// RegisterRoutes mounts the module's routes on the API group.
+	RegisterRoutes(rg *gin.RouterGroup)
+
+	// Start launches background work. It must not block; ctx only bounds startup.
+	Start(ctx context.Context) error
+
+	// Stop ends background work and releases resources, bounded by ctx.
+	Stop(ctx context.Context) error
+
+	// Health returns an error when the module cannot serve traffic.
+	Health(ctx context.Context) error
+}
+
+// JobProvider is implemented by modules that contribute background jobs to
+// the scheduler.
+type JobProvider interface {
+	Jobs(cfg config.SchedulerConfig) []scheduler.Job
+}
+
+// EventSubscriber is implemented by modules that react to domain events.
+type EventSubscriber interface {
+	Subscribe(bus *events.Bus)
+}
+
+// APIDocumenter is implemented by modules with HTTP routes. It documents
+// every route RegisterRoutes mounts, with basePath being the path of the
+// group the routes are mounted on.
+type APIDocumenter interface {
+	APIDocs(basePath string) []openapi.Operation
+}
+
+// Registry holds the enabled modules in dependency order.
+type Registry struct {
+	modules []Module
+	started []Module
+}
+
+// NewRegistry creates an empty module registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register appends a module. Modules start in registration order and stop in
+// reverse order.
+func (r *Registry) Register(m Module) {
+	r.modules = append(r.modules, m)
+}
+
+// Modules returns the registered modules in registration order.
+func (r *Registry) Modules() []Module {
+	return r.modules
+}
+
+// Models collects the AutoMigrate models of every module.
+func (r *Registry) Models() []interface{} {
+	var models []interface{}
+	for _, m := range r.modules {
+		models = append(models, m.GetModelsForMigration()...)
+	}
+	return models
+}
+
+// Jobs collects the background jobs of every module implementing JobProvider.
+func (r *Registry) Jobs(cfg config.SchedulerConfig) []scheduler.Job {
+	var jobs []scheduler.Job
+	for _, m := range r.modules {
+		if p, ok := m.(JobProvider); ok {
+			jobs = append(jobs, p.Jobs(cfg)...)
+		}
+	}
+	return jobs
+}
+
+// Subscribe lets every module implementing EventSubscriber subscribe to bus.
+func (r *Registry) Subscribe(bus *events.Bus) {
+	for _, m := range r.modules {
+		if s, ok := m.(EventSubscriber); ok {
+			s.Subscribe(bus)
+		}
+	}
+}
+
+// APIDocs collects the route documentation of every module implementing
+// APIDocumenter.
+func (r *Registry) APIDocs(basePath string) []openapi.Operation {
+	var ops []openapi.Operation
+	for _, m := range r.modules {
+		if d, ok := m.(APIDocumenter); ok {
+			ops = append(ops, d.APIDocs(basePath)...)
+		}
+	}
+	return ops
+}
+
+// RegisterRoutes mounts the routes of every module on rg.
+func (r *Registry) RegisterRoutes(rg *gin.RouterGroup) {
+	for _, m := range r.modules {
+		m.RegisterRoutes(rg)
+	}
+}
+
+// Start starts every module in order. If one fails, the modules started before
+// it are stopped again.
+func (r *Registry) Start(ctx context.Context) error {
+	for _, m := range r.modules {
+		if err := m.Start(ctx); err != nil {
+			stopErr := r.Stop(ctx)
+			return errors.Join(fmt.Errorf("start module %s: %w", m.Name(), err), stopErr)
+		}
+		r.started = append(r.started, m)
+		logrus.WithField("module", m.Name()).Debug("Module started")
+	}
+	return nil
+}
+
+// Stop stops the started modules in reverse order. Every module is stopped
+// even if an earlier one fails; all errors are returned.
+func (r *Registry) Stop(ctx context.Context) error {
+	var errs []error
+	for i := len(r.started) - 1; i >= 0; i-- {
+		m := r.started[i]
+		if err := m.Stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("stop module %s: %w", m.Name(), err))
+		}
+	}
+	r.started = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeModule records lifecycle calls into a shared log.
+type fakeModule struct {
+	name     string
+	log      *[]string
+	startErr error
+}
+
+func (f *fakeModule) Name() string                         { return f.name }
+func (f *fakeModule) GetModelsForMigration() []interface{} { return nil }
+func (f *fakeModule) RegisterRoutes(rg *gin.RouterGroup)   {}
+func (f *fakeModule) Health(ctx context.Context) error     { return nil }
+func (f *fakeModule) Start(ctx context.Context) error {
+	*f.log = append(*f.log, "start "+f.name)
+	return f.startErr
+}
+func (f *fakeModule) Stop(ctx context.Context) error {
+	*f.log = append(*f.log, "stop "+f.name)
+	return nil
+}
+
+func TestRegistryStartsInOrderAndStopsInReverse(t *testing.T) {
+	var calls []string
+	r := NewRegistry()
+	r.Register(&fakeModule{name: "a", log: &calls})
+	r.Register(&fakeModule{name: "b", log: &calls})
+
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	if err := r.Stop(context.Background()); err != nil {
+		t.Fatalf("stop failed: %v", err)
+	}
+
+	want := "start a,start b,stop b,stop a"
+	if got := strings.Join(calls, ","); got != want {
+		t.Fatalf("expected %q got %q", want, got)
+	}
+}
+
+func TestRegistryStopsStartedModulesWhenStartFails(t *testing.T) {
+	var calls []string
+	r := NewRegistry()
+	r.Register(&fakeModule{name: "a", log: &calls})
+	r.Register(&fakeModule{name: "b", log: &calls, startErr: errors.New("boom")})
+	r.Register(&fakeModule{name: "c", log: &calls})
+
+	if err := r.Start(context.Background()); err == nil {
+		t.Fatalf("expected start error")
+	}
+
+	want := "start a,start b,stop a"
+	if got := strings.Join(calls, ","); got != want {
+		t.Fatalf("expected %q got %q", want, got)
+	}
+}
+
+func TestResolveModules(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  []string
+		want     string
+		errorMsg string
+	}{
+		{
+			name:    "all modules in dependency order",
+			enabled: []string{"websocket", "quiz", "auth", "user"},
+			want:    "user,auth,quiz,websocket",
+		},
+		{
+			name:    "api only node",
+			enabled: []string{"user", "auth", "quiz"},
+			want:    "user,auth,quiz",
+		},
+		{
+			name:     "missing dependency",
+			enabled:  []string{"auth"},
+			errorMsg: `requires module "user"`,
+		},
+		{
+			name:     "unknown module",
+			enabled:  []string{"user", "billing"},
+			errorMsg: `unknown module "billing"`,
+		},
+		{
+			name:     "nothing enabled",
+			enabled:  nil,
+			errorMsg: "no modules enabled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			factories, err := resolveModules(tt.enabled)
+			if tt.errorMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+					t.Fatalf("expected error containing '%s', got %v", tt.errorMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			names := make([]string, len(factories))
+			for i, f := range factories {
+				names[i] = f.name
+			}
+			if got := strings.Join(names, ","); got != tt.want {
+				t.Fatalf("expected %q got %q", tt.want, got)
+			}
+		})
+	}
+}
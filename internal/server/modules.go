@@ -0,0 +1,147 @@
+package server
+
+import (
+	"fmt"
+
+	"egaldeutsch-be/internal/auth"
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/database"
+	"egaldeutsch-be/internal/mail"
+	"egaldeutsch-be/internal/middleware"
+	"egaldeutsch-be/internal/rbac"
+	"egaldeutsch-be/internal/redis"
+	"egaldeutsch-be/modules/audit"
+	authmodule "egaldeutsch-be/modules/auth"
+	"egaldeutsch-be/modules/quiz"
+	"egaldeutsch-be/modules/user"
+	websocketmodule "egaldeutsch-be/modules/websocket"
+)
+
+// moduleDeps carries shared infrastructure, and the modules built so far, into
+// the module factories.
+type moduleDeps struct {
+	cfg   *config.Config
+	db    *database.Database
+	redis *redis.RedisClient
+	idem  *middleware.Idempotency // nil when idempotency is disabled
+	// denylist is nil when token revocation is disabled
+	denylist *auth.Denylist
+	// throttle is nil when login lockout is disabled
+	throttle *auth.LoginThrottle
+	// apiKeys is nil when the auth module is disabled
+	apiKeys *auth.APIKeys
+	mailer  *mail.Sender
+	policy  *rbac.Policy
+
+	user *user.Module
+}
+
+// emailVerifier returns the check of the email verification policy for
+// feature, or nil when the policy lets unverified users use it.
+func (d *moduleDeps) emailVerifier(feature string) middleware.EmailVerifier {
+	if d.user == nil || !d.cfg.EmailVerification.Requires(feature) {
+		return nil
+	}
+	return d.user.Service
+}
+
+// moduleFactory describes how to build one module.
+type moduleFactory struct {
+	name     string
+	requires []string // modules that must be enabled as well
+	build    func(d *moduleDeps) Module
+}
+
+// availableModules lists every known module in dependency order. Adding a
+// module means adding an entry here.
+var availableModules = []moduleFactory{
+	{
+		name: "user",
+		build: func(d *moduleDeps) Module {
+			d.user = user.NewModule(d.db.DB, d.cfg.Jwt, d.cfg.Password, d.idem, d.denylist, d.throttle, d.apiKeys, d.policy)
+			return d.user
+		},
+	},
+	{
+		name:     "auth",
+		requires: []string{"user"},
+		build: func(d *moduleDeps) Module {
+			authRepo := authmodule.NewRepository(d.db.DB)
+			authService := auth.NewService(d.cfg.Jwt, d.cfg.Mfa, d.cfg.APIKeys, authRepo, d.denylist)
+			return authmodule.NewModule(authService, authRepo, d.user.Service, d.cfg.Jwt, d.denylist, d.policy, d.throttle, d.mailer, d.cfg.EmailVerification)
+		},
+	},
+	{
+		name: "audit",
+		build: func(d *moduleDeps) Module {
+			return audit.NewModule(d.db.DB, d.cfg.Jwt, d.denylist, d.apiKeys, d.policy)
+		},
+	},
+	{
+		name: "quiz",
+		build: func(d *moduleDeps) Module {
+			return quiz.NewModule(d.db.DB, d.cfg.Jwt, d.idem, d.denylist, d.apiKeys, d.policy, d.emailVerifier(config.FeatureQuiz))
+		},
+	},
+	{
+		name: "websocket",
+		build: func(d *moduleDeps) Module {
+			return websocketmodule.NewModule(d.db.DB, d.redis, d.cfg.Jwt, d.idem, d.denylist, d.apiKeys, d.policy, d.emailVerifier(config.FeatureChat))
+		},
+	},
+}
+
+// resolveModules returns the factories of the enabled modules in dependency
+// order, rejecting unknown names and missing dependencies.
+func resolveModules(enabled []string) ([]moduleFactory, error) {
+	wanted := make(map[string]bool, len(enabled))
+	for _, name := range enabled {
+		wanted[name] = true
+	}
+
+	known := make(map[string]bool, len(availableModules))
+	var factories []moduleFactory
+	for _, f := range availableModules {
+		known[f.name] = true
+		if !wanted[f.name] {
+			continue
+		}
+		for _, dep := range f.requires {
+			if !wanted[dep] {
+				return nil, fmt.Errorf("module %q requires module %q to be enabled", f.name, dep)
+			}
+		}
+		factories = append(factories, f)
+	}
+
+	for name := range wanted {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown module %q", name)
+		}
+	}
+	if len(factories) == 0 {
+		return nil, fmt.Errorf("no modules enabled")
+	}
+
+	return factories, nil
+}
+
+// newAPIKeys returns the authenticator of API keys, or nil when the auth
+// module, which manages them, is disabled.
+func newAPIKeys(factories []moduleFactory, db *database.Database) *auth.APIKeys {
+	for _, f := range factories {
+		if f.name == "auth" {
+			return auth.NewAPIKeys(authmodule.NewRepository(db.DB))
+		}
+	}
+	return nil
+}
+
+// buildModules constructs the modules and registers them in order.
+func buildModules(factories []moduleFactory, deps *moduleDeps) *Registry {
+	registry := NewRegistry()
+	for _, f := range factories {
+		registry.Register(f.build(deps))
+	}
+	return registry
+}
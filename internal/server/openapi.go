@@ -0,0 +1,114 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"egaldeutsch-be/internal/auth"
+	"egaldeutsch-be/internal/events"
+	"egaldeutsch-be/internal/health"
+	"egaldeutsch-be/internal/openapi"
+	"egaldeutsch-be/internal/rbac"
+	"egaldeutsch-be/internal/scheduler"
+)
+
+var apiInfo = openapi.Info{
+	Title:       "egalDeutsch API",
+	Version:     "v1",
+	Description: "Backend of the egalDeutsch language learning platform.",
+}
+
+// registerDocRoutes mounts the OpenAPI document and the docs page on api.
+// createRouter builds the document once every route is registered.
+func registerDocRoutes(api *gin.RouterGroup, doc **openapi.Document) {
+	api.GET("/openapi.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, *doc)
+	})
+	api.GET("/docs", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", openapi.DocsPage)
+	})
+}
+
+// apiDocs returns the documentation of every route: the server's own and
+// the modules'.
+func apiDocs(modules *Registry) []openapi.Operation {
+	return append(serverDocs(apiBasePath), modules.APIDocs(apiBasePath)...)
+}
+
+// UndocumentedRoutes returns the registered routes, as "METHOD /path", that
+// the OpenAPI document does not cover.
+func (s *Server) UndocumentedRoutes() []string {
+	return openapi.Undocumented(s.router.Routes(), apiDocs(s.modules))
+}
+
+// healthBody documents the health probe responses.
+type healthBody struct {
+	Status  string          `json:"status"`
+	Failing []string        `json:"failing,omitempty"`
+	Service string          `json:"service,omitempty"`
+	Checks  []health.Result `json:"checks,omitempty"`
+}
+
+// limitParam documents the ?limit= of the admin listings.
+type limitParam struct {
+	Limit int `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// serverDocs documents the routes createRouter mounts itself.
+func serverDocs(base string) []openapi.Operation {
+	probe := "Returns 503 with the same body when a check fails. Add `?verbose=1` for every check with its latency."
+	return []openapi.Operation{
+		{Method: http.MethodGet, Path: "/health", Tags: []string{"health"}, Summary: "Liveness probe (alias of /livez)", Description: probe, Response: healthBody{}},
+		{Method: http.MethodGet, Path: "/livez", Tags: []string{"health"}, Summary: "Liveness probe", Description: probe, Response: healthBody{}},
+		{Method: http.MethodGet, Path: "/readyz", Tags: []string{"health"}, Summary: "Readiness probe", Description: probe, Response: healthBody{}},
+		{
+			Method: http.MethodGet, Path: jwksPath, Tags: []string{"auth"},
+			Summary:     "Public keys that verify access tokens",
+			Description: "JSON Web Key Set of the RS256/EdDSA keys, selected by the `kid` header of a token. Empty with HS256.",
+			Response:    auth.JWKS{},
+		},
+
+		{Method: http.MethodGet, Path: base + "/openapi.json", Tags: []string{"docs"}, Summary: "This OpenAPI document", Response: map[string]any{}},
+		{Method: http.MethodGet, Path: base + "/docs", Tags: []string{"docs"}, Summary: "API documentation page", Response: "", ContentTypes: []string{"text/html"}},
+
+		{
+			Method: http.MethodGet, Path: base + "/admin/events/dead", Tags: []string{"admin"}, Permission: rbac.SystemManage,
+			Summary: "List dead-lettered domain events, newest first",
+			Params:  limitParam{}, Response: struct {
+				Events []events.Event `json:"events"`
+			}{},
+		},
+		{
+			Method: http.MethodPost, Path: base + "/admin/events/:id/retry", Tags: []string{"admin"}, Permission: rbac.SystemManage,
+			Summary: "Deliver a dead-lettered event again",
+			Params: struct {
+				ID string `uri:"id" binding:"required,uuid"`
+			}{},
+			Status: http.StatusAccepted, Errors: []int{http.StatusNotFound},
+		},
+		{
+			Method: http.MethodGet, Path: base + "/admin/jobs", Tags: []string{"admin"}, Permission: rbac.SystemManage,
+			Summary: "List background jobs with their next and last run",
+			Response: struct {
+				Jobs []scheduler.JobStatus `json:"jobs"`
+			}{},
+		},
+		{
+			Method: http.MethodGet, Path: base + "/admin/jobs/:name/runs", Tags: []string{"admin"}, Permission: rbac.SystemManage,
+			Summary: "Run history of a job, newest first",
+			Params:  limitParam{}, Response: struct {
+				Runs []scheduler.Run `json:"runs"`
+			}{},
+			Errors: []int{http.StatusNotFound},
+		},
+		{
+			Method: http.MethodPost, Path: base + "/admin/jobs/:name/run", Tags: []string{"admin"}, Permission: rbac.SystemManage,
+			Summary: "Run a job now",
+			Status:  http.StatusAccepted, Response: struct {
+				RunID string `json:"run_id"`
+			}{},
+			Errors: []int{http.StatusNotFound, http.StatusConflict},
+		},
+	}
+}
@@ -0,0 +1,55 @@
+package server_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestOpenAPIDocument(t *testing.T) {
+	srv := newTestServer(t)
+
+	// Every route must be documented; add the operation to the module's
+	// APIDocs (or serverDocs) when adding a route
+	if missing := srv.Server.UndocumentedRoutes(); len(missing) > 0 {
+		t.Fatalf("routes missing from the OpenAPI document: %v", missing)
+	}
+
+	var doc struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Required []string `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if status := doJSON(t, http.MethodGet, srv.URL+"/api/v1/openapi.json", "", nil, &doc); status != http.StatusOK {
+		t.Fatalf("openapi.json: expected 200, got %d", status)
+	}
+	if doc.OpenAPI != "3.1.0" {
+		t.Fatalf("expected OpenAPI 3.1.0, got %q", doc.OpenAPI)
+	}
+	for _, path := range []string{"/api/v1/users", "/api/v1/users/{id}", "/api/v1/auth/login", "/api/v1/quiz/questions", "/api/v1/ws/rooms"} {
+		if doc.Paths[path] == nil {
+			t.Fatalf("expected %s to be documented", path)
+		}
+	}
+	if _, ok := doc.Paths["/api/v1/users/{id}"]["get"]["security"]; !ok {
+		t.Fatalf("expected GET /users/{id} to require a bearer token")
+	}
+	if got := doc.Components.Schemas["CreateUserRequest"].Required; len(got) != 3 {
+		t.Fatalf("expected name, email and password to be required, got %v", got)
+	}
+	if _, ok := doc.Components.Schemas["Problem"]; !ok {
+		t.Fatalf("expected the Problem schema")
+	}
+
+	resp, err := http.Get(srv.URL + "/api/v1/docs")
+	if err != nil {
+		t.Fatalf("docs: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("docs: expected 200, got %d", resp.StatusCode)
+	}
+}
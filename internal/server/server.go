@@ -1,8 +1,12 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
@@ -10,19 +14,29 @@ import (
 	"egaldeutsch-be/internal/auth"
 	"egaldeutsch-be/internal/config"
 	"egaldeutsch-be/internal/database"
+	"egaldeutsch-be/internal/events"
+	"egaldeutsch-be/internal/health"
+	"egaldeutsch-be/internal/mail"
+	"egaldeutsch-be/internal/metrics"
 	"egaldeutsch-be/internal/middleware"
+	"egaldeutsch-be/internal/openapi"
+	"egaldeutsch-be/internal/rbac"
 	"egaldeutsch-be/internal/redis"
-	authmodule "egaldeutsch-be/modules/auth"
-	"egaldeutsch-be/modules/quiz"
-	"egaldeutsch-be/modules/user"
-	websocketmodule "egaldeutsch-be/modules/websocket"
+	"egaldeutsch-be/internal/scheduler"
 )
 
 type Server struct {
-	config *config.Config
-	router *gin.Engine
-	db     *database.Database
-	redis  *redis.RedisClient
+	config     *config.Config
+	router     *gin.Engine
+	httpServer *http.Server
+	// metricsServer serves /metrics; nil when server.metrics_port is empty
+	metricsServer *http.Server
+	db            *database.Database
+	redis         *redis.RedisClient
+	modules       *Registry
+	health        *health.Checker
+	bus           *events.Bus
+	scheduler     *scheduler.Scheduler // nil when scheduler.enabled is false
 }
 
 // NewServer creates a new server instance with all dependencies properly initialized.
@@ -33,6 +47,30 @@ func NewServer(cfg *config.Config) (*Server, error) {
 		return nil, fmt.Errorf("config cannot be nil")
 	}
 
+	// Resolve enabled modules before opening any connection
+	factories, err := resolveModules(cfg.Modules.Enabled)
+	if err != nil {
+		return nil, fmt.Errorf("invalid module configuration: %w", err)
+	}
+
+	// Fail fast on unreadable or mismatched JWT keys
+	jwtKeys, err := auth.LoadKeySet(cfg.Jwt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT keys: %w", err)
+	}
+
+	// Fail fast on roles or permissions the API does not know
+	policy, err := rbac.NewPolicy(cfg.RBAC)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RBAC configuration: %w", err)
+	}
+
+	// Fail fast on broken mail templates or an unusable mail directory
+	mailer, err := mail.NewSender(cfg.Mail)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mail configuration: %w", err)
+	}
+
 	// Configure Gin mode based on environment
 	configureGinMode(cfg.Server.Host)
 
@@ -42,33 +80,80 @@ func NewServer(cfg *config.Config) (*Server, error) {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
 
-	redisClient, err := redis.NewRedisClient(cfg.Redis)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize Redis client: %w", err)
+	// Redis is only needed by some modules, idempotency, token revocation
+	// and login lockout
+	var redisClient *redis.RedisClient
+	if cfg.NeedsRedis() {
+		redisClient, err = redis.NewRedisClient(cfg.Redis)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to initialize Redis client: %w", err)
+		}
+	}
+
+	var idem *middleware.Idempotency
+	if cfg.Idempotency.Enabled {
+		idem = middleware.NewIdempotency(redisClient, time.Duration(cfg.Idempotency.TTLHours)*time.Hour, int64(cfg.Idempotency.MaxBodyKB)*1024)
+	}
+
+	var denylist *auth.Denylist
+	if cfg.Jwt.RevocationEnabled {
+		denylist = auth.NewDenylist(redisClient, time.Duration(cfg.Jwt.ExpirationHours)*time.Hour)
 	}
-	fmt.Printf("Redis client initialized successfully : %+v\n", redisClient)
+
+	var throttle *auth.LoginThrottle
+	if cfg.Lockout.Enabled {
+		throttle = auth.NewLoginThrottle(redisClient, cfg.Lockout)
+	}
+
+	apiKeys := newAPIKeys(factories, db)
 
 	// Initialize modules with dependency injection
-	authRepo := authmodule.NewRepository(db.DB)
-	authService := auth.NewService(cfg.Jwt, authRepo)
-	userModule := user.NewModule(db.DB, cfg.Jwt)
-	authModule := authmodule.NewModule(authService, userModule.Service, cfg.Jwt)
-	quizModule := quiz.NewModule(db.DB)
-	wsModule := websocketmodule.NewModule(db.DB, redisClient)
+	modules := buildModules(factories, &moduleDeps{cfg: cfg, db: db, redis: redisClient, idem: idem, denylist: denylist, throttle: throttle, apiKeys: apiKeys, mailer: mailer, policy: policy})
 
 	// Run database migrations
-	if err := runMigrations(db, userModule, authModule, quizModule, wsModule); err != nil {
+	if err := runMigrations(db, cfg.Database, modules); err != nil {
+		db.Close()
+		if redisClient != nil {
+			redisClient.Close()
+		}
 		return nil, fmt.Errorf("failed to run migrations: %w", err)
 	}
 
+	if err := registerDBMetrics(db); err != nil {
+		logrus.WithError(err).Warn("Database pool metrics unavailable")
+	}
+
+	// Domain events published by the modules
+	bus := events.NewBus(db.DB, cfg.Events)
+	modules.Subscribe(bus)
+
+	// Background maintenance jobs
+	var sched *scheduler.Scheduler
+	if cfg.Scheduler.Enabled {
+		sched, err = newScheduler(db, cfg, modules, bus)
+		if err != nil {
+			db.Close()
+			if redisClient != nil {
+				redisClient.Close()
+			}
+			return nil, fmt.Errorf("failed to initialize scheduler: %w", err)
+		}
+	}
+
 	// Setup HTTP router
-	router := createRouter(cfg.Jwt, userModule, authModule, quizModule, wsModule)
+	checker := newHealthChecker(db, cfg.Database, redisClient, modules)
+	router := createRouter(cfg, modules, checker, bus, sched, jwtKeys, denylist, apiKeys, policy)
 
 	return &Server{
-		config: cfg,
-		router: router,
-		db:     db,
-		redis:  redisClient,
+		config:    cfg,
+		router:    router,
+		db:        db,
+		redis:     redisClient,
+		modules:   modules,
+		health:    checker,
+		bus:       bus,
+		scheduler: sched,
 	}, nil
 }
 
@@ -80,57 +165,264 @@ func configureGinMode(host string) {
 	}
 }
 
-// runMigrations performs database migrations for all modules.
-func runMigrations(db *database.Database, userModule *user.Module, authModule *authmodule.Module, quizModule *quiz.Module, wsModule *websocketmodule.Module) error {
-	// Start with the first module's models
-	modelsToMigrate := userModule.GetModelsForMigration()
-	// Append the other modules' models by unpacking them one at a time
-	modelsToMigrate = append(modelsToMigrate, authModule.GetModelsForMigration()...)
-	modelsToMigrate = append(modelsToMigrate, quizModule.GetModelsForMigration()...)
-	modelsToMigrate = append(modelsToMigrate, wsModule.GetModelsForMigration()...)
+// runMigrations applies pending versioned SQL migrations and, when explicitly
+// enabled for local development, GORM AutoMigrate of the module models.
+// SQLite cannot run the PostgreSQL migrations and always uses AutoMigrate.
+func runMigrations(db *database.Database, dbCfg config.DatabaseConfig, modules *Registry) error {
+	switch {
+	case db.IsSQLite():
+		logrus.Info("Using SQLite; creating the schema with AutoMigrate")
+	case dbCfg.MigrateOnStart:
+		if err := applySQLMigrations(db); err != nil {
+			return err
+		}
+		if !dbCfg.AutoMigrate {
+			return nil
+		}
+		logrus.Warn("database.auto_migrate is enabled; this is meant for local development only")
+	case dbCfg.AutoMigrate:
+		logrus.Warn("database.auto_migrate is enabled; this is meant for local development only")
+	default:
+		return nil
+	}
 
-	if err := db.AutoMigrate(modelsToMigrate...); err != nil {
+	tables := append(modules.Models(), scheduler.Models()...)
+	tables = append(tables, events.Models()...)
+	if err := db.AutoMigrate(tables...); err != nil {
 		return fmt.Errorf("database migration failed: %w", err)
 	}
 
 	return nil
 }
 
+// applySQLMigrations applies the embedded migrations/*.sql files.
+func applySQLMigrations(db *database.Database) error {
+	m, err := db.SchemaMigrator()
+	if err != nil {
+		return err
+	}
+
+	applied, err := m.Up(context.Background())
+	if err != nil {
+		return fmt.Errorf("apply SQL migrations: %w", err)
+	}
+	logrus.Infof("Database schema up to date (%d migrations applied)", applied)
+	return nil
+}
+
+// apiBasePath is the path of the versioned API group.
+const apiBasePath = "/api/v1"
+
 // createRouter sets up the HTTP router with all routes and middleware.
-func createRouter(jwtCfg config.JwtConfig, userModule *user.Module, authModule *authmodule.Module, quizModule *quiz.Module, wsModule *websocketmodule.Module) *gin.Engine {
+func createRouter(cfg *config.Config, modules *Registry, checker *health.Checker, bus *events.Bus, sched *scheduler.Scheduler, jwtKeys *auth.KeySet, denylist *auth.Denylist, apiKeys *auth.APIKeys, policy *rbac.Policy) *gin.Engine {
 	router := gin.New()
 
 	// Add middleware in correct order
-	router.Use(middleware.Logger())
+	router.Use(middleware.RequestID())
+	router.Use(middleware.Logger("/health", "/livez", "/readyz"))
+	router.Use(middleware.Metrics())
 	router.Use(middleware.CORS())
-	router.Use(gin.Recovery())
+	router.Use(middleware.Recovery())
+	router.Use(middleware.Errors())
+	router.NoRoute(middleware.NoRoute())
+
+	// Health check endpoints; /health is kept for existing clients
+	router.GET("/health", livezHandler(checker))
+	router.GET("/livez", livezHandler(checker))
+	router.GET("/readyz", readyzHandler(checker))
 
-	// Health check endpoint
-	router.GET("/health", healthCheckHandler)
+	// Public keys of access tokens
+	router.GET(jwksPath, jwksHandler(jwtKeys))
 
 	// API routes
-	api := router.Group("/api/v1")
-	{
-		userModule.RegisterRoutes(api, jwtCfg)
-		authModule.RegisterRoutes(api, jwtCfg)
-		quizModule.RegisterRoutes(api)
-		wsModule.RegisterRoutes(api, jwtCfg)
+	api := router.Group(apiBasePath)
+	modules.RegisterRoutes(api)
+
+	// OpenAPI document and docs page, built below from the final routes
+	var doc *openapi.Document
+	registerDocRoutes(api, &doc)
+
+	// Server-level admin endpoints
+	admin := api.Group("/admin")
+	admin.Use(middleware.AuthMiddleware(cfg.Jwt, denylist, apiKeys), middleware.RequirePermission(policy, rbac.SystemManage))
+	registerEventRoutes(admin, bus)
+	if sched != nil {
+		registerJobRoutes(admin, sched)
+	}
+
+	ops := apiDocs(modules)
+	doc = openapi.Build(apiInfo, router.Routes(), ops)
+	if missing := openapi.Undocumented(router.Routes(), ops); len(missing) > 0 {
+		logrus.WithField("routes", missing).Warn("Routes missing from the OpenAPI document")
 	}
 
 	return router
 }
 
-// healthCheckHandler provides a simple health check endpoint.
-func healthCheckHandler(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "healthy",
-		"service": "egaldeutsch-be",
-	})
+// Start serves HTTP until ctx is cancelled (typically on SIGINT/SIGTERM) and
+// then shuts the server down gracefully, bounded by server.shutdown_timeout.
+func (s *Server) Start(ctx context.Context) error {
+	addr := fmt.Sprintf("%s:%s", s.config.Server.Host, s.config.Server.Port)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	return s.Serve(ctx, ln)
 }
 
-func (s *Server) Start() error {
-	addr := fmt.Sprintf("%s:%s", s.config.Server.Host, s.config.Server.Port)
-	logrus.Infof("Starting server on %s", addr)
+// Serve is Start on an existing listener, which lets tests bind to an
+// ephemeral port. The listener is closed when Serve returns.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	s.httpServer = &http.Server{
+		Addr:              ln.Addr().String(),
+		Handler:           s.router,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	metricsLn, err := s.listenMetrics()
+	if err != nil {
+		ln.Close()
+		return err
+	}
+
+	if err := s.modules.Start(ctx); err != nil {
+		ln.Close()
+		if metricsLn != nil {
+			metricsLn.Close()
+		}
+		return err
+	}
+
+	s.bus.Start()
+	if s.scheduler != nil {
+		s.scheduler.Start()
+	}
+
+	serveErr := make(chan error, 2)
+	go func() {
+		logrus.Infof("Starting server on %s", ln.Addr())
+		serveErr <- s.httpServer.Serve(ln)
+	}()
+	if metricsLn != nil {
+		go func() {
+			logrus.Infof("Serving metrics on %s", metricsLn.Addr())
+			serveErr <- s.metricsServer.Serve(metricsLn)
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		// One listener failed: stop the other one and everything started
+		// above, so nothing is left running without Serve waiting on it
+		logrus.WithError(err).Error("Server failed, shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout())
+		defer cancel()
+		return errors.Join(err, s.Shutdown(shutdownCtx))
+	case <-ctx.Done():
+	}
+
+	logrus.Info("Shutdown signal received, draining connections")
+
+	// Fail readiness first so load balancers stop sending new traffic
+	s.health.SetDraining()
+	if delay := time.Duration(s.config.Server.ShutdownDelay) * time.Second; delay > 0 {
+		logrus.Infof("Waiting %s before closing the listener", delay)
+		time.Sleep(delay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout())
+	defer cancel()
 
-	return s.router.Run(addr)
+	return s.Shutdown(shutdownCtx)
+}
+
+// listenMetrics binds the metrics listener, which serves /metrics apart from
+// the API. It returns nil if server.metrics_port is empty.
+func (s *Server) listenMetrics() (net.Listener, error) {
+	if s.config.Server.MetricsPort == "" {
+		return nil, nil
+	}
+	addr := fmt.Sprintf("%s:%s", s.config.Server.Host, s.config.Server.MetricsPort)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s for metrics: %w", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Handler())
+	s.metricsServer = &http.Server{
+		Addr:              ln.Addr().String(),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return ln, nil
+}
+
+// Shutdown stops the server in dependency order: it stops accepting new
+// connections and drains in-flight HTTP requests, stops the modules (closing
+// WebSocket clients and the hub), stops the rate limiter and finally closes
+// the database pool and Redis. Every step runs even if an earlier one fails; all errors are returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.health.SetDraining()
+
+	var errs []error
+
+	if s.httpServer != nil {
+		if err := s.httpServer.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("http server shutdown: %w", err))
+		}
+	}
+	if s.metricsServer != nil {
+		if err := s.metricsServer.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("metrics server shutdown: %w", err))
+		}
+	}
+
+	// Jobs and event subscribers may use module resources, so they stop first
+	if s.scheduler != nil {
+		if err := s.scheduler.Stop(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if s.bus != nil {
+		if err := s.bus.Stop(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// Modules stop in reverse order; the websocket module closes its hijacked
+	// connections, which http.Server.Shutdown does not track
+	if err := s.modules.Stop(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	middleware.CloseDefaultRateLimiter()
+
+	if s.db != nil {
+		if err := s.db.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("database close: %w", err))
+		}
+	}
+
+	if s.redis != nil {
+		if err := s.redis.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("redis close: %w", err))
+		}
+	}
+
+	if len(errs) == 0 {
+		logrus.Info("Server stopped gracefully")
+	}
+	return errors.Join(errs...)
+}
+
+// shutdownTimeout returns the configured drain deadline.
+func (s *Server) shutdownTimeout() time.Duration {
+	timeout := time.Duration(s.config.Server.ShutdownTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second // sensible default
+	}
+	return timeout
 }
@@ -0,0 +1,111 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/server"
+	"egaldeutsch-be/internal/testsupport"
+)
+
+func TestMetricsAreServedOnTheirOwnPort(t *testing.T) {
+	// Reserve a free port for the metrics listener
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	ln.Close()
+
+	srv := newTestServer(t, func(cfg *config.Config) { cfg.Server.MetricsPort = port })
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("get /metrics on the API port: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected /metrics to be missing from the API port, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get("http://127.0.0.1:" + port + "/metrics")
+	if err != nil {
+		t.Fatalf("get /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "http_requests_total") {
+		t.Fatalf("expected metrics on the metrics port, got %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestUnknownRouteIsAProblem(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, err := http.Get(srv.URL + "/api/v1/nope")
+	if err != nil {
+		t.Fatalf("unknown route: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound || resp.Header.Get("Content-Type") != apperr.ContentType {
+		t.Fatalf("unknown route: expected a 404 problem, got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+}
+
+func TestServeShutsDownWhenAListenerFails(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cfg := testsupport.Config()
+	cfg.Database.Path = filepath.Join(t.TempDir(), "server.db")
+	cfg.Redis.Host = mr.Host()
+	cfg.Redis.Port, _ = strconv.Atoi(mr.Port())
+
+	// Reserve a free port for the metrics listener
+	metricsLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	_, cfg.Server.MetricsPort, _ = net.SplitHostPort(metricsLn.Addr().String())
+	metricsLn.Close()
+
+	srv, err := server.NewServer(cfg)
+	if err != nil {
+		t.Fatalf("create server: %v", err)
+	}
+
+	// The API listener is closed, so serving it fails at once
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ln.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(context.Background(), ln) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Fatalf("expected the listener error, got %v", err)
+		}
+	case <-time.After(time.Duration(cfg.Server.ShutdownTimeout+5) * time.Second):
+		t.Fatalf("Serve kept running after the API listener failed")
+	}
+
+	// The metrics server was shut down along with the rest, freeing its port
+	again, err := net.Listen("tcp", "127.0.0.1:"+cfg.Server.MetricsPort)
+	if err != nil {
+		t.Fatalf("expected the metrics port to be free after Serve returned: %v", err)
+	}
+	again.Close()
+}
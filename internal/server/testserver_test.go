@@ -0,0 +1,180 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/database"
+	"egaldeutsch-be/internal/server"
+	"egaldeutsch-be/internal/testsupport"
+	"egaldeutsch-be/modules/user"
+	"egaldeutsch-be/pkg/models"
+)
+
+// testServer is the complete server running in-process on an ephemeral
+// loopback port. It uses a temporary SQLite database and an in-memory Redis,
+// so tests need neither Docker nor network access beyond the loopback
+// interface.
+type testServer struct {
+	// URL is the base URL, for example "http://127.0.0.1:41234".
+	URL string
+	// Config is the configuration the server was started with.
+	Config *config.Config
+	// Redis is the in-memory Redis backing the server.
+	Redis *miniredis.Miniredis
+	// Server is the running server.
+	Server *server.Server
+}
+
+// newTestServer starts a server and stops it when the test finishes. The
+// optional configure functions adjust the configuration returned by
+// testsupport.Config before the server is created. The server gets the
+// in-memory Redis and a database file, so that SetRole can reach it.
+func newTestServer(t testing.TB, configure ...func(*config.Config)) *testServer {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+
+	cfg := testsupport.Config()
+	cfg.Database.Path = filepath.Join(t.TempDir(), "server.db")
+	cfg.Redis.Host = mr.Host()
+	cfg.Redis.Port, _ = strconv.Atoi(mr.Port())
+	for _, fn := range configure {
+		fn(cfg)
+	}
+
+	srv, err := server.NewServer(cfg)
+	if err != nil {
+		t.Fatalf("test server: failed to create server: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("test server: failed to listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Serve(ctx, ln)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("test server: server stopped with error: %v", err)
+			}
+		case <-time.After(time.Duration(cfg.Server.ShutdownTimeout+5) * time.Second):
+			t.Errorf("test server: server did not shut down")
+		}
+	})
+
+	return &testServer{
+		URL:    "http://" + ln.Addr().String(),
+		Config: cfg,
+		Redis:  mr,
+		Server: srv,
+	}
+}
+
+// SetRole changes the role of the user with the given email address the way
+// an operator does from the command line. Sign-up always creates learners.
+func (s *testServer) SetRole(t testing.TB, email string, role models.UserRole) {
+	t.Helper()
+
+	db, err := database.NewDatabase(s.Config.Database)
+	if err != nil {
+		t.Fatalf("test server: failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	users := user.NewModule(db.DB, s.Config.Jwt, s.Config.Password, nil, nil, nil, nil, nil)
+	if err := users.SetRole(context.Background(), email, role); err != nil {
+		t.Fatalf("test server: failed to set role of %s: %v", email, err)
+	}
+}
+
+// WebSocketURL returns the ws:// URL for the given path.
+func (s *testServer) WebSocketURL(path string) string {
+	return "ws://" + strings.TrimPrefix(s.URL, "http://") + path
+}
+
+// doJSON sends a JSON request and decodes the JSON response into out.
+func doJSON(t *testing.T, method, url, token string, body, out any) int {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("marshal request: %v", err)
+		}
+		reader = bytes.NewReader(b)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatalf("build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decode response: %v", method, url, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+type tokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// signUp creates a user, gives them the role unless it is learner, and logs
+// in as that user.
+func signUp(t *testing.T, srv *testServer, email, role string) tokenPair {
+	t.Helper()
+
+	user := map[string]string{"name": "Test User", "email": email, "password": "secret123"}
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/users", "", user, nil); status != http.StatusCreated {
+		t.Fatalf("create user: expected 201, got %d", status)
+	}
+	if models.UserRole(role).Canonical() != models.UserRoleLearner {
+		srv.SetRole(t, email, models.UserRole(role))
+	}
+
+	var tokens tokenPair
+	login := map[string]string{"email": email, "password": "secret123"}
+	if status := doJSON(t, http.MethodPost, srv.URL+"/api/v1/auth/login", "", login, &tokens); status != http.StatusOK {
+		t.Fatalf("login: expected 200, got %d", status)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		t.Fatalf("login: expected access and refresh token, got %+v", tokens)
+	}
+	return tokens
+}
@@ -0,0 +1,263 @@
+// Package testsupport sets up what the handler tests of the modules share: a
+// complete test configuration, an SQLite database, an in-memory Redis, the
+// role policy, and a client that sends JSON requests to a router. Only tests
+// import it.
+package testsupport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"egaldeutsch-be/internal/auth"
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/database"
+	"egaldeutsch-be/internal/events"
+	"egaldeutsch-be/internal/middleware"
+	"egaldeutsch-be/internal/rbac"
+	"egaldeutsch-be/internal/redis"
+)
+
+// Config returns a complete configuration for tests: SQLite in memory, every
+// module enabled, fixed secrets and cheap hashing parameters. Redis has no
+// address; tests that start a server fill it in.
+func Config() *config.Config {
+	return &config.Config{
+		Server: config.ServerConfig{
+			Host:            "127.0.0.1",
+			Port:            "0",
+			ShutdownTimeout: 5,
+		},
+		Database: config.DatabaseConfig{
+			Driver: config.DriverSQLite,
+			Path:   ":memory:",
+		},
+		Jwt: config.JwtConfig{
+			SecretKey:                  "test-server-secret-key-with-at-least-32-characters",
+			Issuer:                     "egaldeutsch-test",
+			ExpirationHours:            1,
+			RefreshTokenExpirationDays: 1,
+			RevocationEnabled:          true,
+		},
+		Redis: config.RedisConfig{
+			MessageTTLHours: 1,
+		},
+		Modules: config.ModulesConfig{
+			Enabled: []string{"user", "auth", "audit", "quiz", "websocket"},
+		},
+		Logging: config.LoggingConfig{
+			Level:  "warn",
+			Format: "console",
+		},
+		Scheduler: config.SchedulerConfig{
+			Enabled:                  true,
+			StaleRoomDays:            30,
+			DeletedUserRetentionDays: 30,
+		},
+		Events: config.EventsConfig{
+			PollInterval:  20,
+			MaxAttempts:   3,
+			RetentionDays: 7,
+		},
+		Idempotency: config.IdempotencyConfig{
+			Enabled:   true,
+			TTLHours:  1,
+			MaxBodyKB: 64,
+		},
+		Mfa: config.MfaConfig{
+			Issuer:              "EgalDeutsch Test",
+			ChallengeTTLSeconds: 300,
+			MaxAttempts:         5,
+			EncryptionKey:       "test-server-mfa-key-with-at-least-32-characters",
+		},
+		Lockout: config.LockoutConfig{
+			Enabled:          true,
+			FreeAttempts:     3,
+			BaseDelaySeconds: 1,
+			MaxDelaySeconds:  30,
+			AccountThreshold: 5,
+			IPThreshold:      50,
+			WindowMinutes:    15,
+			LockoutMinutes:   15,
+		},
+		// Cheap hashing parameters keep the tests fast
+		Password: config.PasswordConfig{
+			Algorithm:         config.PasswordArgon2id,
+			Argon2Memory:      64,
+			Argon2Iterations:  1,
+			Argon2Parallelism: 1,
+			BcryptCost:        4,
+			MinLength:         8,
+			RejectCommon:      true,
+			RejectPersonal:    true,
+		},
+		RBAC: config.RBACConfig{
+			Roles: map[string]config.RoleConfig{
+				"learner":   {Permissions: []string{"chat:write"}},
+				"teacher":   {Inherits: []string{"learner"}, Permissions: []string{"quiz:write"}},
+				"moderator": {Inherits: []string{"learner"}, Permissions: []string{"chat:moderate", "users:read"}},
+				"admin":     {Inherits: []string{"teacher", "moderator"}, Permissions: []string{"users:write", "audit:read", "system:manage"}},
+			},
+		},
+		APIKeys: config.APIKeysConfig{
+			MaxTTLDays: 30,
+			MaxPerUser: 3,
+		},
+		Mail: config.MailConfig{
+			Driver:      config.MailDriverConsole,
+			From:        "EgalDeutsch Test <no-reply@example.com>",
+			FrontendURL: "http://localhost:3000",
+		},
+		EmailVerification: config.EmailVerificationConfig{
+			TokenTTLHours: 48,
+		},
+	}
+}
+
+// Database opens an SQLite database in memory and closes it when the test
+// finishes. Migrate creates its tables once the modules are built on it.
+func Database(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	db, err := database.NewDatabase(config.DatabaseConfig{Driver: config.DriverSQLite, Path: ":memory:"})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db.DB
+}
+
+// Migrate creates the given tables and the tables of the event outbox.
+func Migrate(t testing.TB, db *gorm.DB, tables ...any) {
+	t.Helper()
+
+	if err := db.AutoMigrate(append(tables, events.Models()...)...); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+}
+
+// Redis starts an in-memory Redis and returns it with a client connected to
+// it. Both stop when the test finishes.
+func Redis(t testing.TB) (*miniredis.Miniredis, *redis.RedisClient) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	port, _ := strconv.Atoi(mr.Port())
+	rc, err := redis.NewRedisClient(config.RedisConfig{Host: mr.Host(), Port: port})
+	if err != nil {
+		t.Fatalf("connect to Redis: %v", err)
+	}
+	t.Cleanup(func() { rc.Close() })
+	return mr, rc
+}
+
+// Policy builds the role policy of cfg.
+func Policy(t testing.TB, cfg *config.Config) *rbac.Policy {
+	t.Helper()
+
+	policy, err := rbac.NewPolicy(cfg.RBAC)
+	if err != nil {
+		t.Fatalf("policy: %v", err)
+	}
+	return policy
+}
+
+// Router returns a router with the request ID and error middleware of the
+// server. register attaches the routes of the modules to /api/v1.
+func Router(register ...func(*gin.RouterGroup)) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(middleware.RequestID(), middleware.Errors())
+	api := router.Group("/api/v1")
+	for _, fn := range register {
+		fn(api)
+	}
+	return router
+}
+
+// AccessToken issues an access token for the user with the given role.
+func AccessToken(t testing.TB, cfg config.JwtConfig, userID, role string) string {
+	t.Helper()
+
+	token, err := auth.CreateAccessTokenFromStrings(userID, role, cfg)
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+	return token
+}
+
+// lastIP numbers the client addresses, so that the rate limits shared by all
+// routers apply to each client on its own.
+var lastIP atomic.Int32
+
+// Client sends JSON requests to a router from its own client address.
+type Client struct {
+	handler http.Handler
+	ip      string
+}
+
+// NewClient returns a client of handler with a client address of its own.
+func NewClient(handler http.Handler) *Client {
+	return &Client{handler: handler, ip: fmt.Sprintf("192.0.2.%d", lastIP.Add(1))}
+}
+
+// IP returns the client address the requests come from.
+func (c *Client) IP() string {
+	return c.ip
+}
+
+// NewRequest builds a JSON request, signed in with token unless it is empty.
+func (c *Client) NewRequest(t testing.TB, method, path, token string, body any) *http.Request {
+	t.Helper()
+
+	var b []byte
+	if body != nil {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			t.Fatalf("marshal request: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(b))
+	req.RemoteAddr = c.ip + ":40000"
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+// Send serves req and returns the response.
+func (c *Client) Send(req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c.handler.ServeHTTP(w, req)
+	return w
+}
+
+// Request sends a JSON request and returns the response.
+func (c *Client) Request(t testing.TB, method, path, token string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	return c.Send(c.NewRequest(t, method, path, token, body))
+}
+
+// Do sends a JSON request and decodes the JSON response into out.
+func (c *Client) Do(t testing.TB, method, path, token string, body, out any) int {
+	t.Helper()
+
+	w := c.Request(t, method, path, token, body)
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: decode response %q: %v", method, path, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_questions_deleted_at;
@@ -0,0 +1,2 @@
+-- GORM soft deletes filter on deleted_at for every query
+CREATE INDEX IF NOT EXISTS idx_questions_deleted_at ON questions(deleted_at);
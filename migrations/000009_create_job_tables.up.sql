@@ -0,0 +1,22 @@
+-- Scheduler leases: one row per job, held by the replica running it
+CREATE TABLE IF NOT EXISTS job_leases (
+    name VARCHAR(100) PRIMARY KEY,
+    owner VARCHAR(100) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    last_slot TIMESTAMPTZ NOT NULL
+);
+
+-- Scheduler history: one row per job run
+CREATE TABLE IF NOT EXISTS job_runs (
+    id uuid PRIMARY KEY,
+    job VARCHAR(100) NOT NULL,
+    trigger VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    attempts INTEGER NOT NULL,
+    error TEXT,
+    instance VARCHAR(100) NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_runs_job_started ON job_runs(job, started_at DESC);
@@ -0,0 +1,17 @@
+-- Transactional outbox: domain events written with the state change that
+-- caused them and delivered to subscribers by the relay
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id uuid PRIMARY KEY,
+    type VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    last_error TEXT,
+    delivered_to TEXT,
+    delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_type ON outbox_events(type);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_due ON outbox_events(status, next_attempt_at);
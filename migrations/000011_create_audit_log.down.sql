@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS audit_log;
+DROP FUNCTION IF EXISTS audit_log_append_only();
+
+ALTER TABLE outbox_events
+    DROP COLUMN IF EXISTS actor_id,
+    DROP COLUMN IF EXISTS request_id,
+    DROP COLUMN IF EXISTS ip,
+    DROP COLUMN IF EXISTS user_agent;
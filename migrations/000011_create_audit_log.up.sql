@@ -0,0 +1,43 @@
+-- Request metadata of domain events, recorded in the audit log
+ALTER TABLE outbox_events
+    ADD COLUMN IF NOT EXISTS actor_id VARCHAR(36),
+    ADD COLUMN IF NOT EXISTS request_id VARCHAR(128),
+    ADD COLUMN IF NOT EXISTS ip VARCHAR(45),
+    ADD COLUMN IF NOT EXISTS user_agent VARCHAR(255);
+
+-- Append-only audit log; every entry carries the hash of its predecessor
+CREATE TABLE IF NOT EXISTS audit_log (
+    seq BIGSERIAL PRIMARY KEY,
+    event_id uuid NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    action VARCHAR(100) NOT NULL,
+    actor_id VARCHAR(36),
+    target_type VARCHAR(50),
+    target_id VARCHAR(255),
+    before JSONB,
+    after JSONB,
+    ip VARCHAR(45),
+    user_agent VARCHAR(255),
+    request_id VARCHAR(128),
+    prev_hash VARCHAR(64) NOT NULL,
+    hash VARCHAR(64) NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_log_event_id ON audit_log(event_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_log_prev_hash ON audit_log(prev_hash);
+CREATE INDEX IF NOT EXISTS idx_audit_log_occurred_at ON audit_log(occurred_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log(action);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor_id ON audit_log(actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_target ON audit_log(target_type, target_id);
+
+-- Reject edits and deletions at the database level as well
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
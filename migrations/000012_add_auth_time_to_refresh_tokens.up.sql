@@ -0,0 +1,3 @@
+-- When the user logged in; rotation copies it so refreshed access tokens keep
+-- the auth_time claim of the login
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS auth_time timestamptz;
@@ -0,0 +1,7 @@
+-- Every token rotated from a login shares the ID of the login's first token.
+-- Tokens issued before this migration each form a session of their own.
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS session_id uuid;
+UPDATE refresh_tokens SET session_id = id WHERE session_id IS NULL;
+ALTER TABLE refresh_tokens ALTER COLUMN session_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session_id ON refresh_tokens(session_id);
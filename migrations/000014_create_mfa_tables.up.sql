@@ -0,0 +1,42 @@
+-- TOTP secret of a user; pending until enabled_at is set. The secret is
+-- encrypted when mfa.encryption_key is configured.
+CREATE TABLE IF NOT EXISTS user_mfa (
+  user_id uuid PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  secret text NOT NULL,
+  enabled_at timestamptz,
+  last_used_step bigint NOT NULL DEFAULT 0,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- Single-use recovery codes (store code hash only)
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  code_hash text NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  used_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id);
+
+-- Logins waiting for their second factor (store token hash only)
+CREATE TABLE IF NOT EXISTS mfa_challenges (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  token_hash text NOT NULL,
+  enroll boolean NOT NULL DEFAULT false,
+  attempts integer NOT NULL DEFAULT 0,
+  used boolean NOT NULL DEFAULT false,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  expires_at timestamptz NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_challenges_user_id ON mfa_challenges(user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS ux_mfa_challenges_token_hash ON mfa_challenges(token_hash);
+
+-- Roles whose users must use two-factor authentication
+CREATE TABLE IF NOT EXISTS mfa_required_roles (
+  role varchar(20) PRIMARY KEY,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
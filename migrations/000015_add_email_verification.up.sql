@@ -0,0 +1,18 @@
+-- Users choose the language of their emails and verify their email address.
+-- Existing users stay unverified until they request a verification email.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS language varchar(5) NOT NULL DEFAULT 'de';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at timestamptz;
+
+-- Single-use email verification tokens (store token hash only)
+CREATE TABLE IF NOT EXISTS email_verifications (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  email varchar(100) NOT NULL,
+  token_hash text NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  expires_at timestamptz NOT NULL,
+  used boolean NOT NULL DEFAULT false
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_verifications_user_id ON email_verifications(user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS ux_email_verifications_token_hash ON email_verifications(token_hash);
@@ -0,0 +1,7 @@
+-- Teachers and moderators lose their role along with learners.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS chk_users_role;
+UPDATE users SET role = 'user' WHERE role IN ('learner', 'teacher', 'moderator');
+ALTER TABLE users ADD CONSTRAINT chk_users_role CHECK (role IN ('admin', 'user'));
+
+DELETE FROM mfa_required_roles WHERE role IN ('teacher', 'moderator');
+UPDATE mfa_required_roles SET role = 'user' WHERE role = 'learner';
@@ -0,0 +1,6 @@
+-- Roles are learner, teacher, moderator and admin; "user" becomes learner.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS chk_users_role;
+UPDATE users SET role = 'learner' WHERE role = 'user';
+ALTER TABLE users ADD CONSTRAINT chk_users_role CHECK (role IN ('learner', 'teacher', 'moderator', 'admin'));
+
+UPDATE mfa_required_roles SET role = 'learner' WHERE role = 'user';
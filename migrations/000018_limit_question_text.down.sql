@@ -0,0 +1 @@
+ALTER TABLE questions ALTER COLUMN question_text TYPE VARCHAR(5000);
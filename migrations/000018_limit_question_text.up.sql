@@ -0,0 +1,3 @@
+-- Question texts are limited to 500 characters by the API and the model;
+-- 000006 created the column wider than that.
+ALTER TABLE questions ALTER COLUMN question_text TYPE VARCHAR(500);
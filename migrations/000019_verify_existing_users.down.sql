@@ -0,0 +1,3 @@
+-- The backfilled addresses cannot be told apart from verified ones; they stay
+-- verified.
+SELECT 1;
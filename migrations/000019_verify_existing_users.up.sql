@@ -0,0 +1,7 @@
+-- Users who signed up before email verification existed (000015) were never
+-- asked to verify their address. Count them as verified from the time they
+-- signed up, so features that require a verified address stay open to them;
+-- users who signed up since then still verify as usual.
+UPDATE users SET email_verified_at = created_at
+WHERE email_verified_at IS NULL
+  AND created_at < (SELECT applied_at FROM schema_migrations WHERE version = 15);
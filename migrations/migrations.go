@@ -0,0 +1,10 @@
+// Package migrations embeds the versioned SQL schema files so the server
+// binary can apply them without shipping the directory alongside it.
+package migrations
+
+import "embed"
+
+// FS holds every NNNNNN_name.up.sql / NNNNNN_name.down.sql file.
+//
+//go:embed *.sql
+var FS embed.FS
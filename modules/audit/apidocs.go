@@ -0,0 +1,35 @@
+package audit
+
+import (
+	"net/http"
+
+	"egaldeutsch-be/internal/openapi"
+	"egaldeutsch-be/internal/rbac"
+	"egaldeutsch-be/modules/audit/internal/models"
+)
+
+// APIDocs documents the routes of the audit module.
+func (m *Module) APIDocs(base string) []openapi.Operation {
+	tags := []string{"admin"}
+	return []openapi.Operation{
+		{
+			Method: http.MethodGet, Path: base + "/admin/audit", Tags: tags, Permission: rbac.AuditRead,
+			Summary:  "Query the audit log, newest first",
+			Params:   models.ListQuery{},
+			Response: openapi.Page[models.Entry]{},
+		},
+		{
+			Method: http.MethodGet, Path: base + "/admin/audit/export", Tags: tags, Permission: rbac.AuditRead,
+			Summary:      "Export the audit log, oldest first",
+			Description:  "Streams every matching entry as a download; `format` selects JSON (default) or CSV.",
+			Params:       models.ListQuery{},
+			Response:     []models.Entry{},
+			ContentTypes: []string{"application/json", "text/csv"},
+		},
+		{
+			Method: http.MethodGet, Path: base + "/admin/audit/verify", Tags: tags, Permission: rbac.AuditRead,
+			Summary:  "Verify the hash chain of the audit log",
+			Response: models.VerifyResult{},
+		},
+	}
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/logging"
+	"egaldeutsch-be/modules/audit/internal/models"
+	"egaldeutsch-be/modules/audit/internal/services"
+)
+
+// csvHeader lists the exported columns in order.
+var csvHeader = []string{
+	"seq", "occurred_at", "action", "actor_id", "target_type", "target_id",
+	"before", "after", "ip", "user_agent", "request_id", "event_id", "prev_hash", "hash",
+}
+
+// AuditHandler handles HTTP requests for the audit log
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{auditService: auditService}
+}
+
+// ListEntries handles GET /api/v1/admin/audit
+func (h *AuditHandler) ListEntries(c *gin.Context) {
+	var query models.ListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	entries, total, err := h.auditService.List(c.Request.Context(), query.Filter(), query.Page, query.PerPage)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("list audit log: %w", err))
+		return
+	}
+
+	page, perPage := query.Page, query.PerPage
+	if page == 0 {
+		page = 1
+	}
+	if perPage == 0 {
+		perPage = 20
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":       entries,
+		"page":        page,
+		"per_page":    perPage,
+		"total_items": total,
+		"total_pages": (int(total) + perPage - 1) / perPage,
+	})
+}
+
+// ExportEntries handles GET /api/v1/admin/audit/export?format=csv|json. The
+// matching entries are streamed oldest first; an error after the first byte
+// only ends the response early and is recorded in the access log.
+func (h *AuditHandler) ExportEntries(c *gin.Context) {
+	var query models.ListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+	format := query.Format
+	if format == "" {
+		format = "json"
+	}
+
+	filename := fmt.Sprintf("audit-%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	var err error
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		err = h.exportCSV(c, query.Filter())
+	} else {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		err = h.exportJSON(c, query.Filter())
+	}
+	if err != nil {
+		_ = c.Error(fmt.Errorf("export audit log: %w", err))
+	}
+}
+
+func (h *AuditHandler) exportCSV(c *gin.Context, filter models.Filter) error {
+	w := csv.NewWriter(c.Writer)
+	if err := w.Write(csvHeader); err != nil {
+		return err
+	}
+
+	err := h.auditService.Export(c.Request.Context(), filter, func(batch []models.Entry) error {
+		for _, e := range batch {
+			before, _ := json.Marshal(e.Before)
+			after, _ := json.Marshal(e.After)
+			record := []string{
+				strconv.FormatInt(e.Seq, 10),
+				e.OccurredAt.UTC().Format(time.RFC3339Nano),
+				e.Action, e.ActorID, e.TargetType, e.TargetID,
+				string(before), string(after),
+				e.IP, e.UserAgent, e.RequestID,
+				e.EventID.String(), e.PrevHash, e.Hash,
+			}
+			for i := range record {
+				record[i] = csvCell(record[i])
+			}
+			if err := w.Write(record); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		return w.Error()
+	})
+	w.Flush()
+	if err != nil {
+		return err
+	}
+	return w.Error()
+}
+
+// csvCell keeps a spreadsheet from running a cell as a formula: values that
+// start like one, such as a user agent of "=HYPERLINK(...)", get a leading
+// quote.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func (h *AuditHandler) exportJSON(c *gin.Context, filter models.Filter) error {
+	if _, err := c.Writer.WriteString("["); err != nil {
+		return err
+	}
+
+	first := true
+	enc := json.NewEncoder(c.Writer)
+	err := h.auditService.Export(c.Request.Context(), filter, func(batch []models.Entry) error {
+		for i := range batch {
+			if !first {
+				if _, err := c.Writer.WriteString(","); err != nil {
+					return err
+				}
+			}
+			first = false
+			if err := enc.Encode(&batch[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Writer.WriteString("]\n")
+	return err
+}
+
+// VerifyChain handles GET /api/v1/admin/audit/verify
+func (h *AuditHandler) VerifyChain(c *gin.Context) {
+	result, err := h.auditService.Verify(c.Request.Context())
+	if err != nil {
+		_ = c.Error(fmt.Errorf("verify audit log: %w", err))
+		return
+	}
+	if !result.Valid {
+		logging.FromContext(c.Request.Context()).WithField("seq", result.BrokenAt).Error("Audit log hash chain is broken")
+	}
+	c.JSON(http.StatusOK, result)
+}
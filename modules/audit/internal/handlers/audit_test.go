@@ -0,0 +1,21 @@
+package handlers
+
+import "testing"
+
+func TestCSVCell(t *testing.T) {
+	tests := map[string]string{
+		"":                          "",
+		"auth.login":                "auth.login",
+		"=HYPERLINK(\"http://x\")":  "'=HYPERLINK(\"http://x\")",
+		"+1":                        "'+1",
+		"-2+3":                      "'-2+3",
+		"@SUM(A1)":                  "'@SUM(A1)",
+		"\tcmd":                     "'\tcmd",
+		"Mozilla/5.0 (=X11; Linux)": "Mozilla/5.0 (=X11; Linux)",
+	}
+	for in, want := range tests {
+		if got := csvCell(in); got != want {
+			t.Errorf("csvCell(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// ListQuery represents the query parameters for listing and exporting the
+// audit log
+type ListQuery struct {
+	Action     string    `form:"action" binding:"omitempty,max=100"`
+	ActorID    string    `form:"actor_id" binding:"omitempty,uuid"`
+	TargetType string    `form:"target_type" binding:"omitempty,max=50"`
+	TargetID   string    `form:"target_id" binding:"omitempty,max=255"`
+	From       time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Page       int       `form:"page" binding:"omitempty,min=1"`
+	PerPage    int       `form:"per_page" binding:"omitempty,min=1,max=100"`
+	Format     string    `form:"format" binding:"omitempty,oneof=csv json"`
+}
+
+// Filter returns the filter described by the query.
+func (q ListQuery) Filter() Filter {
+	return Filter{
+		Action:     q.Action,
+		ActorID:    q.ActorID,
+		TargetType: q.TargetType,
+		TargetID:   q.TargetID,
+		From:       q.From,
+		To:         q.To,
+	}
+}
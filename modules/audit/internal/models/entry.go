@@ -0,0 +1,128 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Values holds the audited attributes of the target before or after the
+// action, for example {"role": "admin"}.
+type Values map[string]string
+
+// Value implements the driver.Valuer interface.
+func (v Values) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// Scan implements the sql.Scanner interface. PostgreSQL returns jsonb as
+// bytes, SQLite may return text.
+func (v *Values) Scan(value interface{}) error {
+	switch data := value.(type) {
+	case nil:
+		*v = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(data, v)
+	case string:
+		return json.Unmarshal([]byte(data), v)
+	default:
+		return errors.New(fmt.Sprint("Failed to unmarshal audit values:", value))
+	}
+}
+
+// GormDataType tells GORM the generic type of the map.
+func (Values) GormDataType() string {
+	return "json"
+}
+
+// GormDBDataType stores Values as jsonb on PostgreSQL and as JSON text
+// elsewhere.
+func (Values) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	if db.Dialector.Name() == "postgres" {
+		return "jsonb"
+	}
+	return "text"
+}
+
+// Entry is one record in the append-only audit log. Each entry stores the
+// hash of its predecessor and a hash over its own content, so editing or
+// deleting an entry breaks the chain.
+type Entry struct {
+	Seq        int64     `json:"seq" gorm:"primaryKey;autoIncrement"`
+	EventID    uuid.UUID `json:"event_id" gorm:"type:uuid;not null;uniqueIndex"`
+	OccurredAt time.Time `json:"occurred_at" gorm:"not null;index"`
+	Action     string    `json:"action" gorm:"size:100;not null;index"`
+	ActorID    string    `json:"actor_id,omitempty" gorm:"size:36;index"`
+	TargetType string    `json:"target_type,omitempty" gorm:"size:50;index:idx_audit_log_target,priority:1"`
+	TargetID   string    `json:"target_id,omitempty" gorm:"size:255;index:idx_audit_log_target,priority:2"`
+	Before     Values    `json:"before,omitempty"`
+	After      Values    `json:"after,omitempty"`
+	IP         string    `json:"ip,omitempty" gorm:"size:45"`
+	UserAgent  string    `json:"user_agent,omitempty" gorm:"size:255"`
+	RequestID  string    `json:"request_id,omitempty" gorm:"size:128"`
+	PrevHash   string    `json:"prev_hash" gorm:"size:64;not null;uniqueIndex"`
+	Hash       string    `json:"hash" gorm:"size:64;not null"`
+}
+
+// TableName specifies the table name for the Entry model
+func (Entry) TableName() string {
+	return "audit_log"
+}
+
+// ComputeHash returns the SHA-256 over PrevHash and every audited field.
+// Fields are length-prefixed so that moving bytes between adjacent fields
+// changes the hash.
+func (e *Entry) ComputeHash() string {
+	before, _ := json.Marshal(e.Before)
+	after, _ := json.Marshal(e.After)
+
+	h := sha256.New()
+	for _, field := range []string{
+		e.PrevHash,
+		e.EventID.String(),
+		e.OccurredAt.UTC().Format(time.RFC3339Nano),
+		e.Action,
+		e.ActorID,
+		e.TargetType,
+		e.TargetID,
+		string(before),
+		string(after),
+		e.IP,
+		e.UserAgent,
+		e.RequestID,
+	} {
+		fmt.Fprintf(h, "%d:%s", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Filter narrows audit log queries. Zero fields match everything.
+type Filter struct {
+	Action     string
+	ActorID    string
+	TargetType string
+	TargetID   string
+	From       time.Time
+	To         time.Time
+}
+
+// VerifyResult reports the outcome of walking the hash chain.
+type VerifyResult struct {
+	Valid    bool   `json:"valid"`
+	Entries  int64  `json:"entries"`
+	BrokenAt int64  `json:"broken_at,omitempty"` // seq of the first bad entry
+	Reason   string `json:"reason,omitempty"`
+}
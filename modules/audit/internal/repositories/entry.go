@@ -0,0 +1,135 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"egaldeutsch-be/modules/audit/internal/models"
+)
+
+// verifyBatchSize is how many entries are loaded at a time when the chain is
+// walked or exported.
+const verifyBatchSize = 500
+
+// EntryRepository handles database operations for the audit log. It only
+// appends and reads; entries are never updated or deleted.
+type EntryRepository struct {
+	db *gorm.DB
+}
+
+// NewEntryRepository creates a new audit log repository
+func NewEntryRepository(db *gorm.DB) *EntryRepository {
+	return &EntryRepository{db: db}
+}
+
+// Append links the entry to the current end of the chain and stores it. An
+// entry for an event that is already recorded is skipped, since events are
+// delivered at least once. Concurrent appends race on the unique prev_hash;
+// the loser fails and is retried by the event bus.
+func (r *EntryRepository) Append(ctx context.Context, entry *models.Entry) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var recorded int64
+		if err := tx.Model(&models.Entry{}).Where("event_id = ?", entry.EventID).Count(&recorded).Error; err != nil {
+			return err
+		}
+		if recorded > 0 {
+			return nil
+		}
+
+		var last models.Entry
+		if err := tx.Order("seq DESC").Limit(1).Find(&last).Error; err != nil {
+			return err
+		}
+		entry.PrevHash = last.Hash
+		entry.Hash = entry.ComputeHash()
+
+		return tx.Create(entry).Error
+	})
+}
+
+// List retrieves entries matching the filter with pagination, newest first
+func (r *EntryRepository) List(ctx context.Context, filter models.Filter, page, perPage int) ([]models.Entry, int64, error) {
+	var entries []models.Entry
+	var total int64
+
+	if err := r.filtered(ctx, filter).Model(&models.Entry{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * perPage
+	err := r.filtered(ctx, filter).Order("seq DESC").Offset(offset).Limit(perPage).Find(&entries).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
+
+// Each calls fn with successive batches of the entries matching the filter,
+// oldest first (FindInBatches walks the primary key). It stops at the first
+// error.
+func (r *EntryRepository) Each(ctx context.Context, filter models.Filter, fn func([]models.Entry) error) error {
+	var batch []models.Entry
+	return r.filtered(ctx, filter).FindInBatches(&batch, verifyBatchSize, func(tx *gorm.DB, _ int) error {
+		return fn(batch)
+	}).Error
+}
+
+// Verify walks the whole chain in order and checks every link and hash.
+func (r *EntryRepository) Verify(ctx context.Context) (*models.VerifyResult, error) {
+	result := &models.VerifyResult{Valid: true}
+	prevHash := ""
+
+	err := r.Each(ctx, models.Filter{}, func(batch []models.Entry) error {
+		for i := range batch {
+			e := &batch[i]
+			result.Entries++
+			switch {
+			case e.PrevHash != prevHash:
+				result.Reason = "entry does not link to its predecessor"
+			case e.ComputeHash() != e.Hash:
+				result.Reason = "entry content does not match its hash"
+			default:
+				prevHash = e.Hash
+				continue
+			}
+			result.Valid = false
+			result.BrokenAt = e.Seq
+			return errChainBroken
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errChainBroken) {
+		return nil, fmt.Errorf("walk audit log: %w", err)
+	}
+	return result, nil
+}
+
+var errChainBroken = errors.New("audit chain broken")
+
+// filtered applies the filter to a new query.
+func (r *EntryRepository) filtered(ctx context.Context, f models.Filter) *gorm.DB {
+	q := r.db.WithContext(ctx)
+	if f.Action != "" {
+		q = q.Where("action = ?", f.Action)
+	}
+	if f.ActorID != "" {
+		q = q.Where("actor_id = ?", f.ActorID)
+	}
+	if f.TargetType != "" {
+		q = q.Where("target_type = ?", f.TargetType)
+	}
+	if f.TargetID != "" {
+		q = q.Where("target_id = ?", f.TargetID)
+	}
+	if !f.From.IsZero() {
+		q = q.Where("occurred_at >= ?", f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		q = q.Where("occurred_at < ?", f.To.UTC())
+	}
+	return q
+}
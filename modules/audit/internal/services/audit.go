@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"egaldeutsch-be/internal/events"
+	"egaldeutsch-be/modules/audit/internal/models"
+	"egaldeutsch-be/modules/audit/internal/repositories"
+)
+
+// Target types recorded in the audit log.
+const (
+	TargetUser     = "user"
+	TargetEmail    = "email"
+	TargetQuestion = "question"
+	// TargetMFAPolicy is the set of roles required to use a second factor
+	TargetMFAPolicy = "mfa_policy"
+)
+
+// AuditedEvents are the domain events recorded in the audit log.
+var AuditedEvents = []string{
+	events.UserCreated,
+	events.UserRoleChanged,
+	events.UserDeleted,
+	events.UserEmailVerified,
+	events.UserPasswordChanged,
+	events.AuthLogin,
+	events.AuthLoginFailed,
+	events.AuthAccountLocked,
+	events.AuthAccountUnlocked,
+	events.AuthRefreshReuseDetected,
+	events.AuthSessionsRevoked,
+	events.AuthMFAEnabled,
+	events.AuthMFADisabled,
+	events.AuthMFAReset,
+	events.AuthMFARecoveryCodeUsed,
+	events.AuthMFARecoveryCodesRegenerated,
+	events.AuthMFAPolicyChanged,
+	events.AuthAPIKeyCreated,
+	events.AuthAPIKeyRevoked,
+	events.PasswordResetRequested,
+	events.PasswordResetCompleted,
+	events.QuizQuestionCreated,
+}
+
+// AuditService turns domain events into audit log entries and queries them
+type AuditService struct {
+	repo *repositories.EntryRepository
+}
+
+// NewAuditService creates a new audit service
+func NewAuditService(repo *repositories.EntryRepository) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+// Record appends the entry for a domain event. The action is the event type;
+// the actor is the authenticated user of the request that published it.
+func (s *AuditService) Record(ctx context.Context, event *events.Event) error {
+	entry := &models.Entry{
+		EventID: event.ID,
+		// PostgreSQL keeps microseconds; the hash must survive the round trip
+		OccurredAt: event.OccurredAt.UTC().Truncate(time.Microsecond),
+		Action:     event.Type,
+		ActorID:    event.ActorID,
+		IP:         event.IP,
+		UserAgent:  event.UserAgent,
+		RequestID:  event.RequestID,
+	}
+	if err := describe(event, entry); err != nil {
+		return fmt.Errorf("decode %s: %w", event.Type, err)
+	}
+	return s.repo.Append(ctx, entry)
+}
+
+// describe fills in the target and the before/after values of the entry.
+func describe(event *events.Event, entry *models.Entry) error {
+	switch event.Type {
+	case events.UserCreated:
+		var p events.UserCreatedPayload
+		if err := event.Decode(&p); err != nil {
+			return err
+		}
+		entry.TargetType, entry.TargetID = TargetUser, p.UserID
+		entry.After = models.Values{"email": p.Email, "name": p.Name, "role": p.Role}
+
+	case events.UserRoleChanged:
+		var p events.UserRoleChangedPayload
+		if err := event.Decode(&p); err != nil {
+			return err
+		}
+		entry.TargetType, entry.TargetID = TargetUser, p.UserID
+		entry.Before = models.Values{"role": p.OldRole}
+		entry.After = models.Values{"role": p.NewRole}
+
+	case events.UserDeleted:
+		var p events.UserDeletedPayload
+		if err := event.Decode(&p); err != nil {
+			return err
+		}
+		entry.TargetType, entry.TargetID = TargetUser, p.UserID
+		entry.Before = models.Values{"email": p.Email, "role": p.Role}
+
+	case events.UserEmailVerified:
+		var p events.UserEmailVerifiedPayload
+		if err := event.Decode(&p); err != nil {
+			return err
+		}
+		entry.TargetType, entry.TargetID = TargetUser, p.UserID
+		entry.After = models.Values{"email_verified": p.Email}
+
+	case events.AuthLogin:
+		var p events.AuthLoginPayload
+		if err := event.Decode(&p); err != nil {
+			return err
+		}
+		// Logins happen before there is an authenticated request
+		entry.ActorID = p.UserID
+		entry.TargetType, entry.TargetID = TargetUser, p.UserID
+
+	case events.AuthLoginFailed:
+		var p events.AuthLoginFailedPayload
+		if err := event.Decode(&p); err != nil {
+			return err
+		}
+		entry.TargetType, entry.TargetID = TargetEmail, p.Email
+		if p.Failures > 0 {
+			entry.After = models.Values{"failures": strconv.Itoa(p.Failures)}
+		}
+
+	case events.AuthAccountLocked:
+		var p events.AuthAccountLockedPayload
+		if err := event.Decode(&p); err != nil {
+			return err
+		}
+		entry.TargetType, entry.TargetID = TargetEmail, p.Email
+		entry.After = models.Values{"locked_until": p.LockedUntil.UTC().Format(time.RFC3339)}
+
+	case events.AuthMFARecoveryCodeUsed:
+		var p events.AuthMFARecoveryCodeUsedPayload
+		if err := event.Decode(&p); err != nil {
+			return err
+		}
+		entry.TargetType, entry.TargetID = TargetUser, p.UserID
+		entry.After = models.Values{"remaining": strconv.Itoa(p.Remaining)}
+
+	case events.AuthMFAPolicyChanged:
+		var p events.AuthMFAPolicyChangedPayload
+		if err := event.Decode(&p); err != nil {
+			return err
+		}
+		entry.TargetType = TargetMFAPolicy
+		entry.Before = models.Values{"required_roles": strings.Join(p.OldRoles, ",")}
+		entry.After = models.Values{"required_roles": strings.Join(p.NewRoles, ",")}
+
+	case events.AuthAPIKeyCreated:
+		var p events.AuthAPIKeyCreatedPayload
+		if err := event.Decode(&p); err != nil {
+			return err
+		}
+		entry.TargetType, entry.TargetID = TargetUser, p.UserID
+		entry.After = models.Values{"key_id": p.KeyID, "name": p.Name, "scopes": strings.Join(p.Scopes, ",")}
+
+	case events.AuthAPIKeyRevoked:
+		var p events.AuthAPIKeyRevokedPayload
+		if err := event.Decode(&p); err != nil {
+			return err
+		}
+		entry.TargetType, entry.TargetID = TargetUser, p.UserID
+		entry.Before = models.Values{"key_id": p.KeyID, "name": p.Name}
+
+	case events.QuizQuestionCreated:
+		var p events.QuizQuestionCreatedPayload
+		if err := event.Decode(&p); err != nil {
+			return err
+		}
+		entry.TargetType, entry.TargetID = TargetQuestion, p.QuestionID
+		entry.After = models.Values{"category": p.Category}
+
+	default:
+		// The remaining events only name the user they concern
+		var p struct {
+			UserID string `json:"user_id"`
+		}
+		if err := event.Decode(&p); err != nil {
+			return err
+		}
+		entry.TargetType, entry.TargetID = TargetUser, p.UserID
+	}
+	return nil
+}
+
+// List retrieves entries with pagination, newest first
+func (s *AuditService) List(ctx context.Context, filter models.Filter, page, perPage int) ([]models.Entry, int64, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if perPage <= 0 || perPage > 100 {
+		perPage = 20
+	}
+
+	return s.repo.List(ctx, filter, page, perPage)
+}
+
+// Export calls fn with batches of the matching entries, oldest first
+func (s *AuditService) Export(ctx context.Context, filter models.Filter, fn func([]models.Entry) error) error {
+	return s.repo.Each(ctx, filter, fn)
+}
+
+// Verify checks the hash chain of the whole audit log
+func (s *AuditService) Verify(ctx context.Context) (*models.VerifyResult, error) {
+	return s.repo.Verify(ctx)
+}
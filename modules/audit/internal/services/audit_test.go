@@ -0,0 +1,122 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"egaldeutsch-be/internal/events"
+	"egaldeutsch-be/modules/audit/internal/models"
+	"egaldeutsch-be/modules/audit/internal/repositories"
+	"egaldeutsch-be/modules/audit/internal/services"
+)
+
+func setupAudit(t *testing.T) (*gorm.DB, *services.AuditService) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Entry{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db, services.NewAuditService(repositories.NewEntryRepository(db))
+}
+
+func event(t *testing.T, eventType string, payload interface{}) *events.Event {
+	t.Helper()
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("marshal payload: %v", err)
+	}
+	return &events.Event{
+		ID:         uuid.New(),
+		Type:       eventType,
+		Payload:    data,
+		OccurredAt: time.Now(),
+		ActorID:    "11111111-1111-1111-1111-111111111111",
+		IP:         "203.0.113.7",
+	}
+}
+
+func TestRecordBuildsHashChain(t *testing.T) {
+	db, svc := setupAudit(t)
+	ctx := context.Background()
+
+	roleChanged := event(t, events.UserRoleChanged, events.UserRoleChangedPayload{UserID: "u1", OldRole: "user", NewRole: "admin"})
+	for _, e := range []*events.Event{
+		event(t, events.UserCreated, events.UserCreatedPayload{UserID: "u1", Email: "u1@example.com", Role: "user"}),
+		roleChanged,
+		event(t, events.AuthLoginFailed, events.AuthLoginFailedPayload{Email: "nobody@example.com", Failures: 4}),
+	} {
+		if err := svc.Record(ctx, e); err != nil {
+			t.Fatalf("record %s: %v", e.Type, err)
+		}
+	}
+	// Events are delivered at least once; a redelivery adds no entry
+	if err := svc.Record(ctx, roleChanged); err != nil {
+		t.Fatalf("record duplicate: %v", err)
+	}
+
+	entries, total, err := svc.List(ctx, models.Filter{Action: events.UserRoleChanged}, 1, 10)
+	if err != nil || total != 1 {
+		t.Fatalf("expected 1 role change, got %d (%v)", total, err)
+	}
+	e := entries[0]
+	if e.Before["role"] != "user" || e.After["role"] != "admin" || e.TargetID != "u1" || e.ActorID == "" {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+
+	failed, _, err := svc.List(ctx, models.Filter{Action: events.AuthLoginFailed}, 1, 10)
+	if err != nil || len(failed) != 1 || failed[0].After["failures"] != "4" {
+		t.Fatalf("expected the failed login with its failure count, got %+v (%v)", failed, err)
+	}
+
+	result, err := svc.Verify(ctx)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !result.Valid || result.Entries != 3 {
+		t.Fatalf("expected a valid chain of 3 entries, got %+v", result)
+	}
+
+	// Editing an entry breaks its hash
+	if err := db.Exec("UPDATE audit_log SET after = ? WHERE seq = ?", `{"role":"user"}`, e.Seq).Error; err != nil {
+		t.Fatalf("tamper: %v", err)
+	}
+	result, err = svc.Verify(ctx)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if result.Valid || result.BrokenAt != e.Seq {
+		t.Fatalf("expected the chain to break at %d, got %+v", e.Seq, result)
+	}
+}
+
+func TestVerifyDetectsDeletedEntry(t *testing.T) {
+	db, svc := setupAudit(t)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		e := event(t, events.AuthLogin, events.AuthLoginPayload{UserID: "u1"})
+		if err := svc.Record(ctx, e); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	if err := db.Exec("DELETE FROM audit_log WHERE seq = 2").Error; err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	result, err := svc.Verify(ctx)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if result.Valid || result.BrokenAt != 3 {
+		t.Fatalf("expected the chain to break at 3, got %+v", result)
+	}
+}
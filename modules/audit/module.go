@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"egaldeutsch-be/internal/auth"
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/events"
+	"egaldeutsch-be/internal/rbac"
+	"egaldeutsch-be/modules/audit/internal/handlers"
+	"egaldeutsch-be/modules/audit/internal/models"
+	"egaldeutsch-be/modules/audit/internal/repositories"
+	"egaldeutsch-be/modules/audit/internal/services"
+)
+
+// Module records security and admin actions in a tamper-evident audit log.
+// Entries are written by subscribing to domain events, so an entry exists for
+// every committed change regardless of which module made it.
+type Module struct {
+	handler  *handlers.AuditHandler
+	service  *services.AuditService
+	jwtCfg   config.JwtConfig
+	denylist *auth.Denylist
+	apiKeys  *auth.APIKeys
+	policy   *rbac.Policy
+}
+
+// NewModule creates a new audit module with all dependencies. denylist
+// rejects revoked access tokens and apiKeys accepts API keys; nil disables
+// either. policy decides who may read the log.
+func NewModule(db *gorm.DB, jwtCfg config.JwtConfig, denylist *auth.Denylist, apiKeys *auth.APIKeys, policy *rbac.Policy) *Module {
+	repo := repositories.NewEntryRepository(db)
+	service := services.NewAuditService(repo)
+	handler := handlers.NewAuditHandler(service)
+
+	return &Module{handler: handler, service: service, jwtCfg: jwtCfg, denylist: denylist, apiKeys: apiKeys, policy: policy}
+}
+
+// Name returns the module name used in config.yaml.
+func (m *Module) Name() string {
+	return "audit"
+}
+
+// Subscribe records every audited domain event.
+func (m *Module) Subscribe(bus *events.Bus) {
+	bus.Subscribe("audit.record", m.service.Record, services.AuditedEvents...)
+}
+
+// Start is a no-op; entries are written by the event relay.
+func (m *Module) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop is a no-op; entries are written by the event relay.
+func (m *Module) Stop(ctx context.Context) error {
+	return nil
+}
+
+// Health is always healthy; database health is checked by the server.
+func (m *Module) Health(ctx context.Context) error {
+	return nil
+}
+
+// GetModelsForMigration returns models that need to be migrated
+func (m *Module) GetModelsForMigration() []interface{} {
+	return []interface{}{&models.Entry{}}
+}
@@ -0,0 +1,20 @@
+package audit
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"egaldeutsch-be/internal/middleware"
+	"egaldeutsch-be/internal/rbac"
+)
+
+// RegisterRoutes mounts the audit log API under /admin/audit, for roles
+// with the audit:read permission.
+func (m *Module) RegisterRoutes(rg *gin.RouterGroup) {
+	admin := rg.Group("/admin/audit")
+	admin.Use(middleware.AuthMiddleware(m.jwtCfg, m.denylist, m.apiKeys), middleware.RequirePermission(m.policy, rbac.AuditRead))
+	{
+		admin.GET("", m.handler.ListEntries)
+		admin.GET("/export", m.handler.ExportEntries)
+		admin.GET("/verify", m.handler.VerifyChain)
+	}
+}
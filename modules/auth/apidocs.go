@@ -0,0 +1,295 @@
+package authmodule
+
+import (
+	"net/http"
+	"time"
+
+	"egaldeutsch-be/internal/auth"
+	"egaldeutsch-be/internal/openapi"
+	"egaldeutsch-be/internal/rbac"
+	"egaldeutsch-be/modules/auth/internal/models"
+	sharedmodels "egaldeutsch-be/pkg/models"
+)
+
+// tokenPair documents the body of a successful login or refresh.
+type tokenPair = struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// loginResponse documents the body of a login: the tokens, or the challenge
+// of a login that needs a second factor.
+type loginResponse = struct {
+	AccessToken        string    `json:"access_token,omitempty"`
+	RefreshToken       string    `json:"refresh_token,omitempty"`
+	MFARequired        bool      `json:"mfa_required,omitempty"`
+	MFAToken           string    `json:"mfa_token,omitempty"`
+	EnrollmentRequired bool      `json:"enrollment_required,omitempty"`
+	ExpiresAt          time.Time `json:"expires_at,omitempty"`
+}
+
+// mfaTokens documents the body of a passed MFA challenge.
+type mfaTokens = struct {
+	AccessToken   string   `json:"access_token"`
+	RefreshToken  string   `json:"refresh_token"`
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// recoveryCodes documents a body with newly issued recovery codes.
+type recoveryCodes = struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// mfaPolicy documents the roles required to use a second factor.
+type mfaPolicy = struct {
+	RequiredRoles []string `json:"required_roles"`
+}
+
+// sessionList documents the body of a session listing.
+type sessionList = struct {
+	Items []auth.Session `json:"items"`
+}
+
+// revokedCount documents the body of a bulk session revocation.
+type revokedCount = struct {
+	Revoked int `json:"revoked"`
+}
+
+// apiKeyList documents the body of an API key listing.
+type apiKeyList = struct {
+	Items []auth.APIKey `json:"items"`
+}
+
+// createdAPIKey documents the body of a new API key.
+type createdAPIKey = struct {
+	APIKey auth.APIKey `json:"api_key"`
+	Key    string      `json:"key"`
+}
+
+// APIDocs documents the routes of the auth module.
+func (m *Module) APIDocs(base string) []openapi.Operation {
+	tags := []string{"auth"}
+	return []openapi.Operation{
+		{
+			Method: http.MethodPost, Path: base + "/auth/login", Tags: tags,
+			Summary: "Log in with email and password",
+			Description: "Users with two-factor authentication, or whose role requires it, get an MFA token " +
+				"instead of the tokens; pass it to /auth/mfa/verify, after /auth/mfa/enroll if enrollment is required. " +
+				"Repeated failures make the account wait, then lock it; they also block the client IP. " +
+				"Throttled logins get 429 too_many_login_attempts with a Retry-After header.",
+			Body:     models.LoginRequest{},
+			Response: loginResponse{},
+			Errors:   []int{http.StatusUnauthorized, http.StatusTooManyRequests},
+		},
+		{
+			Method: http.MethodPost, Path: base + "/auth/mfa/verify", Tags: tags,
+			Summary: "Complete a login with a TOTP or recovery code",
+			Description: "A login that required enrollment is completed with a code of the new secret and " +
+				"returns the recovery codes, which are not shown again. Wrong codes count towards a limit per login.",
+			Body:     models.MFAVerifyRequest{},
+			Response: mfaTokens{},
+			Errors:   []int{http.StatusUnauthorized, http.StatusConflict, http.StatusTooManyRequests},
+		},
+		{
+			Method: http.MethodPost, Path: base + "/auth/mfa/enroll", Tags: tags,
+			Summary:  "Start the enrollment a login requires",
+			Body:     models.MFAEnrollRequest{},
+			Response: auth.TOTPSetup{},
+			Errors:   []int{http.StatusUnauthorized, http.StatusConflict},
+		},
+		{
+			Method: http.MethodPost, Path: base + "/auth/logout", Tags: tags,
+			Summary:     "Revoke a refresh token",
+			Description: "Send the session's access token as a bearer token to revoke it as well.",
+			Body:        models.LogoutRequest{},
+			Errors:      []int{http.StatusUnauthorized},
+		},
+		{
+			Method: http.MethodPost, Path: base + "/auth/refresh", Tags: tags,
+			Summary:     "Rotate a refresh token",
+			Description: "Presenting a refresh token again after it was rotated revokes every session of the user.",
+			Body:        models.RefreshRequest{},
+			Response:    tokenPair{},
+			Errors:      []int{http.StatusUnauthorized},
+		},
+		{
+			Method: http.MethodPost, Path: base + "/auth/forgot-password", Tags: tags,
+			Summary:     "Request a password reset",
+			Description: "Always accepted, in the same time whether or not the email belongs to a user. A user gets an email linking to /reset-password?token=... on the frontend shortly after.",
+			Body:        models.ForgotPasswordRequest{},
+			Status:      http.StatusAccepted,
+			Errors:      []int{http.StatusTooManyRequests},
+		},
+		{
+			Method: http.MethodPost, Path: base + "/auth/reset-password", Tags: tags,
+			Summary:     "Set a new password with a reset token",
+			Description: "A password the password policy rejects is a 400 and leaves the token usable.",
+			Body:        models.ResetPasswordRequest{},
+			Errors:      []int{http.StatusUnauthorized},
+		},
+		{
+			Method: http.MethodPost, Path: base + "/auth/verify-email", Tags: tags,
+			Summary:     "Verify an email address",
+			Description: "Takes the token of the link in a verification email, which opens /verify-email?token=... on the frontend. Each token works once.",
+			Body:        models.VerifyEmailRequest{},
+			Status:      http.StatusNoContent,
+			Errors:      []int{http.StatusUnauthorized},
+		},
+		{
+			Method: http.MethodPost, Path: base + "/auth/verify-email/resend", Tags: tags, Access: openapi.Bearer,
+			Summary:     "Send another verification email",
+			Description: "Links sent before stay valid until they expire.",
+			Status:      http.StatusAccepted,
+			Errors:      []int{http.StatusConflict, http.StatusTooManyRequests},
+		},
+		{
+			Method: http.MethodGet, Path: base + "/auth/me", Tags: tags, Access: openapi.Bearer,
+			Summary:     "The authenticated user",
+			Description: "`permissions` lists what the user's role may do under the RBAC policy.",
+			Response: struct {
+				User        sharedmodels.UserView `json:"user"`
+				Permissions []rbac.Permission     `json:"permissions"`
+			}{},
+			Errors: []int{http.StatusTooManyRequests},
+		},
+		{
+			Method: http.MethodGet, Path: base + "/auth/sessions", Tags: tags, Access: openapi.Bearer,
+			Summary:     "List your active sessions",
+			Description: "The session of the access token making the request is marked current.",
+			Response:    sessionList{},
+		},
+		{
+			Method: http.MethodDelete, Path: base + "/auth/sessions", Tags: tags, Access: openapi.Bearer,
+			Summary:  "End all your other sessions",
+			Response: revokedCount{},
+		},
+		{
+			Method: http.MethodDelete, Path: base + "/auth/sessions/:session_id", Tags: tags, Access: openapi.Bearer,
+			Summary:     "End one of your sessions",
+			Description: "Its refresh token and access tokens stop working immediately.",
+			Params:      models.SessionIDParam{},
+			Status:      http.StatusNoContent,
+			Errors:      []int{http.StatusNotFound},
+		},
+		{
+			Method: http.MethodGet, Path: base + "/auth/mfa", Tags: tags, Access: openapi.Bearer,
+			Summary:  "Your two-factor authentication status",
+			Response: auth.MFAStatus{},
+		},
+		{
+			Method: http.MethodPost, Path: base + "/auth/mfa/totp", Tags: tags, Access: openapi.Bearer,
+			Summary:     "Start enrolling an authenticator app",
+			Description: "Returns a new secret and its otpauth URI; it is enabled once confirmed with a code.",
+			Response:    auth.TOTPSetup{},
+			Errors:      []int{http.StatusConflict},
+		},
+		{
+			Method: http.MethodPost, Path: base + "/auth/mfa/totp/confirm", Tags: tags, Access: openapi.Bearer,
+			Summary:     "Enable two-factor authentication with a code from the app",
+			Description: "Returns the recovery codes, which are not shown again.",
+			Body:        models.MFACodeRequest{},
+			Response:    recoveryCodes{},
+			Errors:      []int{http.StatusUnauthorized, http.StatusConflict},
+		},
+		{
+			Method: http.MethodPost, Path: base + "/auth/mfa/recovery-codes", Tags: tags, Access: openapi.Bearer,
+			Summary:     "Replace your recovery codes",
+			Description: "Requires a TOTP or recovery code. The previous codes stop working.",
+			Body:        models.MFACodeRequest{},
+			Response:    recoveryCodes{},
+			Errors:      []int{http.StatusUnauthorized, http.StatusConflict},
+		},
+		{
+			Method: http.MethodPost, Path: base + "/auth/mfa/disable", Tags: tags, Access: openapi.Bearer,
+			Summary:     "Turn off two-factor authentication",
+			Description: "Requires a TOTP or recovery code. Not allowed if your role requires two-factor authentication.",
+			Body:        models.MFACodeRequest{},
+			Status:      http.StatusNoContent,
+			Errors:      []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusConflict},
+		},
+		{
+			Method: http.MethodPost, Path: base + "/auth/api-keys", Tags: tags, Access: openapi.Bearer,
+			Summary:     "Create an API key for a machine client",
+			Description: "The key is sent as a Bearer token and may use the given permissions, as long as your role has them. It is shown only in this response. ttl_days defaults to the longest lifetime allowed.",
+			Body:        models.CreateAPIKeyRequest{},
+			Response:    createdAPIKey{},
+			Status:      http.StatusCreated,
+			Errors:      []int{http.StatusConflict},
+		},
+		{
+			Method: http.MethodGet, Path: base + "/auth/api-keys", Tags: tags, Access: openapi.Bearer,
+			Summary:  "List your API keys",
+			Response: apiKeyList{},
+		},
+		{
+			Method: http.MethodDelete, Path: base + "/auth/api-keys/:key_id", Tags: tags, Access: openapi.Bearer,
+			Summary:     "Revoke one of your API keys",
+			Description: "The key stops working immediately.",
+			Params:      models.APIKeyIDParam{},
+			Status:      http.StatusNoContent,
+			Errors:      []int{http.StatusNotFound},
+		},
+		{
+			Method: http.MethodGet, Path: base + "/admin/users/:id/sessions", Tags: tags, Permission: rbac.UsersRead,
+			Summary:  "List a user's active sessions",
+			Params:   models.UserIDParam{},
+			Response: sessionList{},
+			Errors:   []int{http.StatusNotFound},
+		},
+		{
+			Method: http.MethodDelete, Path: base + "/admin/users/:id/sessions", Tags: tags, Permission: rbac.UsersWrite,
+			Summary:  "End all sessions of a user",
+			Params:   models.UserIDParam{},
+			Response: revokedCount{},
+			Errors:   []int{http.StatusNotFound},
+		},
+		{
+			Method: http.MethodDelete, Path: base + "/admin/users/:id/sessions/:session_id", Tags: tags, Permission: rbac.UsersWrite,
+			Summary: "End a session of a user",
+			Params:  models.UserSessionParams{},
+			Status:  http.StatusNoContent,
+			Errors:  []int{http.StatusNotFound},
+		},
+		{
+			Method: http.MethodDelete, Path: base + "/admin/users/:id/mfa", Tags: tags, Permission: rbac.UsersWrite,
+			Summary:     "Reset the two-factor authentication of a user",
+			Description: "For a user who lost their authenticator and recovery codes. They enroll again at their next login if their role requires it.",
+			Params:      models.UserIDParam{},
+			Status:      http.StatusNoContent,
+			Errors:      []int{http.StatusNotFound, http.StatusConflict},
+		},
+		{
+			Method: http.MethodDelete, Path: base + "/admin/users/:id/lockout", Tags: tags, Permission: rbac.UsersWrite,
+			Summary:     "Unlock a user locked out by failed logins",
+			Description: "Also forgets the user's failed logins. Blocked IPs stay blocked.",
+			Params:      models.UserIDParam{},
+			Status:      http.StatusNoContent,
+			Errors:      []int{http.StatusNotFound},
+		},
+		{
+			Method: http.MethodGet, Path: base + "/admin/api-keys", Tags: tags, Permission: rbac.UsersRead,
+			Summary:  "List the API keys of all users, or of one",
+			Params:   models.APIKeyListQuery{},
+			Response: apiKeyList{},
+		},
+		{
+			Method: http.MethodDelete, Path: base + "/admin/api-keys/:key_id", Tags: tags, Permission: rbac.UsersWrite,
+			Summary: "Revoke an API key of any user",
+			Params:  models.APIKeyIDParam{},
+			Status:  http.StatusNoContent,
+			Errors:  []int{http.StatusNotFound},
+		},
+		{
+			Method: http.MethodGet, Path: base + "/admin/mfa/policy", Tags: tags, Permission: rbac.SystemManage,
+			Summary:  "Roles required to use two-factor authentication",
+			Response: mfaPolicy{},
+		},
+		{
+			Method: http.MethodPut, Path: base + "/admin/mfa/policy", Tags: tags, Permission: rbac.SystemManage,
+			Summary:     "Set the roles required to use two-factor authentication",
+			Description: "Users of a newly required role enroll at their next login.",
+			Body:        models.MFAPolicyRequest{},
+			Response:    mfaPolicy{},
+		},
+	}
+}
@@ -0,0 +1,146 @@
+package authmodule
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/events"
+)
+
+// Subscribe revokes a user's refresh tokens when their account is deleted,
+// and their access tokens on a password reset and a role change as well. A
+// password reset ends the sessions itself, before it succeeds.
+// Deleting an account revokes its API keys; a password reset revokes them
+// itself, before it succeeds.
+// Changing the password ends every other session of the user.
+// New users get an email to verify their address, users who asked for one a
+// password reset link, and users whose account failed logins locked a notice.
+func (m *Module) Subscribe(bus *events.Bus) {
+	bus.Subscribe("auth.revoke-sessions", m.revokeSessions, events.UserDeleted)
+	bus.Subscribe("auth.revoke-other-sessions", m.revokeOtherSessions, events.UserPasswordChanged)
+	bus.Subscribe("auth.revoke-api-keys", m.revokeAPIKeys, events.UserDeleted)
+	bus.Subscribe("auth.revoke-access-tokens", m.revokeAccessTokens, events.PasswordResetCompleted, events.UserDeleted, events.UserRoleChanged)
+	bus.Subscribe("auth.send-verification-email", m.sendVerificationEmail, events.UserCreated)
+	bus.Subscribe("auth.send-password-reset-email", m.sendPasswordResetEmail, events.PasswordResetLinkRequested)
+	bus.Subscribe("auth.notify-account-locked", m.notifyAccountLocked, events.AuthAccountLocked)
+}
+
+// revokeSessions ends every session of a deleted user.
+func (m *Module) revokeSessions(ctx context.Context, event *events.Event) error {
+	var payload struct {
+		UserID string `json:"user_id"`
+	}
+	if err := event.Decode(&payload); err != nil {
+		return fmt.Errorf("decode %s: %w", event.Type, err)
+	}
+	return m.service.RevokeAllRefreshTokens(ctx, payload.UserID)
+}
+
+// revokeAPIKeys revokes the keys of a deleted user. They stop working as
+// soon as the user is deleted; revoking them lets the maintenance job delete
+// them and takes them off the admin list.
+func (m *Module) revokeAPIKeys(ctx context.Context, event *events.Event) error {
+	var payload events.UserDeletedPayload
+	if err := event.Decode(&payload); err != nil {
+		return fmt.Errorf("decode %s: %w", event.Type, err)
+	}
+	return m.service.RevokeAllAPIKeys(ctx, payload.UserID)
+}
+
+// revokeOtherSessions ends every session of the user but the one the
+// password was changed from, and revokes the access tokens issued to them.
+func (m *Module) revokeOtherSessions(ctx context.Context, event *events.Event) error {
+	var payload events.UserPasswordChangedPayload
+	if err := event.Decode(&payload); err != nil {
+		return fmt.Errorf("decode %s: %w", event.Type, err)
+	}
+	_, err := m.service.RevokeOtherSessions(ctx, payload.UserID, payload.SessionID)
+	return err
+}
+
+// revokeAccessTokens revokes the tokens issued before the event. The user
+// service usually did so already when the change was made; this covers
+// changes made elsewhere, such as from the command line, and Redis failures.
+func (m *Module) revokeAccessTokens(ctx context.Context, event *events.Event) error {
+	var payload struct {
+		UserID string `json:"user_id"`
+	}
+	if err := event.Decode(&payload); err != nil {
+		return fmt.Errorf("decode %s: %w", event.Type, err)
+	}
+	return m.denylist.RevokeUser(ctx, payload.UserID, event.OccurredAt)
+}
+
+// sendVerificationEmail emails a new user the link verifying their address.
+// Users deleted or verified in the meantime, such as admins created from the
+// command line, get none. A retried delivery sends another link; each one
+// works.
+func (m *Module) sendVerificationEmail(ctx context.Context, event *events.Event) error {
+	var payload events.UserCreatedPayload
+	if err := event.Decode(&payload); err != nil {
+		return fmt.Errorf("decode %s: %w", event.Type, err)
+	}
+	user, err := m.userService.GetUserViewByID(ctx, payload.UserID)
+	if e := apperr.As(err); e != nil && e.Status == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return nil
+	}
+	return m.Handler.SendVerificationEmail(ctx, user)
+}
+
+// sendPasswordResetEmail emails a reset link to the user with the requested
+// email; emails that belong to no user get nothing. A retried delivery sends
+// another link; each one works.
+func (m *Module) sendPasswordResetEmail(ctx context.Context, event *events.Event) error {
+	var payload events.PasswordResetLinkRequestedPayload
+	if err := event.Decode(&payload); err != nil {
+		return fmt.Errorf("decode %s: %w", event.Type, err)
+	}
+	userID, err := m.userService.GetUserIDByEmail(ctx, payload.Email)
+	if e := apperr.As(err); e != nil && e.Status == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	user, err := m.userService.GetUserViewByID(ctx, userID)
+	if e := apperr.As(err); e != nil && e.Status == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return m.Handler.SendPasswordResetEmail(ctx, user)
+}
+
+// notifyAccountLocked emails the owner of a locked account. Emails that
+// belong to no user are locked as well, so nobody learns which do; those get
+// no email.
+func (m *Module) notifyAccountLocked(ctx context.Context, event *events.Event) error {
+	var payload events.AuthAccountLockedPayload
+	if err := event.Decode(&payload); err != nil {
+		return fmt.Errorf("decode %s: %w", event.Type, err)
+	}
+	userID, err := m.userService.GetUserIDByEmail(ctx, payload.Email)
+	if e := apperr.As(err); e != nil && e.Status == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	user, err := m.userService.GetUserViewByID(ctx, userID)
+	if e := apperr.As(err); e != nil && e.Status == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return m.Handler.SendAccountLockedEmail(ctx, user, payload.LockedUntil.Sub(event.OccurredAt))
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/middleware"
+	"egaldeutsch-be/internal/rbac"
+	authModels "egaldeutsch-be/modules/auth/internal/models"
+)
+
+// CreateAPIKey handles POST /auth/api-keys. The key is only in this
+// response; afterwards only its prefix is shown.
+func (h *AuthHandler) CreateAPIKey(c *gin.Context) {
+	var req authModels.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	// A key may only carry permissions its user has, so that it cannot be
+	// used to do more than they can
+	role := middleware.GetUserRoleFromContext(c)
+	for _, scope := range req.Scopes {
+		perm := rbac.Permission(scope)
+		if !slices.Contains(rbac.Permissions, perm) {
+			_ = c.Error(apperr.Validation(apperr.FieldError{Field: "scopes", Message: fmt.Sprintf("unknown permission %q", scope)}))
+			return
+		}
+		if !h.policy.Can(role, perm) {
+			_ = c.Error(apperr.Validation(apperr.FieldError{Field: "scopes", Message: fmt.Sprintf("your role does not have permission %q", scope)}))
+			return
+		}
+	}
+	slices.Sort(req.Scopes)
+	req.Scopes = slices.Compact(req.Scopes)
+
+	userID, _ := middleware.GetUserIDFromContext(c)
+	apiKey, key, err := h.authService.CreateAPIKey(c.Request.Context(), userID, req.Name, req.Scopes, req.TTLDays)
+	if err != nil {
+		// auth.ErrTooManyAPIKeys is a 409
+		_ = c.Error(fmt.Errorf("create API key: %w", err))
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"api_key": apiKey, "key": key})
+}
+
+// ListAPIKeys handles GET /auth/api-keys: the unrevoked keys of the
+// authenticated user, newest first.
+func (h *AuthHandler) ListAPIKeys(c *gin.Context) {
+	userID, _ := middleware.GetUserIDFromContext(c)
+	keys, err := h.authService.ListAPIKeys(c.Request.Context(), userID)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("list API keys: %w", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": keys})
+}
+
+// RevokeAPIKey handles DELETE /auth/api-keys/:key_id.
+func (h *AuthHandler) RevokeAPIKey(c *gin.Context) {
+	var params authModels.APIKeyIDParam
+	if err := c.ShouldBindUri(&params); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	userID, _ := middleware.GetUserIDFromContext(c)
+	if err := h.authService.RevokeAPIKey(c.Request.Context(), userID, params.KeyID); err != nil {
+		// auth.ErrAPIKeyNotFound is a 404, for keys of other users as well
+		_ = c.Error(fmt.Errorf("revoke API key: %w", err))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListAllAPIKeys handles GET /admin/api-keys: the unrevoked keys of every
+// user, or of the one given by ?user_id=.
+func (h *AuthHandler) ListAllAPIKeys(c *gin.Context) {
+	var query authModels.APIKeyListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	keys, err := h.authService.ListAPIKeys(c.Request.Context(), query.UserID)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("list API keys: %w", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": keys})
+}
+
+// RevokeAnyAPIKey handles DELETE /admin/api-keys/:key_id.
+func (h *AuthHandler) RevokeAnyAPIKey(c *gin.Context) {
+	var params authModels.APIKeyIDParam
+	if err := c.ShouldBindUri(&params); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	if err := h.authService.RevokeAPIKey(c.Request.Context(), "", params.KeyID); err != nil {
+		_ = c.Error(fmt.Errorf("revoke API key: %w", err))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
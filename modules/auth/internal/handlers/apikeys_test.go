@@ -0,0 +1,97 @@
+package handlers_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/pkg/models"
+)
+
+func TestAPIKeys(t *testing.T) {
+	env := newTestEnv(t)
+	moderator := env.signUp(t, "moderator@example.com", models.UserRoleModerator)
+	admin := env.signUp(t, "admin@example.com", models.UserRoleAdmin)
+	learner := env.signUp(t, "learner@example.com", models.UserRoleLearner)
+
+	var problem apperr.Problem
+	create := map[string]any{"name": "user export", "scopes": []string{"quiz:write"}}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/api-keys", moderator.AccessToken, create, &problem); status != http.StatusBadRequest || len(problem.Errors) != 1 || problem.Errors[0].Field != "scopes" {
+		t.Fatalf("scope the role lacks: expected 400 on scopes, got %d %+v", status, problem)
+	}
+	create["scopes"] = []string{"users:read"}
+	create["ttl_days"] = 31
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/api-keys", moderator.AccessToken, create, nil); status != http.StatusBadRequest {
+		t.Fatalf("lifetime over the limit: expected 400, got %d", status)
+	}
+	delete(create, "ttl_days")
+
+	var created struct {
+		APIKey struct {
+			ID        string     `json:"id"`
+			Prefix    string     `json:"prefix"`
+			ExpiresAt *time.Time `json:"expires_at"`
+		} `json:"api_key"`
+		Key string `json:"key"`
+	}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/api-keys", moderator.AccessToken, create, &created); status != http.StatusCreated {
+		t.Fatalf("create key: expected 201, got %d", status)
+	}
+	if !strings.HasPrefix(created.Key, "egd_") || !strings.HasPrefix(created.Key, created.APIKey.Prefix) || created.APIKey.ExpiresAt == nil {
+		t.Fatalf("unexpected key %+v", created)
+	}
+
+	// The key works where its scope is required, and nowhere else
+	if status := env.Do(t, http.MethodGet, "/api/v1/users", created.Key, nil, nil); status != http.StatusOK {
+		t.Fatalf("list users with the key: expected 200, got %d", status)
+	}
+	if status := env.Do(t, http.MethodGet, "/api/v1/users/me", created.Key, nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("users/me with the key: expected 401, got %d", status)
+	}
+	if status := env.Do(t, http.MethodGet, "/api/v1/auth/api-keys", created.Key, nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("list keys with the key: expected 401, got %d", status)
+	}
+
+	var keys struct {
+		Items []struct {
+			ID         string     `json:"id"`
+			UserID     string     `json:"user_id"`
+			Scopes     []string   `json:"scopes"`
+			LastUsedAt *time.Time `json:"last_used_at"`
+		} `json:"items"`
+	}
+	if status := env.Do(t, http.MethodGet, "/api/v1/auth/api-keys", moderator.AccessToken, nil, &keys); status != http.StatusOK {
+		t.Fatalf("list keys: expected 200, got %d", status)
+	}
+	if len(keys.Items) != 1 || keys.Items[0].ID != created.APIKey.ID || keys.Items[0].LastUsedAt == nil || strings.Join(keys.Items[0].Scopes, ",") != "users:read" {
+		t.Fatalf("unexpected keys %+v", keys.Items)
+	}
+
+	// Admins see every key; users cannot revoke other users' keys
+	adminCreate := map[string]any{"name": "reporting", "scopes": []string{"audit:read"}}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/api-keys", admin.AccessToken, adminCreate, nil); status != http.StatusCreated {
+		t.Fatalf("create admin key: expected 201, got %d", status)
+	}
+	if status := env.Do(t, http.MethodGet, "/api/v1/admin/api-keys", admin.AccessToken, nil, &keys); status != http.StatusOK || len(keys.Items) != 2 {
+		t.Fatalf("list all keys: expected 200 with 2 keys, got %d %+v", status, keys.Items)
+	}
+	if status := env.Do(t, http.MethodGet, "/api/v1/admin/api-keys?user_id="+keys.Items[1].UserID, admin.AccessToken, nil, &keys); status != http.StatusOK || len(keys.Items) != 1 {
+		t.Fatalf("list a user's keys: expected 200 with 1 key, got %d %+v", status, keys.Items)
+	}
+	if status := env.Do(t, http.MethodGet, "/api/v1/admin/api-keys", learner.AccessToken, nil, nil); status != http.StatusForbidden {
+		t.Fatalf("list all keys as learner: expected 403, got %d", status)
+	}
+	if status := env.Do(t, http.MethodDelete, "/api/v1/auth/api-keys/"+created.APIKey.ID, admin.AccessToken, nil, nil); status != http.StatusNotFound {
+		t.Fatalf("revoke another user's key: expected 404, got %d", status)
+	}
+
+	if status := env.Do(t, http.MethodDelete, "/api/v1/auth/api-keys/"+created.APIKey.ID, moderator.AccessToken, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("revoke key: expected 204, got %d", status)
+	}
+	problem = apperr.Problem{}
+	if status := env.Do(t, http.MethodGet, "/api/v1/users", created.Key, nil, &problem); status != http.StatusUnauthorized || problem.Code != "invalid_api_key" {
+		t.Fatalf("revoked key: expected 401 invalid_api_key, got %d %+v", status, problem)
+	}
+}
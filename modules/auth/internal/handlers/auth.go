@@ -1,74 +1,152 @@
 package handlers
 
 import (
-	"egaldeutsch-be/internal/auth"
-	"egaldeutsch-be/internal/config"
-	authModels "egaldeutsch-be/modules/auth/internal/models"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/auth"
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/logging"
+	"egaldeutsch-be/internal/mail"
+	"egaldeutsch-be/internal/rbac"
+	authModels "egaldeutsch-be/modules/auth/internal/models"
 )
 
 type AuthHandler struct {
 	authService auth.AuthService
 	userService UserService
 	jwtCfg      config.JwtConfig
+	denylist    *auth.Denylist
+	policy      *rbac.Policy
+	throttle    *auth.LoginThrottle
+	mailer      *mail.Sender
+	verifyCfg   config.EmailVerificationConfig
 }
 
-func NewAuthHandler(authService auth.AuthService, userService UserService, jwtCfg config.JwtConfig) *AuthHandler {
+func NewAuthHandler(authService auth.AuthService, userService UserService, jwtCfg config.JwtConfig, denylist *auth.Denylist, policy *rbac.Policy, throttle *auth.LoginThrottle, mailer *mail.Sender, verifyCfg config.EmailVerificationConfig) *AuthHandler {
 	return &AuthHandler{
 		authService: authService,
 		userService: userService,
 		jwtCfg:      jwtCfg,
+		denylist:    denylist,
+		policy:      policy,
+		throttle:    throttle,
+		mailer:      mailer,
+		verifyCfg:   verifyCfg,
 	}
 }
 
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req authModels.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(apperr.FromBinding(err))
 		return
 	}
-	userId, role, err := h.userService.AuthenticateUser(req.Email, req.Password)
+	ctx := c.Request.Context()
+
+	// Throttled logins are turned away before the password is checked, in
+	// the same way whether or not the email belongs to a user. Without Redis
+	// logins go unthrottled rather than failing.
+	wait, err := h.throttle.Check(ctx, req.Email, c.ClientIP())
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		logging.FromContext(ctx).WithError(err).Error("failed to check login throttle")
+	}
+	if wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		_ = c.Error(auth.ErrTooManyLoginAttempts)
+		return
+	}
+
+	userId, role, err := h.userService.AuthenticateUser(ctx, req.Email, req.Password)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		h.recordFailedLogin(ctx, req.Email, c.ClientIP())
+		_ = c.Error(err)
 		return
 	}
-	// include user's role in the access token so middleware can derive role
-	token, err := auth.CreateAccessTokenFromStrings(userId, string(role), h.jwtCfg)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create token: " + err.Error()})
+		_ = c.Error(fmt.Errorf("authenticate user: %w", err))
 		return
 	}
-	// create refresh token
-	ip := c.ClientIP()
-	ua := c.Request.UserAgent()
-	refresh, err := h.authService.CreateRefreshToken(userId, ip, ua)
+	if err := h.throttle.Succeed(ctx, req.Email); err != nil {
+		logging.FromContext(ctx).WithError(err).Error("failed to reset failed logins")
+	}
+	// A second factor, or a role that requires one, turns the login into a
+	// challenge; the tokens are issued once it is passed
+	challenge, err := h.authService.StartMFAChallenge(c.Request.Context(), userId, string(role))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create refresh token"})
+		_ = c.Error(fmt.Errorf("start MFA challenge: %w", err))
+		return
+	}
+	if challenge != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required":        true,
+			"mfa_token":           challenge.Token,
+			"enrollment_required": challenge.Enroll,
+			"expires_at":          challenge.ExpiresAt,
+		})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"access_token": token, "refresh_token": refresh})
 
+	access, refresh, err := h.startSession(c, userId, string(role))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"access_token": access, "refresh_token": refresh})
+}
+
+// startSession issues the tokens of a new session once the user has
+// authenticated.
+func (h *AuthHandler) startSession(c *gin.Context, userID, role string) (access, refresh string, err error) {
+	// create refresh token, which starts the session
+	refresh, sessionID, err := h.authService.CreateRefreshToken(c.Request.Context(), userID, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		return "", "", fmt.Errorf("create refresh token: %w", err)
+	}
+	// include user's role in the access token so middleware can derive role
+	access, err = auth.NewJWTService(h.jwtCfg).CreateSessionAccessToken(userID, role, sessionID, time.Now())
+	if err != nil {
+		return "", "", fmt.Errorf("create access token: %w", err)
+	}
+	return access, refresh, nil
 }
 
 // Logout revokes the provided refresh token. Clients should call this when logging out.
+// When the request also carries the session's access token as a bearer token,
+// that token is revoked as well.
 func (h *AuthHandler) Logout(c *gin.Context) {
 	var req authModels.LogoutRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(apperr.FromBinding(err))
 		return
 	}
 
-	if err := h.authService.RevokeRefreshToken(req.RefreshToken); err != nil {
-		if err == auth.ErrInvalidRefreshToken {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"}) //TODO:  treat as success to avoid token fishing?
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke token"})
+	// An unknown token is reported as auth.ErrInvalidRefreshToken (401)
+	// TODO: treat as success to avoid token fishing?
+	if err := h.authService.RevokeRefreshToken(c.Request.Context(), req.RefreshToken); err != nil {
+		_ = c.Error(fmt.Errorf("revoke refresh token: %w", err))
 		return
 	}
 
+	// The access token is optional, and an invalid or expired one needs no
+	// revoking
+	if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		if claims, err := h.authService.ParseToken(header[7:]); err == nil {
+			if err := h.denylist.RevokeToken(c.Request.Context(), claims); err != nil {
+				_ = c.Error(fmt.Errorf("revoke access token: %w", err))
+				return
+			}
+		}
+	}
+
 	c.Status(http.StatusOK)
 }
 
@@ -76,24 +154,17 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	var req authModels.RefreshRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(apperr.FromBinding(err))
 		return
 	}
 
 	ip := c.ClientIP()
 	ua := c.Request.UserAgent()
-	access, newRefresh, err := h.authService.RefreshTokens(req.RefreshToken, ip, ua)
+	access, newRefresh, err := h.authService.RefreshTokens(c.Request.Context(), req.RefreshToken, ip, ua)
 	if err != nil {
-		if err == auth.ErrRefreshTokenReuse {
-			// revoke-all already performed by repo; return 401
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token reuse detected"})
-			return
-		}
-		if err == auth.ErrInvalidRefreshToken {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh tokens"})
+		// auth.ErrRefreshTokenReuse and auth.ErrInvalidRefreshToken are 401s;
+		// on reuse the repo has already revoked every token of the user
+		_ = c.Error(fmt.Errorf("refresh tokens: %w", err))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"access_token": access, "refresh_token": newRefresh})
@@ -104,7 +175,7 @@ func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
 	// Expect Authorization: Bearer <token>
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing authorization header"})
+		_ = c.Error(auth.ErrMissingToken)
 		return
 	}
 	// Basic parsing
@@ -112,22 +183,27 @@ func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
 	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
 		token = authHeader[7:]
 	} else {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header"})
+		_ = c.Error(auth.ErrMissingToken)
 		return
 	}
 
 	claims, err := h.authService.ParseToken(token)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		_ = c.Error(auth.ErrInvalidToken.Wrap(err))
 		return
 	}
 
 	// Lookup user view
-	userView, err := h.userService.GetUserViewByID(claims.UserId)
+	userView, err := h.userService.GetUserViewByID(c.Request.Context(), claims.UserId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user"})
+		_ = c.Error(fmt.Errorf("get current user: %w", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"user": userView})
+	// Permissions of the current role, which the token may predate
+	permissions := h.policy.Permissions(userView.Role)
+	if permissions == nil {
+		permissions = []rbac.Permission{}
+	}
+	c.JSON(http.StatusOK, gin.H{"user": userView, "permissions": permissions})
 }
@@ -0,0 +1,240 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"gorm.io/gorm"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/auth"
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/events"
+	"egaldeutsch-be/internal/mail"
+	"egaldeutsch-be/internal/testsupport"
+	authmodule "egaldeutsch-be/modules/auth"
+	"egaldeutsch-be/modules/user"
+	"egaldeutsch-be/pkg/models"
+)
+
+// mailbox records the emails sent by the module.
+type mailbox struct {
+	mu   sync.Mutex
+	sent []*mail.Message
+}
+
+func (m *mailbox) Send(ctx context.Context, msg *mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// wait returns the n-th email, waiting for the event bus to send it.
+func (m *mailbox) wait(t *testing.T, n int) *mail.Message {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		m.mu.Lock()
+		if len(m.sent) >= n {
+			msg := m.sent[n-1]
+			m.mu.Unlock()
+			return msg
+		}
+		got := len(m.sent)
+		m.mu.Unlock()
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d emails, got %d", n, got)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+var tokenParam = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+// token waits for the n-th email and returns the token of the link in it.
+func (m *mailbox) token(t *testing.T, n int) string {
+	t.Helper()
+
+	msg := m.wait(t, n)
+	match := tokenParam.FindStringSubmatch(msg.Text)
+	if match == nil {
+		t.Fatalf("no link with a token in email:\n%s", msg.Text)
+	}
+	return match[1]
+}
+
+// testEnv is the auth module, with the user module it requires, behind a
+// router. It is backed by SQLite and an in-memory Redis, delivers domain
+// events as the server does and records the emails it sends.
+type testEnv struct {
+	*testsupport.Client
+	db    *gorm.DB
+	redis *miniredis.Miniredis
+	users *user.Module
+	mail  *mailbox
+}
+
+// newTestEnv starts a test environment. The optional configure functions
+// adjust the configuration returned by testsupport.Config.
+func newTestEnv(t *testing.T, configure ...func(*config.Config)) *testEnv {
+	t.Helper()
+
+	cfg := testsupport.Config()
+	for _, fn := range configure {
+		fn(cfg)
+	}
+
+	db := testsupport.Database(t)
+	mr, rc := testsupport.Redis(t)
+	policy := testsupport.Policy(t, cfg)
+	box := &mailbox{}
+	mailer, err := mail.NewSenderWithMailer(cfg.Mail, box)
+	if err != nil {
+		t.Fatalf("mailer: %v", err)
+	}
+
+	denylist := auth.NewDenylist(rc, time.Duration(cfg.Jwt.ExpirationHours)*time.Hour)
+	throttle := auth.NewLoginThrottle(rc, cfg.Lockout)
+	authRepo := authmodule.NewRepository(db)
+	apiKeys := auth.NewAPIKeys(authRepo)
+	users := user.NewModule(db, cfg.Jwt, cfg.Password, nil, denylist, throttle, apiKeys, policy)
+	authService := auth.NewService(cfg.Jwt, cfg.Mfa, cfg.APIKeys, authRepo, denylist)
+	authMod := authmodule.NewModule(authService, authRepo, users.Service, cfg.Jwt, denylist, policy, throttle, mailer, cfg.EmailVerification)
+	testsupport.Migrate(t, db, append(users.GetModelsForMigration(), authMod.GetModelsForMigration()...)...)
+
+	bus := events.NewBus(db, cfg.Events)
+	authMod.Subscribe(bus)
+	bus.Start()
+	t.Cleanup(func() { bus.Stop(context.Background()) })
+
+	router := testsupport.Router(users.RegisterRoutes, authMod.RegisterRoutes)
+	return &testEnv{
+		Client: testsupport.NewClient(router),
+		db:     db,
+		redis:  mr,
+		users:  users,
+		mail:   box,
+	}
+}
+
+type tokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// signUp creates a user with the password secret123, gives them the role
+// unless it is learner, and logs in as that user.
+func (e *testEnv) signUp(t *testing.T, email string, role models.UserRole) tokenPair {
+	t.Helper()
+
+	body := map[string]string{"name": "Test User", "email": email, "password": "secret123"}
+	if status := e.Do(t, http.MethodPost, "/api/v1/users", "", body, nil); status != http.StatusCreated {
+		t.Fatalf("create user: expected 201, got %d", status)
+	}
+	if role != models.UserRoleLearner {
+		if err := e.users.SetRole(context.Background(), email, role); err != nil {
+			t.Fatalf("set role of %s: %v", email, err)
+		}
+	}
+	return e.login(t, email, "secret123")
+}
+
+// login logs in with a password and expects tokens.
+func (e *testEnv) login(t *testing.T, email, password string) tokenPair {
+	t.Helper()
+
+	var tokens tokenPair
+	body := map[string]string{"email": email, "password": password}
+	if status := e.Do(t, http.MethodPost, "/api/v1/auth/login", "", body, &tokens); status != http.StatusOK {
+		t.Fatalf("login: expected 200, got %d", status)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		t.Fatalf("login: expected access and refresh token, got %+v", tokens)
+	}
+	return tokens
+}
+
+// userID returns the ID of the user with the given email address.
+func (e *testEnv) userID(t *testing.T, email string) string {
+	t.Helper()
+
+	u, err := e.users.Service.GetByEmail(context.Background(), email)
+	if err != nil {
+		t.Fatalf("find user %s: %v", email, err)
+	}
+	return u.ID.String()
+}
+
+func TestLoginWithWrongPassword(t *testing.T) {
+	env := newTestEnv(t)
+	env.signUp(t, "learner@example.com", models.UserRoleLearner)
+
+	var problem apperr.Problem
+	login := map[string]string{"email": "learner@example.com", "password": "wrong-password"}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/login", "", login, &problem); status != http.StatusUnauthorized {
+		t.Fatalf("wrong password: expected 401, got %d", status)
+	}
+	if problem.Code != "invalid_credentials" || problem.RequestID == "" {
+		t.Fatalf("wrong password: unexpected problem %+v", problem)
+	}
+}
+
+func TestAccessTokenRevocation(t *testing.T) {
+	env := newTestEnv(t)
+	first := env.signUp(t, "learner@example.com", models.UserRoleLearner)
+
+	// Logging out with the access token revokes it along with the refresh token
+	var problem apperr.Problem
+	logout := map[string]string{"refresh_token": first.RefreshToken}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/logout", first.AccessToken, logout, nil); status != http.StatusOK {
+		t.Fatalf("logout: expected 200, got %d", status)
+	}
+	if status := env.Do(t, http.MethodGet, "/api/v1/auth/me", first.AccessToken, nil, &problem); status != http.StatusUnauthorized || problem.Code != "token_revoked" {
+		t.Fatalf("me after logout: expected 401 token_revoked, got %d %+v", status, problem)
+	}
+
+	// A role change revokes every token issued before it. Tokens issued in
+	// the millisecond of the change stay valid, so let it pass first.
+	second := env.login(t, "learner@example.com", "secret123")
+	time.Sleep(2 * time.Millisecond)
+	if err := env.users.SetRole(context.Background(), "learner@example.com", models.UserRoleAdmin); err != nil {
+		t.Fatalf("change role: %v", err)
+	}
+	problem = apperr.Problem{}
+	if status := env.Do(t, http.MethodGet, "/api/v1/auth/me", second.AccessToken, nil, &problem); status != http.StatusUnauthorized || problem.Code != "token_revoked" {
+		t.Fatalf("me after role change: expected 401 token_revoked, got %d %+v", status, problem)
+	}
+
+	// The refresh token still works and yields a token with the new role
+	var rotated tokenPair
+	refresh := map[string]string{"refresh_token": second.RefreshToken}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/refresh", "", refresh, &rotated); status != http.StatusOK {
+		t.Fatalf("refresh: expected 200, got %d", status)
+	}
+	if status := env.Do(t, http.MethodGet, "/api/v1/admin/api-keys", rotated.AccessToken, nil, nil); status != http.StatusOK {
+		t.Fatalf("admin route as the new admin: expected 200, got %d", status)
+	}
+}
+
+func TestMeListsInheritedPermissions(t *testing.T) {
+	env := newTestEnv(t)
+	moderator := env.signUp(t, "moderator@example.com", models.UserRoleModerator)
+
+	var me struct {
+		Permissions []string `json:"permissions"`
+	}
+	if status := env.Do(t, http.MethodGet, "/api/v1/auth/me", moderator.AccessToken, nil, &me); status != http.StatusOK {
+		t.Fatalf("me: expected 200, got %d", status)
+	}
+	if strings.Join(me.Permissions, ",") != "chat:moderate,chat:write,users:read" {
+		t.Fatalf("expected the moderator to inherit chat:write, got %v", me.Permissions)
+	}
+}
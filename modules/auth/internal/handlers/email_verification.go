@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/auth"
+	"egaldeutsch-be/internal/mail"
+	"egaldeutsch-be/internal/middleware"
+	authModels "egaldeutsch-be/modules/auth/internal/models"
+	sharedmodels "egaldeutsch-be/pkg/models"
+)
+
+// verifyEmailPath is the page of the web app that verification links open;
+// it posts the token to /auth/verify-email.
+const verifyEmailPath = "/verify-email"
+
+// VerifyEmail handles POST /auth/verify-email with the token of a
+// verification link.
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req authModels.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	// auth.ErrInvalidVerificationToken is a 401
+	userID, email, err := h.authService.VerifyEmailToken(c.Request.Context(), req.Token)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("verify email token: %w", err))
+		return
+	}
+	if err := h.userService.MarkEmailVerified(c.Request.Context(), userID, email); err != nil {
+		_ = c.Error(fmt.Errorf("mark email verified: %w", err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ResendVerificationEmail handles POST /auth/verify-email/resend. Links sent
+// before stay valid until they expire.
+func (h *AuthHandler) ResendVerificationEmail(c *gin.Context) {
+	userID, _ := middleware.GetUserIDFromContext(c)
+	user, err := h.userService.GetUserViewByID(c.Request.Context(), userID)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("get user: %w", err))
+		return
+	}
+	if user.EmailVerified {
+		_ = c.Error(auth.ErrEmailAlreadyVerified)
+		return
+	}
+
+	if err := h.SendVerificationEmail(c.Request.Context(), user); err != nil {
+		_ = c.Error(fmt.Errorf("send verification email: %w", err))
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+// SendVerificationEmail emails the user a link that verifies their current
+// email address.
+func (h *AuthHandler) SendVerificationEmail(ctx context.Context, user *sharedmodels.UserView) error {
+	ttl := time.Duration(h.verifyCfg.TokenTTLHours) * time.Hour
+	token, err := h.authService.CreateEmailVerification(ctx, user.ID, user.Email, ttl)
+	if err != nil {
+		return err
+	}
+	return h.mailer.Send(ctx, recipient(user), mail.TemplateVerifyEmail, map[string]any{
+		"Link":       h.mailer.Link(verifyEmailPath, token),
+		"ValidHours": h.verifyCfg.TokenTTLHours,
+	})
+}
+
+// recipient addresses an email to user.
+func recipient(user *sharedmodels.UserView) mail.Recipient {
+	return mail.Recipient{Email: user.Email, Name: user.Name, Language: user.Language}
+}
@@ -0,0 +1,143 @@
+package handlers_test
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/pkg/models"
+)
+
+func TestEmailVerification(t *testing.T) {
+	env := newTestEnv(t)
+	tokens := env.signUp(t, "learner@example.com", models.UserRoleLearner)
+
+	// Signing up sends the first email; a resent link works as well
+	first := env.mail.token(t, 1)
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/verify-email/resend", tokens.AccessToken, nil, nil); status != http.StatusAccepted {
+		t.Fatalf("resend: expected 202, got %d", status)
+	}
+	second := env.mail.token(t, 2)
+	if first == second {
+		t.Fatalf("resend reused the token")
+	}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/verify-email", "", map[string]string{"token": second}, nil); status != http.StatusNoContent {
+		t.Fatalf("verify: expected 204, got %d", status)
+	}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/verify-email", "", map[string]string{"token": second}, nil); status != http.StatusUnauthorized {
+		t.Fatalf("reuse a token: expected 401, got %d", status)
+	}
+
+	var me struct {
+		User struct {
+			EmailVerified bool `json:"email_verified"`
+		} `json:"user"`
+	}
+	env.Do(t, http.MethodGet, "/api/v1/auth/me", tokens.AccessToken, nil, &me)
+	if !me.User.EmailVerified {
+		t.Fatalf("expected /auth/me to report the address as verified")
+	}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/verify-email/resend", tokens.AccessToken, nil, nil); status != http.StatusConflict {
+		t.Fatalf("resend when verified: expected 409, got %d", status)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	env := newTestEnv(t)
+	tokens := env.signUp(t, "moderator@example.com", models.UserRoleModerator)
+	env.mail.wait(t, 1)
+
+	// An API key, which the password reset below revokes
+	var apiKey struct {
+		Key string `json:"key"`
+	}
+	create := map[string]any{"name": "importer", "scopes": []string{"users:read"}}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/api-keys", tokens.AccessToken, create, &apiKey); status != http.StatusCreated {
+		t.Fatalf("create API key: expected 201, got %d", status)
+	}
+	if status := env.Do(t, http.MethodGet, "/api/v1/users", apiKey.Key, nil, nil); status != http.StatusOK {
+		t.Fatalf("API key before the reset: expected 200, got %d", status)
+	}
+
+	forgot := map[string]string{"email": "moderator@example.com"}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/forgot-password", "", forgot, nil); status != http.StatusAccepted {
+		t.Fatalf("forgot password: expected 202, got %d", status)
+	}
+	// A password the policy rejects leaves the link usable
+	token := env.mail.token(t, 2)
+	weak := map[string]string{"token": token, "password": "moderator-2024", "password_confirm": "moderator-2024"}
+	var problem apperr.Problem
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/reset-password", "", weak, &problem); status != http.StatusBadRequest || len(problem.Errors) != 1 {
+		t.Fatalf("reset to a personal password: expected 400 with a field error, got %d %+v", status, problem)
+	}
+	reset := map[string]string{"token": token, "password": "secret456", "password_confirm": "secret456"}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/reset-password", "", reset, nil); status != http.StatusOK {
+		t.Fatalf("reset password: expected 200, got %d", status)
+	}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/reset-password", "", reset, nil); status != http.StatusUnauthorized {
+		t.Fatalf("reuse the reset link: expected 401, got %d", status)
+	}
+
+	// The sessions and API keys end with the reset, not when its event is
+	// delivered
+	refresh := map[string]string{"refresh_token": tokens.RefreshToken}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/refresh", "", refresh, nil); status != http.StatusUnauthorized {
+		t.Fatalf("refresh after the reset: expected 401, got %d", status)
+	}
+	if status := env.Do(t, http.MethodGet, "/api/v1/users", apiKey.Key, nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("API key after the reset: expected 401, got %d", status)
+	}
+	env.login(t, "moderator@example.com", "secret456")
+}
+
+func TestPasswordResetLinkSetsOnePassword(t *testing.T) {
+	env := newTestEnv(t)
+	env.signUp(t, "learner@example.com", models.UserRoleLearner)
+	env.mail.wait(t, 1)
+
+	forgot := map[string]string{"email": "learner@example.com"}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/forgot-password", "", forgot, nil); status != http.StatusAccepted {
+		t.Fatalf("forgot password: expected 202, got %d", status)
+	}
+	token := env.mail.token(t, 2)
+
+	// Several requests race with the same link, each for its own password
+	passwords := []string{"Erstes-Passwort-1", "Zweites-Passwort-2", "Drittes-Passwort-3", "Viertes-Passwort-4"}
+	codes := make([]int, len(passwords))
+	var wg sync.WaitGroup
+	for i, password := range passwords {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reset := map[string]string{"token": token, "password": password, "password_confirm": password}
+			codes[i] = env.Request(t, http.MethodPost, "/api/v1/auth/reset-password", "", reset).Code
+		}()
+	}
+	wg.Wait()
+
+	winner := ""
+	for i, code := range codes {
+		switch code {
+		case http.StatusOK:
+			if winner != "" {
+				t.Fatalf("expected the link to set one password, got %v", codes)
+			}
+			winner = passwords[i]
+		case http.StatusUnauthorized:
+		default:
+			t.Fatalf("reset %d: expected 200 or 401, got %d", i+1, code)
+		}
+	}
+	if winner == "" {
+		t.Fatalf("expected one reset to succeed, got %v", codes)
+	}
+	env.login(t, "learner@example.com", winner)
+
+	// Replaying the link later changes nothing
+	replay := map[string]string{"token": token, "password": "Spaeteres-Passwort-5", "password_confirm": "Spaeteres-Passwort-5"}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/reset-password", "", replay, nil); status != http.StatusUnauthorized {
+		t.Fatalf("replay the reset link: expected 401, got %d", status)
+	}
+	env.login(t, "learner@example.com", winner)
+}
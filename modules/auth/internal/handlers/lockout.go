@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/logging"
+	"egaldeutsch-be/internal/mail"
+	authModels "egaldeutsch-be/modules/auth/internal/models"
+	sharedmodels "egaldeutsch-be/pkg/models"
+)
+
+// forgotPasswordPath is the page of the web app where users request a
+// password reset link.
+const forgotPasswordPath = "/forgot-password"
+
+// recordFailedLogin counts a failed login towards the throttle and records it
+// for the audit log. Only the first failure of an account from each IP in a
+// lockout window is recorded, with the account's failures so far, and the
+// lock if there is one: guessing from one IP cannot grow the audit log by a
+// row per attempt, while every IP taking part in credential stuffing shows
+// up with its user agent. Without Redis nothing is counted and every failure
+// is recorded. Errors are only logged, so the client gets the same answer
+// either way.
+func (h *AuthHandler) recordFailedLogin(ctx context.Context, email string, ip string) {
+	log := logging.FromContext(ctx).WithField("email", logging.RedactEmail(email))
+	failure, err := h.throttle.Fail(ctx, email, ip)
+	if err != nil {
+		log.WithError(err).Error("failed to count failed login")
+	}
+	if failure.SourceFailures <= 1 {
+		if err := h.authService.RecordFailedLogin(ctx, email, failure.Failures); err != nil {
+			log.WithError(err).Error("failed to record failed login")
+		}
+	}
+	if failure.LockedUntil.IsZero() {
+		return
+	}
+	log.WithField("locked_until", failure.LockedUntil).Warn("account locked after failed logins")
+	if err := h.authService.RecordAccountLocked(ctx, email, failure.LockedUntil); err != nil {
+		log.WithError(err).Error("failed to record account lock")
+	}
+}
+
+// UnlockUser handles DELETE /admin/users/:id/lockout. It lifts a lock from
+// failed logins and forgets the user's failures; blocked IPs stay blocked.
+func (h *AuthHandler) UnlockUser(c *gin.Context) {
+	var params authModels.UserIDParam
+	if err := c.ShouldBindUri(&params); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+	user, err := h.userService.GetUserViewByID(c.Request.Context(), params.ID)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("get user: %w", err))
+		return
+	}
+
+	if err := h.throttle.Unlock(c.Request.Context(), user.Email); err != nil {
+		_ = c.Error(fmt.Errorf("unlock account: %w", err))
+		return
+	}
+	if err := h.authService.RecordAccountUnlocked(c.Request.Context(), user.ID, user.Email); err != nil {
+		_ = c.Error(fmt.Errorf("record account unlock: %w", err))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SendAccountLockedEmail tells a user that failed logins locked their account
+// for lockout, suggesting a new password in case it was not them.
+func (h *AuthHandler) SendAccountLockedEmail(ctx context.Context, user *sharedmodels.UserView, lockout time.Duration) error {
+	return h.mailer.Send(ctx, recipient(user), mail.TemplateAccountLocked, map[string]any{
+		"Link":          h.mailer.URL(forgotPasswordPath),
+		"LockedMinutes": int(lockout / time.Minute),
+	})
+}
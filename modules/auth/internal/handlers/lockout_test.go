@@ -0,0 +1,118 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/events"
+	"egaldeutsch-be/pkg/models"
+)
+
+func TestLoginLockout(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.signUp(t, "admin@example.com", models.UserRoleAdmin)
+	learner := env.signUp(t, "learner@example.com", models.UserRoleLearner)
+
+	// login returns the status, problem code and Retry-After header
+	login := func(email, password string) (int, string, string) {
+		t.Helper()
+		w := env.Request(t, http.MethodPost, "/api/v1/auth/login", "", map[string]string{"email": email, "password": password})
+		var problem apperr.Problem
+		if w.Code != http.StatusOK {
+			json.Unmarshal(w.Body.Bytes(), &problem)
+		}
+		return w.Code, problem.Code, w.Header().Get("Retry-After")
+	}
+
+	// A registered and an unknown email are throttled alike: three free
+	// failures, a wait after the fourth and a lock at the fifth
+	for _, email := range []string{"learner@example.com", "nobody@example.com"} {
+		for i := 1; i <= 4; i++ {
+			if status, code, _ := login(email, "wrong-password"); status != http.StatusUnauthorized || code != "invalid_credentials" {
+				t.Fatalf("%s: failure %d: expected 401 invalid_credentials, got %d %s", email, i, status, code)
+			}
+		}
+		if status, code, retry := login(email, "secret123"); status != http.StatusTooManyRequests || code != "too_many_login_attempts" || retry != "1" {
+			t.Fatalf("%s: expected 429 with Retry-After 1, got %d %s %q", email, status, code, retry)
+		}
+		env.redis.FastForward(time.Second)
+		if status, _, _ := login(email, "wrong-password"); status != http.StatusUnauthorized {
+			t.Fatalf("%s: failure 5: expected 401, got %d", email, status)
+		}
+		if status, _, retry := login(email, "secret123"); status != http.StatusTooManyRequests || retry != "900" {
+			t.Fatalf("%s: expected a 15 minute lock, got %d %q", email, status, retry)
+		}
+	}
+
+	// The owner of the registered account is told, after the two emails
+	// asking to verify the addresses
+	notified := false
+	for n := 1; n <= 3; n++ {
+		msg := env.mail.wait(t, n)
+		notified = notified || (msg.To == "learner@example.com" && strings.Contains(msg.Text, "/forgot-password"))
+	}
+	if !notified {
+		t.Fatalf("no email about the locked account")
+	}
+
+	// Other accounts can still log in from the same IP
+	if status, _, _ := login("admin@example.com", "secret123"); status != http.StatusOK {
+		t.Fatalf("admin login: expected 200, got %d", status)
+	}
+
+	// An admin lifts the lock
+	unlock := "/api/v1/admin/users/" + env.userID(t, "learner@example.com") + "/lockout"
+	if status := env.Do(t, http.MethodDelete, unlock, learner.AccessToken, nil, nil); status != http.StatusForbidden {
+		t.Fatalf("unlock as learner: expected 403, got %d", status)
+	}
+	if status := env.Do(t, http.MethodDelete, unlock, admin.AccessToken, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("unlock: expected 204, got %d", status)
+	}
+	if status, _, _ := login("learner@example.com", "secret123"); status != http.StatusOK {
+		t.Fatalf("login after unlock: expected 200, got %d", status)
+	}
+}
+
+func TestFailedLoginsAreAuditedPerIP(t *testing.T) {
+	env := newTestEnv(t)
+	env.signUp(t, "learner@example.com", models.UserRoleLearner)
+
+	// fail sends a wrong password for the learner from ip
+	fail := func(ip string) {
+		t.Helper()
+		req := env.NewRequest(t, http.MethodPost, "/api/v1/auth/login", "", map[string]string{"email": "learner@example.com", "password": "wrong-password"})
+		req.RemoteAddr = ip + ":40000"
+		req.Header.Set("User-Agent", "stuffer/"+ip)
+		if w := env.Send(req); w.Code != http.StatusUnauthorized {
+			t.Fatalf("failed login from %s: expected 401, got %d", ip, w.Code)
+		}
+	}
+	fail("198.51.100.1")
+	fail("198.51.100.1")
+	fail("198.51.100.2")
+
+	// One event per IP, with the user agent and the failures so far
+	var recorded []events.Event
+	if err := env.db.Where("type = ?", events.AuthLoginFailed).Order("occurred_at").Find(&recorded).Error; err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(recorded) != 2 {
+		t.Fatalf("expected one failed login per IP, got %d", len(recorded))
+	}
+	for i, want := range []struct {
+		ip       string
+		failures int
+	}{{"198.51.100.1", 1}, {"198.51.100.2", 3}} {
+		var p events.AuthLoginFailedPayload
+		if err := recorded[i].Decode(&p); err != nil {
+			t.Fatalf("decode payload: %v", err)
+		}
+		if recorded[i].IP != want.ip || recorded[i].UserAgent != "stuffer/"+want.ip || p.Failures != want.failures {
+			t.Fatalf("event %d: expected %s with %d failures, got %s %q with %d", i+1, want.ip, want.failures, recorded[i].IP, recorded[i].UserAgent, p.Failures)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/auth"
+	"egaldeutsch-be/internal/middleware"
+	authModels "egaldeutsch-be/modules/auth/internal/models"
+)
+
+// VerifyMFA handles POST /auth/mfa/verify: the second step of a login. It
+// issues the session's tokens once the challenge is passed; a login that
+// required enrolling also returns the new recovery codes.
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req authModels.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	// auth.ErrInvalidMFAToken and auth.ErrInvalidMFACode are 401s
+	challenge, recoveryCodes, err := h.authService.CompleteMFAChallenge(c.Request.Context(), req.MFAToken, req.Code)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("complete MFA challenge: %w", err))
+		return
+	}
+
+	access, refresh, err := h.startSession(c, challenge.UserID, challenge.Role)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	body := gin.H{"access_token": access, "refresh_token": refresh}
+	if recoveryCodes != nil {
+		body["recovery_codes"] = recoveryCodes
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// EnrollMFA handles POST /auth/mfa/enroll: the enrollment of a user whose
+// role requires a second factor, started with the token of their login.
+func (h *AuthHandler) EnrollMFA(c *gin.Context) {
+	var req authModels.MFAEnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	challenge, err := h.authService.GetMFAChallenge(c.Request.Context(), req.MFAToken)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("get MFA challenge: %w", err))
+		return
+	}
+	if !challenge.Enroll {
+		_ = c.Error(auth.ErrMFAAlreadyEnabled)
+		return
+	}
+	h.beginTOTPEnrollment(c, challenge.UserID)
+}
+
+// GetMFAStatus handles GET /auth/mfa.
+func (h *AuthHandler) GetMFAStatus(c *gin.Context) {
+	userID, _ := middleware.GetUserIDFromContext(c)
+	role := middleware.GetUserRoleFromContext(c)
+	status, err := h.authService.GetMFAStatus(c.Request.Context(), userID, string(role))
+	if err != nil {
+		_ = c.Error(fmt.Errorf("get MFA status: %w", err))
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// BeginTOTPEnrollment handles POST /auth/mfa/totp. Calling it again before
+// confirming replaces the secret.
+func (h *AuthHandler) BeginTOTPEnrollment(c *gin.Context) {
+	userID, _ := middleware.GetUserIDFromContext(c)
+	h.beginTOTPEnrollment(c, userID)
+}
+
+func (h *AuthHandler) beginTOTPEnrollment(c *gin.Context, userID string) {
+	// Authenticator apps list the account by email
+	user, err := h.userService.GetUserViewByID(c.Request.Context(), userID)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("get user: %w", err))
+		return
+	}
+	setup, err := h.authService.BeginTOTPEnrollment(c.Request.Context(), userID, user.Email)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("begin TOTP enrollment: %w", err))
+		return
+	}
+	c.JSON(http.StatusOK, setup)
+}
+
+// ConfirmTOTPEnrollment handles POST /auth/mfa/totp/confirm. The recovery
+// codes are only shown in its response.
+func (h *AuthHandler) ConfirmTOTPEnrollment(c *gin.Context) {
+	var req authModels.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	userID, _ := middleware.GetUserIDFromContext(c)
+	codes, err := h.authService.ConfirmTOTPEnrollment(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("confirm TOTP enrollment: %w", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodes handles POST /auth/mfa/recovery-codes; the codes
+// issued before stop working.
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req authModels.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	userID, _ := middleware.GetUserIDFromContext(c)
+	codes, err := h.authService.RegenerateRecoveryCodes(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("regenerate recovery codes: %w", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableMFA handles POST /auth/mfa/disable.
+func (h *AuthHandler) DisableMFA(c *gin.Context) {
+	var req authModels.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	userID, _ := middleware.GetUserIDFromContext(c)
+	role := middleware.GetUserRoleFromContext(c)
+	if err := h.authService.DisableMFA(c.Request.Context(), userID, string(role), req.Code); err != nil {
+		// auth.ErrMFARequired is a 403
+		_ = c.Error(fmt.Errorf("disable MFA: %w", err))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ResetUserMFA handles DELETE /admin/users/:id/mfa, for a user who lost
+// their authenticator and recovery codes. Their next login starts over,
+// enrolling again if their role requires it.
+func (h *AuthHandler) ResetUserMFA(c *gin.Context) {
+	var params authModels.UserIDParam
+	if err := c.ShouldBindUri(&params); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+	if _, err := h.userService.GetUserViewByID(c.Request.Context(), params.ID); err != nil {
+		_ = c.Error(fmt.Errorf("get user: %w", err))
+		return
+	}
+
+	if err := h.authService.ResetMFA(c.Request.Context(), params.ID); err != nil {
+		_ = c.Error(fmt.Errorf("reset MFA: %w", err))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetMFAPolicy handles GET /admin/mfa/policy.
+func (h *AuthHandler) GetMFAPolicy(c *gin.Context) {
+	roles, err := h.authService.MFARequiredRoles(c.Request.Context())
+	if err != nil {
+		_ = c.Error(fmt.Errorf("get MFA policy: %w", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"required_roles": roles})
+}
+
+// SetMFAPolicy handles PUT /admin/mfa/policy. Users of a role that becomes
+// required enroll at their next login; their current sessions stay valid.
+func (h *AuthHandler) SetMFAPolicy(c *gin.Context) {
+	var req authModels.MFAPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	if err := h.authService.SetMFARequiredRoles(c.Request.Context(), req.RequiredRoles); err != nil {
+		_ = c.Error(fmt.Errorf("set MFA policy: %w", err))
+		return
+	}
+	h.GetMFAPolicy(c)
+}
@@ -0,0 +1,128 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/auth"
+	"egaldeutsch-be/pkg/models"
+)
+
+func TestTwoFactorLogin(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.signUp(t, "admin@example.com", models.UserRoleAdmin)
+	learner := env.signUp(t, "learner@example.com", models.UserRoleLearner)
+
+	var setup struct {
+		Secret string `json:"secret"`
+		URI    string `json:"otpauth_uri"`
+	}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/mfa/totp", learner.AccessToken, nil, &setup); status != http.StatusOK {
+		t.Fatalf("begin enrollment: expected 200, got %d", status)
+	}
+	code := func(secret string, at time.Time) map[string]string {
+		c, err := auth.TOTPCode(secret, at)
+		if err != nil {
+			t.Fatalf("TOTP code: %v", err)
+		}
+		return map[string]string{"code": c}
+	}
+	var enrolled struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/mfa/totp/confirm", learner.AccessToken, code(setup.Secret, time.Now()), &enrolled); status != http.StatusOK {
+		t.Fatalf("confirm enrollment: expected 200, got %d", status)
+	}
+	if len(enrolled.RecoveryCodes) != auth.RecoveryCodeCount {
+		t.Fatalf("expected %d recovery codes, got %+v", auth.RecoveryCodeCount, enrolled)
+	}
+
+	type challenge struct {
+		tokenPair
+		MFARequired        bool   `json:"mfa_required"`
+		MFAToken           string `json:"mfa_token"`
+		EnrollmentRequired bool   `json:"enrollment_required"`
+	}
+	login := func(email string) challenge {
+		t.Helper()
+		var c challenge
+		body := map[string]string{"email": email, "password": "secret123"}
+		if status := env.Do(t, http.MethodPost, "/api/v1/auth/login", "", body, &c); status != http.StatusOK {
+			t.Fatalf("login: expected 200, got %d", status)
+		}
+		return c
+	}
+
+	// The password alone no longer yields tokens
+	c := login("learner@example.com")
+	if !c.MFARequired || c.MFAToken == "" || c.AccessToken != "" || c.EnrollmentRequired {
+		t.Fatalf("expected an MFA challenge, got %+v", c)
+	}
+	var problem apperr.Problem
+	wrong := map[string]string{"mfa_token": c.MFAToken, "code": "wrong-code"}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/mfa/verify", "", wrong, &problem); status != http.StatusUnauthorized || problem.Code != "invalid_mfa_code" {
+		t.Fatalf("wrong code: expected 401 invalid_mfa_code, got %d %+v", status, problem)
+	}
+	// The code used to confirm is spent; the next period's code is accepted
+	verify := code(setup.Secret, time.Now().Add(30*time.Second))
+	verify["mfa_token"] = c.MFAToken
+	var tokens tokenPair
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/mfa/verify", "", verify, &tokens); status != http.StatusOK || tokens.AccessToken == "" {
+		t.Fatalf("verify: expected 200 with tokens, got %d %+v", status, tokens)
+	}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/mfa/verify", "", verify, nil); status != http.StatusUnauthorized {
+		t.Fatalf("verify a passed challenge again: expected 401, got %d", status)
+	}
+
+	// A recovery code works once
+	recovery := map[string]string{"mfa_token": login("learner@example.com").MFAToken, "code": enrolled.RecoveryCodes[0]}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/mfa/verify", "", recovery, nil); status != http.StatusOK {
+		t.Fatalf("verify with a recovery code: expected 200, got %d", status)
+	}
+	recovery["mfa_token"] = login("learner@example.com").MFAToken
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/mfa/verify", "", recovery, nil); status != http.StatusUnauthorized {
+		t.Fatalf("reuse a recovery code: expected 401, got %d", status)
+	}
+
+	// Requiring 2FA for admins makes the admin enroll at the next login
+	policy := map[string][]string{"required_roles": {"admin"}}
+	if status := env.Do(t, http.MethodPut, "/api/v1/admin/mfa/policy", admin.AccessToken, policy, nil); status != http.StatusOK {
+		t.Fatalf("set policy: expected 200, got %d", status)
+	}
+	c = login("admin@example.com")
+	if !c.MFARequired || !c.EnrollmentRequired {
+		t.Fatalf("expected an enrollment challenge, got %+v", c)
+	}
+	setup.Secret = ""
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/mfa/enroll", "", map[string]string{"mfa_token": c.MFAToken}, &setup); status != http.StatusOK || setup.Secret == "" {
+		t.Fatalf("enroll: expected 200 with a secret, got %d %+v", status, setup)
+	}
+	verify = code(setup.Secret, time.Now())
+	verify["mfa_token"] = c.MFAToken
+	var adminTokens struct {
+		tokenPair
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/mfa/verify", "", verify, &adminTokens); status != http.StatusOK {
+		t.Fatalf("verify enrollment: expected 200, got %d", status)
+	}
+	if adminTokens.AccessToken == "" || len(adminTokens.RecoveryCodes) != auth.RecoveryCodeCount {
+		t.Fatalf("expected tokens and recovery codes, got %+v", adminTokens)
+	}
+	problem = apperr.Problem{}
+	disable := map[string]string{"code": adminTokens.RecoveryCodes[0]}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/mfa/disable", adminTokens.AccessToken, disable, &problem); status != http.StatusForbidden || problem.Code != "mfa_required" {
+		t.Fatalf("disable required 2FA: expected 403 mfa_required, got %d %+v", status, problem)
+	}
+
+	// An admin resets the learner's 2FA; the password suffices again
+	learnerID := env.userID(t, "learner@example.com")
+	if status := env.Do(t, http.MethodDelete, "/api/v1/admin/users/"+learnerID+"/mfa", adminTokens.AccessToken, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("reset 2FA: expected 204, got %d", status)
+	}
+	if c := login("learner@example.com"); c.MFARequired || c.AccessToken == "" {
+		t.Fatalf("expected tokens after the reset, got %+v", c)
+	}
+}
@@ -1,64 +1,99 @@
 package handlers
 
 import (
-	authModels "egaldeutsch-be/modules/auth/internal/models"
+	"context"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/auth"
+	"egaldeutsch-be/internal/mail"
+	authModels "egaldeutsch-be/modules/auth/internal/models"
+	sharedmodels "egaldeutsch-be/pkg/models"
 )
 
+// resetPasswordPath is the page of the web app that password reset links
+// open; it posts the token and the new password to /auth/reset-password.
+const resetPasswordPath = "/reset-password"
+
 func (h *AuthHandler) ForgotPassword(c *gin.Context) {
 	var req authModels.ForgotPasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(apperr.FromBinding(err))
 		return
 	}
 
-	// Lookup user by email via unified user service
-	userService := h.userService
-	userID, err := userService.GetUserIDByEmail(req.Email)
-	if err == nil {
-		// create reset token
-		token, err := h.authService.CreatePasswordResetForUser(userID)
-		if err != nil {
-			logrus.WithError(err).Error("failed to create password reset token")
-			// still return accepted to avoid enumeration
-			c.Status(http.StatusAccepted)
-			return
-		}
-
-		// log the reset link (replace with Mailer in future)
-		resetLink := "https://your.app/reset-password?token=" + token
-		logrus.WithField("email", req.Email).Infof("password reset link: %s", resetLink)
+	// The email is looked up, and the link created and sent, when the event
+	// is delivered. The request does the same work whether or not the email
+	// belongs to a user, so neither its answer nor its timing tells.
+	if err := h.authService.RequestPasswordReset(c.Request.Context(), req.Email); err != nil {
+		_ = c.Error(fmt.Errorf("request password reset: %w", err))
+		return
 	}
-
-	// Always return accepted to avoid enumeration
 	c.Status(http.StatusAccepted)
 }
 
+// SendPasswordResetEmail emails the user a new password reset link.
+func (h *AuthHandler) SendPasswordResetEmail(ctx context.Context, user *sharedmodels.UserView) error {
+	token, err := h.authService.CreatePasswordResetForUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	return h.mailer.Send(ctx, recipient(user), mail.TemplatePasswordReset, map[string]any{
+		"Link":         h.mailer.Link(resetPasswordPath, token),
+		"ValidMinutes": int(auth.PasswordResetTTL / time.Minute),
+	})
+}
+
 func (h *AuthHandler) ResetPassword(c *gin.Context) {
 	var req authModels.ResetPasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(apperr.FromBinding(err))
 		return
 	}
 
-	userID, err := h.authService.VerifyPasswordResetToken(req.Token)
+	// A rejected password does not cost the user their link: the new
+	// password is checked before the link is consumed
+	ctx := c.Request.Context()
+	userID, err := h.authService.GetPasswordResetUser(ctx, req.Token)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+		// auth.ErrInvalidResetToken is a 401
+		_ = c.Error(fmt.Errorf("get password reset: %w", err))
+		return
+	}
+	if err := h.userService.CheckPassword(ctx, userID, req.Password); err != nil {
+		_ = c.Error(fmt.Errorf("check password: %w", err))
 		return
 	}
 
-	// Update password via unified user service
-	if err := h.userService.UpdatePassword(userID, req.Password); err != nil {
-		logrus.WithError(err).Error("failed to update password")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+	// The link is consumed before the password changes, so of two requests
+	// with the same link only one sets a password
+	userID, err = h.authService.VerifyPasswordResetToken(ctx, req.Token)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("consume password reset token: %w", err))
 		return
 	}
 
-	// Revoke all refresh tokens for this user
-	_ = h.authService.RevokeAllRefreshTokens(userID)
+	// Update password via unified user service, which revokes the user's
+	// access tokens
+	if err := h.userService.CompletePasswordReset(ctx, userID, req.Password); err != nil {
+		_ = c.Error(fmt.Errorf("update password: %w", err))
+		return
+	}
+	// A reset is how a user takes back a compromised account, so every
+	// session and API key ends before the reset succeeds rather than when the
+	// event is delivered
+	if err := h.authService.RevokeAllRefreshTokens(ctx, userID); err != nil {
+		_ = c.Error(fmt.Errorf("revoke sessions: %w", err))
+		return
+	}
+	if err := h.authService.RevokeAllAPIKeys(ctx, userID); err != nil {
+		_ = c.Error(fmt.Errorf("revoke API keys: %w", err))
+		return
+	}
 
 	c.Status(http.StatusOK)
 }
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/middleware"
+	authModels "egaldeutsch-be/modules/auth/internal/models"
+)
+
+// ListSessions handles GET /auth/sessions: the active sessions of the
+// authenticated user, with the one making the request marked as current.
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	userID, _ := middleware.GetUserIDFromContext(c)
+	sessions, err := h.authService.ListSessions(c.Request.Context(), userID, middleware.GetSessionIDFromContext(c))
+	if err != nil {
+		_ = c.Error(fmt.Errorf("list sessions: %w", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": sessions})
+}
+
+// RevokeSession handles DELETE /auth/sessions/:session_id. Revoking the
+// current session logs the user out.
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	var params authModels.SessionIDParam
+	if err := c.ShouldBindUri(&params); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	userID, _ := middleware.GetUserIDFromContext(c)
+	if err := h.authService.RevokeSession(c.Request.Context(), userID, params.SessionID); err != nil {
+		// auth.ErrSessionNotFound is a 404
+		_ = c.Error(fmt.Errorf("revoke session: %w", err))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeOtherSessions handles DELETE /auth/sessions: every session of the
+// authenticated user except the current one.
+func (h *AuthHandler) RevokeOtherSessions(c *gin.Context) {
+	userID, _ := middleware.GetUserIDFromContext(c)
+	n, err := h.authService.RevokeOtherSessions(c.Request.Context(), userID, middleware.GetSessionIDFromContext(c))
+	if err != nil {
+		_ = c.Error(fmt.Errorf("revoke sessions: %w", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"revoked": n})
+}
+
+// ListUserSessions handles GET /admin/users/:id/sessions.
+func (h *AuthHandler) ListUserSessions(c *gin.Context) {
+	var params authModels.UserIDParam
+	if err := c.ShouldBindUri(&params); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+	// Report unknown users rather than an empty list
+	if _, err := h.userService.GetUserViewByID(c.Request.Context(), params.ID); err != nil {
+		_ = c.Error(fmt.Errorf("get user: %w", err))
+		return
+	}
+
+	sessions, err := h.authService.ListSessions(c.Request.Context(), params.ID, middleware.GetSessionIDFromContext(c))
+	if err != nil {
+		_ = c.Error(fmt.Errorf("list sessions: %w", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": sessions})
+}
+
+// RevokeUserSession handles DELETE /admin/users/:id/sessions/:session_id.
+func (h *AuthHandler) RevokeUserSession(c *gin.Context) {
+	var params authModels.UserSessionParams
+	if err := c.ShouldBindUri(&params); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	if err := h.authService.RevokeSession(c.Request.Context(), params.ID, params.SessionID); err != nil {
+		_ = c.Error(fmt.Errorf("revoke session: %w", err))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeUserSessions handles DELETE /admin/users/:id/sessions: every session
+// of the user, except the admin's own when they target themselves.
+func (h *AuthHandler) RevokeUserSessions(c *gin.Context) {
+	var params authModels.UserIDParam
+	if err := c.ShouldBindUri(&params); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+	if _, err := h.userService.GetUserViewByID(c.Request.Context(), params.ID); err != nil {
+		_ = c.Error(fmt.Errorf("get user: %w", err))
+		return
+	}
+
+	n, err := h.authService.RevokeOtherSessions(c.Request.Context(), params.ID, middleware.GetSessionIDFromContext(c))
+	if err != nil {
+		_ = c.Error(fmt.Errorf("revoke sessions: %w", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"revoked": n})
+}
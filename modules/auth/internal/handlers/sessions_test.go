@@ -0,0 +1,93 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"egaldeutsch-be/pkg/models"
+)
+
+func TestSessions(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.signUp(t, "admin@example.com", models.UserRoleAdmin)
+	laptop := env.signUp(t, "learner@example.com", models.UserRoleLearner)
+
+	var phone tokenPair
+	login := map[string]string{"email": "learner@example.com", "password": "secret123"}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/login", "", login, &phone); status != http.StatusOK {
+		t.Fatalf("login: expected 200, got %d", status)
+	}
+
+	type sessionList struct {
+		Items []struct {
+			ID      string `json:"id"`
+			Device  string `json:"device"`
+			Current bool   `json:"current"`
+		} `json:"items"`
+	}
+	var sessions sessionList
+	if status := env.Do(t, http.MethodGet, "/api/v1/auth/sessions", laptop.AccessToken, nil, &sessions); status != http.StatusOK {
+		t.Fatalf("list sessions: expected 200, got %d", status)
+	}
+	if len(sessions.Items) != 2 {
+		t.Fatalf("expected 2 sessions, got %+v", sessions)
+	}
+	var current string
+	for _, s := range sessions.Items {
+		if s.Current {
+			current = s.ID
+		}
+	}
+	if current == "" || sessions.Items[0].Device == "" {
+		t.Fatalf("expected a described current session, got %+v", sessions)
+	}
+
+	// Ending the other sessions stops the phone's tokens at once
+	var revoked struct {
+		Revoked int `json:"revoked"`
+	}
+	if status := env.Do(t, http.MethodDelete, "/api/v1/auth/sessions", laptop.AccessToken, nil, &revoked); status != http.StatusOK || revoked.Revoked != 1 {
+		t.Fatalf("revoke other sessions: expected 200 with 1 revoked, got %d %+v", status, revoked)
+	}
+	if status := env.Do(t, http.MethodGet, "/api/v1/auth/me", phone.AccessToken, nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("me on the ended session: expected 401, got %d", status)
+	}
+	refresh := map[string]string{"refresh_token": phone.RefreshToken}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/refresh", "", refresh, nil); status != http.StatusUnauthorized {
+		t.Fatalf("refresh on the ended session: expected 401, got %d", status)
+	}
+
+	// A refreshed token stays in its session
+	var rotated tokenPair
+	refresh = map[string]string{"refresh_token": laptop.RefreshToken}
+	if status := env.Do(t, http.MethodPost, "/api/v1/auth/refresh", "", refresh, &rotated); status != http.StatusOK {
+		t.Fatalf("refresh: expected 200, got %d", status)
+	}
+
+	var me struct {
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+	}
+	env.Do(t, http.MethodGet, "/api/v1/auth/me", rotated.AccessToken, nil, &me)
+	sessions = sessionList{}
+	userSessions := "/api/v1/admin/users/" + me.User.ID + "/sessions"
+	if status := env.Do(t, http.MethodGet, userSessions, admin.AccessToken, nil, &sessions); status != http.StatusOK {
+		t.Fatalf("admin list sessions: expected 200, got %d", status)
+	}
+	if len(sessions.Items) != 1 || sessions.Items[0].ID != current || sessions.Items[0].Current {
+		t.Fatalf("expected the laptop session only, not marked current for the admin, got %+v", sessions)
+	}
+	if status := env.Do(t, http.MethodDelete, userSessions+"/"+current, laptop.AccessToken, nil, nil); status != http.StatusForbidden {
+		t.Fatalf("admin route as a learner: expected 403, got %d", status)
+	}
+	if status := env.Do(t, http.MethodDelete, userSessions+"/"+current, admin.AccessToken, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("admin revoke session: expected 204, got %d", status)
+	}
+	if status := env.Do(t, http.MethodGet, "/api/v1/auth/me", rotated.AccessToken, nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("me after the admin ended the session: expected 401, got %d", status)
+	}
+	if status := env.Do(t, http.MethodDelete, userSessions+"/"+current, admin.AccessToken, nil, nil); status != http.StatusNotFound {
+		t.Fatalf("revoke an ended session: expected 404, got %d", status)
+	}
+}
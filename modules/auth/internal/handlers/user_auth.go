@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"context"
+
 	sharedmodels "egaldeutsch-be/pkg/models"
 )
 
@@ -8,22 +10,37 @@ import (
 // Small interface following Go philosophy: do one thing well.
 type UserAuthenticator interface {
 	// AuthenticateUser verifies credentials and returns user ID (UUID string) and role if valid.
-	AuthenticateUser(email, password string) (string, sharedmodels.UserRole, error)
+	AuthenticateUser(ctx context.Context, email, password string) (string, sharedmodels.UserRole, error)
 }
 
 // UserPasswordManager handles password-related operations.
 type UserPasswordManager interface {
+	// CheckPassword returns a validation error if the password policy rejects
+	// newPassword for the user.
+	CheckPassword(ctx context.Context, userID string, newPassword string) error
+
 	// UpdatePassword updates a user's password (the implementation should handle hashing).
-	UpdatePassword(userID string, newPassword string) error
+	UpdatePassword(ctx context.Context, userID string, newPassword string) error
+
+	// CompletePasswordReset sets the new password after a reset and publishes
+	// password.reset_completed in the same transaction.
+	CompletePasswordReset(ctx context.Context, userID string, newPassword string) error
 }
 
 // UserLookup handles user lookup operations.
 type UserLookup interface {
 	// GetUserIDByEmail returns the user ID string for the provided email.
-	GetUserIDByEmail(email string) (string, error)
+	GetUserIDByEmail(ctx context.Context, email string) (string, error)
 
 	// GetUserViewByID returns a small user view (id, name, email, role).
-	GetUserViewByID(userID string) (*sharedmodels.UserView, error)
+	GetUserViewByID(ctx context.Context, userID string) (*sharedmodels.UserView, error)
+}
+
+// UserEmailVerifier handles email verification.
+type UserEmailVerifier interface {
+	// MarkEmailVerified marks email as the verified address of the user,
+	// failing if the user's address has changed since.
+	MarkEmailVerified(ctx context.Context, userID string, email string) error
 }
 
 // UserService combines minimal user-related operations required by the auth module.
@@ -32,4 +49,5 @@ type UserService interface {
 	UserAuthenticator
 	UserPasswordManager
 	UserLookup
+	UserEmailVerifier
 }
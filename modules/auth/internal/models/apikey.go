@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	sharedmodels "egaldeutsch-be/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// APIKey is a key a user created for a machine client. Only the hash of the
+// key is stored, and its first characters to tell keys apart.
+type APIKey struct {
+	sharedmodels.UUIDKey
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Name       string     `gorm:"type:varchar(100);not null" json:"name"`
+	Prefix     string     `gorm:"type:varchar(20);not null" json:"prefix"`
+	KeyHash    string     `gorm:"type:text;not null;uniqueIndex" json:"-"`
+	Scopes     string     `gorm:"type:text;not null" json:"scopes"` // permissions separated by spaces
+	CreatedAt  time.Time  `gorm:"not null;autoCreateTime" json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"` // nil if the key does not expire
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// TableName specifies the table name for the APIKey model
+func (APIKey) TableName() string {
+	return "api_keys"
+}
@@ -11,10 +11,15 @@ type ForgotPasswordRequest struct {
 
 type ResetPasswordRequest struct {
 	Token           string `json:"token" binding:"required"`
-	Password        string `json:"password" binding:"required,min=8"`
+	Password        string `json:"password" binding:"required"`
 	PasswordConfirm string `json:"password_confirm" binding:"required,eqfield=Password"`
 }
 
+// VerifyEmailRequest carries the token of a verification link.
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
 type LogoutRequest struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
 }
@@ -22,3 +27,59 @@ type LogoutRequest struct {
 type RefreshRequest struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
 }
+
+// SessionIDParam is the URI parameter of a session of the authenticated user
+type SessionIDParam struct {
+	SessionID string `uri:"session_id" binding:"required,uuid"`
+}
+
+// UserIDParam is the URI parameter of the user whose sessions an admin manages
+type UserIDParam struct {
+	ID string `uri:"id" binding:"required,uuid"`
+}
+
+// UserSessionParams are the URI parameters of a session of a user
+type UserSessionParams struct {
+	ID        string `uri:"id" binding:"required,uuid"`
+	SessionID string `uri:"session_id" binding:"required,uuid"`
+}
+
+// MFACodeRequest carries a TOTP code, or a recovery code where one is accepted
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required,max=32"`
+}
+
+// MFAEnrollRequest starts the enrollment a login challenge requires
+type MFAEnrollRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// MFAVerifyRequest completes a login with its second factor
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	// Code is a TOTP code, or a recovery code unless the login requires enrolling
+	Code string `json:"code" binding:"required,max=32"`
+}
+
+// MFAPolicyRequest sets the roles that must use two-factor authentication
+type MFAPolicyRequest struct {
+	RequiredRoles []string `json:"required_roles" binding:"dive,oneof=learner teacher moderator admin"`
+}
+
+// CreateAPIKeyRequest names a new API key and the permissions it may use.
+// TTLDays defaults to the longest lifetime allowed.
+type CreateAPIKeyRequest struct {
+	Name    string   `json:"name" binding:"required,max=100"`
+	Scopes  []string `json:"scopes" binding:"required,min=1,dive,required"`
+	TTLDays int      `json:"ttl_days" binding:"omitempty,min=1"`
+}
+
+// APIKeyIDParam is the URI parameter of an API key
+type APIKeyIDParam struct {
+	KeyID string `uri:"key_id" binding:"required,uuid"`
+}
+
+// APIKeyListQuery narrows the API keys an admin lists to those of one user
+type APIKeyListQuery struct {
+	UserID string `form:"user_id" binding:"omitempty,uuid"`
+}
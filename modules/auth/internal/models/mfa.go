@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	sharedmodels "egaldeutsch-be/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// MFASecret is the TOTP secret of a user. It is pending until the user
+// confirms it with a code.
+type MFASecret struct {
+	UserID       uuid.UUID  `gorm:"type:uuid;primaryKey" json:"user_id"`
+	Secret       string     `gorm:"type:text;not null" json:"-"` // encrypted when an encryption key is configured
+	EnabledAt    *time.Time `json:"enabled_at"`                  // nil while pending
+	LastUsedStep int64      `gorm:"not null;default:0" json:"-"` // TOTP time step of the last accepted code
+	CreatedAt    time.Time  `gorm:"not null;autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"not null;autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for the MFASecret model
+func (MFASecret) TableName() string {
+	return "user_mfa"
+}
+
+// RecoveryCode is a single-use code that stands in for a TOTP code.
+type RecoveryCode struct {
+	sharedmodels.UUIDKey
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"type:text;not null" json:"-"`
+	CreatedAt time.Time  `gorm:"not null;autoCreateTime" json:"created_at"`
+	UsedAt    *time.Time `json:"used_at"`
+}
+
+// TableName specifies the table name for the RecoveryCode model
+func (RecoveryCode) TableName() string {
+	return "mfa_recovery_codes"
+}
+
+// MFAChallenge is a login waiting for its second factor.
+type MFAChallenge struct {
+	sharedmodels.UUIDKey
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	TokenHash string    `gorm:"type:text;not null;uniqueIndex" json:"token_hash"`
+	Enroll    bool      `gorm:"not null;default:false" json:"enroll"` // completed by confirming an enrollment
+	Attempts  int       `gorm:"not null;default:0" json:"attempts"`
+	Used      bool      `gorm:"not null;default:false" json:"used"`
+	CreatedAt time.Time `gorm:"not null;autoCreateTime" json:"created_at"`
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+}
+
+// TableName specifies the table name for the MFAChallenge model
+func (MFAChallenge) TableName() string {
+	return "mfa_challenges"
+}
+
+// MFARequiredRole is a role whose users must use two-factor authentication.
+type MFARequiredRole struct {
+	Role      string    `gorm:"type:varchar(20);primaryKey" json:"role"`
+	CreatedAt time.Time `gorm:"not null;autoCreateTime" json:"created_at"`
+}
+
+// TableName specifies the table name for the MFARequiredRole model
+func (MFARequiredRole) TableName() string {
+	return "mfa_required_roles"
+}
@@ -3,27 +3,50 @@ package models
 import (
 	"time"
 
+	sharedmodels "egaldeutsch-be/pkg/models"
+
 	"github.com/google/uuid"
 )
 
+// RefreshToken is one token of a session's rotation chain. Only the newest
+// token of a chain is unrevoked while the session is active.
 type RefreshToken struct {
-	ID         uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	sharedmodels.UUIDKey
 	UserID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	SessionID  uuid.UUID  `gorm:"type:uuid;index" json:"session_id"` // ID of the first token of the chain
 	TokenHash  string     `gorm:"type:text;not null;uniqueIndex" json:"token_hash"`
-	CreatedAt  time.Time  `gorm:"not null;default:now()" json:"created_at"`
+	CreatedAt  time.Time  `gorm:"not null;autoCreateTime" json:"created_at"`
 	LastUsedAt *time.Time `json:"last_used_at"`
+	AuthTime   *time.Time `json:"auth_time"` // login time, copied on rotation; nil for older tokens
 	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
 	Revoked    bool       `gorm:"not null;default:false" json:"revoked"`
-	ReplacedBy *string    `gorm:"type:text" json:"replaced_by"`
+	ReplacedBy *string    `gorm:"type:text" json:"replaced_by"` // ID of the token this one was rotated into
 	IP         *string    `gorm:"type:text" json:"ip"`
 	UserAgent  *string    `gorm:"type:text" json:"user_agent"`
 }
 
 type PasswordReset struct {
-	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	sharedmodels.UUIDKey
 	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
 	TokenHash string    `gorm:"type:text;not null;uniqueIndex" json:"token_hash"`
-	CreatedAt time.Time `gorm:"not null;default:now()" json:"created_at"`
+	CreatedAt time.Time `gorm:"not null;autoCreateTime" json:"created_at"`
 	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
 	Used      bool      `gorm:"not null;default:false" json:"used"`
 }
+
+// EmailVerification is a single-use token that verifies the email address
+// it was sent to.
+type EmailVerification struct {
+	sharedmodels.UUIDKey
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Email     string    `gorm:"type:varchar(100);not null" json:"email"`
+	TokenHash string    `gorm:"type:text;not null;uniqueIndex" json:"token_hash"`
+	CreatedAt time.Time `gorm:"not null;autoCreateTime" json:"created_at"`
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+	Used      bool      `gorm:"not null;default:false" json:"used"`
+}
+
+// TableName specifies the table name for the EmailVerification model
+func (EmailVerification) TableName() string {
+	return "email_verifications"
+}
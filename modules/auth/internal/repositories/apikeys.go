@@ -0,0 +1,168 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	authpkg "egaldeutsch-be/internal/auth"
+	"egaldeutsch-be/internal/events"
+	models "egaldeutsch-be/modules/auth/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func toAPIKey(k models.APIKey) authpkg.APIKey {
+	return authpkg.APIKey{
+		ID:         k.ID.String(),
+		UserID:     k.UserID.String(),
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     strings.Fields(k.Scopes),
+		CreatedAt:  k.CreatedAt,
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+	}
+}
+
+// InsertAPIKey locks the user's row while counting their keys, so concurrent
+// requests cannot exceed the limit together.
+func (r *Repository) InsertAPIKey(ctx context.Context, key authpkg.APIKey, keyHash string, maxPerUser int) (authpkg.APIKey, error) {
+	uid, err := uuid.Parse(key.UserID)
+	if err != nil {
+		return authpkg.APIKey{}, err
+	}
+	m := models.APIKey{
+		UserID:    uid,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		KeyHash:   keyHash,
+		Scopes:    strings.Join(key.Scopes, " "),
+		ExpiresAt: key.ExpiresAt,
+	}
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var locked []uuid.UUID
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Table("users").Where("id = ?", uid).Pluck("id", &locked).Error; err != nil {
+			return err
+		}
+		var n int64
+		if err := tx.Model(&models.APIKey{}).Where("user_id = ? AND revoked_at IS NULL", uid).Count(&n).Error; err != nil {
+			return err
+		}
+		if n >= int64(maxPerUser) {
+			return authpkg.ErrTooManyAPIKeys
+		}
+		if err := tx.Create(&m).Error; err != nil {
+			return err
+		}
+		return events.Publish(tx, events.AuthAPIKeyCreated, events.AuthAPIKeyCreatedPayload{
+			UserID:    key.UserID,
+			KeyID:     m.ID.String(),
+			Name:      m.Name,
+			Scopes:    key.Scopes,
+			ExpiresAt: m.ExpiresAt,
+		})
+	})
+	if err != nil {
+		return authpkg.APIKey{}, err
+	}
+	return toAPIKey(m), nil
+}
+
+func (r *Repository) GetAPIKeyByHash(ctx context.Context, keyHash string) (authpkg.APIKeyPrincipal, error) {
+	var row struct {
+		models.APIKey
+		Role string
+	}
+	err := r.db.WithContext(ctx).Model(&models.APIKey{}).
+		Select("api_keys.*, users.role AS role").
+		Joins("JOIN users ON users.id = api_keys.user_id AND users.deleted_at IS NULL").
+		Where("api_keys.key_hash = ? AND api_keys.revoked_at IS NULL", keyHash).
+		Where("api_keys.expires_at IS NULL OR api_keys.expires_at > ?", time.Now()).
+		Take(&row).Error
+	if err == gorm.ErrRecordNotFound {
+		return authpkg.APIKeyPrincipal{}, authpkg.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return authpkg.APIKeyPrincipal{}, err
+	}
+	return authpkg.APIKeyPrincipal{
+		KeyID:      row.ID.String(),
+		UserID:     row.UserID.String(),
+		Role:       row.Role,
+		Scopes:     strings.Fields(row.Scopes),
+		LastUsedAt: row.LastUsedAt,
+	}, nil
+}
+
+func (r *Repository) TouchAPIKey(ctx context.Context, keyID string, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.APIKey{}).Where("id = ?", keyID).Update("last_used_at", at).Error
+}
+
+func (r *Repository) ListAPIKeys(ctx context.Context, userID string) ([]authpkg.APIKey, error) {
+	q := r.db.WithContext(ctx).Where("revoked_at IS NULL")
+	if userID != "" {
+		uid, err := uuid.Parse(userID)
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where("user_id = ?", uid)
+	}
+	var keys []models.APIKey
+	if err := q.Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	out := make([]authpkg.APIKey, len(keys))
+	for i, k := range keys {
+		out[i] = toAPIKey(k)
+	}
+	return out, nil
+}
+
+func (r *Repository) RevokeAPIKey(ctx context.Context, userID string, keyID string) error {
+	kid, err := uuid.Parse(keyID)
+	if err != nil {
+		return authpkg.ErrAPIKeyNotFound
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		q := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND revoked_at IS NULL", kid)
+		if userID != "" {
+			q = q.Where("user_id = ?", userID)
+		}
+		var key models.APIKey
+		if err := q.First(&key).Error; err == gorm.ErrRecordNotFound {
+			return authpkg.ErrAPIKeyNotFound
+		} else if err != nil {
+			return err
+		}
+		if err := tx.Model(&key).Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return events.Publish(tx, events.AuthAPIKeyRevoked, events.AuthAPIKeyRevokedPayload{
+			UserID: key.UserID.String(),
+			KeyID:  key.ID.String(),
+			Name:   key.Name,
+		})
+	})
+}
+
+func (r *Repository) RevokeAllAPIKeys(ctx context.Context, userID string) error {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", uid).
+		Update("revoked_at", time.Now()).Error
+}
+
+// PurgeExpiredAPIKeys deletes keys that can no longer be used; their
+// creation and revocation stay in the audit log.
+func (r *Repository) PurgeExpiredAPIKeys(ctx context.Context, before time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).
+		Where("expires_at < ? OR revoked_at < ?", before, before).
+		Delete(&models.APIKey{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,285 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	authpkg "egaldeutsch-be/internal/auth"
+	"egaldeutsch-be/internal/events"
+	models "egaldeutsch-be/modules/auth/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (r *Repository) GetMFASecret(ctx context.Context, userID string) (*authpkg.MFASecret, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, err
+	}
+	var m models.MFASecret
+	if err := r.db.WithContext(ctx).Where("user_id = ?", uid).First(&m).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &authpkg.MFASecret{Secret: m.Secret, EnabledAt: m.EnabledAt, LastUsedStep: m.LastUsedStep}, nil
+}
+
+func (r *Repository) SavePendingMFASecret(ctx context.Context, userID string, secret string) error {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing models.MFASecret
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", uid).First(&existing).Error
+		switch {
+		case err == gorm.ErrRecordNotFound:
+			return tx.Create(&models.MFASecret{UserID: uid, Secret: secret}).Error
+		case err != nil:
+			return err
+		case existing.EnabledAt != nil:
+			return authpkg.ErrMFAAlreadyEnabled
+		}
+		return tx.Model(&existing).Updates(map[string]interface{}{"secret": secret, "last_used_step": 0}).Error
+	})
+}
+
+// EnableMFA enables the pending secret. Recovery codes left over from an
+// earlier enrollment are replaced.
+func (r *Repository) EnableMFA(ctx context.Context, userID string, step int64, recoveryCodeHashes []string) error {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.MFASecret{}).
+			Where("user_id = ? AND enabled_at IS NULL", uid).
+			Updates(map[string]interface{}{"enabled_at": time.Now(), "last_used_step": step})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			// Confirmed concurrently
+			return authpkg.ErrMFAAlreadyEnabled
+		}
+		if err := replaceRecoveryCodes(tx, uid, recoveryCodeHashes); err != nil {
+			return err
+		}
+		return events.Publish(tx, events.AuthMFAEnabled, events.AuthMFAEnabledPayload{UserID: userID})
+	})
+}
+
+// UseTOTPStep only moves the last used step forward, so of two requests
+// presenting the same code only one succeeds.
+func (r *Repository) UseTOTPStep(ctx context.Context, userID string, step int64) error {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return err
+	}
+	res := r.db.WithContext(ctx).Model(&models.MFASecret{}).
+		Where("user_id = ? AND enabled_at IS NOT NULL AND last_used_step < ?", uid, step).
+		Update("last_used_step", step)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return authpkg.ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (r *Repository) UseRecoveryCode(ctx context.Context, userID string, codeHash string) error {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", uid, codeHash).
+			Update("used_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return authpkg.ErrInvalidMFACode
+		}
+		var remaining int64
+		if err := tx.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", uid).Count(&remaining).Error; err != nil {
+			return err
+		}
+		return events.Publish(tx, events.AuthMFARecoveryCodeUsed, events.AuthMFARecoveryCodeUsedPayload{
+			UserID:    userID,
+			Remaining: int(remaining),
+		})
+	})
+}
+
+func (r *Repository) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := replaceRecoveryCodes(tx, uid, codeHashes); err != nil {
+			return err
+		}
+		return events.Publish(tx, events.AuthMFARecoveryCodesRegenerated, events.AuthMFARecoveryCodesRegeneratedPayload{UserID: userID})
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, uid uuid.UUID, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", uid).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	codes := make([]models.RecoveryCode, 0, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes = append(codes, models.RecoveryCode{UserID: uid, CodeHash: hash})
+	}
+	return tx.Create(&codes).Error
+}
+
+func (r *Repository) CountRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	err = r.db.WithContext(ctx).Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", uid).Count(&n).Error
+	return int(n), err
+}
+
+// DeleteMFA removes an enabled secret, or a pending one alongside it, and
+// the recovery codes of the user.
+func (r *Repository) DeleteMFA(ctx context.Context, userID string, reset bool) error {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("user_id = ? AND enabled_at IS NOT NULL", uid).Delete(&models.MFASecret{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return authpkg.ErrMFANotEnabled
+		}
+		if err := tx.Where("user_id = ?", uid).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if reset {
+			return events.Publish(tx, events.AuthMFAReset, events.AuthMFAResetPayload{UserID: userID})
+		}
+		return events.Publish(tx, events.AuthMFADisabled, events.AuthMFADisabledPayload{UserID: userID})
+	})
+}
+
+func (r *Repository) InsertMFAChallenge(ctx context.Context, tokenHash string, userID string, enroll bool, expiresAt time.Time) error {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Create(&models.MFAChallenge{
+		UserID:    uid,
+		TokenHash: tokenHash,
+		Enroll:    enroll,
+		ExpiresAt: expiresAt,
+	}).Error
+}
+
+func (r *Repository) GetMFAChallenge(ctx context.Context, tokenHash string, maxAttempts int) (authpkg.MFAChallenge, error) {
+	var c models.MFAChallenge
+	err := r.db.WithContext(ctx).
+		Where("token_hash = ? AND used = ? AND attempts < ? AND expires_at > ?", tokenHash, false, maxAttempts, time.Now()).
+		First(&c).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return authpkg.MFAChallenge{}, authpkg.ErrInvalidMFAToken
+		}
+		return authpkg.MFAChallenge{}, err
+	}
+	return authpkg.MFAChallenge{UserID: c.UserID.String(), Enroll: c.Enroll, ExpiresAt: c.ExpiresAt}, nil
+}
+
+// ClaimMFAAttempt counts the attempt in its own transaction, so a wrong code
+// uses up an attempt whatever happens afterwards.
+func (r *Repository) ClaimMFAAttempt(ctx context.Context, tokenHash string, maxAttempts int) (authpkg.MFAChallenge, error) {
+	var challenge authpkg.MFAChallenge
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.MFAChallenge{}).
+			Where("token_hash = ? AND used = ? AND attempts < ? AND expires_at > ?", tokenHash, false, maxAttempts, time.Now()).
+			Update("attempts", gorm.Expr("attempts + 1"))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return authpkg.ErrInvalidMFAToken
+		}
+
+		var c models.MFAChallenge
+		if err := tx.Where("token_hash = ?", tokenHash).First(&c).Error; err != nil {
+			return err
+		}
+		challenge = authpkg.MFAChallenge{UserID: c.UserID.String(), Enroll: c.Enroll, ExpiresAt: c.ExpiresAt}
+
+		// fetch role from users table inside the same tx
+		return tx.Raw("SELECT role FROM users WHERE id = ?", c.UserID).Scan(&challenge.Role).Error
+	})
+	if err != nil {
+		return authpkg.MFAChallenge{}, err
+	}
+	return challenge, nil
+}
+
+func (r *Repository) ConsumeMFAChallenge(ctx context.Context, tokenHash string) error {
+	res := r.db.WithContext(ctx).Model(&models.MFAChallenge{}).
+		Where("token_hash = ? AND used = ?", tokenHash, false).
+		Update("used", true)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return authpkg.ErrInvalidMFAToken
+	}
+	return nil
+}
+
+// PurgeExpiredMFAChallenges deletes expired challenges, passed or not.
+func (r *Repository) PurgeExpiredMFAChallenges(ctx context.Context, before time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Where("expires_at < ?", before).Delete(&models.MFAChallenge{})
+	return res.RowsAffected, res.Error
+}
+
+func (r *Repository) GetMFARequiredRoles(ctx context.Context) ([]string, error) {
+	roles := []string{}
+	err := r.db.WithContext(ctx).Model(&models.MFARequiredRole{}).Order("role").Pluck("role", &roles).Error
+	return roles, err
+}
+
+func (r *Repository) SetMFARequiredRoles(ctx context.Context, roles []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		old := []string{}
+		if err := tx.Model(&models.MFARequiredRole{}).Order("role").Pluck("role", &old).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("1 = 1").Delete(&models.MFARequiredRole{}).Error; err != nil {
+			return err
+		}
+		if len(roles) > 0 {
+			rows := make([]models.MFARequiredRole, 0, len(roles))
+			for _, role := range roles {
+				rows = append(rows, models.MFARequiredRole{Role: role})
+			}
+			if err := tx.Create(&rows).Error; err != nil {
+				return err
+			}
+		}
+		return events.Publish(tx, events.AuthMFAPolicyChanged, events.AuthMFAPolicyChangedPayload{
+			OldRoles: old,
+			NewRoles: roles,
+		})
+	})
+}
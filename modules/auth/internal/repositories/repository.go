@@ -1,10 +1,14 @@
 package repositories
 
 import (
+	"context"
+	"sort"
 	"time"
 
 	authpkg "egaldeutsch-be/internal/auth"
+	"egaldeutsch-be/internal/events"
 	models "egaldeutsch-be/modules/auth/internal/models"
+	sharedmodels "egaldeutsch-be/pkg/models"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -19,15 +23,22 @@ func NewRepository(db *gorm.DB) *Repository {
 	return &Repository{db: db}
 }
 
-func (r *Repository) InsertRefreshToken(tokenHash string, userID string, expiresAt int64, ip *string, userAgent *string) error {
+func (r *Repository) InsertRefreshToken(ctx context.Context, tokenHash string, userID string, expiresAt int64, ip *string, userAgent *string) (string, error) {
 	uid, err := uuid.Parse(userID)
 	if err != nil {
-		return err
+		return "", err
 	}
+	// The first token of a session gives it its ID
+	id := uuid.New()
+	now := time.Now()
 	rt := &models.RefreshToken{
-		UserID:    uid,
-		TokenHash: tokenHash,
-		ExpiresAt: time.Unix(expiresAt, 0),
+		UUIDKey:    sharedmodels.UUIDKey{ID: id},
+		UserID:     uid,
+		SessionID:  id,
+		TokenHash:  tokenHash,
+		AuthTime:   &now,
+		LastUsedAt: &now,
+		ExpiresAt:  time.Unix(expiresAt, 0),
 	}
 	if ip != nil {
 		rt.IP = ip
@@ -35,58 +46,108 @@ func (r *Repository) InsertRefreshToken(tokenHash string, userID string, expires
 	if userAgent != nil {
 		rt.UserAgent = userAgent
 	}
-	return r.db.Create(rt).Error
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(rt).Error; err != nil {
+			return err
+		}
+		return events.Publish(tx, events.AuthLogin, events.AuthLoginPayload{
+			UserID:    userID,
+			IP:        deref(ip),
+			UserAgent: deref(userAgent),
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
 }
 
 // RotateRefreshToken creates a new refresh token row and marks the old one revoked.
-// It returns the user id as string and whether reuse was detected.
-func (r *Repository) RotateRefreshToken(oldHash, newHash string, newExpiresAt int64, ip *string, userAgent *string) (string, string, bool, error) {
-	// Use transaction and FOR UPDATE semantics
-	tx := r.db.Begin()
+// It returns the session of the token and whether reuse was detected.
+func (r *Repository) RotateRefreshToken(ctx context.Context, oldHash, newHash string, newExpiresAt int64, ip *string, userAgent *string) (authpkg.RefreshSession, bool, error) {
+	// Use transaction and FOR UPDATE semantics. SQLite has no row locks; the
+	// sqlite driver drops the clause and the single-connection pool
+	// serializes transactions instead.
+	tx := r.db.WithContext(ctx).Begin()
 	if tx.Error != nil {
-		return "", "", false, tx.Error
+		return authpkg.RefreshSession{}, false, tx.Error
 	}
 
 	var old models.RefreshToken
 	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash = ?", oldHash).First(&old).Error; err != nil {
 		tx.Rollback()
 		if err == gorm.ErrRecordNotFound {
-			return "", "", false, authpkg.ErrInvalidRefreshToken
+			return authpkg.RefreshSession{}, false, authpkg.ErrInvalidRefreshToken
 		}
-		return "", "", false, err
+		return authpkg.RefreshSession{}, false, err
 	}
 
 	uid := old.UserID
+	session := authpkg.RefreshSession{ID: old.SessionID.String(), UserID: uid.String(), AuthTime: old.CreatedAt}
+	if old.SessionID == uuid.Nil {
+		// Tokens issued before sessions were recorded start their own
+		session.ID = old.ID.String()
+	}
+	if old.AuthTime != nil {
+		session.AuthTime = *old.AuthTime
+	}
+
+	// A token revoked by logout or by ending its session is merely invalid;
+	// presenting it again does not show that it leaked
+	if old.Revoked && old.ReplacedBy == nil {
+		tx.Rollback()
+		return authpkg.RefreshSession{}, false, authpkg.ErrInvalidRefreshToken
+	}
 
-	// If token was already revoked -> reuse detected. Revoke all tokens for user and return reused=true
+	// If token was already rotated -> reuse detected. Revoke all tokens for user and return reused=true
 	if old.Revoked {
 		if err := tx.Model(&models.RefreshToken{}).Where("user_id = ?", uid).Updates(map[string]interface{}{"revoked": true}).Error; err != nil {
 			tx.Rollback()
-			return "", "", false, err
+			return authpkg.RefreshSession{}, false, err
+		}
+		err := events.Publish(tx, events.AuthRefreshReuseDetected, events.AuthRefreshReuseDetectedPayload{
+			UserID:    uid.String(),
+			IP:        deref(ip),
+			UserAgent: deref(userAgent),
+		})
+		if err != nil {
+			tx.Rollback()
+			return authpkg.RefreshSession{}, false, err
 		}
 
 		// fetch role from users table inside the same tx
-		var role string
-		if err := tx.Raw("SELECT role FROM users WHERE id = ?", uid).Scan(&role).Error; err != nil {
+		if err := tx.Raw("SELECT role FROM users WHERE id = ?", uid).Scan(&session.Role).Error; err != nil {
 			tx.Rollback()
 			if err == gorm.ErrRecordNotFound {
-				return "", "", false, authpkg.ErrInvalidRefreshToken
+				return authpkg.RefreshSession{}, false, authpkg.ErrInvalidRefreshToken
 			}
-			return "", "", false, err
+			return authpkg.RefreshSession{}, false, err
 		}
 
 		if err := tx.Commit().Error; err != nil {
-			return "", "", false, err
+			return authpkg.RefreshSession{}, false, err
 		}
 
-		return uid.String(), role, true, nil
+		return session, true, nil
 	}
 
-	// create new token
+	// create new token; it continues the session of the old one
+	now := time.Now()
 	newRT := &models.RefreshToken{
-		UserID:    uid,
-		TokenHash: newHash,
-		ExpiresAt: time.Unix(newExpiresAt, 0),
+		UserID:     uid,
+		SessionID:  uuid.MustParse(session.ID),
+		TokenHash:  newHash,
+		AuthTime:   &session.AuthTime,
+		LastUsedAt: &now,
+		ExpiresAt:  time.Unix(newExpiresAt, 0),
 	}
 	if ip != nil {
 		newRT.IP = ip
@@ -97,38 +158,38 @@ func (r *Repository) RotateRefreshToken(oldHash, newHash string, newExpiresAt in
 
 	if err := tx.Create(newRT).Error; err != nil {
 		tx.Rollback()
-		return "", "", false, err
+		return authpkg.RefreshSession{}, false, err
 	}
 
-	// mark old revoked and set replaced_by
-	if err := tx.Model(&models.RefreshToken{}).Where("token_hash = ?", oldHash).Updates(map[string]interface{}{"revoked": true, "replaced_by": newHash}).Error; err != nil {
+	// mark old revoked and link it to its successor
+	updates := map[string]interface{}{"revoked": true, "replaced_by": newRT.ID.String(), "last_used_at": now}
+	if err := tx.Model(&models.RefreshToken{}).Where("token_hash = ?", oldHash).Updates(updates).Error; err != nil {
 		tx.Rollback()
-		return "", "", false, err
+		return authpkg.RefreshSession{}, false, err
 	}
 
 	// fetch role from users table inside the same tx
-	var role string
-	if err := tx.Raw("SELECT role FROM users WHERE id = ?", uid).Scan(&role).Error; err != nil {
+	if err := tx.Raw("SELECT role FROM users WHERE id = ?", uid).Scan(&session.Role).Error; err != nil {
 		tx.Rollback()
 		if err == gorm.ErrRecordNotFound {
-			return "", "", false, authpkg.ErrInvalidRefreshToken
+			return authpkg.RefreshSession{}, false, authpkg.ErrInvalidRefreshToken
 		}
-		return "", "", false, err
+		return authpkg.RefreshSession{}, false, err
 	}
 
 	if err := tx.Commit().Error; err != nil {
-		return "", "", false, err
+		return authpkg.RefreshSession{}, false, err
 	}
 
-	return uid.String(), role, false, nil
+	return session, false, nil
 }
 
-func (r *Repository) RevokeRefreshTokenByHash(hash string, replacedBy *string) error {
+func (r *Repository) RevokeRefreshTokenByHash(ctx context.Context, hash string, replacedBy *string) error {
 	updates := map[string]interface{}{"revoked": true}
 	if replacedBy != nil {
 		updates["replaced_by"] = *replacedBy
 	}
-	res := r.db.Model(&models.RefreshToken{}).Where("token_hash = ?", hash).Updates(updates)
+	res := r.db.WithContext(ctx).Model(&models.RefreshToken{}).Where("token_hash = ?", hash).Updates(updates)
 	if res.Error != nil {
 		return res.Error
 	}
@@ -138,15 +199,135 @@ func (r *Repository) RevokeRefreshTokenByHash(hash string, replacedBy *string) e
 	return nil
 }
 
-func (r *Repository) RevokeAllForUser(userID string) error {
+func (r *Repository) RevokeAllForUser(ctx context.Context, userID string) error {
 	uid, err := uuid.Parse(userID)
 	if err != nil {
 		return err
 	}
-	return r.db.Model(&models.RefreshToken{}).Where("user_id = ?", uid).Updates(map[string]interface{}{"revoked": true}).Error
+	return r.db.WithContext(ctx).Model(&models.RefreshToken{}).Where("user_id = ?", uid).Updates(map[string]interface{}{"revoked": true}).Error
 }
 
-func (r *Repository) InsertPasswordReset(tokenHash string, userID string, expiresAt int64) error {
+// ListSessions returns the active sessions of a user, newest login first. A
+// session is active while the last token of its chain is unrevoked and
+// unexpired; that token holds the session's current details.
+func (r *Repository) ListSessions(ctx context.Context, userID string) ([]authpkg.Session, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []models.RefreshToken
+	err = r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked = ? AND expires_at > ?", uid, false, time.Now()).
+		Find(&tokens).Error
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]authpkg.Session, 0, len(tokens))
+	for _, t := range tokens {
+		s := authpkg.Session{
+			ID:         sessionID(t).String(),
+			Device:     authpkg.DescribeDevice(deref(t.UserAgent)),
+			IP:         deref(t.IP),
+			UserAgent:  deref(t.UserAgent),
+			CreatedAt:  t.CreatedAt,
+			LastUsedAt: t.CreatedAt,
+			ExpiresAt:  t.ExpiresAt,
+		}
+		if t.AuthTime != nil {
+			s.CreatedAt = *t.AuthTime
+		}
+		if t.LastUsedAt != nil {
+			s.LastUsedAt = *t.LastUsedAt
+		}
+		sessions = append(sessions, s)
+	}
+	sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].CreatedAt.After(sessions[j].CreatedAt) })
+	return sessions, nil
+}
+
+// sessionID returns the session of a token; tokens issued before sessions
+// were recorded form a session of their own.
+func sessionID(t models.RefreshToken) uuid.UUID {
+	if t.SessionID == uuid.Nil {
+		return t.ID
+	}
+	return t.SessionID
+}
+
+// RevokeSession revokes the active token of one session of a user.
+func (r *Repository) RevokeSession(ctx context.Context, userID string, sessionID string) error {
+	revoked, err := r.revokeSessions(ctx, userID, func(id string) bool { return id == sessionID })
+	if err != nil {
+		return err
+	}
+	if len(revoked) == 0 {
+		return authpkg.ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeOtherSessions revokes the active tokens of every session of a user
+// but exceptSessionID.
+func (r *Repository) RevokeOtherSessions(ctx context.Context, userID string, exceptSessionID string) ([]string, error) {
+	return r.revokeSessions(ctx, userID, func(id string) bool { return id != exceptSessionID })
+}
+
+// revokeSessions revokes the active tokens of the user's sessions selected by
+// match and publishes auth.sessions_revoked for them.
+func (r *Repository) revokeSessions(ctx context.Context, userID string, match func(sessionID string) bool) ([]string, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var revoked []string
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var tokens []models.RefreshToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND revoked = ? AND expires_at > ?", uid, false, time.Now()).
+			Find(&tokens).Error
+		if err != nil {
+			return err
+		}
+
+		var ids []uuid.UUID
+		for _, t := range tokens {
+			if sid := sessionID(t).String(); match(sid) {
+				ids = append(ids, t.ID)
+				revoked = append(revoked, sid)
+			}
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := tx.Model(&models.RefreshToken{}).Where("id IN ?", ids).Update("revoked", true).Error; err != nil {
+			return err
+		}
+		return events.Publish(tx, events.AuthSessionsRevoked, events.AuthSessionsRevokedPayload{
+			UserID:     userID,
+			SessionIDs: revoked,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revoked, nil
+}
+
+// PurgeExpiredRefreshTokens deletes expired refresh tokens. Revoked tokens are
+// kept until they expire so that reuse can still be detected.
+func (r *Repository) PurgeExpiredRefreshTokens(ctx context.Context, before time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Where("expires_at < ?", before).Delete(&models.RefreshToken{})
+	return res.RowsAffected, res.Error
+}
+
+// RecordPasswordResetRequest publishes password.reset_link_requested.
+func (r *Repository) RecordPasswordResetRequest(ctx context.Context, email string) error {
+	return events.Publish(r.db.WithContext(ctx), events.PasswordResetLinkRequested, events.PasswordResetLinkRequestedPayload{Email: email})
+}
+
+func (r *Repository) InsertPasswordReset(ctx context.Context, tokenHash string, userID string, expiresAt int64) error {
 	uid, err := uuid.Parse(userID)
 	if err != nil {
 		return err
@@ -156,27 +337,54 @@ func (r *Repository) InsertPasswordReset(tokenHash string, userID string, expire
 		TokenHash: tokenHash,
 		ExpiresAt: time.Unix(expiresAt, 0),
 	}
-	return r.db.Create(pr).Error
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(pr).Error; err != nil {
+			return err
+		}
+		return events.Publish(tx, events.PasswordResetRequested, events.PasswordResetRequestedPayload{UserID: userID})
+	})
+}
+
+// GetPasswordReset returns the user of an unused, unexpired token without
+// marking it used.
+func (r *Repository) GetPasswordReset(ctx context.Context, tokenHash string) (string, error) {
+	var pr models.PasswordReset
+	err := r.db.WithContext(ctx).Where("token_hash = ? AND used = ? AND expires_at > ?", tokenHash, false, time.Now()).First(&pr).Error
+	if err == gorm.ErrRecordNotFound {
+		return "", authpkg.ErrInvalidResetToken
+	}
+	if err != nil {
+		return "", err
+	}
+	return pr.UserID.String(), nil
 }
 
-func (r *Repository) VerifyAndMarkPasswordReset(tokenHash string) (string, error) {
-	tx := r.db.Begin()
+func (r *Repository) VerifyAndMarkPasswordReset(ctx context.Context, tokenHash string) (string, error) {
+	tx := r.db.WithContext(ctx).Begin()
 	if tx.Error != nil {
 		return "", tx.Error
 	}
 
 	var pr models.PasswordReset
-	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash = ? AND used = false AND expires_at > now()", tokenHash).First(&pr).Error; err != nil {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash = ? AND used = ? AND expires_at > ?", tokenHash, false, time.Now()).First(&pr).Error; err != nil {
 		tx.Rollback()
 		if err == gorm.ErrRecordNotFound {
-			return "", authpkg.ErrInvalidRefreshToken
+			return "", authpkg.ErrInvalidResetToken
 		}
 		return "", err
 	}
 
-	if err := tx.Model(&models.PasswordReset{}).Where("token_hash = ?", tokenHash).Update("used", true).Error; err != nil {
+	// Only an unused token is marked, so a concurrent claim that got past
+	// the lock, as on SQLite, finds nothing to mark
+	res := tx.Model(&models.PasswordReset{}).Where("token_hash = ? AND used = ?", tokenHash, false).Update("used", true)
+	if res.Error != nil {
 		tx.Rollback()
-		return "", err
+		return "", res.Error
+	}
+	if res.RowsAffected == 0 {
+		tx.Rollback()
+		return "", authpkg.ErrInvalidResetToken
 	}
 
 	if err := tx.Commit().Error; err != nil {
@@ -185,3 +393,72 @@ func (r *Repository) VerifyAndMarkPasswordReset(tokenHash string) (string, error
 
 	return pr.UserID.String(), nil
 }
+
+// PurgeExpiredPasswordResets deletes expired password reset tokens, used or not.
+func (r *Repository) PurgeExpiredPasswordResets(ctx context.Context, before time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Where("expires_at < ?", before).Delete(&models.PasswordReset{})
+	return res.RowsAffected, res.Error
+}
+
+func (r *Repository) InsertEmailVerification(ctx context.Context, tokenHash string, userID string, email string, expiresAt time.Time) error {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Create(&models.EmailVerification{
+		UserID:    uid,
+		Email:     email,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+	}).Error
+}
+
+// VerifyAndMarkEmailVerification only marks an unused token, so of two
+// requests presenting the same token only one succeeds.
+func (r *Repository) VerifyAndMarkEmailVerification(ctx context.Context, tokenHash string) (string, string, error) {
+	var v models.EmailVerification
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("token_hash = ? AND used = ? AND expires_at > ?", tokenHash, false, time.Now()).First(&v).Error
+		if err == gorm.ErrRecordNotFound {
+			return authpkg.ErrInvalidVerificationToken
+		}
+		if err != nil {
+			return err
+		}
+		res := tx.Model(&models.EmailVerification{}).Where("id = ? AND used = ?", v.ID, false).Update("used", true)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return authpkg.ErrInvalidVerificationToken
+		}
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return v.UserID.String(), v.Email, nil
+}
+
+// PurgeExpiredEmailVerifications deletes expired email verification tokens, used or not.
+func (r *Repository) PurgeExpiredEmailVerifications(ctx context.Context, before time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Where("expires_at < ?", before).Delete(&models.EmailVerification{})
+	return res.RowsAffected, res.Error
+}
+
+// RecordFailedLogin publishes auth.login_failed with the failures counted
+// so far. The failure counts that throttle logins live in Redis; the audit
+// log keeps the record.
+func (r *Repository) RecordFailedLogin(ctx context.Context, email string, failures int) error {
+	return events.Publish(r.db.WithContext(ctx), events.AuthLoginFailed, events.AuthLoginFailedPayload{Email: email, Failures: failures})
+}
+
+// RecordAccountLocked publishes auth.account_locked.
+func (r *Repository) RecordAccountLocked(ctx context.Context, email string, until time.Time) error {
+	return events.Publish(r.db.WithContext(ctx), events.AuthAccountLocked, events.AuthAccountLockedPayload{Email: email, LockedUntil: until})
+}
+
+// RecordAccountUnlocked publishes auth.account_unlocked.
+func (r *Repository) RecordAccountUnlocked(ctx context.Context, userID string, email string) error {
+	return events.Publish(r.db.WithContext(ctx), events.AuthAccountUnlocked, events.AuthAccountUnlockedPayload{UserID: userID, Email: email})
+}
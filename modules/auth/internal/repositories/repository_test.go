@@ -1,11 +1,13 @@
 package repositories_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	"egaldeutsch-be/internal/auth"
 	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/events"
 	"egaldeutsch-be/modules/auth/internal/models"
 	"egaldeutsch-be/modules/auth/internal/repositories"
 
@@ -19,8 +21,10 @@ type testRefreshToken struct {
 	ID         uuid.UUID
 	UserID     uuid.UUID
 	TokenHash  string
+	SessionID  uuid.UUID
 	CreatedAt  time.Time
 	LastUsedAt *time.Time
+	AuthTime   *time.Time
 	ExpiresAt  time.Time
 	Revoked    bool
 	ReplacedBy *string
@@ -42,11 +46,12 @@ type testPasswordReset struct {
 func (testPasswordReset) TableName() string { return "password_resets" }
 
 type testUser struct {
-	ID       uuid.UUID `gorm:"primaryKey"`
-	Email    string
-	Password string
-	Name     string
-	Role     string
+	ID        uuid.UUID `gorm:"primaryKey"`
+	Email     string
+	Password  string
+	Name      string
+	Role      string
+	DeletedAt *time.Time
 }
 
 func (testUser) TableName() string { return "users" }
@@ -58,7 +63,9 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	}
 
 	// create tables using test-only structs to avoid Postgres-specific DDL
-	if err := db.AutoMigrate(&testRefreshToken{}, &testPasswordReset{}, &testUser{}); err != nil {
+	err = db.AutoMigrate(&testRefreshToken{}, &testPasswordReset{}, &testUser{}, &events.Event{},
+		&models.MFASecret{}, &models.RecoveryCode{}, &models.MFAChallenge{}, &models.MFARequiredRole{}, &models.EmailVerification{}, &models.APIKey{})
+	if err != nil {
 		t.Fatalf("failed to automigrate: %v", err)
 	}
 
@@ -88,7 +95,6 @@ func TestRotateRefreshToken_Success(t *testing.T) {
 	// insert an existing refresh token
 	oldHash := "oldhash"
 	rt := &models.RefreshToken{
-		ID:        uuid.New(),
 		UserID:    uid,
 		TokenHash: oldHash,
 		ExpiresAt: time.Now().Add(24 * time.Hour),
@@ -98,18 +104,18 @@ func TestRotateRefreshToken_Success(t *testing.T) {
 	}
 
 	newHash := "newhash"
-	userID, role, reused, err := r.RotateRefreshToken(oldHash, newHash, time.Now().Add(24*time.Hour).Unix(), nil, nil)
+	session, reused, err := r.RotateRefreshToken(context.Background(), oldHash, newHash, time.Now().Add(24*time.Hour).Unix(), nil, nil)
 	if err != nil {
 		t.Fatalf("rotate failed: %v", err)
 	}
 	if reused {
 		t.Fatalf("expected reused=false")
 	}
-	if userID != uid.String() {
-		t.Fatalf("expected userID %s got %s", uid.String(), userID)
+	if session.UserID != uid.String() {
+		t.Fatalf("expected userID %s got %s", uid.String(), session.UserID)
 	}
-	if role != "learner" {
-		t.Fatalf("expected role learner got %s", role)
+	if session.Role != "learner" {
+		t.Fatalf("expected role learner got %s", session.Role)
 	}
 
 	// ensure new token exists and old is revoked
@@ -137,7 +143,6 @@ func TestRotateRefreshToken_ReuseDetected(t *testing.T) {
 	oldHash := "revokedhash"
 	replaced := "somehash"
 	rt := &models.RefreshToken{
-		ID:         uuid.New(),
 		UserID:     uid,
 		TokenHash:  oldHash,
 		ExpiresAt:  time.Now().Add(24 * time.Hour),
@@ -149,18 +154,18 @@ func TestRotateRefreshToken_ReuseDetected(t *testing.T) {
 	}
 
 	newHash := "anothernewhash"
-	userID, role, reused, err := r.RotateRefreshToken(oldHash, newHash, time.Now().Add(24*time.Hour).Unix(), nil, nil)
+	session, reused, err := r.RotateRefreshToken(context.Background(), oldHash, newHash, time.Now().Add(24*time.Hour).Unix(), nil, nil)
 	if err != nil {
 		t.Fatalf("rotate failed: %v", err)
 	}
 	if !reused {
 		t.Fatalf("expected reused=true")
 	}
-	if userID != uid.String() {
-		t.Fatalf("expected userID %s got %s", uid.String(), userID)
+	if session.UserID != uid.String() {
+		t.Fatalf("expected userID %s got %s", uid.String(), session.UserID)
 	}
-	if role != "teacher" {
-		t.Fatalf("expected role teacher got %s", role)
+	if session.Role != "teacher" {
+		t.Fatalf("expected role teacher got %s", session.Role)
 	}
 }
 
@@ -172,10 +177,11 @@ func TestLoginThenRefreshFlow(t *testing.T) {
 
 	// simulate login: we will not hash for test simplicity; instead insert refresh token directly and call RotateRefreshToken
 	oldHash := "oldhashlogin"
+	loggedInAt := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
 	rt := &models.RefreshToken{
-		ID:        uuid.New(),
 		UserID:    uid,
 		TokenHash: oldHash,
+		AuthTime:  &loggedInAt,
 		ExpiresAt: time.Now().Add(1 * time.Hour),
 	}
 	if err := db.Create(rt).Error; err != nil {
@@ -184,23 +190,23 @@ func TestLoginThenRefreshFlow(t *testing.T) {
 
 	// rotate
 	newHash := "rotatedhash"
-	userID, role, reused, err := repositories.NewRepository(db).RotateRefreshToken(oldHash, newHash, time.Now().Add(24*time.Hour).Unix(), nil, nil)
+	session, reused, err := repositories.NewRepository(db).RotateRefreshToken(context.Background(), oldHash, newHash, time.Now().Add(24*time.Hour).Unix(), nil, nil)
 	if err != nil {
 		t.Fatalf("rotate failed: %v", err)
 	}
 	if reused {
 		t.Fatalf("unexpected reuse")
 	}
-	if userID != uid.String() {
-		t.Fatalf("expected user id %s got %s", uid.String(), userID)
+	if session.UserID != uid.String() {
+		t.Fatalf("expected user id %s got %s", uid.String(), session.UserID)
 	}
-	if role != "learner" {
-		t.Fatalf("expected role learner got %s", role)
+	if session.Role != "learner" {
+		t.Fatalf("expected role learner got %s", session.Role)
 	}
 
 	// create access token using internal auth CreateAccessToken with a minimal jwt config
 	jwtCfg := config.JwtConfig{SecretKey: "this-is-a-very-secure-secret-key-with-32-plus-characters", Issuer: "egaldeutsch", ExpirationHours: 24, RefreshTokenExpirationDays: 30}
-	token, err := auth.CreateAccessTokenFromStrings(userID, role, jwtCfg)
+	token, err := auth.NewJWTService(jwtCfg).CreateSessionAccessToken(session.UserID, session.Role, session.ID, session.AuthTime)
 	if err != nil {
 		t.Fatalf("failed to create access token: %v", err)
 	}
@@ -210,10 +216,381 @@ func TestLoginThenRefreshFlow(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to parse token: %v", err)
 	}
-	if claims.UserId != userID {
-		t.Fatalf("claims user id mismatch: expected %s got %s", userID, claims.UserId)
+	if claims.UserId != session.UserID {
+		t.Fatalf("claims user id mismatch: expected %s got %s", session.UserID, claims.UserId)
+	}
+	if claims.Role != session.Role {
+		t.Fatalf("claims role mismatch: expected %s got %s", session.Role, claims.Role)
+	}
+	if claims.AuthTime == nil || !claims.AuthTime.Equal(loggedInAt) {
+		t.Fatalf("expected auth_time %v of the login, got %v", loggedInAt, claims.AuthTime)
+	}
+}
+
+func TestPurgeExpired(t *testing.T) {
+	db := setupTestDB(t)
+	r := repositories.NewRepository(db)
+	ctx := context.Background()
+
+	uid := uuid.New()
+	insertUserWithRole(t, db, uid, "learner")
+
+	now := time.Now()
+	for hash, expiresAt := range map[string]time.Time{"expired": now.Add(-time.Hour), "valid": now.Add(time.Hour)} {
+		if _, err := r.InsertRefreshToken(ctx, hash, uid.String(), expiresAt.Unix(), nil, nil); err != nil {
+			t.Fatalf("insert refresh token: %v", err)
+		}
+		if err := r.InsertPasswordReset(ctx, hash, uid.String(), expiresAt.Unix()); err != nil {
+			t.Fatalf("insert password reset: %v", err)
+		}
+	}
+
+	if n, err := r.PurgeExpiredRefreshTokens(ctx, now); err != nil || n != 1 {
+		t.Fatalf("purge refresh tokens: expected 1, got %d (%v)", n, err)
+	}
+	if n, err := r.PurgeExpiredPasswordResets(ctx, now); err != nil || n != 1 {
+		t.Fatalf("purge password resets: expected 1, got %d (%v)", n, err)
+	}
+
+	var left int64
+	db.Model(&models.RefreshToken{}).Where("token_hash = ?", "valid").Count(&left)
+	if left != 1 {
+		t.Fatalf("valid refresh token was purged")
+	}
+}
+
+func TestEmailVerification(t *testing.T) {
+	db := setupTestDB(t)
+	r := repositories.NewRepository(db)
+	ctx := context.Background()
+
+	uid := uuid.New()
+	now := time.Now()
+	if err := r.InsertEmailVerification(ctx, "valid", uid.String(), "anna@example.com", now.Add(time.Hour)); err != nil {
+		t.Fatalf("insert email verification: %v", err)
+	}
+	if err := r.InsertEmailVerification(ctx, "expired", uid.String(), "anna@example.com", now.Add(-time.Hour)); err != nil {
+		t.Fatalf("insert email verification: %v", err)
+	}
+
+	userID, email, err := r.VerifyAndMarkEmailVerification(ctx, "valid")
+	if err != nil || userID != uid.String() || email != "anna@example.com" {
+		t.Fatalf("verify: got %q %q (%v)", userID, email, err)
+	}
+	if _, _, err := r.VerifyAndMarkEmailVerification(ctx, "valid"); err != auth.ErrInvalidVerificationToken {
+		t.Fatalf("reuse: expected ErrInvalidVerificationToken, got %v", err)
+	}
+	if _, _, err := r.VerifyAndMarkEmailVerification(ctx, "expired"); err != auth.ErrInvalidVerificationToken {
+		t.Fatalf("expired: expected ErrInvalidVerificationToken, got %v", err)
+	}
+
+	if n, err := r.PurgeExpiredEmailVerifications(ctx, now); err != nil || n != 1 {
+		t.Fatalf("purge: expected 1, got %d (%v)", n, err)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	db := setupTestDB(t)
+	r := repositories.NewRepository(db)
+	ctx := context.Background()
+
+	uid := uuid.New()
+	insertUserWithRole(t, db, uid, "learner")
+
+	expiresAt := time.Now().Add(time.Hour).Unix()
+	ip, firefox, curl := "10.0.0.1", "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0", "curl/8.5.0"
+	laptop, err := r.InsertRefreshToken(ctx, "laptop-1", uid.String(), expiresAt, &ip, &firefox)
+	if err != nil {
+		t.Fatalf("insert refresh token: %v", err)
+	}
+	script, err := r.InsertRefreshToken(ctx, "script-1", uid.String(), expiresAt, &ip, &curl)
+	if err != nil {
+		t.Fatalf("insert refresh token: %v", err)
+	}
+
+	// Rotation keeps the session and links the chain
+	session, _, err := r.RotateRefreshToken(ctx, "laptop-1", "laptop-2", expiresAt, &ip, &firefox)
+	if err != nil || session.ID != laptop {
+		t.Fatalf("expected rotation to continue session %s, got %+v (%v)", laptop, session, err)
+	}
+	var old, rotated models.RefreshToken
+	db.Where("token_hash = ?", "laptop-1").First(&old)
+	db.Where("token_hash = ?", "laptop-2").First(&rotated)
+	if old.ReplacedBy == nil || *old.ReplacedBy != rotated.ID.String() || old.LastUsedAt == nil {
+		t.Fatalf("expected the old token to link to %s and record its use, got %+v", rotated.ID, old)
+	}
+
+	sessions, err := r.ListSessions(ctx, uid.String())
+	if err != nil {
+		t.Fatalf("list sessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %+v", sessions)
+	}
+	byID := map[string]auth.Session{}
+	for _, s := range sessions {
+		byID[s.ID] = s
+	}
+	if s := byID[laptop]; s.Device != "Firefox on Linux" || s.IP != ip || s.LastUsedAt.Before(s.CreatedAt) {
+		t.Fatalf("unexpected laptop session %+v", s)
+	}
+	if s := byID[script]; s.Device != "curl" {
+		t.Fatalf("unexpected script session %+v", s)
+	}
+
+	if err := r.RevokeSession(ctx, uid.String(), script); err != nil {
+		t.Fatalf("revoke session: %v", err)
+	}
+	if err := r.RevokeSession(ctx, uid.String(), script); err != auth.ErrSessionNotFound {
+		t.Fatalf("expected an ended session to be not found, got %v", err)
+	}
+	if err := r.RevokeSession(ctx, uuid.NewString(), laptop); err != auth.ErrSessionNotFound {
+		t.Fatalf("expected another user's session to be not found, got %v", err)
+	}
+
+	// The ended session's refresh token is invalid, but is not reuse that
+	// would end the other sessions too
+	if _, reused, err := r.RotateRefreshToken(ctx, "script-1", "script-2", expiresAt, &ip, &curl); err != auth.ErrInvalidRefreshToken || reused {
+		t.Fatalf("expected an invalid refresh token, got reused=%v (%v)", reused, err)
+	}
+	if sessions, _ := r.ListSessions(ctx, uid.String()); len(sessions) != 1 || sessions[0].ID != laptop {
+		t.Fatalf("expected the laptop session to stay active, got %+v", sessions)
+	}
+
+	revoked, err := r.RevokeOtherSessions(ctx, uid.String(), laptop)
+	if err != nil || len(revoked) != 0 {
+		t.Fatalf("expected the current session to be kept, got %v (%v)", revoked, err)
+	}
+	revoked, err = r.RevokeOtherSessions(ctx, uid.String(), "")
+	if err != nil || len(revoked) != 1 || revoked[0] != laptop {
+		t.Fatalf("expected the laptop session to be revoked, got %v (%v)", revoked, err)
+	}
+	if sessions, _ := r.ListSessions(ctx, uid.String()); len(sessions) != 0 {
+		t.Fatalf("expected no active sessions, got %+v", sessions)
+	}
+
+	var published int64
+	db.Model(&events.Event{}).Where("type = ?", events.AuthSessionsRevoked).Count(&published)
+	if published != 2 {
+		t.Fatalf("expected 2 %s events, got %d", events.AuthSessionsRevoked, published)
+	}
+}
+
+func TestMFAChallengeAttempts(t *testing.T) {
+	db := setupTestDB(t)
+	r := repositories.NewRepository(db)
+	ctx := context.Background()
+
+	uid := uuid.New()
+	insertUserWithRole(t, db, uid, "admin")
+
+	if err := r.InsertMFAChallenge(ctx, "challenge", uid.String(), false, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("insert challenge: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		challenge, err := r.ClaimMFAAttempt(ctx, "challenge", 3)
+		if err != nil || challenge.UserID != uid.String() || challenge.Role != "admin" {
+			t.Fatalf("attempt %d: unexpected challenge %+v (%v)", i+1, challenge, err)
+		}
+	}
+	if _, err := r.ClaimMFAAttempt(ctx, "challenge", 3); err != auth.ErrInvalidMFAToken {
+		t.Fatalf("expected the challenge to be out of attempts, got %v", err)
+	}
+	if _, err := r.GetMFAChallenge(ctx, "challenge", 3); err != auth.ErrInvalidMFAToken {
+		t.Fatalf("expected the challenge to be out of attempts, got %v", err)
+	}
+
+	// A passed challenge cannot be passed again
+	if err := r.InsertMFAChallenge(ctx, "passed", uid.String(), true, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("insert challenge: %v", err)
 	}
-	if claims.Role != role {
-		t.Fatalf("claims role mismatch: expected %s got %s", role, claims.Role)
+	if challenge, err := r.GetMFAChallenge(ctx, "passed", 3); err != nil || !challenge.Enroll {
+		t.Fatalf("expected an enrollment challenge, got %+v (%v)", challenge, err)
+	}
+	if err := r.ConsumeMFAChallenge(ctx, "passed"); err != nil {
+		t.Fatalf("consume challenge: %v", err)
+	}
+	if err := r.ConsumeMFAChallenge(ctx, "passed"); err != auth.ErrInvalidMFAToken {
+		t.Fatalf("expected a passed challenge to be invalid, got %v", err)
+	}
+
+	if err := r.InsertMFAChallenge(ctx, "expired", uid.String(), false, time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("insert challenge: %v", err)
+	}
+	if _, err := r.ClaimMFAAttempt(ctx, "expired", 3); err != auth.ErrInvalidMFAToken {
+		t.Fatalf("expected an expired challenge to be invalid, got %v", err)
+	}
+	if n, err := r.PurgeExpiredMFAChallenges(ctx, time.Now()); err != nil || n != 1 {
+		t.Fatalf("expected 1 purged challenge, got %d (%v)", n, err)
+	}
+}
+
+func TestMFASecretAndRecoveryCodes(t *testing.T) {
+	db := setupTestDB(t)
+	r := repositories.NewRepository(db)
+	ctx := context.Background()
+
+	uid := uuid.New()
+	insertUserWithRole(t, db, uid, "learner")
+	userID := uid.String()
+
+	if err := r.SavePendingMFASecret(ctx, userID, "first"); err != nil {
+		t.Fatalf("save pending secret: %v", err)
+	}
+	// Starting over replaces the pending secret
+	if err := r.SavePendingMFASecret(ctx, userID, "second"); err != nil {
+		t.Fatalf("save pending secret: %v", err)
+	}
+	if secret, err := r.GetMFASecret(ctx, userID); err != nil || secret.Secret != "second" || secret.EnabledAt != nil {
+		t.Fatalf("expected the second secret pending, got %+v (%v)", secret, err)
+	}
+
+	if err := r.EnableMFA(ctx, userID, 100, []string{"code-1", "code-2"}); err != nil {
+		t.Fatalf("enable MFA: %v", err)
+	}
+	if err := r.SavePendingMFASecret(ctx, userID, "third"); err != auth.ErrMFAAlreadyEnabled {
+		t.Fatalf("expected an enabled secret to be kept, got %v", err)
+	}
+
+	// Time steps only move forward
+	if err := r.UseTOTPStep(ctx, userID, 100); err != auth.ErrInvalidMFACode {
+		t.Fatalf("expected the confirmation's step to be used, got %v", err)
+	}
+	if err := r.UseTOTPStep(ctx, userID, 101); err != nil {
+		t.Fatalf("use step: %v", err)
+	}
+
+	if err := r.UseRecoveryCode(ctx, userID, "code-1"); err != nil {
+		t.Fatalf("use recovery code: %v", err)
+	}
+	if err := r.UseRecoveryCode(ctx, userID, "code-1"); err != auth.ErrInvalidMFACode {
+		t.Fatalf("expected a used recovery code to be rejected, got %v", err)
+	}
+	if n, err := r.CountRecoveryCodes(ctx, userID); err != nil || n != 1 {
+		t.Fatalf("expected 1 recovery code left, got %d (%v)", n, err)
+	}
+	if err := r.ReplaceRecoveryCodes(ctx, userID, []string{"code-3", "code-4", "code-5"}); err != nil {
+		t.Fatalf("replace recovery codes: %v", err)
+	}
+	if err := r.UseRecoveryCode(ctx, userID, "code-2"); err != auth.ErrInvalidMFACode {
+		t.Fatalf("expected a replaced recovery code to be rejected, got %v", err)
+	}
+
+	if err := r.DeleteMFA(ctx, userID, true); err != nil {
+		t.Fatalf("delete MFA: %v", err)
+	}
+	if secret, err := r.GetMFASecret(ctx, userID); err != nil || secret != nil {
+		t.Fatalf("expected no secret, got %+v (%v)", secret, err)
+	}
+	if n, _ := r.CountRecoveryCodes(ctx, userID); n != 0 {
+		t.Fatalf("expected the recovery codes to be deleted, got %d", n)
+	}
+	if err := r.DeleteMFA(ctx, userID, true); err != auth.ErrMFANotEnabled {
+		t.Fatalf("expected nothing to reset, got %v", err)
+	}
+
+	for _, eventType := range []string{events.AuthMFAEnabled, events.AuthMFARecoveryCodeUsed, events.AuthMFARecoveryCodesRegenerated, events.AuthMFAReset} {
+		var published int64
+		db.Model(&events.Event{}).Where("type = ?", eventType).Count(&published)
+		if published != 1 {
+			t.Fatalf("expected 1 %s event, got %d", eventType, published)
+		}
+	}
+}
+
+func TestMFARequiredRoles(t *testing.T) {
+	db := setupTestDB(t)
+	r := repositories.NewRepository(db)
+	ctx := context.Background()
+
+	if roles, err := r.GetMFARequiredRoles(ctx); err != nil || len(roles) != 0 {
+		t.Fatalf("expected no required roles, got %v (%v)", roles, err)
+	}
+	if err := r.SetMFARequiredRoles(ctx, []string{"admin", "user"}); err != nil {
+		t.Fatalf("set roles: %v", err)
+	}
+	if err := r.SetMFARequiredRoles(ctx, []string{"admin"}); err != nil {
+		t.Fatalf("set roles: %v", err)
+	}
+	if roles, err := r.GetMFARequiredRoles(ctx); err != nil || len(roles) != 1 || roles[0] != "admin" {
+		t.Fatalf("expected [admin], got %v (%v)", roles, err)
+	}
+
+	var last events.Event
+	db.Where("type = ?", events.AuthMFAPolicyChanged).Order("occurred_at DESC").First(&last)
+	var payload events.AuthMFAPolicyChangedPayload
+	if err := last.Decode(&payload); err != nil || len(payload.OldRoles) != 2 || len(payload.NewRoles) != 1 {
+		t.Fatalf("unexpected policy change %+v (%v)", payload, err)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	db := setupTestDB(t)
+	r := repositories.NewRepository(db)
+	ctx := context.Background()
+
+	uid := uuid.New()
+	insertUserWithRole(t, db, uid, "teacher")
+	future := time.Now().Add(time.Hour)
+	key, err := r.InsertAPIKey(ctx, auth.APIKey{UserID: uid.String(), Name: "ci", Prefix: "egd_abcdefgh", Scopes: []string{"quiz:write"}, ExpiresAt: &future}, "hash", 2)
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if _, err := r.InsertAPIKey(ctx, auth.APIKey{UserID: uid.String(), Name: "other", Prefix: "egd_ijklmnop", Scopes: []string{"quiz:write"}}, "other", 2); err != nil {
+		t.Fatalf("insert second: %v", err)
+	}
+	if _, err := r.InsertAPIKey(ctx, auth.APIKey{UserID: uid.String(), Name: "third", Prefix: "egd_qrstuvwx"}, "third", 2); err != auth.ErrTooManyAPIKeys {
+		t.Fatalf("over the limit: expected ErrTooManyAPIKeys, got %v", err)
+	}
+
+	principal, err := r.GetAPIKeyByHash(ctx, "hash")
+	if err != nil {
+		t.Fatalf("get by hash: %v", err)
+	}
+	if principal.KeyID != key.ID || principal.UserID != uid.String() || principal.Role != "teacher" || len(principal.Scopes) != 1 || principal.Scopes[0] != "quiz:write" {
+		t.Fatalf("unexpected principal %+v", principal)
+	}
+	if err := r.TouchAPIKey(ctx, key.ID, time.Now()); err != nil {
+		t.Fatalf("touch: %v", err)
+	}
+
+	keys, err := r.ListAPIKeys(ctx, uid.String())
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("list: expected 2 keys, got %d (%v)", len(keys), err)
+	}
+	if all, err := r.ListAPIKeys(ctx, ""); err != nil || len(all) != 2 {
+		t.Fatalf("list all: expected 2 keys, got %d (%v)", len(all), err)
+	}
+
+	// Other users cannot revoke the key; admins, passing no user, can
+	if err := r.RevokeAPIKey(ctx, uuid.NewString(), key.ID); err != auth.ErrAPIKeyNotFound {
+		t.Fatalf("revoke as other user: expected ErrAPIKeyNotFound, got %v", err)
+	}
+	if err := r.RevokeAPIKey(ctx, "", key.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if err := r.RevokeAPIKey(ctx, "", key.ID); err != auth.ErrAPIKeyNotFound {
+		t.Fatalf("revoke twice: expected ErrAPIKeyNotFound, got %v", err)
+	}
+	if _, err := r.GetAPIKeyByHash(ctx, "hash"); err != auth.ErrInvalidAPIKey {
+		t.Fatalf("revoked: expected ErrInvalidAPIKey, got %v", err)
+	}
+
+	// Keys of deleted users stop working
+	if err := db.Table("users").Where("id = ?", uid).Update("deleted_at", time.Now()).Error; err != nil {
+		t.Fatalf("delete user: %v", err)
+	}
+	if _, err := r.GetAPIKeyByHash(ctx, "other"); err != auth.ErrInvalidAPIKey {
+		t.Fatalf("deleted user: expected ErrInvalidAPIKey, got %v", err)
+	}
+	if err := r.RevokeAllAPIKeys(ctx, uid.String()); err != nil {
+		t.Fatalf("revoke all: %v", err)
+	}
+	if keys, err := r.ListAPIKeys(ctx, uid.String()); err != nil || len(keys) != 0 {
+		t.Fatalf("list after revoke all: expected none, got %d (%v)", len(keys), err)
+	}
+
+	if n, err := r.PurgeExpiredAPIKeys(ctx, time.Now().Add(time.Minute)); err != nil || n != 2 {
+		t.Fatalf("purge: expected 2, got %d (%v)", n, err)
 	}
 }
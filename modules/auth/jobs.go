@@ -0,0 +1,57 @@
+package authmodule
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/scheduler"
+)
+
+// Jobs returns the auth module's maintenance jobs.
+func (m *Module) Jobs(cfg config.SchedulerConfig) []scheduler.Job {
+	return []scheduler.Job{
+		{
+			Name:        "auth.purge-expired-tokens",
+			Description: "Delete expired refresh tokens, password reset and email verification tokens, MFA challenges and expired or revoked API keys",
+			Schedule:    scheduler.MustParseCron("15 * * * *"),
+			MaxRetries:  3,
+			Run:         m.purgeExpiredTokens,
+		},
+	}
+}
+
+func (m *Module) purgeExpiredTokens(ctx context.Context) error {
+	now := time.Now()
+	tokens, err := m.repo.PurgeExpiredRefreshTokens(ctx, now)
+	if err != nil {
+		return fmt.Errorf("purge refresh tokens: %w", err)
+	}
+	resets, err := m.repo.PurgeExpiredPasswordResets(ctx, now)
+	if err != nil {
+		return fmt.Errorf("purge password resets: %w", err)
+	}
+	verifications, err := m.repo.PurgeExpiredEmailVerifications(ctx, now)
+	if err != nil {
+		return fmt.Errorf("purge email verifications: %w", err)
+	}
+	challenges, err := m.repo.PurgeExpiredMFAChallenges(ctx, now)
+	if err != nil {
+		return fmt.Errorf("purge MFA challenges: %w", err)
+	}
+	apiKeys, err := m.repo.PurgeExpiredAPIKeys(ctx, now)
+	if err != nil {
+		return fmt.Errorf("purge API keys: %w", err)
+	}
+	logrus.WithFields(logrus.Fields{
+		"refresh_tokens":      tokens,
+		"password_resets":     resets,
+		"email_verifications": verifications,
+		"mfa_challenges":      challenges,
+		"api_keys":            apiKeys,
+	}).Info("Purged expired tokens")
+	return nil
+}
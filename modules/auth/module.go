@@ -1,21 +1,73 @@
 package authmodule
 
 import (
+	"context"
+
 	"egaldeutsch-be/internal/auth"
 	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/mail"
+	"egaldeutsch-be/internal/rbac"
 	"egaldeutsch-be/modules/auth/internal/handlers"
 	"egaldeutsch-be/modules/auth/internal/models"
 )
 
 type Module struct {
-	Handler *handlers.AuthHandler
+	Handler     *handlers.AuthHandler
+	service     auth.AuthService
+	repo        auth.AuthRepo
+	userService handlers.UserService
+	jwtCfg      config.JwtConfig
+	denylist    *auth.Denylist
+	policy      *rbac.Policy
+}
+
+// NewModule creates the auth module. denylist revokes access tokens on
+// logout and account changes; nil disables revocation. policy authorizes the
+// admin routes. throttle locks out
+// password guessing; nil disables it. mailer sends the password reset and
+// email verification links and lockout notices.
+func NewModule(authService auth.AuthService, authRepo auth.AuthRepo, userService handlers.UserService, jwtCfg config.JwtConfig, denylist *auth.Denylist, policy *rbac.Policy, throttle *auth.LoginThrottle, mailer *mail.Sender, verifyCfg config.EmailVerificationConfig) *Module {
+	return &Module{
+		Handler:     handlers.NewAuthHandler(authService, userService, jwtCfg, denylist, policy, throttle, mailer, verifyCfg),
+		service:     authService,
+		repo:        authRepo,
+		userService: userService,
+		jwtCfg:      jwtCfg,
+		denylist:    denylist,
+		policy:      policy,
+	}
+}
+
+// Name returns the module name used in config.yaml.
+func (m *Module) Name() string {
+	return "auth"
+}
+
+// Start is a no-op; the auth module has no background work.
+func (m *Module) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop is a no-op; the auth module has no background work.
+func (m *Module) Stop(ctx context.Context) error {
+	return nil
 }
 
-func NewModule(authService auth.AuthService, userService handlers.UserService, jwtCfg config.JwtConfig) *Module {
-	return &Module{Handler: handlers.NewAuthHandler(authService, userService, jwtCfg)}
+// Health is always healthy; database health is checked by the server.
+func (m *Module) Health(ctx context.Context) error {
+	return nil
 }
 
 // GetModelsForMigration returns module models that should be auto-migrated.
 func (m *Module) GetModelsForMigration() []interface{} {
-	return []interface{}{&models.RefreshToken{}, &models.PasswordReset{}}
+	return []interface{}{
+		&models.RefreshToken{},
+		&models.PasswordReset{},
+		&models.EmailVerification{},
+		&models.MFASecret{},
+		&models.RecoveryCode{},
+		&models.MFAChallenge{},
+		&models.MFARequiredRole{},
+		&models.APIKey{},
+	}
 }
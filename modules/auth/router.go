@@ -1,31 +1,69 @@
 package authmodule
 
 import (
-	"egaldeutsch-be/internal/config"
 	"egaldeutsch-be/internal/middleware"
+	"egaldeutsch-be/internal/rbac"
 
 	"github.com/gin-gonic/gin"
 )
 
 // RegisterRoutes registers both public and protected routes for the auth module.
 // Protected routes (like /me) are registered under the module and are guarded by the
-// provided JWT config via the auth middleware.
-func (m *Module) RegisterRoutes(rg *gin.RouterGroup, jwtCfg config.JwtConfig) {
+// module's JWT config via the auth middleware.
+func (m *Module) RegisterRoutes(rg *gin.RouterGroup) {
+	jwtCfg := m.jwtCfg
 
 	ag := rg.Group("/auth")
 	{
 		ag.POST("/login", m.Handler.Login)
 		ag.POST("/logout", m.Handler.Logout)
 		ag.POST("/refresh", m.Handler.RefreshToken)
-		ag.POST("/forgot-password", m.Handler.ForgotPassword)
+		ag.POST("/forgot-password", middleware.RateLimit(5), m.Handler.ForgotPassword)
 		ag.POST("/reset-password", m.Handler.ResetPassword)
+		ag.POST("/verify-email", m.Handler.VerifyEmail)
+		// second step of a login that needs a second factor
+		ag.POST("/mfa/verify", middleware.RateLimit(20), m.Handler.VerifyMFA)
+		ag.POST("/mfa/enroll", m.Handler.EnrollMFA)
 	}
 
-	// Protected routes inside the module
+	// Protected routes inside the module. API keys are not accepted here, so a
+	// leaked key cannot be used to create more keys or take over the account.
 	agProtected := rg.Group("/auth")
-	agProtected.Use(middleware.AuthMiddleware(jwtCfg))
+	agProtected.Use(middleware.AuthMiddleware(jwtCfg, m.denylist, nil))
 	{
 		agProtected.GET("/me", middleware.RateLimit(10), m.Handler.GetCurrentUser)
+		agProtected.POST("/verify-email/resend", middleware.RateLimit(3), m.Handler.ResendVerificationEmail)
+		agProtected.GET("/sessions", m.Handler.ListSessions)
+		agProtected.DELETE("/sessions", m.Handler.RevokeOtherSessions)
+		agProtected.DELETE("/sessions/:session_id", m.Handler.RevokeSession)
+		agProtected.GET("/mfa", m.Handler.GetMFAStatus)
+		agProtected.POST("/mfa/totp", m.Handler.BeginTOTPEnrollment)
+		agProtected.POST("/mfa/totp/confirm", m.Handler.ConfirmTOTPEnrollment)
+		agProtected.POST("/mfa/recovery-codes", m.Handler.RegenerateRecoveryCodes)
+		agProtected.POST("/mfa/disable", m.Handler.DisableMFA)
+		agProtected.POST("/api-keys", m.Handler.CreateAPIKey)
+		agProtected.GET("/api-keys", m.Handler.ListAPIKeys)
+		agProtected.DELETE("/api-keys/:key_id", m.Handler.RevokeAPIKey)
+	}
+
+	// Management of any user's sessions, two-factor authentication, login
+	// lockout and API keys, and of the two-factor authentication policy
+	admin := rg.Group("/admin")
+	admin.Use(middleware.AuthMiddleware(jwtCfg, m.denylist, nil))
+	{
+		canRead := middleware.RequirePermission(m.policy, rbac.UsersRead)
+		canWrite := middleware.RequirePermission(m.policy, rbac.UsersWrite)
+		admin.GET("/users/:id/sessions", canRead, m.Handler.ListUserSessions)
+		admin.DELETE("/users/:id/sessions", canWrite, m.Handler.RevokeUserSessions)
+		admin.DELETE("/users/:id/sessions/:session_id", canWrite, m.Handler.RevokeUserSession)
+		admin.DELETE("/users/:id/mfa", canWrite, m.Handler.ResetUserMFA)
+		admin.DELETE("/users/:id/lockout", canWrite, m.Handler.UnlockUser)
+		admin.GET("/api-keys", canRead, m.Handler.ListAllAPIKeys)
+		admin.DELETE("/api-keys/:key_id", canWrite, m.Handler.RevokeAnyAPIKey)
+
+		canManage := middleware.RequirePermission(m.policy, rbac.SystemManage)
+		admin.GET("/mfa/policy", canManage, m.Handler.GetMFAPolicy)
+		admin.PUT("/mfa/policy", canManage, m.Handler.SetMFAPolicy)
 	}
 
 }
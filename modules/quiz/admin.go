@@ -0,0 +1,43 @@
+package quiz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin/binding"
+
+	"egaldeutsch-be/modules/quiz/internal/models"
+)
+
+// ErrQuestionExists is returned by CreateQuestion when a question with the
+// same text already exists.
+var ErrQuestionExists = errors.New("question already exists")
+
+// CreateQuestion creates a question outside of an HTTP request, for example
+// when seeding fixtures. The input is validated with the same rules as
+// POST /quiz/questions.
+func (m *Module) CreateQuestion(ctx context.Context, text string, options []string, correctOption int, category string) error {
+	dto := models.CreateQuestionDTO{
+		QuestionText:  text,
+		Options:       options,
+		CorrectOption: correctOption,
+		Category:      category,
+	}
+	if err := binding.Validator.ValidateStruct(&dto); err != nil {
+		return fmt.Errorf("invalid question: %w", err)
+	}
+	if correctOption >= len(options) {
+		return fmt.Errorf("invalid question: correct_option %d is out of range", correctOption)
+	}
+
+	exists, err := m.service.QuestionExists(ctx, text)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrQuestionExists
+	}
+
+	return m.service.CreateQuestion(ctx, dto)
+}
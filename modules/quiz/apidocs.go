@@ -0,0 +1,31 @@
+package quiz
+
+import (
+	"net/http"
+
+	"egaldeutsch-be/internal/openapi"
+	"egaldeutsch-be/internal/rbac"
+	"egaldeutsch-be/modules/quiz/internal/models"
+)
+
+// APIDocs documents the routes of the quiz module.
+func (m *Module) APIDocs(base string) []openapi.Operation {
+	tags := []string{"quiz"}
+	return []openapi.Operation{
+		{
+			Method: http.MethodPost, Path: base + "/quiz/questions", Tags: tags, Permission: rbac.QuizWrite,
+			Summary:     "Create a question",
+			Description: "Needs a verified email address when the email verification policy covers the quiz.",
+			Body:        models.CreateQuestionDTO{},
+			Idempotent:  true,
+			Status:      http.StatusCreated, Response: struct {
+				Message string `json:"message"`
+			}{},
+		},
+		{
+			Method: http.MethodGet, Path: base + "/quiz/questions", Tags: tags,
+			Summary:  "List questions",
+			Response: []models.Question{},
+		},
+	}
+}
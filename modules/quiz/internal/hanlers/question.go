@@ -1,8 +1,10 @@
 package hanlers
 
 import (
+	"egaldeutsch-be/internal/apperr"
 	"egaldeutsch-be/modules/quiz/internal/models"
 	"egaldeutsch-be/modules/quiz/internal/services"
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -20,13 +22,13 @@ func (h *QuestionHandler) CreateQuestion(c *gin.Context) {
 	var req models.CreateQuestionDTO
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(apperr.FromBinding(err))
 		return
 	}
 
-	err := h.service.CreateQuestion(req)
+	err := h.service.CreateQuestion(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = c.Error(fmt.Errorf("create question: %w", err))
 		return
 	}
 
@@ -34,9 +36,9 @@ func (h *QuestionHandler) CreateQuestion(c *gin.Context) {
 }
 
 func (h *QuestionHandler) GetAllQuestions(c *gin.Context) {
-	questions, err := h.service.GetAllQuestions()
+	questions, err := h.service.GetAllQuestions(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = c.Error(fmt.Errorf("list questions: %w", err))
 		return
 	}
 
@@ -0,0 +1,137 @@
+package hanlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/middleware"
+	"egaldeutsch-be/internal/testsupport"
+	"egaldeutsch-be/modules/quiz"
+)
+
+// testEnv is the quiz module behind a router, backed by SQLite and an
+// in-memory Redis for idempotency keys. Users sign in with access tokens
+// issued by the test.
+type testEnv struct {
+	*testsupport.Client
+	cfg *config.Config
+}
+
+// newTestEnv starts a test environment. verifier is the email verification
+// check of the quiz; nil lets every user create questions.
+func newTestEnv(t *testing.T, verifier middleware.EmailVerifier) *testEnv {
+	t.Helper()
+
+	cfg := testsupport.Config()
+	db := testsupport.Database(t)
+	_, rc := testsupport.Redis(t)
+	policy := testsupport.Policy(t, cfg)
+
+	idem := middleware.NewIdempotency(rc, time.Duration(cfg.Idempotency.TTLHours)*time.Hour, int64(cfg.Idempotency.MaxBodyKB)*1024)
+	module := quiz.NewModule(db, cfg.Jwt, idem, nil, nil, policy, verifier)
+	testsupport.Migrate(t, db, module.GetModelsForMigration()...)
+
+	router := testsupport.Router(module.RegisterRoutes)
+	return &testEnv{Client: testsupport.NewClient(router), cfg: cfg}
+}
+
+// token returns an access token of a new user with the given role.
+func (e *testEnv) token(t *testing.T, role string) string {
+	t.Helper()
+	return testsupport.AccessToken(t, e.cfg.Jwt, uuid.NewString(), role)
+}
+
+// post creates a question and returns the response.
+func (e *testEnv) post(t *testing.T, token, idempotencyKey string, question any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := e.NewRequest(t, http.MethodPost, "/api/v1/quiz/questions", token, question)
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	return e.Send(req)
+}
+
+// questions lists the questions.
+func (e *testEnv) questions(t *testing.T) []json.RawMessage {
+	t.Helper()
+
+	var questions []json.RawMessage
+	if status := e.Do(t, http.MethodGet, "/api/v1/quiz/questions", "", nil, &questions); status != http.StatusOK {
+		t.Fatalf("list questions: expected 200, got %d", status)
+	}
+	return questions
+}
+
+func TestCreateQuestionIdempotentRetry(t *testing.T) {
+	env := newTestEnv(t, nil)
+	teacher := env.token(t, "teacher")
+
+	question := map[string]any{
+		"question_text":  "Der, die oder das Buch?",
+		"options":        []string{"der", "die", "das"},
+		"correct_option": 2,
+		"category":       "articles",
+	}
+	for i := range 2 {
+		w := env.post(t, teacher, "3f9c2a4e-retry", question)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("attempt %d: expected 201, got %d", i+1, w.Code)
+		}
+		if replayed := w.Header().Get("Idempotent-Replayed") == "true"; replayed != (i == 1) {
+			t.Fatalf("attempt %d: unexpected Idempotent-Replayed %q", i+1, w.Header().Get("Idempotent-Replayed"))
+		}
+	}
+	if got := len(env.questions(t)); got != 1 {
+		t.Fatalf("expected the retry not to create a second question, got %d", got)
+	}
+
+	other := map[string]any{"question_text": "Something else?", "options": []string{"a", "b"}, "correct_option": 0, "category": "other"}
+	if w := env.post(t, teacher, "3f9c2a4e-retry", other); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a reused key, got %d", w.Code)
+	}
+}
+
+// verifierFunc reports a user as verified when the function returns true.
+type verifierFunc func(userID string) bool
+
+func (f verifierFunc) IsEmailVerified(ctx context.Context, userID string) (bool, error) {
+	return f(userID), nil
+}
+
+func TestCreateQuestionRequiresVerifiedEmail(t *testing.T) {
+	verified := false
+	env := newTestEnv(t, verifierFunc(func(string) bool { return verified }))
+	teacher := env.token(t, "teacher")
+
+	question := map[string]any{
+		"question_text":  "Der, die oder das Haus?",
+		"options":        []string{"der", "die", "das"},
+		"correct_option": 2,
+		"category":       "articles",
+	}
+	if w := env.post(t, "", "", question); w.Code != http.StatusUnauthorized {
+		t.Fatalf("create question anonymously: expected 401, got %d", w.Code)
+	}
+	w := env.post(t, teacher, "", question)
+	var problem apperr.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	if w.Code != http.StatusForbidden || problem.Code != "email_not_verified" {
+		t.Fatalf("create question unverified: expected 403 email_not_verified, got %d %+v", w.Code, problem)
+	}
+
+	verified = true
+	if w := env.post(t, teacher, "", question); w.Code != http.StatusCreated {
+		t.Fatalf("create question verified: expected 201, got %d", w.Code)
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"errors"
 	"fmt"
 
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
 	"egaldeutsch-be/pkg/models"
 )
 
@@ -24,21 +27,32 @@ func (o Options) Value() (driver.Value, error) {
 }
 
 // Scan implements the sql.Scanner interface. This method is called when
-// reading a value from the database. It unmarshals a JSON byte slice into
-// the string slice.
+// reading a value from the database. It unmarshals the JSON value into the
+// string slice. PostgreSQL returns jsonb as bytes, SQLite may return text.
 func (o *Options) Scan(value interface{}) error {
-	bytes, ok := value.([]byte)
-	if !ok {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, o)
+	case string:
+		return json.Unmarshal([]byte(v), o)
+	default:
 		return errors.New(fmt.Sprint("Failed to unmarshal JSONB value:", value))
 	}
+}
 
-	return json.Unmarshal(bytes, o)
+// GormDBDataType stores Options as jsonb on PostgreSQL and as JSON text
+// elsewhere, since other databases do not know the jsonb type.
+func (Options) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	if db.Dialector.Name() == "postgres" {
+		return "jsonb"
+	}
+	return "text"
 }
 
 type Question struct {
 	models.BaseModel
 	QuestionText  string  `json:"question_text" gorm:"not null;size:500"`
-	Options       Options `json:"options" gorm:"type:jsonb;not null"`
+	Options       Options `json:"options" gorm:"not null"`
 	CorrectOption int     `json:"correct_option" gorm:"not null"`
 	Category      string  `json:"category" gorm:"not null;size:100"`
 }
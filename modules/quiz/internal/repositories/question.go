@@ -1,6 +1,9 @@
 package repositories
 
 import (
+	"context"
+
+	"egaldeutsch-be/internal/events"
 	questionModels "egaldeutsch-be/modules/quiz/internal/models"
 
 	"gorm.io/gorm"
@@ -14,12 +17,33 @@ func NewQuestionRepository(db *gorm.DB) *QuestionRepository {
 	return &QuestionRepository{db: db}
 }
 
-func (r *QuestionRepository) Create(question *questionModels.Question) error {
-	return r.db.Create(question).Error
+// Transaction runs fn with a repository bound to a new transaction. The
+// transaction commits when fn returns nil and rolls back otherwise.
+func (r *QuestionRepository) Transaction(ctx context.Context, fn func(tx *QuestionRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&QuestionRepository{db: tx})
+	})
+}
+
+// Publish writes a domain event to the outbox, in the repository's
+// transaction when called inside Transaction.
+func (r *QuestionRepository) Publish(ctx context.Context, eventType string, payload interface{}) error {
+	return events.Publish(r.db.WithContext(ctx), eventType, payload)
+}
+
+func (r *QuestionRepository) Create(ctx context.Context, question *questionModels.Question) error {
+	return r.db.WithContext(ctx).Create(question).Error
+}
+
+// ExistsByText reports whether a question with exactly this text exists.
+func (r *QuestionRepository) ExistsByText(ctx context.Context, text string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&questionModels.Question{}).Where("question_text = ?", text).Count(&count).Error
+	return count > 0, err
 }
 
-func (r *QuestionRepository) GetAll() ([]questionModels.Question, error) {
+func (r *QuestionRepository) GetAll(ctx context.Context) ([]questionModels.Question, error) {
 	var questions []questionModels.Question
-	err := r.db.Find(&questions).Error
+	err := r.db.WithContext(ctx).Find(&questions).Error
 	return questions, err
 }
@@ -1,6 +1,10 @@
 package services
 
 import (
+	"context"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/events"
 	"egaldeutsch-be/modules/quiz/internal/models"
 	"egaldeutsch-be/modules/quiz/internal/repositories"
 )
@@ -13,16 +17,39 @@ func NewQuestionService(repo *repositories.QuestionRepository) *QuestionService
 	return &QuestionService{repo: repo}
 }
 
-func (s *QuestionService) CreateQuestion(question models.CreateQuestionDTO) error {
+// ErrCorrectOptionOutOfRange is returned when correct_option is not the
+// index of one of the options.
+var ErrCorrectOptionOutOfRange = apperr.Validation(apperr.FieldError{
+	Field:   "correct_option",
+	Message: "must be the index of one of the options",
+})
+
+func (s *QuestionService) CreateQuestion(ctx context.Context, question models.CreateQuestionDTO) error {
+	if question.CorrectOption >= len(question.Options) {
+		return ErrCorrectOptionOutOfRange
+	}
 	q := &models.Question{
 		QuestionText:  question.QuestionText,
 		Options:       question.Options,
 		CorrectOption: question.CorrectOption,
 		Category:      question.Category,
 	}
-	return s.repo.Create(q)
+	return s.repo.Transaction(ctx, func(repo *repositories.QuestionRepository) error {
+		if err := repo.Create(ctx, q); err != nil {
+			return err
+		}
+		return repo.Publish(ctx, events.QuizQuestionCreated, events.QuizQuestionCreatedPayload{
+			QuestionID: q.ID.String(),
+			Category:   q.Category,
+		})
+	})
+}
+
+// QuestionExists reports whether a question with the given text exists.
+func (s *QuestionService) QuestionExists(ctx context.Context, text string) (bool, error) {
+	return s.repo.ExistsByText(ctx, text)
 }
 
-func (s *QuestionService) GetAllQuestions() ([]models.Question, error) {
-	return s.repo.GetAll()
+func (s *QuestionService) GetAllQuestions(ctx context.Context) ([]models.Question, error) {
+	return s.repo.GetAll(ctx)
 }
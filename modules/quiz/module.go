@@ -1,6 +1,12 @@
 package quiz
 
 import (
+	"context"
+
+	"egaldeutsch-be/internal/auth"
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/middleware"
+	"egaldeutsch-be/internal/rbac"
 	"egaldeutsch-be/modules/quiz/internal/hanlers"
 	"egaldeutsch-be/modules/quiz/internal/models"
 	"egaldeutsch-be/modules/quiz/internal/repositories"
@@ -10,12 +16,23 @@ import (
 )
 
 type Module struct {
-	handler *hanlers.QuestionHandler
-	service *services.QuestionService
-	repo    *repositories.QuestionRepository
+	handler  *hanlers.QuestionHandler
+	service  *services.QuestionService
+	repo     *repositories.QuestionRepository
+	jwtCfg   config.JwtConfig
+	idem     *middleware.Idempotency
+	denylist *auth.Denylist
+	apiKeys  *auth.APIKeys
+	policy   *rbac.Policy
+	verifier middleware.EmailVerifier
 }
 
-func NewModule(db *gorm.DB) *Module {
+// NewModule creates the quiz module. idem makes question creation safe to
+// retry and apiKeys lets machine clients create questions with an API key;
+// nil disables either. Questions are created by signed-in users whose
+// role has the quiz:write permission under policy; a non-nil verifier also
+// requires a verified email address.
+func NewModule(db *gorm.DB, jwtCfg config.JwtConfig, idem *middleware.Idempotency, denylist *auth.Denylist, apiKeys *auth.APIKeys, policy *rbac.Policy, verifier middleware.EmailVerifier) *Module {
 
 	repo := repositories.NewQuestionRepository(db)
 
@@ -23,7 +40,37 @@ func NewModule(db *gorm.DB) *Module {
 
 	handler := hanlers.NewQuestionHandler(service)
 
-	return &Module{handler: handler, service: service, repo: repo}
+	return &Module{
+		handler:  handler,
+		service:  service,
+		repo:     repo,
+		jwtCfg:   jwtCfg,
+		idem:     idem,
+		denylist: denylist,
+		apiKeys:  apiKeys,
+		policy:   policy,
+		verifier: verifier,
+	}
+}
+
+// Name returns the module name used in config.yaml.
+func (m *Module) Name() string {
+	return "quiz"
+}
+
+// Start is a no-op; the quiz module has no background work.
+func (m *Module) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop is a no-op; the quiz module has no background work.
+func (m *Module) Stop(ctx context.Context) error {
+	return nil
+}
+
+// Health is always healthy; database health is checked by the server.
+func (m *Module) Health(ctx context.Context) error {
+	return nil
 }
 
 func (m *Module) GetModelsForMigration() []interface{} {
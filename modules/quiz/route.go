@@ -1,12 +1,23 @@
 package quiz
 
-import "github.com/gin-gonic/gin"
+import (
+	"egaldeutsch-be/internal/middleware"
+	"egaldeutsch-be/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
 
 func (m *Module) RegisterRoutes(r *gin.RouterGroup) {
 	quizs := r.Group("/quiz")
 
 	{
-		quizs.POST("/questions", m.handler.CreateQuestion)
+		quizs.POST("/questions",
+			middleware.AuthMiddleware(m.jwtCfg, m.denylist, m.apiKeys),
+			middleware.RequirePermission(m.policy, rbac.QuizWrite),
+			middleware.RequireVerifiedEmail(m.verifier),
+			m.idem.Middleware(),
+			m.handler.CreateQuestion,
+		)
 		quizs.GET("/questions", m.handler.GetAllQuestions)
 	}
 }
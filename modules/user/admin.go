@@ -0,0 +1,54 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin/binding"
+
+	"egaldeutsch-be/modules/user/internal/models"
+	"egaldeutsch-be/modules/user/internal/repositories"
+	sharedmodels "egaldeutsch-be/pkg/models"
+)
+
+// ErrUserExists is returned by CreateUser when the email is already registered.
+var ErrUserExists = errors.New("user already exists")
+
+// CreateUser creates a user outside of an HTTP request, for example from the
+// command line. The input is validated with the same rules as POST /users.
+// The operator vouches for the email address, so it counts as verified.
+func (m *Module) CreateUser(ctx context.Context, name, email, password string, role sharedmodels.UserRole) (string, error) {
+	req := &models.CreateUserRequest{
+		Name:     name,
+		Email:    email,
+		Password: password,
+	}
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return "", fmt.Errorf("invalid user: %w", err)
+	}
+
+	if _, err := m.Service.GetByEmail(ctx, email); err == nil {
+		return "", ErrUserExists
+	}
+
+	user, err := m.Service.CreateVerifiedUser(ctx, req, role)
+	if errors.Is(err, repositories.ErrEmailTaken) {
+		return "", ErrUserExists
+	}
+	if err != nil {
+		return "", err
+	}
+	return user.ID.String(), nil
+}
+
+// SetRole changes the role of the user with the given email address.
+func (m *Module) SetRole(ctx context.Context, email string, role sharedmodels.UserRole) error {
+	user, err := m.Service.GetByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("find user %s: %w", email, err)
+	}
+
+	_, err = m.Service.UpdateUser(ctx, user.ID.String(), &models.UpdateUserRequest{Role: role.String()})
+	return err
+}
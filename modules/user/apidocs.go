@@ -0,0 +1,86 @@
+package user
+
+import (
+	"net/http"
+
+	"egaldeutsch-be/internal/openapi"
+	"egaldeutsch-be/internal/rbac"
+	"egaldeutsch-be/modules/user/internal/models"
+)
+
+// APIDocs documents the routes of the user module.
+func (m *Module) APIDocs(base string) []openapi.Operation {
+	tags := []string{"users"}
+	return []openapi.Operation{
+		{
+			Method: http.MethodPost, Path: base + "/users", Tags: tags,
+			Summary:     "Sign up",
+			Description: "New users are learners. The password must satisfy the password policy.",
+			Body:        models.CreateUserRequest{},
+			Idempotent:  true,
+			Status:      http.StatusCreated, Response: struct {
+				User  models.UserResponse `json:"user"`
+				Token string              `json:"token"`
+			}{},
+			Errors: []int{http.StatusConflict},
+		},
+		{
+			Method: http.MethodGet, Path: base + "/users", Tags: tags, Permission: rbac.UsersRead,
+			Summary:  "List users",
+			Params:   models.ListUsersQuery{},
+			Response: openapi.Page[models.UserResponse]{},
+		},
+		{
+			Method: http.MethodGet, Path: base + "/users/me", Tags: tags, Access: openapi.Bearer,
+			Summary:  "Get your user",
+			Response: models.UserResponse{},
+		},
+		{
+			Method: http.MethodPatch, Path: base + "/users/me", Tags: tags, Access: openapi.Bearer,
+			Summary:  "Update your user",
+			Body:     models.UpdateMeRequest{},
+			Response: models.UserResponse{},
+		},
+		{
+			Method: http.MethodDelete, Path: base + "/users/me", Tags: tags, Access: openapi.Bearer,
+			Summary:     "Delete your user",
+			Description: "Needs your current password. Wrong passwords count as failed logins.",
+			Body:        models.DeleteMeRequest{},
+			Status:      http.StatusNoContent,
+			Errors:      []int{http.StatusTooManyRequests},
+		},
+		{
+			Method: http.MethodPost, Path: base + "/users/me/password", Tags: tags, Access: openapi.Bearer,
+			Summary:     "Change your password",
+			Description: "Needs your current password; the new one must satisfy the password policy. Every other session ends, the one making the request stays signed in. Wrong current passwords count as failed logins.",
+			Body:        models.ChangePasswordRequest{},
+			Status:      http.StatusNoContent,
+			Errors:      []int{http.StatusTooManyRequests},
+		},
+		{
+			Method: http.MethodGet, Path: base + "/users/:id", Tags: tags, Access: openapi.Bearer,
+			Summary:     "Get a user",
+			Description: "Your own user, or any with users:read.",
+			Params:      models.UserIDParam{},
+			Response:    models.UserResponse{},
+			Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+		},
+		{
+			Method: http.MethodPut, Path: base + "/users/:id", Tags: tags, Access: openapi.Bearer,
+			Summary:     "Update a user",
+			Description: "Your own user, or any with users:write. Changing the role needs users:write.",
+			Params:      models.UserIDParam{},
+			Body:        models.UpdateUserRequest{},
+			Response:    models.UserResponse{},
+			Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+		},
+		{
+			Method: http.MethodDelete, Path: base + "/users/:id", Tags: tags, Access: openapi.Bearer,
+			Summary:     "Delete a user",
+			Description: "Any user with users:write. Without it, only your own user, confirmed with your current password in a `password` field as on DELETE /users/me.",
+			Params:      models.UserIDParam{},
+			Status:      http.StatusNoContent,
+			Errors:      []int{http.StatusForbidden, http.StatusNotFound, http.StatusTooManyRequests},
+		},
+	}
+}
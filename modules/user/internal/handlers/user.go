@@ -1,120 +1,131 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
 
+	"egaldeutsch-be/internal/apperr"
 	"egaldeutsch-be/internal/auth"
 	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/middleware"
+	"egaldeutsch-be/internal/rbac"
 	"egaldeutsch-be/modules/user/internal/models"
 	"egaldeutsch-be/modules/user/internal/services"
 )
 
+var errRoleChangeForbidden = apperr.Forbidden(apperr.CodeForbidden, "Changing the role needs the users:write permission.")
+
 // UserHandler handles HTTP requests for users
 type UserHandler struct {
 	userService *services.UserService
 	jwtCfg      config.JwtConfig
+	// policy decides who may change roles
+	policy *rbac.Policy
 }
 
 // NewUserHandler creates a new user handler
-func NewUserHandler(userService *services.UserService, jwtCfg config.JwtConfig) *UserHandler {
-	return &UserHandler{userService: userService, jwtCfg: jwtCfg}
+func NewUserHandler(userService *services.UserService, jwtCfg config.JwtConfig, policy *rbac.Policy) *UserHandler {
+	return &UserHandler{userService: userService, jwtCfg: jwtCfg, policy: policy}
 }
 
 // CreateUser handles POST /api/v1/users
 func (h *UserHandler) CreateUser(c *gin.Context) {
 	var req models.CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(apperr.FromBinding(err))
 		return
 	}
 
-	user, err := h.userService.CreateUser(&req)
+	user, err := h.userService.CreateUser(c.Request.Context(), &req)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to create user")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		_ = c.Error(fmt.Errorf("create user: %w", err))
 		return
 	}
 
 	// Generate JWT token (include role so token contains user's role)
 	token, err := auth.CreateAccessTokenFromStrings(user.ID.String(), string(user.Role), h.jwtCfg)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to generate access token")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access token"})
+		_ = c.Error(fmt.Errorf("create access token: %w", err))
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"user": user, "token": token})
+	c.JSON(http.StatusCreated, gin.H{"user": models.NewUserResponse(user), "token": token})
 }
 
 // GetUser handles GET /api/v1/users/:id
 func (h *UserHandler) GetUser(c *gin.Context) {
 	var params models.UserIDParam
 	if err := c.ShouldBindUri(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(apperr.FromBinding(err))
 		return
 	}
 
-	user, err := h.userService.GetUserByID(params.ID)
+	user, err := h.userService.GetUserByID(c.Request.Context(), params.ID)
 	if err != nil {
-		if err.Error() == "user not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-			return
-		}
-		logrus.WithError(err).Error("Failed to get user")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		_ = c.Error(fmt.Errorf("get user: %w", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	c.JSON(http.StatusOK, models.NewUserResponse(user))
 }
 
 // UpdateUser handles PUT /api/v1/users/:id
 func (h *UserHandler) UpdateUser(c *gin.Context) {
 	var params models.UserIDParam
 	if err := c.ShouldBindUri(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(apperr.FromBinding(err))
 		return
 	}
 
 	var req models.UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+	// The owner may update their own user, but not their role
+	if req.Role != "" && !middleware.HasPermission(c, h.policy, rbac.UsersWrite) {
+		_ = c.Error(errRoleChangeForbidden)
 		return
 	}
 
-	user, err := h.userService.UpdateUser(params.ID, &req)
+	user, err := h.userService.UpdateUser(c.Request.Context(), params.ID, &req)
 	if err != nil {
-		if err.Error() == "user not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-			return
-		}
-		logrus.WithError(err).Error("Failed to update user")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		_ = c.Error(fmt.Errorf("update user: %w", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	c.JSON(http.StatusOK, models.NewUserResponse(user))
 }
 
 // DeleteUser handles DELETE /api/v1/users/:id
 func (h *UserHandler) DeleteUser(c *gin.Context) {
 	var params models.UserIDParam
 	if err := c.ShouldBindUri(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(apperr.FromBinding(err))
 		return
 	}
 
-	if err := h.userService.DeleteUser(params.ID); err != nil {
-		if err.Error() == "user not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	// The owner confirms with their password, as on DELETE /users/me, so a
+	// stolen access token alone cannot delete the account
+	if !middleware.HasPermission(c, h.policy, rbac.UsersWrite) {
+		var req models.DeleteMeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			_ = c.Error(apperr.FromBinding(err))
+			return
+		}
+		if err := h.userService.DeleteOwnUser(c.Request.Context(), params.ID, c.ClientIP(), req.Password); err != nil {
+			_ = c.Error(fmt.Errorf("delete user: %w", err))
 			return
 		}
-		logrus.WithError(err).Error("Failed to delete user")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	if err := h.userService.DeleteUser(c.Request.Context(), params.ID); err != nil {
+		_ = c.Error(fmt.Errorf("delete user: %w", err))
 		return
 	}
 
@@ -138,20 +149,92 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 		}
 	}
 
-	users, total, err := h.userService.ListUsers(page, perPage)
+	users, total, err := h.userService.ListUsers(c.Request.Context(), page, perPage)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to list users")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users"})
+		_ = c.Error(fmt.Errorf("list users: %w", err))
 		return
 	}
 
 	totalPages := (int(total) + perPage - 1) / perPage
 
+	items := make([]models.UserResponse, len(users))
+	for i := range users {
+		items[i] = models.NewUserResponse(&users[i])
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"items":       users,
+		"items":       items,
 		"page":        page,
 		"per_page":    perPage,
 		"total_items": total,
 		"total_pages": totalPages,
 	})
 }
+
+// GetMe handles GET /api/v1/users/me
+func (h *UserHandler) GetMe(c *gin.Context) {
+	userID, _ := middleware.GetUserIDFromContext(c)
+	user, err := h.userService.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		_ = c.Error(fmt.Errorf("get user: %w", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewUserResponse(user))
+}
+
+// UpdateMe handles PATCH /api/v1/users/me
+func (h *UserHandler) UpdateMe(c *gin.Context) {
+	var req models.UpdateMeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	userID, _ := middleware.GetUserIDFromContext(c)
+	user, err := h.userService.UpdateUser(c.Request.Context(), userID, &models.UpdateUserRequest{
+		Name:     req.Name,
+		Language: req.Language,
+	})
+	if err != nil {
+		_ = c.Error(fmt.Errorf("update user: %w", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewUserResponse(user))
+}
+
+// DeleteMe handles DELETE /api/v1/users/me
+func (h *UserHandler) DeleteMe(c *gin.Context) {
+	var req models.DeleteMeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	userID, _ := middleware.GetUserIDFromContext(c)
+	if err := h.userService.DeleteOwnUser(c.Request.Context(), userID, c.ClientIP(), req.Password); err != nil {
+		_ = c.Error(fmt.Errorf("delete user: %w", err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ChangePassword handles POST /api/v1/users/me/password. The session making
+// the request stays signed in; every other session of the user ends.
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	userID, _ := middleware.GetUserIDFromContext(c)
+	sessionID := middleware.GetSessionIDFromContext(c)
+	if err := h.userService.ChangePassword(c.Request.Context(), userID, sessionID, c.ClientIP(), req.CurrentPassword, req.NewPassword); err != nil {
+		_ = c.Error(fmt.Errorf("change password: %w", err))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
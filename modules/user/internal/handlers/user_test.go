@@ -0,0 +1,237 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/auth"
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/testsupport"
+	"egaldeutsch-be/modules/user"
+	"egaldeutsch-be/pkg/models"
+)
+
+// testEnv is the user module behind a router, backed by SQLite and an
+// in-memory Redis for the token denylist. Users sign in with access tokens
+// issued by the test, as the auth module is not part of it.
+type testEnv struct {
+	*testsupport.Client
+	cfg   *config.Config
+	users *user.Module
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	cfg := testsupport.Config()
+	db := testsupport.Database(t)
+	_, rc := testsupport.Redis(t)
+	policy := testsupport.Policy(t, cfg)
+
+	denylist := auth.NewDenylist(rc, time.Duration(cfg.Jwt.ExpirationHours)*time.Hour)
+	users := user.NewModule(db, cfg.Jwt, cfg.Password, nil, denylist, nil, nil, policy)
+	testsupport.Migrate(t, db, users.GetModelsForMigration()...)
+
+	router := testsupport.Router(users.RegisterRoutes)
+	return &testEnv{Client: testsupport.NewClient(router), cfg: cfg, users: users}
+}
+
+// createUser creates a user with the password secret123 and returns their ID
+// and an access token.
+func (e *testEnv) createUser(t *testing.T, email string, role models.UserRole) (string, string) {
+	t.Helper()
+
+	id, err := e.users.CreateUser(context.Background(), "Test User", email, "secret123", role)
+	if err != nil {
+		t.Fatalf("create user %s: %v", email, err)
+	}
+	return id, testsupport.AccessToken(t, e.cfg.Jwt, id, string(role))
+}
+
+func TestProblemResponses(t *testing.T) {
+	env := newTestEnv(t)
+	_, admin := env.createUser(t, "admin@example.com", models.UserRoleAdmin)
+
+	var problem apperr.Problem
+	dup := map[string]string{"name": "Again", "email": "admin@example.com", "password": "secret123"}
+	if status := env.Do(t, http.MethodPost, "/api/v1/users", "", dup, &problem); status != http.StatusConflict {
+		t.Fatalf("duplicate email: expected 409, got %d", status)
+	}
+	if problem.Code != "email_taken" || problem.Status != http.StatusConflict || problem.RequestID == "" {
+		t.Fatalf("duplicate email: unexpected problem %+v", problem)
+	}
+
+	problem = apperr.Problem{}
+	invalid := map[string]string{"name": "X", "email": "not-an-email", "password": "secret123"}
+	if status := env.Do(t, http.MethodPost, "/api/v1/users", "", invalid, &problem); status != http.StatusBadRequest {
+		t.Fatalf("invalid user: expected 400, got %d", status)
+	}
+	fields := map[string]string{}
+	for _, f := range problem.Errors {
+		fields[f.Field] = f.Message
+	}
+	if problem.Code != apperr.CodeValidation || fields["name"] != "must be at least 2 characters" || fields["email"] == "" {
+		t.Fatalf("invalid user: unexpected problem %+v", problem)
+	}
+
+	problem = apperr.Problem{}
+	missing := "/api/v1/users/00000000-0000-0000-0000-000000000000"
+	if status := env.Do(t, http.MethodGet, missing, admin, nil, &problem); status != http.StatusNotFound {
+		t.Fatalf("unknown user: expected 404, got %d", status)
+	}
+	if problem.Code != "user_not_found" || problem.Instance != missing {
+		t.Fatalf("unknown user: unexpected problem %+v", problem)
+	}
+}
+
+func TestRoleChangeRevokesAccessTokens(t *testing.T) {
+	env := newTestEnv(t)
+	_, admin := env.createUser(t, "admin@example.com", models.UserRoleAdmin)
+	id, learner := env.createUser(t, "learner@example.com", models.UserRoleLearner)
+
+	// Tokens issued in the millisecond of the change stay valid
+	time.Sleep(2 * time.Millisecond)
+	role := map[string]string{"role": "admin"}
+	if status := env.Do(t, http.MethodPut, "/api/v1/users/"+id, admin, role, nil); status != http.StatusOK {
+		t.Fatalf("change role: expected 200, got %d", status)
+	}
+
+	var problem apperr.Problem
+	if status := env.Do(t, http.MethodGet, "/api/v1/users/me", learner, nil, &problem); status != http.StatusUnauthorized || problem.Code != "token_revoked" {
+		t.Fatalf("me after role change: expected 401 token_revoked, got %d %+v", status, problem)
+	}
+	if status := env.Do(t, http.MethodGet, "/api/v1/users/me", admin, nil, nil); status != http.StatusOK {
+		t.Fatalf("me of the admin: expected 200, got %d", status)
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	env := newTestEnv(t)
+	moderatorID, moderator := env.createUser(t, "moderator@example.com", models.UserRoleModerator)
+	_, admin := env.createUser(t, "admin@example.com", models.UserRoleAdmin)
+
+	// Sign-up ignores a role in the body; everyone starts as a learner
+	var created struct {
+		User struct {
+			ID   string `json:"id"`
+			Role string `json:"role"`
+		} `json:"user"`
+		Token string `json:"token"`
+	}
+	signUpAsAdmin := map[string]string{"name": "Mallory", "email": "learner@example.com", "password": "secret123", "role": "admin"}
+	if status := env.Do(t, http.MethodPost, "/api/v1/users", "", signUpAsAdmin, &created); status != http.StatusCreated {
+		t.Fatalf("sign up with a role: expected 201, got %d", status)
+	}
+	if created.User.Role != "learner" {
+		t.Fatalf("expected the user who asked for admin to be a learner, got %q", created.User.Role)
+	}
+	learner := created.Token
+
+	user := "/api/v1/users/" + created.User.ID
+	rename := map[string]string{"name": "Renamed"}
+	for _, c := range []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   any
+		want   int
+	}{
+		{"learner reads own user", http.MethodGet, user, learner, nil, http.StatusOK},
+		{"learner reads other user", http.MethodGet, "/api/v1/users/" + moderatorID, learner, nil, http.StatusForbidden},
+		{"learner changes own role", http.MethodPut, user, learner, map[string]string{"role": "admin"}, http.StatusForbidden},
+		{"learner lists users", http.MethodGet, "/api/v1/users", learner, nil, http.StatusForbidden},
+		{"moderator lists users", http.MethodGet, "/api/v1/users", moderator, nil, http.StatusOK},
+		{"moderator reads user", http.MethodGet, user, moderator, nil, http.StatusOK},
+		{"moderator updates user", http.MethodPut, user, moderator, rename, http.StatusForbidden},
+		{"admin updates user", http.MethodPut, user, admin, rename, http.StatusOK},
+	} {
+		if status := env.Do(t, c.method, c.path, c.token, c.body, nil); status != c.want {
+			t.Fatalf("%s: expected %d, got %d", c.name, c.want, status)
+		}
+	}
+}
+
+func TestSelfService(t *testing.T) {
+	env := newTestEnv(t)
+	_, token := env.createUser(t, "learner@example.com", models.UserRoleLearner)
+
+	// Responses never carry the password hash
+	var me map[string]any
+	if status := env.Do(t, http.MethodGet, "/api/v1/users/me", token, nil, &me); status != http.StatusOK {
+		t.Fatalf("get me: expected 200, got %d", status)
+	}
+	if _, ok := me["password"]; ok || me["email"] != "learner@example.com" {
+		t.Fatalf("unexpected user %v", me)
+	}
+
+	var updated map[string]any
+	patch := map[string]string{"name": "Lena Lernt", "language": "en"}
+	if status := env.Do(t, http.MethodPatch, "/api/v1/users/me", token, patch, &updated); status != http.StatusOK {
+		t.Fatalf("update me: expected 200, got %d", status)
+	}
+	if updated["name"] != "Lena Lernt" || updated["language"] != "en" || updated["role"] != "learner" {
+		t.Fatalf("unexpected update %v", updated)
+	}
+
+	var problem apperr.Problem
+	change := map[string]string{"current_password": "wrong-password", "new_password": "Neues-Passwort-7"}
+	if status := env.Do(t, http.MethodPost, "/api/v1/users/me/password", token, change, &problem); status != http.StatusBadRequest || len(problem.Errors) != 1 || problem.Errors[0].Field != "current_password" {
+		t.Fatalf("wrong current password: expected 400 on current_password, got %d %+v", status, problem)
+	}
+	change["current_password"] = "secret123"
+	if status := env.Do(t, http.MethodPost, "/api/v1/users/me/password", token, change, nil); status != http.StatusNoContent {
+		t.Fatalf("change password: expected 204, got %d", status)
+	}
+	// The token the password was changed with stays valid
+	if status := env.Do(t, http.MethodGet, "/api/v1/users/me", token, nil, nil); status != http.StatusOK {
+		t.Fatalf("me after password change: expected 200, got %d", status)
+	}
+
+	remove := map[string]string{"password": "secret123"}
+	if status := env.Do(t, http.MethodDelete, "/api/v1/users/me", token, remove, nil); status != http.StatusBadRequest {
+		t.Fatalf("delete me with the old password: expected 400, got %d", status)
+	}
+	remove["password"] = "Neues-Passwort-7"
+	if status := env.Do(t, http.MethodDelete, "/api/v1/users/me", token, remove, nil); status != http.StatusNoContent {
+		t.Fatalf("delete me: expected 204, got %d", status)
+	}
+	if _, err := env.users.Service.GetByEmail(context.Background(), "learner@example.com"); err == nil {
+		t.Fatalf("expected the deleted user to be gone")
+	}
+}
+
+func TestDeleteUserAsOwnerNeedsPassword(t *testing.T) {
+	env := newTestEnv(t)
+	id, learner := env.createUser(t, "learner@example.com", models.UserRoleLearner)
+	otherID, _ := env.createUser(t, "other@example.com", models.UserRoleLearner)
+	_, admin := env.createUser(t, "admin@example.com", models.UserRoleAdmin)
+
+	// Owners without users:write confirm with their password, as on /users/me
+	path := "/api/v1/users/" + id
+	if status := env.Do(t, http.MethodDelete, path, learner, nil, nil); status != http.StatusBadRequest {
+		t.Fatalf("delete own user without a password: expected 400, got %d", status)
+	}
+	var problem apperr.Problem
+	wrong := map[string]string{"password": "wrong-password"}
+	if status := env.Do(t, http.MethodDelete, path, learner, wrong, &problem); status != http.StatusBadRequest || len(problem.Errors) != 1 || problem.Errors[0].Field != "password" {
+		t.Fatalf("delete own user with a wrong password: expected 400 on password, got %d %+v", status, problem)
+	}
+	if _, err := env.users.Service.GetByEmail(context.Background(), "learner@example.com"); err != nil {
+		t.Fatalf("expected the user to remain: %v", err)
+	}
+	if status := env.Do(t, http.MethodDelete, path, learner, map[string]string{"password": "secret123"}, nil); status != http.StatusNoContent {
+		t.Fatalf("delete own user with the password: expected 204, got %d", status)
+	}
+	if _, err := env.users.Service.GetByEmail(context.Background(), "learner@example.com"); err == nil {
+		t.Fatalf("expected the deleted user to be gone")
+	}
+
+	// users:write deletes any user without a password
+	if status := env.Do(t, http.MethodDelete, "/api/v1/users/"+otherID, admin, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("admin deletes user: expected 204, got %d", status)
+	}
+}
@@ -1,18 +1,70 @@
 package models
 
-// CreateUserRequest represents the request payload for creating a user
+import (
+	"time"
+
+	"egaldeutsch-be/pkg/models"
+)
+
+// CreateUserRequest represents the request payload for creating a user. New
+// users are learners; only users:write or an operator changes the role.
 type CreateUserRequest struct {
 	Name     string `json:"name" binding:"required,min=2,max=100"`
-	Role     string `json:"role" binding:"omitempty,oneof=admin user"`
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"`
+	Language string `json:"language" binding:"omitempty,oneof=de en"`
 }
 
-// UpdateUserRequest represents the request payload for updating a user
+// UpdateUserRequest represents the request payload for updating a user.
+// Changing the role needs users:write.
 type UpdateUserRequest struct {
 	Name     string `json:"name,omitempty" binding:"omitempty,min=2,max=100"`
-	Role     string `json:"role,omitempty" binding:"omitempty,oneof=admin user"`
-	Password string `json:"password,omitempty" binding:"omitempty,min=6"`
+	Role     string `json:"role,omitempty" binding:"omitempty,oneof=learner teacher moderator admin user"`
+	Language string `json:"language,omitempty" binding:"omitempty,oneof=de en"`
+}
+
+// UpdateMeRequest represents the request payload for updating your own user
+type UpdateMeRequest struct {
+	Name     string `json:"name,omitempty" binding:"omitempty,min=2,max=100"`
+	Language string `json:"language,omitempty" binding:"omitempty,oneof=de en"`
+}
+
+// ChangePasswordRequest represents the request payload for changing your own password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// DeleteMeRequest represents the request payload for deleting your own user
+type DeleteMeRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// UserResponse is a user as the API returns it
+type UserResponse struct {
+	ID       string          `json:"id"`
+	Email    string          `json:"email"`
+	Name     string          `json:"name"`
+	Role     models.UserRole `json:"role"`
+	Language string          `json:"language"`
+	// EmailVerifiedAt is nil until the user verified their email address
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// NewUserResponse returns the public fields of user.
+func NewUserResponse(user *User) UserResponse {
+	return UserResponse{
+		ID:              user.ID.String(),
+		Email:           user.Email,
+		Name:            user.Name,
+		Role:            user.Role,
+		Language:        user.Language,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+	}
 }
 
 // UserIDParam represents the URI parameter for user ID
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"egaldeutsch-be/pkg/models"
 )
 
@@ -8,9 +10,13 @@ import (
 type User struct {
 	models.BaseModel
 	Email    string          `json:"email" gorm:"not null;uniqueIndex;size:100"`
-	Password string          `json:"password" gorm:"not null;size:255"`
+	Password string          `json:"-" gorm:"not null;size:255"`
 	Name     string          `json:"name" gorm:"not null;size:100"`
-	Role     models.UserRole `json:"role" gorm:"omit;type:varchar(20);check:role IN ('admin', 'user')"`
+	Role     models.UserRole `json:"role" gorm:"omit;type:varchar(20);check:role IN ('learner', 'teacher', 'moderator', 'admin')"`
+	// Language selects the language of emails to the user
+	Language string `json:"language" gorm:"not null;size:5;default:'de'"`
+	// EmailVerifiedAt is nil until the user opens the link of a verification email
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
 }
 
 // TableName specifies the table name for the User model
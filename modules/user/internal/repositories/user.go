@@ -1,16 +1,21 @@
 package repositories
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
 
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/events"
 	usermodels "egaldeutsch-be/modules/user/internal/models"
 	"egaldeutsch-be/pkg/models"
 )
 
 var (
-	ErrUserNotFound = errors.New("user not found")
+	ErrUserNotFound = apperr.NotFound("user_not_found", "User not found.")
+	ErrEmailTaken   = apperr.Conflict("email_taken", "A user with this email address already exists.")
 )
 
 // UserRepository handles database operations for users
@@ -23,15 +28,33 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+// Transaction runs fn with a repository bound to a new transaction. The
+// transaction commits when fn returns nil and rolls back otherwise.
+func (r *UserRepository) Transaction(ctx context.Context, fn func(tx *UserRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&UserRepository{db: tx})
+	})
+}
+
+// Publish writes a domain event to the outbox, in the repository's
+// transaction when called inside Transaction.
+func (r *UserRepository) Publish(ctx context.Context, eventType string, payload interface{}) error {
+	return events.Publish(r.db.WithContext(ctx), eventType, payload)
+}
+
 // Create creates a new user in the database
-func (r *UserRepository) Create(user *usermodels.User) error {
-	return r.db.Create(user).Error
+func (r *UserRepository) Create(ctx context.Context, user *usermodels.User) error {
+	err := r.db.WithContext(ctx).Create(user).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrEmailTaken.Wrap(err)
+	}
+	return err
 }
 
 // GetByID retrieves a user by ID
-func (r *UserRepository) GetByID(id string) (*usermodels.User, error) {
+func (r *UserRepository) GetByID(ctx context.Context, id string) (*usermodels.User, error) {
 	var user usermodels.User
-	err := r.db.Where("id = ?", id).First(&user).Error
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrUserNotFound
@@ -42,9 +65,9 @@ func (r *UserRepository) GetByID(id string) (*usermodels.User, error) {
 }
 
 // GetByEmail retrieves a user by email
-func (r *UserRepository) GetByEmail(email string) (*usermodels.User, error) {
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*usermodels.User, error) {
 	var user usermodels.User
-	err := r.db.Where("email = ?", email).First(&user).Error
+	err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrUserNotFound
@@ -55,28 +78,50 @@ func (r *UserRepository) GetByEmail(email string) (*usermodels.User, error) {
 }
 
 // Update updates an existing user
-func (r *UserRepository) Update(user *usermodels.User) error {
-	return r.db.Save(user).Error
+func (r *UserRepository) Update(ctx context.Context, user *usermodels.User) error {
+	return r.db.WithContext(ctx).Save(user).Error
+}
+
+// ReplacePasswordHash replaces the password hash of a user if it is still
+// oldHash, so that rehashing at login cannot undo a concurrent password change.
+func (r *UserRepository) ReplacePasswordHash(ctx context.Context, id string, oldHash string, newHash string) error {
+	return r.db.WithContext(ctx).Model(&usermodels.User{}).
+		Where("id = ? AND password = ?", id, oldHash).
+		Update("password", newHash).Error
 }
 
 // Delete soft deletes a user by ID
-func (r *UserRepository) Delete(id string) error {
-	return r.db.Where("id = ?", id).Delete(&usermodels.User{}).Error
+func (r *UserRepository) Delete(ctx context.Context, id string) error {
+	res := r.db.WithContext(ctx).Where("id = ?", id).Delete(&usermodels.User{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// PurgeDeleted permanently removes users soft-deleted before the given time.
+// Their tokens and rooms go with them through ON DELETE CASCADE.
+func (r *UserRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", before).Delete(&usermodels.User{})
+	return res.RowsAffected, res.Error
 }
 
 // List retrieves users with pagination
-func (r *UserRepository) List(page, perPage int) ([]usermodels.User, int64, error) {
+func (r *UserRepository) List(ctx context.Context, page, perPage int) ([]usermodels.User, int64, error) {
 	var users []usermodels.User
 	var total int64
 
 	// Count total records
-	if err := r.db.Model(&usermodels.User{}).Count(&total).Error; err != nil {
+	if err := r.db.WithContext(ctx).Model(&usermodels.User{}).Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
 	// Get paginated results
 	offset := (page - 1) * perPage
-	err := r.db.Offset(offset).Limit(perPage).Find(&users).Error
+	err := r.db.WithContext(ctx).Offset(offset).Limit(perPage).Find(&users).Error
 	if err != nil {
 		return nil, 0, err
 	}
@@ -85,8 +130,8 @@ func (r *UserRepository) List(page, perPage int) ([]usermodels.User, int64, erro
 }
 
 // GetByRole retrieves users by role
-func (r *UserRepository) GetByRole(role models.UserRole) ([]usermodels.User, error) {
+func (r *UserRepository) GetByRole(ctx context.Context, role models.UserRole) ([]usermodels.User, error) {
 	var users []usermodels.User
-	err := r.db.Where("role = ?", role).Find(&users).Error
+	err := r.db.WithContext(ctx).Where("role = ?", role).Find(&users).Error
 	return users, err
 }
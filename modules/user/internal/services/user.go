@@ -1,53 +1,126 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/auth"
+	"egaldeutsch-be/internal/events"
+	"egaldeutsch-be/internal/logging"
+	"egaldeutsch-be/internal/password"
 	usermodels "egaldeutsch-be/modules/user/internal/models"
 	"egaldeutsch-be/modules/user/internal/repositories"
 	sharedmodels "egaldeutsch-be/pkg/models"
 )
 
 var (
-	ErrInvalidUserRole = errors.New("invalid user role")
-	ErrUserValidation  = errors.New("user validation failed")
+	ErrInvalidUserRole = apperr.Validation(apperr.FieldError{Field: "role", Message: "must be one of: learner, teacher, moderator, admin"})
+	ErrInvalidUserID   = apperr.Validation(apperr.FieldError{Field: "id", Message: "must be a UUID"})
 )
 
 // UserService handles business logic for users
 type UserService struct {
 	repo *repositories.UserRepository
+	// denylist revokes a user's access tokens when their password, role or
+	// account changes; nil leaves it to the auth module's event subscriber
+	denylist *auth.Denylist
+	// throttle counts wrong current passwords like failed logins, so a
+	// stolen access token cannot be used to guess the password; nil
+	// throttles nothing
+	throttle  *auth.LoginThrottle
+	passwords password.PasswordHasher
+	policy    *password.Policy
+	// dummyHash is verified against when no user has the email, so an
+	// unknown email takes as long to reject as a wrong password
+	dummyHash func() string
 }
 
-// NewUserService creates a new user service
-func NewUserService(repo *repositories.UserRepository) *UserService {
-	return &UserService{repo: repo}
+// NewUserService creates a new user service. passwords hashes and verifies
+// passwords; policy decides which new ones are accepted.
+func NewUserService(repo *repositories.UserRepository, denylist *auth.Denylist, throttle *auth.LoginThrottle, passwords password.PasswordHasher, policy *password.Policy) *UserService {
+	return &UserService{
+		repo:      repo,
+		denylist:  denylist,
+		throttle:  throttle,
+		passwords: passwords,
+		policy:    policy,
+		dummyHash: sync.OnceValue(func() string {
+			hash, _ := passwords.Hash("dummy password")
+			return hash
+		}),
+	}
+}
+
+// revokeAccessTokens revokes the tokens issued to the user so far. A failure
+// is only logged: the event published with the change revokes them as well.
+func (s *UserService) revokeAccessTokens(ctx context.Context, userID string) {
+	if err := s.denylist.RevokeUser(ctx, userID, time.Now()); err != nil {
+		logging.FromContext(ctx).WithError(err).WithField("target_user_id", userID).Error("failed to revoke access tokens")
+	}
+}
+
+// CreateUser creates a new learner with validation. The email address is
+// unverified until the user opens the link of the verification email.
+func (s *UserService) CreateUser(ctx context.Context, req *usermodels.CreateUserRequest) (*usermodels.User, error) {
+	return s.createUser(ctx, req, sharedmodels.UserRoleLearner, nil)
+}
+
+// CreateVerifiedUser creates a user with the given role whose email address
+// counts as verified, for accounts set up by an operator.
+func (s *UserService) CreateVerifiedUser(ctx context.Context, req *usermodels.CreateUserRequest, role sharedmodels.UserRole) (*usermodels.User, error) {
+	now := time.Now()
+	return s.createUser(ctx, req, role, &now)
 }
 
-// CreateUser creates a new user with validation
-func (s *UserService) CreateUser(req *usermodels.CreateUserRequest) (*usermodels.User, error) {
+func (s *UserService) createUser(ctx context.Context, req *usermodels.CreateUserRequest, role sharedmodels.UserRole, verifiedAt *time.Time) (*usermodels.User, error) {
 	// Extract fields from request
 	name := req.Name
 	email := req.Email
-	password, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err := s.policy.Check(ctx, req.Password, name, email); err != nil {
+		return nil, err
+	}
+	passwordHash, err := s.passwords.Hash(req.Password)
 	if err != nil {
 		return nil, err
 	}
-	role := req.Role
-	if role == "" {
-		role = "user"
+	// "user" is the former name of learner
+	role = role.Canonical()
+	if !role.IsValid() {
+		return nil, ErrInvalidUserRole
+	}
+	language := req.Language
+	if language == "" {
+		language = "de"
 	}
 
 	user := &usermodels.User{
-		Name:     name,
-		Email:    email,
-		Password: string(password),
-		Role:     sharedmodels.UserRole(role),
+		Name:            name,
+		Email:           email,
+		Password:        passwordHash,
+		Role:            role,
+		Language:        language,
+		EmailVerifiedAt: verifiedAt,
 	}
 
-	if err := s.repo.Create(user); err != nil {
+	err = s.repo.Transaction(ctx, func(repo *repositories.UserRepository) error {
+		if err := repo.Create(ctx, user); err != nil {
+			return err
+		}
+		return repo.Publish(ctx, events.UserCreated, events.UserCreatedPayload{
+			UserID: user.ID.String(),
+			Email:  user.Email,
+			Name:   user.Name,
+			Role:   user.Role.String(),
+		})
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -55,29 +128,33 @@ func (s *UserService) CreateUser(req *usermodels.CreateUserRequest) (*usermodels
 }
 
 // GetUserByID retrieves a user by ID
-func (s *UserService) GetUserByID(id string) (*usermodels.User, error) {
+func (s *UserService) GetUserByID(ctx context.Context, id string) (*usermodels.User, error) {
 	// Validate UUID format
 	if _, err := uuid.Parse(id); err != nil {
-		return nil, errors.New("invalid user ID format")
+		return nil, ErrInvalidUserID
 	}
 
-	return s.repo.GetByID(id)
+	return s.repo.GetByID(ctx, id)
 }
 
 // UpdateUser updates an existing user
-func (s *UserService) UpdateUser(id string, updates *usermodels.UpdateUserRequest) (*usermodels.User, error) {
+func (s *UserService) UpdateUser(ctx context.Context, id string, updates *usermodels.UpdateUserRequest) (*usermodels.User, error) {
 	// Get existing user
-	user, err := s.GetUserByID(id)
+	user, err := s.GetUserByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
 	// Apply updates
+	oldRole := user.Role
 	if updates.Name != "" {
 		user.Name = updates.Name
 	}
+	if updates.Language != "" {
+		user.Language = updates.Language
+	}
 	if updates.Role != "" {
-		role := sharedmodels.UserRole(updates.Role)
+		role := sharedmodels.UserRole(updates.Role).Canonical()
 		if !role.IsValid() {
 			return nil, ErrInvalidUserRole
 		}
@@ -85,25 +162,65 @@ func (s *UserService) UpdateUser(id string, updates *usermodels.UpdateUserReques
 	}
 
 	// Save updates
-	if err := s.repo.Update(user); err != nil {
+	err = s.repo.Transaction(ctx, func(repo *repositories.UserRepository) error {
+		if err := repo.Update(ctx, user); err != nil {
+			return err
+		}
+		if user.Role == oldRole {
+			return nil
+		}
+		return repo.Publish(ctx, events.UserRoleChanged, events.UserRoleChangedPayload{
+			UserID:  user.ID.String(),
+			OldRole: oldRole.String(),
+			NewRole: user.Role.String(),
+		})
+	})
+	if err != nil {
 		return nil, err
 	}
+	// Tokens carry the role, so tokens with the old one must go
+	if user.Role != oldRole {
+		s.revokeAccessTokens(ctx, user.ID.String())
+	}
 
 	return user, nil
 }
 
 // DeleteUser soft deletes a user
-func (s *UserService) DeleteUser(id string) error {
+func (s *UserService) DeleteUser(ctx context.Context, id string) error {
 	// Validate UUID format
 	if _, err := uuid.Parse(id); err != nil {
-		return errors.New("invalid user ID format")
+		return ErrInvalidUserID
 	}
 
-	return s.repo.Delete(id)
+	err := s.repo.Transaction(ctx, func(repo *repositories.UserRepository) error {
+		user, err := repo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := repo.Delete(ctx, id); err != nil {
+			return err
+		}
+		return repo.Publish(ctx, events.UserDeleted, events.UserDeletedPayload{
+			UserID: id,
+			Email:  user.Email,
+			Role:   user.Role.String(),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	s.revokeAccessTokens(ctx, id)
+	return nil
+}
+
+// PurgeDeletedUsers permanently removes users soft-deleted before the given time.
+func (s *UserService) PurgeDeletedUsers(ctx context.Context, before time.Time) (int64, error) {
+	return s.repo.PurgeDeleted(ctx, before)
 }
 
 // ListUsers retrieves users with pagination
-func (s *UserService) ListUsers(page, perPage int) ([]usermodels.User, int64, error) {
+func (s *UserService) ListUsers(ctx context.Context, page, perPage int) ([]usermodels.User, int64, error) {
 	if page <= 0 {
 		page = 1
 	}
@@ -111,71 +228,274 @@ func (s *UserService) ListUsers(page, perPage int) ([]usermodels.User, int64, er
 		perPage = 10
 	}
 
-	return s.repo.List(page, perPage)
+	return s.repo.List(ctx, page, perPage)
 }
 
 // GetUsersByRole retrieves users by role
-func (s *UserService) GetUsersByRole(role sharedmodels.UserRole) ([]usermodels.User, error) {
+func (s *UserService) GetUsersByRole(ctx context.Context, role sharedmodels.UserRole) ([]usermodels.User, error) {
+	role = role.Canonical()
 	if !role.IsValid() {
 		return nil, ErrInvalidUserRole
 	}
 
-	return s.repo.GetByRole(role)
+	return s.repo.GetByRole(ctx, role)
 }
 
-// AuthenticateUser authenticates by email and password and returns the userId and role
-func (s *UserService) AuthenticateUser(email, password string) (string, sharedmodels.UserRole, error) {
-	user, err := s.repo.GetByEmail(email)
-
+// AuthenticateUser authenticates by email and password and returns the userId
+// and role. A password hashed with another algorithm or other parameters than
+// the configured ones is hashed again.
+func (s *UserService) AuthenticateUser(ctx context.Context, email, password string) (string, sharedmodels.UserRole, error) {
+	user, err := s.repo.GetByEmail(ctx, email)
+	if errors.Is(err, repositories.ErrUserNotFound) {
+		_, _ = s.passwords.Verify(s.dummyHash(), password)
+		return "", "", auth.ErrInvalidCredentials
+	}
 	if err != nil {
 		return "", "", err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		return "", "", errors.New("invalid credentials")
+	ok, err := s.passwords.Verify(user.Password, password)
+	if err != nil {
+		return "", "", fmt.Errorf("verify password: %w", err)
+	}
+	if !ok {
+		return "", "", auth.ErrInvalidCredentials
 	}
 
+	if s.passwords.NeedsRehash(user.Password) {
+		s.rehashPassword(ctx, user, password)
+	}
 	return user.ID.String(), user.Role, nil
 }
 
+// rehashPassword replaces an outdated hash once the user has given the
+// password. A failure is only logged; the old hash keeps working.
+func (s *UserService) rehashPassword(ctx context.Context, user *usermodels.User, password string) {
+	log := logging.FromContext(ctx).WithField("target_user_id", user.ID.String())
+	hash, err := s.passwords.Hash(password)
+	if err == nil {
+		err = s.repo.ReplacePasswordHash(ctx, user.ID.String(), user.Password, hash)
+	}
+	if err != nil {
+		log.WithError(err).Error("failed to rehash password")
+		return
+	}
+	log.Info("rehashed password with the configured algorithm")
+}
+
+// CheckPassword checks a new password of a user against the password policy.
+func (s *UserService) CheckPassword(ctx context.Context, userID string, newPassword string) error {
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.policy.Check(ctx, newPassword, user.Name, user.Email)
+}
+
 // GetByEmail retrieves a user by email (public wrapper)
-func (s *UserService) GetByEmail(email string) (*usermodels.User, error) {
-	return s.repo.GetByEmail(email)
+func (s *UserService) GetByEmail(ctx context.Context, email string) (*usermodels.User, error) {
+	return s.repo.GetByEmail(ctx, email)
 }
 
 // GetUserIDByEmail returns user ID (string) by email
-func (s *UserService) GetUserIDByEmail(email string) (string, error) {
-	u, err := s.repo.GetByEmail(email)
+func (s *UserService) GetUserIDByEmail(ctx context.Context, email string) (string, error) {
+	u, err := s.repo.GetByEmail(ctx, email)
 	if err != nil {
 		return "", err
 	}
 	return u.ID.String(), nil
 }
 
-// UpdatePassword updates the user's password with a bcrypt hash
-func (s *UserService) UpdatePassword(userID string, newPassword string) error {
-	user, err := s.GetUserByID(userID)
+// UpdatePassword checks the new password against the password policy and
+// stores its hash
+func (s *UserService) UpdatePassword(ctx context.Context, userID string, newPassword string) error {
+	user, err := s.GetUserByID(ctx, userID)
 	if err != nil {
 		return err
 	}
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err := s.setPassword(ctx, user, newPassword); err != nil {
+		return err
+	}
+	return s.repo.Update(ctx, user)
+}
+
+// setPassword sets the hash of a new password on user, if the password
+// policy accepts it.
+func (s *UserService) setPassword(ctx context.Context, user *usermodels.User, newPassword string) error {
+	if err := s.policy.Check(ctx, newPassword, user.Name, user.Email); err != nil {
+		return err
+	}
+	passwordHash, err := s.passwords.Hash(newPassword)
 	if err != nil {
 		return err
 	}
-	user.Password = string(passwordHash)
-	return s.repo.Update(user)
+	user.Password = passwordHash
+	return nil
+}
+
+// ChangePassword replaces the password of a user who gave their current
+// one and publishes user.password_changed in the same transaction, which
+// ends every session of the user but sessionID.
+func (s *UserService) ChangePassword(ctx context.Context, userID string, sessionID string, ip string, currentPassword string, newPassword string) error {
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.verifyPassword(ctx, user, currentPassword, "current_password", ip); err != nil {
+		return err
+	}
+	if err := s.setPassword(ctx, user, newPassword); err != nil {
+		return err
+	}
+
+	return s.repo.Transaction(ctx, func(repo *repositories.UserRepository) error {
+		if err := repo.Update(ctx, user); err != nil {
+			return err
+		}
+		return repo.Publish(ctx, events.UserPasswordChanged, events.UserPasswordChangedPayload{
+			UserID:    userID,
+			SessionID: sessionID,
+		})
+	})
+}
+
+// DeleteOwnUser deletes the account of a user who confirmed it with their
+// password.
+func (s *UserService) DeleteOwnUser(ctx context.Context, userID string, ip string, password string) error {
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.verifyPassword(ctx, user, password, "password", ip); err != nil {
+		return err
+	}
+	return s.DeleteUser(ctx, userID)
+}
+
+// verifyPassword returns a validation error on field unless password is the
+// password of user. Attempts from ip go through the login throttle: a wrong
+// password counts as a failed login, and a throttled account or IP gets
+// auth.ErrTooManyLoginAttempts without the password being checked. Redis
+// failures are only logged, as they are for logins.
+func (s *UserService) verifyPassword(ctx context.Context, user *usermodels.User, password string, field string, ip string) error {
+	log := logging.FromContext(ctx).WithField("target_user_id", user.ID.String())
+	wait, err := s.throttle.Check(ctx, user.Email, ip)
+	if err != nil {
+		log.WithError(err).Error("failed to check login throttle")
+	}
+	if wait > 0 {
+		return auth.ErrTooManyLoginAttempts
+	}
+
+	ok, err := s.passwords.Verify(user.Password, password)
+	if err != nil {
+		return fmt.Errorf("verify password: %w", err)
+	}
+	if !ok {
+		s.recordWrongPassword(ctx, user, ip)
+		return apperr.Validation(apperr.FieldError{Field: field, Message: "is incorrect"})
+	}
+	if err := s.throttle.Succeed(ctx, user.Email); err != nil {
+		log.WithError(err).Error("failed to reset failed logins")
+	}
+	return nil
+}
+
+// recordWrongPassword counts a wrong password towards the throttle. If it
+// locks the account, auth.account_locked is published as for failed logins,
+// so the user is told and the lock is audited.
+func (s *UserService) recordWrongPassword(ctx context.Context, user *usermodels.User, ip string) {
+	log := logging.FromContext(ctx).WithField("target_user_id", user.ID.String())
+	failure, err := s.throttle.Fail(ctx, user.Email, ip)
+	if err != nil {
+		log.WithError(err).Error("failed to count wrong password")
+		return
+	}
+	if failure.LockedUntil.IsZero() {
+		return
+	}
+	log.WithField("locked_until", failure.LockedUntil).Warn("account locked after wrong passwords")
+	err = s.repo.Publish(ctx, events.AuthAccountLocked, events.AuthAccountLockedPayload{Email: user.Email, LockedUntil: failure.LockedUntil})
+	if err != nil {
+		log.WithError(err).Error("failed to record account lock")
+	}
+}
+
+// CompletePasswordReset sets a new password after a password reset and
+// publishes password.reset_completed in the same transaction. Access tokens
+// issued before the reset are revoked right away; the caller ends the user's
+// sessions.
+func (s *UserService) CompletePasswordReset(ctx context.Context, userID string, newPassword string) error {
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.setPassword(ctx, user, newPassword); err != nil {
+		return err
+	}
+
+	err = s.repo.Transaction(ctx, func(repo *repositories.UserRepository) error {
+		if err := repo.Update(ctx, user); err != nil {
+			return err
+		}
+		return repo.Publish(ctx, events.PasswordResetCompleted, events.PasswordResetCompletedPayload{UserID: userID})
+	})
+	if err != nil {
+		return err
+	}
+	s.revokeAccessTokens(ctx, userID)
+	return nil
 }
 
 // GetUserViewByID returns a minimal user representation for external modules.
-func (s *UserService) GetUserViewByID(userID string) (*sharedmodels.UserView, error) {
-	u, err := s.GetUserByID(userID)
+func (s *UserService) GetUserViewByID(ctx context.Context, userID string) (*sharedmodels.UserView, error) {
+	u, err := s.GetUserByID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 	return &sharedmodels.UserView{
-		ID:    u.ID.String(),
-		Name:  u.Name,
-		Email: u.Email,
-		Role:  u.Role,
+		ID:            u.ID.String(),
+		Name:          u.Name,
+		Email:         u.Email,
+		Role:          u.Role,
+		Language:      u.Language,
+		EmailVerified: u.EmailVerifiedAt != nil,
 	}, nil
 }
+
+// IsEmailVerified reports whether the user has verified their email address.
+func (s *UserService) IsEmailVerified(ctx context.Context, userID string) (bool, error) {
+	u, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return u.EmailVerifiedAt != nil, nil
+}
+
+// MarkEmailVerified marks email as the verified address of the user and
+// publishes user.email_verified. A link sent to an address the user no
+// longer has is rejected with auth.ErrInvalidVerificationToken; verifying
+// twice is not an error.
+func (s *UserService) MarkEmailVerified(ctx context.Context, userID string, email string) error {
+	return s.repo.Transaction(ctx, func(repo *repositories.UserRepository) error {
+		user, err := repo.GetByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(user.Email, email) {
+			return auth.ErrInvalidVerificationToken
+		}
+		if user.EmailVerifiedAt != nil {
+			return nil
+		}
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		if err := repo.Update(ctx, user); err != nil {
+			return err
+		}
+		return repo.Publish(ctx, events.UserEmailVerified, events.UserEmailVerifiedPayload{
+			UserID: userID,
+			Email:  user.Email,
+		})
+	})
+}
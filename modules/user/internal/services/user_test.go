@@ -1,14 +1,25 @@
 package services_test
 
 import (
+	"context"
+	"net/http"
+	"strings"
 	"testing"
 	"time"
 
+	"egaldeutsch-be/internal/apperr"
+	"egaldeutsch-be/internal/auth"
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/events"
+	"egaldeutsch-be/internal/password"
+	"egaldeutsch-be/internal/redis"
 	"egaldeutsch-be/modules/user/internal/models"
 	"egaldeutsch-be/modules/user/internal/repositories"
 	"egaldeutsch-be/modules/user/internal/services"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/google/uuid"
+	rawRedis "github.com/redis/go-redis/v9"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -23,6 +34,9 @@ type testUser struct {
 	Password  string
 	Name      string
 	Role      string
+	Language  string
+
+	EmailVerifiedAt *time.Time
 }
 
 func (testUser) TableName() string { return "users" }
@@ -34,30 +48,46 @@ func setupUserDB(t *testing.T) *gorm.DB {
 	}
 
 	// Create minimal users table for repository
-	if err := db.AutoMigrate(&testUser{}); err != nil {
+	if err := db.AutoMigrate(&testUser{}, &events.Event{}); err != nil {
 		t.Fatalf("migrate: %v", err)
 	}
 	return db
 }
 
+// testPasswordConfig hashes with cheap Argon2id parameters.
+var testPasswordConfig = config.PasswordConfig{
+	Algorithm:         config.PasswordArgon2id,
+	Argon2Memory:      64,
+	Argon2Iterations:  1,
+	Argon2Parallelism: 1,
+	BcryptCost:        4,
+	MinLength:         8,
+	RejectCommon:      true,
+	RejectPersonal:    true,
+}
+
+func newUserService(repo *repositories.UserRepository) *services.UserService {
+	return services.NewUserService(repo, nil, nil, password.NewHasher(testPasswordConfig), password.NewPolicy(testPasswordConfig))
+}
+
 func TestAuthenticateUser_Success(t *testing.T) {
 	db := setupUserDB(t)
 	repo := repositories.NewUserRepository(db)
-	svc := services.NewUserService(repo)
+	svc := newUserService(repo)
 
-	// create user via repo.Create
-	password := "supersecret"
-	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	// create user via repo.Create, with a bcrypt hash from before Argon2id
+	pw := "supersecret"
+	hashed, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.MinCost)
 	if err != nil {
 		t.Fatalf("bcrypt failed: %v", err)
 	}
 	u := &models.User{Email: "bob@example.com", Password: string(hashed), Name: "Bob", Role: "learner"}
-	if err := repo.Create(u); err != nil {
+	if err := repo.Create(context.Background(), u); err != nil {
 		t.Fatalf("create user: %v", err)
 	}
 
 	// Now authenticate
-	id, role, err := svc.AuthenticateUser("bob@example.com", password)
+	id, role, err := svc.AuthenticateUser(context.Background(), "bob@example.com", pw)
 	if err != nil {
 		t.Fatalf("authenticate user failed: %v", err)
 	}
@@ -67,4 +97,256 @@ func TestAuthenticateUser_Success(t *testing.T) {
 	if string(role) != "learner" {
 		t.Fatalf("expected role learner got %s", role)
 	}
+
+	// The login replaced the bcrypt hash, and the new one still works
+	var stored testUser
+	db.First(&stored, "id = ?", id)
+	if !strings.HasPrefix(stored.Password, "$argon2id$") {
+		t.Fatalf("expected the password to be rehashed with argon2id, got %q", stored.Password)
+	}
+	if _, _, err := svc.AuthenticateUser(context.Background(), "bob@example.com", pw); err != nil {
+		t.Fatalf("authenticate after rehash: %v", err)
+	}
+	if _, _, err := svc.AuthenticateUser(context.Background(), "bob@example.com", "wrong-password"); err != auth.ErrInvalidCredentials {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestPasswordPolicyOnCreateAndUpdate(t *testing.T) {
+	db := setupUserDB(t)
+	svc := newUserService(repositories.NewUserRepository(db))
+	ctx := context.Background()
+
+	for _, pw := range []string{"short", "password123", "martina-rocks"} {
+		_, err := svc.CreateUser(ctx, &models.CreateUserRequest{Name: "Martina Vogel", Email: "mv@example.com", Password: pw})
+		if e := apperr.As(err); e == nil || e.Status != http.StatusBadRequest {
+			t.Fatalf("expected a validation error for %q, got %v", pw, err)
+		}
+	}
+	u, err := svc.CreateUser(ctx, &models.CreateUserRequest{Name: "Martina Vogel", Email: "mv@example.com", Password: "grüne-Tinte-42"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := svc.UpdatePassword(ctx, u.ID.String(), "vogelhaus-99"); apperr.As(err) == nil {
+		t.Fatalf("expected the policy to reject the user's name, got %v", err)
+	}
+	if err := svc.UpdatePassword(ctx, u.ID.String(), "blaue-Kreide-17"); err != nil {
+		t.Fatalf("update password: %v", err)
+	}
+	if _, _, err := svc.AuthenticateUser(ctx, "mv@example.com", "blaue-Kreide-17"); err != nil {
+		t.Fatalf("authenticate with new password: %v", err)
+	}
+}
+
+func TestPurgeDeletedUsers(t *testing.T) {
+	db := setupUserDB(t)
+	repo := repositories.NewUserRepository(db)
+	svc := newUserService(repo)
+	ctx := context.Background()
+
+	now := time.Now()
+	for _, u := range []testUser{
+		{ID: uuid.New(), Email: "old@example.com", DeletedAt: gorm.DeletedAt{Time: now.Add(-48 * time.Hour), Valid: true}},
+		{ID: uuid.New(), Email: "recent@example.com", DeletedAt: gorm.DeletedAt{Time: now.Add(-time.Hour), Valid: true}},
+		{ID: uuid.New(), Email: "active@example.com"},
+	} {
+		if err := db.Create(&u).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+
+	n, err := svc.PurgeDeletedUsers(ctx, now.Add(-24*time.Hour))
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 purged user, got %d (%v)", n, err)
+	}
+
+	var left int64
+	db.Unscoped().Model(&testUser{}).Count(&left)
+	if left != 2 {
+		t.Fatalf("expected 2 users left, got %d", left)
+	}
+}
+
+func TestUserChangesPublishEvents(t *testing.T) {
+	db := setupUserDB(t)
+	repo := repositories.NewUserRepository(db)
+	svc := newUserService(repo)
+	ctx := context.Background()
+
+	u, err := svc.CreateUser(ctx, &models.CreateUserRequest{Name: "Eve", Email: "eve@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	// Renaming does not change the role and publishes nothing
+	if _, err := svc.UpdateUser(ctx, u.ID.String(), &models.UpdateUserRequest{Name: "Eva"}); err != nil {
+		t.Fatalf("rename user: %v", err)
+	}
+	if _, err := svc.UpdateUser(ctx, u.ID.String(), &models.UpdateUserRequest{Role: "admin"}); err != nil {
+		t.Fatalf("promote user: %v", err)
+	}
+	if err := svc.DeleteUser(ctx, u.ID.String()); err != nil {
+		t.Fatalf("delete user: %v", err)
+	}
+	if err := svc.DeleteUser(ctx, u.ID.String()); err != repositories.ErrUserNotFound {
+		t.Fatalf("expected ErrUserNotFound deleting twice, got %v", err)
+	}
+
+	var published []events.Event
+	if err := db.Order("occurred_at").Find(&published).Error; err != nil {
+		t.Fatalf("load outbox: %v", err)
+	}
+	var types []string
+	for _, e := range published {
+		types = append(types, e.Type)
+	}
+	want := []string{events.UserCreated, events.UserRoleChanged, events.UserDeleted}
+	if len(types) != len(want) {
+		t.Fatalf("expected events %v, got %v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("expected events %v, got %v", want, types)
+		}
+	}
+
+	var changed events.UserRoleChangedPayload
+	if err := published[1].Decode(&changed); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	if changed.OldRole != "learner" || changed.NewRole != "admin" {
+		t.Fatalf("unexpected role change payload: %+v", changed)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	db := setupUserDB(t)
+	svc := newUserService(repositories.NewUserRepository(db))
+	ctx := context.Background()
+
+	u, err := svc.CreateUser(ctx, &models.CreateUserRequest{Name: "Karl", Email: "karl@example.com", Password: "rote-Tafel-31"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	id := u.ID.String()
+
+	err = svc.ChangePassword(ctx, id, "session-1", "192.0.2.1", "falsch", "gelbe-Feder-64")
+	if e := apperr.As(err); e == nil || len(e.Fields) != 1 || e.Fields[0].Field != "current_password" {
+		t.Fatalf("expected a validation error on current_password, got %v", err)
+	}
+	if err := svc.ChangePassword(ctx, id, "session-1", "192.0.2.1", "rote-Tafel-31", "gelbe-Feder-64"); err != nil {
+		t.Fatalf("change password: %v", err)
+	}
+	if _, _, err := svc.AuthenticateUser(ctx, "karl@example.com", "gelbe-Feder-64"); err != nil {
+		t.Fatalf("authenticate with new password: %v", err)
+	}
+
+	var published events.Event
+	if err := db.Where("type = ?", events.UserPasswordChanged).First(&published).Error; err != nil {
+		t.Fatalf("load %s event: %v", events.UserPasswordChanged, err)
+	}
+	var payload events.UserPasswordChangedPayload
+	if err := published.Decode(&payload); err != nil || payload.UserID != id || payload.SessionID != "session-1" {
+		t.Fatalf("unexpected payload %+v (%v)", payload, err)
+	}
+
+	if err := svc.DeleteOwnUser(ctx, id, "192.0.2.1", "rote-Tafel-31"); apperr.As(err) == nil {
+		t.Fatalf("expected the old password to be rejected, got %v", err)
+	}
+	if err := svc.DeleteOwnUser(ctx, id, "192.0.2.1", "gelbe-Feder-64"); err != nil {
+		t.Fatalf("delete own user: %v", err)
+	}
+}
+
+func TestChangePasswordThrottled(t *testing.T) {
+	db := setupUserDB(t)
+	mr := miniredis.RunT(t)
+	client := rawRedis.NewClient(&rawRedis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	throttle := auth.NewLoginThrottle(&redis.RedisClient{Client: client}, config.LockoutConfig{
+		Enabled:          true,
+		FreeAttempts:     5,
+		BaseDelaySeconds: 1,
+		MaxDelaySeconds:  2,
+		AccountThreshold: 3,
+		IPThreshold:      50,
+		WindowMinutes:    15,
+		LockoutMinutes:   10,
+	})
+	svc := services.NewUserService(repositories.NewUserRepository(db), nil, throttle, password.NewHasher(testPasswordConfig), password.NewPolicy(testPasswordConfig))
+	ctx := context.Background()
+
+	u, err := svc.CreateUser(ctx, &models.CreateUserRequest{Name: "Karl", Email: "karl@example.com", Password: "rote-Tafel-31"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	id := u.ID.String()
+
+	// Wrong current passwords count as failed logins and lock the account
+	for i := 0; i < 3; i++ {
+		if err := svc.ChangePassword(ctx, id, "", "192.0.2.1", "falsch", "gelbe-Feder-64"); apperr.As(err) == nil || err == auth.ErrTooManyLoginAttempts {
+			t.Fatalf("attempt %d: expected a validation error, got %v", i+1, err)
+		}
+	}
+	if err := svc.ChangePassword(ctx, id, "", "192.0.2.1", "rote-Tafel-31", "gelbe-Feder-64"); err != auth.ErrTooManyLoginAttempts {
+		t.Fatalf("locked: expected ErrTooManyLoginAttempts, got %v", err)
+	}
+	if err := svc.DeleteOwnUser(ctx, id, "192.0.2.1", "rote-Tafel-31"); err != auth.ErrTooManyLoginAttempts {
+		t.Fatalf("delete while locked: expected ErrTooManyLoginAttempts, got %v", err)
+	}
+	if _, _, err := svc.AuthenticateUser(ctx, "karl@example.com", "rote-Tafel-31"); err != nil {
+		t.Fatalf("expected the password to be unchanged: %v", err)
+	}
+
+	var n int64
+	db.Model(&events.Event{}).Where("type = ?", events.AuthAccountLocked).Count(&n)
+	if n != 1 {
+		t.Fatalf("expected one %s event, got %d", events.AuthAccountLocked, n)
+	}
+}
+
+func TestMarkEmailVerified(t *testing.T) {
+	db := setupUserDB(t)
+	repo := repositories.NewUserRepository(db)
+	svc := newUserService(repo)
+	ctx := context.Background()
+
+	u, err := svc.CreateUser(ctx, &models.CreateUserRequest{Name: "Ida", Email: "ida@example.com", Password: "secret123"})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	id := u.ID.String()
+	if u.Language != "de" {
+		t.Fatalf("expected default language de, got %q", u.Language)
+	}
+	if verified, err := svc.IsEmailVerified(ctx, id); err != nil || verified {
+		t.Fatalf("expected a new user to be unverified, got %v (%v)", verified, err)
+	}
+
+	// A link sent to another address does not verify the current one
+	if err := svc.MarkEmailVerified(ctx, id, "old@example.com"); err != auth.ErrInvalidVerificationToken {
+		t.Fatalf("expected ErrInvalidVerificationToken, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := svc.MarkEmailVerified(ctx, id, "IDA@example.com"); err != nil {
+			t.Fatalf("mark verified: %v", err)
+		}
+	}
+	if verified, err := svc.IsEmailVerified(ctx, id); err != nil || !verified {
+		t.Fatalf("expected the user to be verified, got %v (%v)", verified, err)
+	}
+
+	var n int64
+	db.Model(&events.Event{}).Where("type = ?", events.UserEmailVerified).Count(&n)
+	if n != 1 {
+		t.Fatalf("expected one %s event, got %d", events.UserEmailVerified, n)
+	}
+
+	admin, err := svc.CreateVerifiedUser(ctx, &models.CreateUserRequest{Name: "Root", Email: "root@example.com", Password: "secret123", Language: "en"}, "admin")
+	if err != nil {
+		t.Fatalf("create verified user: %v", err)
+	}
+	view, err := svc.GetUserViewByID(ctx, admin.ID.String())
+	if err != nil || !view.EmailVerified || view.Language != "en" || view.Role != "admin" {
+		t.Fatalf("expected a verified English admin, got %+v (%v)", view, err)
+	}
 }
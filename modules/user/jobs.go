@@ -0,0 +1,32 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/scheduler"
+)
+
+// Jobs returns the user module's maintenance jobs.
+func (m *Module) Jobs(cfg config.SchedulerConfig) []scheduler.Job {
+	retention := time.Duration(cfg.DeletedUserRetentionDays) * 24 * time.Hour
+	return []scheduler.Job{
+		{
+			Name:        "user.purge-deleted",
+			Description: "Permanently remove users soft-deleted longer than the retention period",
+			Schedule:    scheduler.MustParseCron("0 4 * * *"),
+			MaxRetries:  3,
+			Run: func(ctx context.Context) error {
+				n, err := m.Service.PurgeDeletedUsers(ctx, time.Now().Add(-retention))
+				if err != nil {
+					return err
+				}
+				logrus.WithField("users", n).Info("Purged soft-deleted users")
+				return nil
+			},
+		},
+	}
+}
@@ -1,9 +1,15 @@
 package user
 
 import (
+	"context"
+
 	"gorm.io/gorm"
 
+	"egaldeutsch-be/internal/auth"
 	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/middleware"
+	"egaldeutsch-be/internal/password"
+	"egaldeutsch-be/internal/rbac"
 	"egaldeutsch-be/modules/user/internal/handlers"
 	"egaldeutsch-be/modules/user/internal/models"
 	"egaldeutsch-be/modules/user/internal/repositories"
@@ -12,29 +18,64 @@ import (
 
 // Module provides the user module functionality
 type Module struct {
-	Handler *handlers.UserHandler
-	Service *services.UserService
-	Repo    *repositories.UserRepository
+	Handler  *handlers.UserHandler
+	Service  *services.UserService
+	Repo     *repositories.UserRepository
+	jwtCfg   config.JwtConfig
+	idem     *middleware.Idempotency
+	denylist *auth.Denylist
+	apiKeys  *auth.APIKeys
+	policy   *rbac.Policy
 }
 
-// NewModule creates a new user module with all dependencies
-func NewModule(db *gorm.DB, jwtCfg config.JwtConfig) *Module {
+// NewModule creates a new user module with all dependencies. passwordCfg
+// selects the password hashing algorithm and policy. idem makes sign-up safe
+// to retry, denylist revokes access tokens, throttle slows down guessing the
+// current password of an account and apiKeys accepts API keys for managing
+// users; nil disables any of them. policy authorizes managing other users and
+// changing roles.
+func NewModule(db *gorm.DB, jwtCfg config.JwtConfig, passwordCfg config.PasswordConfig, idem *middleware.Idempotency, denylist *auth.Denylist, throttle *auth.LoginThrottle, apiKeys *auth.APIKeys, policy *rbac.Policy) *Module {
 	// Initialize repository
 	repo := repositories.NewUserRepository(db)
 
 	// Initialize service
-	service := services.NewUserService(repo)
+	service := services.NewUserService(repo, denylist, throttle, password.NewHasher(passwordCfg), password.NewPolicy(passwordCfg))
 
 	// Initialize handler
-	handler := handlers.NewUserHandler(service, jwtCfg)
+	handler := handlers.NewUserHandler(service, jwtCfg, policy)
 
 	return &Module{
-		Handler: handler,
-		Service: service,
-		Repo:    repo,
+		Handler:  handler,
+		Service:  service,
+		Repo:     repo,
+		jwtCfg:   jwtCfg,
+		idem:     idem,
+		denylist: denylist,
+		apiKeys:  apiKeys,
+		policy:   policy,
 	}
 }
 
+// Name returns the module name used in config.yaml.
+func (m *Module) Name() string {
+	return "user"
+}
+
+// Start is a no-op; the user module has no background work.
+func (m *Module) Start(ctx context.Context) error {
+	return nil
+}
+
+// Stop is a no-op; the user module has no background work.
+func (m *Module) Stop(ctx context.Context) error {
+	return nil
+}
+
+// Health is always healthy; database health is checked by the server.
+func (m *Module) Health(ctx context.Context) error {
+	return nil
+}
+
 // GetModelsForMigration returns models that need to be migrated
 func (m *Module) GetModelsForMigration() []interface{} {
 	return []interface{}{
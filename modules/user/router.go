@@ -1,9 +1,8 @@
 package user
 
 import (
-	"egaldeutsch-be/internal/config"
 	"egaldeutsch-be/internal/middleware"
-	"egaldeutsch-be/pkg/models"
+	"egaldeutsch-be/internal/rbac"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,36 +12,46 @@ import (
 // (for example: /api/v1) and pass it to modules so they can attach their
 // sub-routes (for example: /users).
 //
-// Protection rules (recommended):
-// - POST /users            : public (user sign-up)
-// - GET /users/:id         : protected (authenticated users can fetch their own data, admin can fetch any)
-// - PUT /users/:id         : protected (user can update their own, admin can update any)
-// - DELETE /users/:id      : role-protected (admin only)
-// - GET /users             : role-protected (admin only)
+// Protection rules:
+// - POST /users               : public (user sign-up)
+// - GET|PATCH|DELETE /users/me : protected (the signed-in user)
+// - POST /users/me/password   : protected (the signed-in user)
+// - GET /users/:id            : the owner, or users:read
+// - PUT /users/:id            : the owner, or users:write; changing the role needs users:write
+// - DELETE /users/:id         : the owner with their password, or users:write
+// - GET /users                : permission-protected (users:read)
 //
-// The function accepts the JWT config so it can mount the AuthMiddleware
-// consistently with the rest of the app.
-func (m *Module) RegisterRoutes(rg *gin.RouterGroup, jwtCfg config.JwtConfig) {
+// The JWT config given to NewModule is used to mount the AuthMiddleware
+// consistently with the rest of the app. API keys are accepted on the routes
+// guarded by a permission, not on /users/me.
+func (m *Module) RegisterRoutes(rg *gin.RouterGroup) {
+	jwtCfg := m.jwtCfg
+
 	users := rg.Group("/users")
 	{
 		// public: signup
-		users.POST("", m.Handler.CreateUser)
+		users.POST("", m.idem.Middleware(), m.Handler.CreateUser)
 
-		// protected routes: require authentication
-		usersAuth := users.Group("")
-		usersAuth.Use(middleware.AuthMiddleware(jwtCfg))
+		// protected routes: the signed-in user
+		usersAuth := users.Group("/me")
+		usersAuth.Use(middleware.AuthMiddleware(jwtCfg, m.denylist, nil))
 		{
-
+			usersAuth.GET("", m.Handler.GetMe)
+			usersAuth.PATCH("", m.Handler.UpdateMe)
+			usersAuth.DELETE("", m.Handler.DeleteMe)
+			usersAuth.POST("/password", m.Handler.ChangePassword)
 		}
 
-		// admin-only routes
+		// a user's own record, or any by permission
 		usersAdmin := users.Group("")
-		usersAdmin.Use(middleware.AuthMiddleware(jwtCfg), middleware.RequireRole(models.UserRoleAdmin))
+		usersAdmin.Use(middleware.AuthMiddleware(jwtCfg, m.denylist, m.apiKeys))
 		{
-			usersAdmin.GET(":id", m.Handler.GetUser)
-			usersAdmin.PUT(":id", m.Handler.UpdateUser)
-			usersAdmin.DELETE(":id", m.Handler.DeleteUser)
-			usersAdmin.GET("", m.Handler.ListUsers)
+			ownerOrRead := middleware.RequireOwnerOrPermission(m.policy, rbac.UsersRead, "id")
+			ownerOrWrite := middleware.RequireOwnerOrPermission(m.policy, rbac.UsersWrite, "id")
+			usersAdmin.GET(":id", ownerOrRead, m.Handler.GetUser)
+			usersAdmin.PUT(":id", ownerOrWrite, m.Handler.UpdateUser)
+			usersAdmin.DELETE(":id", ownerOrWrite, m.Handler.DeleteUser)
+			usersAdmin.GET("", middleware.RequirePermission(m.policy, rbac.UsersRead), m.Handler.ListUsers)
 		}
 	}
 }
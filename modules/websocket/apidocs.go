@@ -0,0 +1,67 @@
+package websocket
+
+import (
+	"net/http"
+
+	"egaldeutsch-be/internal/openapi"
+	"egaldeutsch-be/internal/rbac"
+	"egaldeutsch-be/modules/websocket/internal/models"
+)
+
+// APIDocs documents the routes of the websocket module.
+func (m *Module) APIDocs(base string) []openapi.Operation {
+	tags := []string{"chat"}
+	return []openapi.Operation{
+		{
+			Method: http.MethodGet, Path: base + "/ws/chat/:room_id", Tags: tags, Permission: rbac.ChatWrite,
+			Summary:     "Join a chat room over WebSocket",
+			Description: "Upgrades to a WebSocket with the `chat` subprotocol. Messages in both directions are JSON objects with `type`, `room_id`, `user_id`, `username`, `content` and `timestamp`.",
+			Params:      models.RoomIDParam{},
+			Status:      http.StatusSwitchingProtocols,
+		},
+		{
+			Method: http.MethodGet, Path: base + "/ws/chat/:room_id/history", Tags: tags, Permission: rbac.ChatWrite,
+			Summary: "Recent messages of a room",
+			Params: struct {
+				models.RoomIDParam
+				models.GetHistoryQuery
+			}{},
+			Response: struct {
+				Messages []models.WSMessage `json:"messages"`
+				Count    int                `json:"count"`
+			}{},
+		},
+		{
+			Method: http.MethodDelete, Path: base + "/ws/chat/:room_id/history", Tags: tags, Permission: rbac.ChatModerate,
+			Summary:     "Clear the history of a room",
+			Description: "Deletes the stored messages; connected clients keep what they already received.",
+			Params:      models.RoomIDParam{},
+			Status:      http.StatusNoContent,
+		},
+		{
+			Method: http.MethodGet, Path: base + "/ws/chat/:room_id/info", Tags: tags, Permission: rbac.ChatWrite,
+			Summary: "Connected users of a room",
+			Params:  models.RoomIDParam{},
+			Response: struct {
+				RoomID    string `json:"room_id"`
+				UserCount int    `json:"user_count"`
+			}{},
+		},
+		{
+			Method: http.MethodPost, Path: base + "/ws/rooms", Tags: tags, Permission: rbac.ChatWrite,
+			Summary:    "Create a chat room",
+			Body:       models.CreateRoomRequest{},
+			Idempotent: true,
+			Status:     http.StatusCreated,
+			Response:   models.Room{},
+		},
+		{
+			Method: http.MethodGet, Path: base + "/ws/rooms", Tags: tags, Permission: rbac.ChatWrite,
+			Summary: "List active chat rooms",
+			Response: struct {
+				Rooms []models.Room `json:"rooms"`
+				Count int           `json:"count"`
+			}{},
+		},
+	}
+}
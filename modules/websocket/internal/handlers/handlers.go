@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 
 	"github.com/coder/websocket"
@@ -10,11 +11,19 @@ import (
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 
+	"egaldeutsch-be/internal/apperr"
 	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/events"
+	"egaldeutsch-be/internal/logging"
 	"egaldeutsch-be/modules/websocket/internal/hub"
 	"egaldeutsch-be/modules/websocket/internal/models"
 )
 
+var (
+	errNotAuthenticated = apperr.Unauthorized(apperr.CodeUnauthorized, "Authentication is required.")
+	errInvalidUserID    = apperr.BadRequest("invalid_user_id", "The token does not identify a valid user.")
+)
+
 type WSHandler struct {
 	hub    *hub.Hub
 	jwtCfg config.JwtConfig
@@ -34,20 +43,20 @@ func (h *WSHandler) HandleConnection(c *gin.Context) {
 	// Get room ID from URL parameter
 	var params models.RoomIDParam
 	if err := c.ShouldBindUri(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		_ = c.Error(apperr.FromBinding(err))
 		return
 	}
 
 	// Get user ID from context (set by auth middleware)
 	userIDStr, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		_ = c.Error(errNotAuthenticated)
 		return
 	}
 
 	userID, err := uuid.Parse(userIDStr.(string))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		_ = c.Error(errInvalidUserID)
 		return
 	}
 
@@ -62,26 +71,27 @@ func (h *WSHandler) HandleConnection(c *gin.Context) {
 		Subprotocols: []string{"chat"},
 	})
 	if err != nil {
-		logrus.WithError(err).Error("Failed to upgrade to WebSocket")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to upgrade connection"})
+		// Accept has already written the error response
+		_ = c.Error(fmt.Errorf("accept websocket: %w", err))
 		return
 	}
 
 	// Create client
-	client := hub.NewClient(h.hub, conn, userID, username, params.RoomID)
+	client := hub.NewClient(c.Request.Context(), h.hub, conn, userID, username, params.RoomID)
 
-	// Register client with hub
-	h.hub.Register <- client
+	// Register client with hub, unless it has already stopped during shutdown
+	select {
+	case h.hub.Register <- client:
+	case <-h.hub.Done():
+		conn.Close(websocket.StatusGoingAway, "server shutting down")
+		return
+	}
 
 	// Create context for this connection
 	ctx, cancel := context.WithCancel(c.Request.Context())
 	defer cancel()
 
-	logrus.WithFields(logrus.Fields{
-		"user_id":  userID,
-		"username": username,
-		"room_id":  params.RoomID,
-	}).Info("WebSocket connection established")
+	logging.FromContext(c.Request.Context()).WithField("room_id", params.RoomID).Info("WebSocket connection established")
 
 	// Start read and write pumps
 	go client.WritePump(ctx)
@@ -93,27 +103,27 @@ func (h *WSHandler) GetRoomHistory(c *gin.Context) {
 	// Get room ID from URL parameter
 	var params models.RoomIDParam
 	if err := c.ShouldBindUri(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		_ = c.Error(apperr.FromBinding(err))
 		return
 	}
 
 	// Get user ID from context (set by auth middleware)
 	userIDStr, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		_ = c.Error(errNotAuthenticated)
 		return
 	}
 
 	_, err := uuid.Parse(userIDStr.(string))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		_ = c.Error(errInvalidUserID)
 		return
 	}
 
 	// Parse query parameters
 	var query models.GetHistoryQuery
 	if err := c.ShouldBindQuery(&query); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(apperr.FromBinding(err))
 		return
 	}
 
@@ -128,8 +138,7 @@ func (h *WSHandler) GetRoomHistory(c *gin.Context) {
 	// Get history from hub (which gets it from Redis)
 	history, err := h.hub.GetRoomHistory(c.Request.Context(), params.RoomID, int64(query.Limit))
 	if err != nil {
-		logrus.WithError(err).WithField("room_id", params.RoomID).Error("Failed to get room history")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve chat history"})
+		_ = c.Error(fmt.Errorf("get history of room %s: %w", params.RoomID, err))
 		return
 	}
 
@@ -139,25 +148,43 @@ func (h *WSHandler) GetRoomHistory(c *gin.Context) {
 	})
 }
 
+// ClearRoomHistory deletes the stored history of a room. Connected clients
+// keep the messages they already received.
+func (h *WSHandler) ClearRoomHistory(c *gin.Context) {
+	var params models.RoomIDParam
+	if err := c.ShouldBindUri(&params); err != nil {
+		_ = c.Error(apperr.FromBinding(err))
+		return
+	}
+
+	if _, err := h.hub.FlushHistory(c.Request.Context(), params.RoomID); err != nil {
+		_ = c.Error(fmt.Errorf("clear history of room %s: %w", params.RoomID, err))
+		return
+	}
+
+	logging.FromContext(c.Request.Context()).WithField("room_id", params.RoomID).Info("chat history cleared by moderator")
+	c.Status(http.StatusNoContent)
+}
+
 // GetRoomInfo returns information about a room
 func (h *WSHandler) GetRoomInfo(c *gin.Context) {
 	// Get room ID from URL parameter
 	var params models.RoomIDParam
 	if err := c.ShouldBindUri(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		_ = c.Error(apperr.FromBinding(err))
 		return
 	}
 
 	// Get user ID from context (set by auth middleware)
 	userIDStr, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		_ = c.Error(errNotAuthenticated)
 		return
 	}
 
 	_, err := uuid.Parse(userIDStr.(string))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		_ = c.Error(errInvalidUserID)
 		return
 	}
 
@@ -174,20 +201,20 @@ func (h *WSHandler) GetRoomInfo(c *gin.Context) {
 func (h *WSHandler) CreateRoom(c *gin.Context) {
 	var req models.CreateRoomRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(apperr.FromBinding(err))
 		return
 	}
 
 	// Get user ID from context
 	userIDStr, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		_ = c.Error(errNotAuthenticated)
 		return
 	}
 
 	userID, err := uuid.Parse(userIDStr.(string))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		_ = c.Error(errInvalidUserID)
 		return
 	}
 
@@ -203,13 +230,22 @@ func (h *WSHandler) CreateRoom(c *gin.Context) {
 	}
 
 	// Save room to database
-	if err := h.db.Create(room).Error; err != nil {
-		logrus.WithError(err).Error("Failed to create room in database")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create room"})
+	err = h.db.WithContext(c.Request.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(room).Error; err != nil {
+			return err
+		}
+		return events.Publish(tx, events.ChatRoomCreated, events.ChatRoomCreatedPayload{
+			RoomID:    room.ID,
+			Name:      room.Name,
+			CreatedBy: userID.String(),
+		})
+	})
+	if err != nil {
+		_ = c.Error(fmt.Errorf("create room: %w", err))
 		return
 	}
 
-	logrus.WithFields(logrus.Fields{
+	logging.FromContext(c.Request.Context()).WithFields(logrus.Fields{
 		"room_id":    roomID,
 		"room_name":  req.Name,
 		"created_by": userID,
@@ -223,22 +259,21 @@ func (h *WSHandler) ListRooms(c *gin.Context) {
 	// Get user ID from context
 	userIDStr, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		_ = c.Error(errNotAuthenticated)
 		return
 	}
 
 	_, err := uuid.Parse(userIDStr.(string))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		_ = c.Error(errInvalidUserID)
 		return
 	}
 
 	// Query active rooms from database using GORM
 	var rooms []models.Room
-	result := h.db.Where("is_active = ?", true).Order("created_at DESC").Find(&rooms)
+	result := h.db.WithContext(c.Request.Context()).Where("is_active = ?", true).Order("created_at DESC").Find(&rooms)
 	if result.Error != nil {
-		logrus.WithError(result.Error).Error("Failed to query rooms")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rooms"})
+		_ = c.Error(fmt.Errorf("list rooms: %w", result.Error))
 		return
 	}
 
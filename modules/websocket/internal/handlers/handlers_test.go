@@ -0,0 +1,50 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"egaldeutsch-be/internal/testsupport"
+	"egaldeutsch-be/modules/websocket/internal/handlers"
+	"egaldeutsch-be/modules/websocket/internal/hub"
+)
+
+func TestConnectAfterHubStopped(t *testing.T) {
+	h := hub.NewHub(nil)
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	go h.Run(hubCtx)
+	stopHub()
+	<-h.Done()
+
+	handler := handlers.NewWSHandler(h, testsupport.Config().Jwt, nil)
+	router := testsupport.Router(func(rg *gin.RouterGroup) {
+		rg.GET("/ws/chat/:room_id", func(c *gin.Context) {
+			c.Set("user_id", uuid.NewString())
+		}, handler.HandleConnection)
+	})
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/v1/ws/chat/general"
+	conn, _, err := websocket.Dial(ctx, url, &websocket.DialOptions{Subprotocols: []string{"chat"}})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.CloseNow()
+
+	// The connection is closed instead of waiting for a hub that is gone
+	_, _, err = conn.Read(ctx)
+	if status := websocket.CloseStatus(err); status != websocket.StatusGoingAway {
+		t.Fatalf("expected going-away close, got %v", err)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/coder/websocket"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+
+	"egaldeutsch-be/internal/logging"
 )
 
 const (
@@ -18,6 +20,9 @@ const (
 
 	// Send pings to peer with this period (must be less than pongWait)
 	pingPeriod = (pongWait * 9) / 10
+
+	// Close reason sent on shutdown so clients know to reconnect elsewhere
+	shutdownCloseReason = "server restarting, please reconnect"
 )
 
 type Client struct {
@@ -27,9 +32,15 @@ type Client struct {
 	userID   uuid.UUID
 	username string
 	roomID   string
+
+	// log carries the request ID of the upgrade request, so every line about
+	// this connection correlates with it
+	log *logrus.Entry
 }
 
-func NewClient(hub *Hub, conn *websocket.Conn, userID uuid.UUID, username, roomID string) *Client {
+// NewClient creates a client for an upgraded connection. ctx is the context of
+// the upgrade request and provides the request ID for the connection's logs.
+func NewClient(ctx context.Context, hub *Hub, conn *websocket.Conn, userID uuid.UUID, username, roomID string) *Client {
 	return &Client{
 		hub:      hub,
 		conn:     conn,
@@ -37,36 +48,56 @@ func NewClient(hub *Hub, conn *websocket.Conn, userID uuid.UUID, username, roomI
 		userID:   userID,
 		username: username,
 		roomID:   roomID,
+		log: logging.FromContext(ctx).WithFields(logrus.Fields{
+			logging.FieldUserID: userID.String(),
+			"room_id":           roomID,
+		}),
 	}
 }
 
 // ReadPump reads messages from the WebSocket connection
 func (c *Client) ReadPump(ctx context.Context) {
 	defer func() {
-		c.hub.Unregister <- c
+		// The hub may already be stopped during shutdown
+		select {
+		case c.hub.Unregister <- c:
+		case <-c.hub.done:
+		}
 		c.conn.Close(websocket.StatusNormalClosure, "")
 	}()
 
 	for {
 		_, message, err := c.conn.Read(ctx)
 		if err != nil {
-			if websocket.CloseStatus(err) == websocket.StatusNormalClosure {
-				logrus.WithField("user_id", c.userID).Info("WebSocket closed normally")
-			} else {
-				logrus.WithError(err).WithField("user_id", c.userID).Error("Error reading from WebSocket")
+			switch websocket.CloseStatus(err) {
+			case websocket.StatusNormalClosure, websocket.StatusGoingAway:
+				c.log.Info("WebSocket closed normally")
+			default:
+				c.log.WithError(err).Error("Error reading from WebSocket")
 			}
-			break
+			return
 		}
 
 		// Forward message to hub for broadcasting
-		c.hub.Broadcast <- &BroadcastMessage{
+		select {
+		case c.hub.Broadcast <- &BroadcastMessage{
 			roomID:  c.roomID,
 			message: message,
 			sender:  c,
+		}:
+		case <-c.hub.done:
+			return
 		}
 	}
 }
 
+// closeGoingAway performs the close handshake with a going-away status.
+func (c *Client) closeGoingAway() {
+	if err := c.conn.Close(websocket.StatusGoingAway, shutdownCloseReason); err != nil {
+		c.log.WithError(err).Debug("WebSocket close handshake did not complete")
+	}
+}
+
 // WritePump sends messages to the WebSocket connection
 func (c *Client) WritePump(ctx context.Context) {
 	ticker := time.NewTicker(pingPeriod)
@@ -89,7 +120,7 @@ func (c *Client) WritePump(ctx context.Context) {
 			cancel()
 
 			if err != nil {
-				logrus.WithError(err).WithField("user_id", c.userID).Error("Error writing to WebSocket")
+				c.log.WithError(err).Error("Error writing to WebSocket")
 				return
 			}
 
@@ -99,7 +130,7 @@ func (c *Client) WritePump(ctx context.Context) {
 			cancel()
 
 			if err != nil {
-				logrus.WithError(err).WithField("user_id", c.userID).Error("Error sending ping")
+				c.log.WithError(err).Error("Error sending ping")
 				return
 			}
 
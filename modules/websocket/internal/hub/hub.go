@@ -1,18 +1,31 @@
 package hub
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
+	"egaldeutsch-be/internal/metrics"
 	"egaldeutsch-be/internal/redis"
 	"egaldeutsch-be/modules/websocket/internal/models"
 )
 
+// broadcastQueueSize bounds how many messages may wait for the Run loop.
+const broadcastQueueSize = 256
+
+var slowClientsDropped = metrics.NewCounterVec(
+	"ws_slow_clients_dropped_total",
+	"WebSocket clients disconnected because their send buffer was full.",
+)
+
 type BroadcastMessage struct {
 	roomID  string
 	message []byte
@@ -37,6 +50,12 @@ type Hub struct {
 
 	// Mutex for thread-safe operations
 	mu sync.RWMutex
+
+	// Closed once Run has returned
+	done chan struct{}
+
+	// Whether the Run loop is currently active
+	running atomic.Bool
 }
 
 func NewHub(redisClient *redis.RedisClient) *Hub {
@@ -44,12 +63,20 @@ func NewHub(redisClient *redis.RedisClient) *Hub {
 		rooms:      make(map[string]map[*Client]bool),
 		Register:   make(chan *Client),
 		Unregister: make(chan *Client),
-		Broadcast:  make(chan *BroadcastMessage),
+		Broadcast:  make(chan *BroadcastMessage, broadcastQueueSize),
 		redis:      redisClient,
+		done:       make(chan struct{}),
 	}
 }
 
+// Run processes register, unregister and broadcast requests until ctx is cancelled.
 func (h *Hub) Run(ctx context.Context) {
+	h.running.Store(true)
+	defer func() {
+		h.running.Store(false)
+		close(h.done)
+	}()
+
 	for {
 		select {
 		case client := <-h.Register:
@@ -68,20 +95,64 @@ func (h *Hub) Run(ctx context.Context) {
 	}
 }
 
+// Running reports whether the Run loop is active.
+func (h *Hub) Running() bool {
+	return h.running.Load()
+}
+
+// Done returns a channel that is closed once Run has returned.
+func (h *Hub) Done() <-chan struct{} {
+	return h.done
+}
+
+// Shutdown closes every connected client with a going-away status and a
+// reconnect hint so clients can move to another instance. It returns once all
+// close handshakes have finished or ctx expires. Run keeps serving unregister
+// requests meanwhile and must be stopped by the caller afterwards.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.mu.RLock()
+	var clients []*Client
+	for _, room := range h.rooms {
+		for client := range room {
+			clients = append(clients, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	logrus.WithField("clients", len(clients)).Info("Closing WebSocket clients")
+
+	var wg sync.WaitGroup
+	for _, client := range clients {
+		wg.Add(1)
+		go func(c *Client) {
+			defer wg.Done()
+			c.closeGoingAway()
+		}(client)
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("closing WebSocket clients: %w", ctx.Err())
+	}
+}
+
 func (h *Hub) registerClient(client *Client) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
-
 	if h.rooms[client.roomID] == nil {
 		h.rooms[client.roomID] = make(map[*Client]bool)
 	}
 	h.rooms[client.roomID][client] = true
+	h.mu.Unlock()
 
-	logrus.WithFields(logrus.Fields{
-		"user_id":  client.userID,
-		"username": client.username,
-		"room_id":  client.roomID,
-	}).Info("Client registered")
+	client.log.Info("Client registered")
 
 	// Send join message to room
 	h.sendJoinMessage(client)
@@ -92,43 +163,44 @@ func (h *Hub) registerClient(client *Client) {
 
 func (h *Hub) unregisterClient(client *Client) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
+	clients, ok := h.rooms[client.roomID]
+	if !ok {
+		h.mu.Unlock()
+		return
+	}
+	if _, exists := clients[client]; !exists {
+		h.mu.Unlock()
+		return
+	}
 
-	if clients, ok := h.rooms[client.roomID]; ok {
-		if _, exists := clients[client]; exists {
-			delete(clients, client)
-			close(client.send)
+	delete(clients, client)
+	close(client.send)
 
-			// Remove room if empty
-			if len(clients) == 0 {
-				delete(h.rooms, client.roomID)
-			}
+	// Remove room if empty
+	if len(clients) == 0 {
+		delete(h.rooms, client.roomID)
+	}
+	h.mu.Unlock()
 
-			logrus.WithFields(logrus.Fields{
-				"user_id":  client.userID,
-				"username": client.username,
-				"room_id":  client.roomID,
-			}).Info("Client unregistered")
+	client.log.Info("Client unregistered")
 
-			// Send leave message to room
-			h.sendLeaveMessage(client)
-		}
-	}
+	// Send leave message to room
+	h.sendLeaveMessage(client)
 }
 
 func (h *Hub) broadcastToRoom(ctx context.Context, msg *BroadcastMessage) {
 	h.mu.RLock()
-	clients := h.rooms[msg.roomID]
+	_, ok := h.rooms[msg.roomID]
 	h.mu.RUnlock()
 
-	if clients == nil {
+	if !ok {
 		return
 	}
 
 	// Parse message
 	var wsMsg models.WSMessage
 	if err := json.Unmarshal(msg.message, &wsMsg); err != nil {
-		logrus.WithError(err).Error("Failed to parse WebSocket message")
+		msg.sender.log.WithError(err).Error("Failed to parse WebSocket message")
 		return
 	}
 
@@ -146,20 +218,36 @@ func (h *Hub) broadcastToRoom(ctx context.Context, msg *BroadcastMessage) {
 	// Marshal updated message
 	messageBytes, err := json.Marshal(wsMsg)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to marshal WebSocket message")
+		msg.sender.log.WithError(err).Error("Failed to marshal WebSocket message")
 		return
 	}
 
 	// Broadcast to all clients in room
+	h.deliver(msg.roomID, messageBytes)
+}
+
+// deliver queues a message for every client in the room. Clients whose send
+// buffer is full are too slow to keep up and get disconnected.
+func (h *Hub) deliver(roomID string, messageBytes []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	clients := h.rooms[roomID]
 	for client := range clients {
 		select {
 		case client.send <- messageBytes:
 		default:
 			// Client's send channel is full, close it
+			slowClientsDropped.Inc()
+			client.log.Warn("Dropping slow WebSocket client")
 			close(client.send)
 			delete(clients, client)
 		}
 	}
+
+	if clients != nil && len(clients) == 0 {
+		delete(h.rooms, roomID)
+	}
 }
 
 func (h *Hub) sendJoinMessage(client *Client) {
@@ -173,11 +261,10 @@ func (h *Hub) sendJoinMessage(client *Client) {
 	}
 
 	messageBytes, _ := json.Marshal(msg)
-	h.Broadcast <- &BroadcastMessage{
-		roomID:  client.roomID,
-		message: messageBytes,
-		sender:  client,
-	}
+
+	// Deliver directly: this runs on the Run goroutine, which is the only
+	// reader of the Broadcast channel.
+	h.deliver(client.roomID, messageBytes)
 }
 
 func (h *Hub) sendLeaveMessage(client *Client) {
@@ -193,33 +280,21 @@ func (h *Hub) sendLeaveMessage(client *Client) {
 	messageBytes, _ := json.Marshal(msg)
 
 	// Send directly to remaining clients
-	h.mu.RLock()
-	clients := h.rooms[client.roomID]
-	h.mu.RUnlock()
-
-	if clients != nil {
-		for c := range clients {
-			select {
-			case c.send <- messageBytes:
-			default:
-			}
-		}
-	}
+	h.deliver(client.roomID, messageBytes)
 }
 
 func (h *Hub) sendRoomInfo(client *Client) {
 	h.mu.RLock()
 	clients := h.rooms[client.roomID]
-	h.mu.RUnlock()
-
 	users := make([]string, 0, len(clients))
 	for c := range clients {
 		users = append(users, c.username)
 	}
+	h.mu.RUnlock()
 
 	roomInfo := models.RoomInfo{
 		RoomID:    client.roomID,
-		UserCount: len(clients),
+		UserCount: len(users),
 		Users:     users,
 	}
 
@@ -243,7 +318,7 @@ func (h *Hub) sendRoomInfo(client *Client) {
 
 func (h *Hub) storeMessageInRedis(ctx context.Context, msg *models.WSMessage) {
 	// Store in Redis list with expiry (e.g., 24 hours)
-	key := fmt.Sprintf("chat:room:%s", msg.RoomID)
+	key := historyKey(msg.RoomID)
 
 	messageBytes, err := json.Marshal(msg)
 	if err != nil {
@@ -262,7 +337,7 @@ func (h *Hub) storeMessageInRedis(ctx context.Context, msg *models.WSMessage) {
 
 // GetRoomHistory retrieves chat history from Redis
 func (h *Hub) GetRoomHistory(ctx context.Context, roomID string, limit int64) ([]models.WSMessage, error) {
-	key := fmt.Sprintf("chat:room:%s", roomID)
+	key := historyKey(roomID)
 
 	// Get last N messages (0 = oldest, -1 = newest)
 	start := int64(0)
@@ -296,6 +371,82 @@ func (h *Hub) GetRoomHistory(ctx context.Context, roomID string, limit int64) ([
 	return result, nil
 }
 
+// FlushHistory deletes the stored chat history of a room, or of every room
+// when roomID is empty, and returns the number of rooms flushed.
+func (h *Hub) FlushHistory(ctx context.Context, roomID string) (int64, error) {
+	if roomID != "" {
+		return h.redis.Del(ctx, historyKey(roomID)).Result()
+	}
+
+	var flushed int64
+	iter := h.redis.Scan(ctx, 0, historyKey("*"), 100).Iterator()
+	for iter.Next(ctx) {
+		n, err := h.redis.Del(ctx, iter.Val()).Result()
+		if err != nil {
+			return flushed, err
+		}
+		flushed += n
+	}
+	return flushed, iter.Err()
+}
+
+// HasHistory reports whether a room has stored chat history, i.e. whether a
+// message was sent within the history TTL.
+func (h *Hub) HasHistory(ctx context.Context, roomID string) (bool, error) {
+	n, err := h.redis.Exists(ctx, historyKey(roomID)).Result()
+	return n > 0, err
+}
+
+// historyKey is the Redis list holding a room's chat history.
+func historyKey(roomID string) string {
+	return fmt.Sprintf("chat:room:%s", roomID)
+}
+
+// Stats is a point-in-time snapshot of the hub for metrics.
+type Stats struct {
+	RoomClients         map[string]int // connected clients per room
+	BroadcastQueueDepth int            // messages waiting for the Run loop
+}
+
+// Stats returns the current client count per room and the broadcast queue depth.
+func (h *Hub) Stats() Stats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	rooms := make(map[string]int, len(h.rooms))
+	for roomID, clients := range h.rooms {
+		rooms[roomID] = len(clients)
+	}
+	return Stats{RoomClients: rooms, BroadcastQueueDepth: len(h.Broadcast)}
+}
+
+// TopRooms returns the client counts of the n rooms with the most clients
+// and the clients of all other rooms summed up. Ties go to the lower room
+// ID, so equally busy rooms are reported the same way on every snapshot.
+func (s Stats) TopRooms(n int) (map[string]int, int) {
+	ids := make([]string, 0, len(s.RoomClients))
+	for roomID := range s.RoomClients {
+		ids = append(ids, roomID)
+	}
+	slices.SortFunc(ids, func(a, b string) int {
+		if c := cmp.Compare(s.RoomClients[b], s.RoomClients[a]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+
+	top := make(map[string]int, min(n, len(ids)))
+	other := 0
+	for i, roomID := range ids {
+		if i < n {
+			top[roomID] = s.RoomClients[roomID]
+		} else {
+			other += s.RoomClients[roomID]
+		}
+	}
+	return top, other
+}
+
 // GetRoomUserCount returns the number of users in a room
 func (h *Hub) GetRoomUserCount(roomID string) int {
 	h.mu.RLock()
@@ -0,0 +1,145 @@
+package hub
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+	rawRedis "github.com/redis/go-redis/v9"
+
+	"egaldeutsch-be/internal/redis"
+	"egaldeutsch-be/modules/websocket/internal/models"
+)
+
+// startHubServer runs a hub behind a test HTTP server that upgrades every
+// request and registers the connection as a client in room "general".
+func startHubServer(t *testing.T) (*Hub, context.CancelFunc, string) {
+	t.Helper()
+
+	h := NewHub(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	go h.Run(ctx)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		client := NewClient(r.Context(), h, conn, uuid.New(), "tester", "general")
+		h.Register <- client
+
+		connCtx, connCancel := context.WithCancel(context.Background())
+		defer connCancel()
+		go client.WritePump(connCtx)
+		client.ReadPump(connCtx)
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(cancel)
+
+	return h, cancel, "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func TestHubShutdownClosesClientsWithGoingAway(t *testing.T) {
+	h, stopHub, url := startHubServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.CloseNow()
+
+	// The join message proves the hub loop keeps running after registration
+	if _, _, err := conn.Read(ctx); err != nil {
+		t.Fatalf("expected join message, got %v", err)
+	}
+
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			if _, _, err := conn.Read(ctx); err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	if err := h.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	select {
+	case err := <-readErr:
+		if status := websocket.CloseStatus(err); status != websocket.StatusGoingAway {
+			t.Fatalf("expected close status %v got %v (%v)", websocket.StatusGoingAway, status, err)
+		}
+	case <-ctx.Done():
+		t.Fatalf("client was not closed")
+	}
+
+	stopHub()
+	select {
+	case <-h.Done():
+	case <-ctx.Done():
+		t.Fatalf("hub did not stop")
+	}
+}
+
+func TestFlushHistory(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := rawRedis.NewClient(&rawRedis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	h := NewHub(&redis.RedisClient{Client: client})
+
+	ctx := context.Background()
+	for _, room := range []string{"a", "b", "c"} {
+		h.storeMessageInRedis(ctx, &models.WSMessage{Type: models.MessageTypeChat, RoomID: room, Content: "hi"})
+	}
+
+	n, err := h.FlushHistory(ctx, "a")
+	if err != nil || n != 1 {
+		t.Fatalf("flush room a: expected 1, got %d (%v)", n, err)
+	}
+	if mr.Exists("chat:room:a") {
+		t.Fatalf("history of room a still exists")
+	}
+
+	n, err = h.FlushHistory(ctx, "")
+	if err != nil || n != 2 {
+		t.Fatalf("flush all: expected 2, got %d (%v)", n, err)
+	}
+	if keys := mr.Keys(); len(keys) != 0 {
+		t.Fatalf("expected no keys left, got %v", keys)
+	}
+}
+
+func TestStatsTopRooms(t *testing.T) {
+	stats := Stats{RoomClients: map[string]int{"a": 1, "b": 5, "c": 3, "d": 3, "e": 2}}
+
+	top, other := stats.TopRooms(3)
+	if len(top) != 3 || top["b"] != 5 || top["c"] != 3 || top["d"] != 3 {
+		t.Fatalf("expected the three busiest rooms, got %v", top)
+	}
+	if other != 3 {
+		t.Fatalf("expected the other rooms to sum to 3 clients, got %d", other)
+	}
+
+	// Ties go to the lower room ID
+	top, other = stats.TopRooms(2)
+	if _, ok := top["c"]; !ok || other != 6 {
+		t.Fatalf("expected room c to win the tie, got %v and %d others", top, other)
+	}
+
+	top, other = stats.TopRooms(10)
+	if len(top) != 5 || other != 0 {
+		t.Fatalf("expected every room when there are fewer than n, got %v and %d others", top, other)
+	}
+}
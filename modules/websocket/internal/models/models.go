@@ -53,13 +53,13 @@ type RoomInfo struct {
 
 // Room represents a chat room
 type Room struct {
-	ID          string    `json:"id" db:"id"`
-	Name        string    `json:"name" db:"name"`
+	ID          string    `json:"id" db:"id" gorm:"type:uuid;primaryKey"`
+	Name        string    `json:"name" db:"name" gorm:"not null;size:100"`
 	Description string    `json:"description" db:"description"`
-	CreatedBy   uuid.UUID `json:"created_by" db:"created_by"`
+	CreatedBy   uuid.UUID `json:"created_by" db:"created_by" gorm:"type:uuid;not null;index"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
-	IsActive    bool      `json:"is_active" db:"is_active"`
+	IsActive    bool      `json:"is_active" db:"is_active" gorm:"not null;index"`
 }
 
 // TableName specifies the table name for the Room model
@@ -0,0 +1,60 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/scheduler"
+	"egaldeutsch-be/modules/websocket/internal/models"
+)
+
+// Jobs returns the websocket module's maintenance jobs.
+func (m *Module) Jobs(cfg config.SchedulerConfig) []scheduler.Job {
+	maxIdle := time.Duration(cfg.StaleRoomDays) * 24 * time.Hour
+	return []scheduler.Job{
+		{
+			Name:        "chat.deactivate-stale-rooms",
+			Description: "Deactivate chat rooms without recent activity",
+			Schedule:    scheduler.MustParseCron("30 3 * * *"),
+			MaxRetries:  3,
+			Run: func(ctx context.Context) error {
+				return m.deactivateStaleRooms(ctx, time.Now().Add(-maxIdle))
+			},
+		},
+	}
+}
+
+// deactivateStaleRooms marks active rooms last updated before the cutoff as
+// inactive, unless they still have recent chat history in Redis or clients
+// connected to this replica.
+func (m *Module) deactivateStaleRooms(ctx context.Context, cutoff time.Time) error {
+	var rooms []models.Room
+	if err := m.db.WithContext(ctx).Where("is_active = ? AND updated_at < ?", true, cutoff).Find(&rooms).Error; err != nil {
+		return fmt.Errorf("find stale rooms: %w", err)
+	}
+
+	deactivated := 0
+	for _, room := range rooms {
+		if m.hub.GetRoomUserCount(room.ID) > 0 {
+			continue
+		}
+		active, err := m.hub.HasHistory(ctx, room.ID)
+		if err != nil {
+			return fmt.Errorf("check history of room %s: %w", room.ID, err)
+		}
+		if active {
+			continue
+		}
+		if err := m.db.WithContext(ctx).Model(&room).Update("is_active", false).Error; err != nil {
+			return fmt.Errorf("deactivate room %s: %w", room.ID, err)
+		}
+		deactivated++
+	}
+
+	logrus.WithFields(logrus.Fields{"checked": len(rooms), "deactivated": deactivated}).Info("Deactivated stale chat rooms")
+	return nil
+}
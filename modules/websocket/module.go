@@ -2,47 +2,161 @@ package websocket
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
+	"egaldeutsch-be/internal/auth"
 	"egaldeutsch-be/internal/config"
+	"egaldeutsch-be/internal/metrics"
 	"egaldeutsch-be/internal/middleware"
+	"egaldeutsch-be/internal/rbac"
 	"egaldeutsch-be/internal/redis"
 	"egaldeutsch-be/modules/websocket/internal/handlers"
 	"egaldeutsch-be/modules/websocket/internal/hub"
+	"egaldeutsch-be/modules/websocket/internal/models"
 )
 
 type Module struct {
-	hub     *hub.Hub
-	handler *handlers.WSHandler
-	db      *gorm.DB
+	hub      *hub.Hub
+	handler  *handlers.WSHandler
+	db       *gorm.DB
+	jwtCfg   config.JwtConfig
+	idem     *middleware.Idempotency
+	denylist *auth.Denylist
+	apiKeys  *auth.APIKeys
+	policy   *rbac.Policy
+	verifier middleware.EmailVerifier
+
+	// stopHub cancels the context Hub.Run was started with
+	stopHub context.CancelFunc
 }
 
-func NewModule(db *gorm.DB, redisClient *redis.RedisClient) *Module {
+// NewModule creates the websocket module. idem makes room creation safe to
+// retry, denylist rejects revoked access tokens, apiKeys accepts API keys
+// and verifier keeps users with an unverified email address out of the
+// chat; nil disables any of them.
+// policy decides who may chat and who may moderate.
+func NewModule(db *gorm.DB, redisClient *redis.RedisClient, jwtCfg config.JwtConfig, idem *middleware.Idempotency, denylist *auth.Denylist, apiKeys *auth.APIKeys, policy *rbac.Policy, verifier middleware.EmailVerifier) *Module {
 	// Create hub with Redis client
 	h := hub.NewHub(redisClient)
 
-	// Start hub in background
-	go h.Run(context.Background())
-
 	// Create handler with hub
-	handler := handlers.NewWSHandler(h, config.JwtConfig{}, db) // TODO: Pass JWT config
+	handler := handlers.NewWSHandler(h, jwtCfg, db)
 
 	return &Module{
-		hub:     h,
-		handler: handler,
-		db:      db,
+		hub:      h,
+		handler:  handler,
+		db:       db,
+		jwtCfg:   jwtCfg,
+		idem:     idem,
+		denylist: denylist,
+		apiKeys:  apiKeys,
+		policy:   policy,
+		verifier: verifier,
 	}
 }
 
-// RegisterRoutes registers WebSocket routes
-func (m *Module) RegisterRoutes(rg *gin.RouterGroup, jwtCfg config.JwtConfig) {
-	// Update handler with JWT config and database
-	m.handler = handlers.NewWSHandler(m.hub, jwtCfg, m.db)
+// Name returns the module name used in config.yaml.
+func (m *Module) Name() string {
+	return "websocket"
+}
+
+// Start runs the hub in the background until Stop is called.
+func (m *Module) Start(ctx context.Context) error {
+	hubCtx, cancel := context.WithCancel(context.Background())
+	m.stopHub = cancel
+	go m.hub.Run(hubCtx)
+
+	m.registerMetrics()
+	return nil
+}
+
+// metricsTopRooms is how many rooms ws_room_clients labels. Rooms are
+// created by users, so the clients of the remaining rooms are summed under
+// the label otherRooms to keep the number of series bounded.
+const (
+	metricsTopRooms = 20
+	otherRooms      = "other"
+)
+
+// registerMetrics exports the hub's client, room and queue gauges on
+// /metrics.
+func (m *Module) registerMetrics() {
+	metrics.Default.NewGaugeFunc("ws_room_clients", "Connected WebSocket clients in the busiest rooms; the clients of all other rooms are summed under room other.", func() []metrics.Sample {
+		top, other := m.hub.Stats().TopRooms(metricsTopRooms)
+		samples := make([]metrics.Sample, 0, len(top)+1)
+		for roomID, n := range top {
+			// Any room ID is accepted, so a room may be called "other" too
+			if roomID == otherRooms {
+				other += n
+				continue
+			}
+			samples = append(samples, metrics.Sample{LabelValues: []string{roomID}, Value: float64(n)})
+		}
+		return append(samples, metrics.Sample{LabelValues: []string{otherRooms}, Value: float64(other)})
+	}, "room")
+
+	metrics.Default.NewGaugeFunc("ws_connected_clients", "Connected WebSocket clients.", func() []metrics.Sample {
+		clients := 0
+		for _, n := range m.hub.Stats().RoomClients {
+			clients += n
+		}
+		return []metrics.Sample{{Value: float64(clients)}}
+	})
+
+	metrics.Default.NewGaugeFunc("ws_active_rooms", "Rooms with at least one connected WebSocket client.", func() []metrics.Sample {
+		return []metrics.Sample{{Value: float64(len(m.hub.Stats().RoomClients))}}
+	})
+
+	metrics.Default.NewGaugeFunc("ws_broadcast_queue_depth", "Messages waiting to be broadcast by the hub.", func() []metrics.Sample {
+		return []metrics.Sample{{Value: float64(m.hub.Stats().BroadcastQueueDepth)}}
+	})
+}
 
+// Stop closes all WebSocket clients with a going-away status and stops
+// the hub loop. It returns early with an error if ctx expires first.
+func (m *Module) Stop(ctx context.Context) error {
+	if m.stopHub == nil {
+		return nil
+	}
+
+	closeErr := m.hub.Shutdown(ctx)
+	m.stopHub()
+
+	select {
+	case <-m.hub.Done():
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for hub to stop: %w", ctx.Err())
+	}
+
+	return closeErr
+}
+
+// FlushHistory deletes the stored chat history of a room, or of every room
+// when roomID is empty. It is used by the "chat flush-history" command.
+func (m *Module) FlushHistory(ctx context.Context, roomID string) (int64, error) {
+	return m.hub.FlushHistory(ctx, roomID)
+}
+
+// Health reports an error unless the hub loop is running.
+func (m *Module) Health(ctx context.Context) error {
+	if !m.hub.Running() {
+		return errors.New("hub is not running")
+	}
+	return nil
+}
+
+// RegisterRoutes registers WebSocket routes
+func (m *Module) RegisterRoutes(rg *gin.RouterGroup) {
 	ws := rg.Group("/ws")
-	ws.Use(middleware.AuthMiddleware(jwtCfg)) // Apply JWT authentication middleware
+	ws.Use(
+		middleware.AuthMiddleware(m.jwtCfg, m.denylist, m.apiKeys),
+		middleware.RequirePermission(m.policy, rbac.ChatWrite),
+		middleware.RequireVerifiedEmail(m.verifier),
+	)
 	{
 		// Protected routes - require authentication
 		ws.GET("/chat/:room_id", m.handler.HandleConnection)
@@ -50,13 +164,16 @@ func (m *Module) RegisterRoutes(rg *gin.RouterGroup, jwtCfg config.JwtConfig) {
 		ws.GET("/chat/:room_id/info", m.handler.GetRoomInfo)
 
 		// Room management (optional)
-		ws.POST("/rooms", m.handler.CreateRoom)
+		ws.POST("/rooms", m.idem.Middleware(), m.handler.CreateRoom)
 		ws.GET("/rooms", m.handler.ListRooms)
+
+		// Moderation
+		ws.DELETE("/chat/:room_id/history", middleware.RequirePermission(m.policy, rbac.ChatModerate), m.handler.ClearRoomHistory)
 	}
 }
 
-// GetModelsForMigration returns models that need to be migrated (for future persistence)
+// GetModelsForMigration returns models that need to be migrated
 func (m *Module) GetModelsForMigration() []interface{} {
-	// TODO: Return chat room and message models when implementing persistence
-	return []interface{}{}
+	// TODO: Add the chat message model when messages are persisted
+	return []interface{}{&models.Room{}}
 }
@@ -8,9 +8,11 @@ import (
 )
 
 // BaseModel contains common fields for all domain models
-// This provides consistent ID generation, timestamps, and soft deletes
+// This provides consistent ID generation, timestamps, and soft deletes.
+// IDs are generated in BeforeCreate rather than by a database default so the
+// models work on both PostgreSQL and SQLite.
 type BaseModel struct {
-	ID        uuid.UUID      `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey"`
 	CreatedAt time.Time      `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
@@ -18,30 +20,68 @@ type BaseModel struct {
 
 // BeforeCreate hook to ensure UUID is generated
 func (b *BaseModel) BeforeCreate(tx *gorm.DB) error {
-	if b.ID == uuid.Nil {
-		b.ID = uuid.New()
-	}
+	ensureID(&b.ID)
+	return nil
+}
+
+// UUIDKey is the primary key of models that keep their own timestamps. Like
+// that of BaseModel it is generated in BeforeCreate.
+type UUIDKey struct {
+	ID uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+}
+
+// BeforeCreate hook to ensure UUID is generated
+func (k *UUIDKey) BeforeCreate(tx *gorm.DB) error {
+	ensureID(&k.ID)
 	return nil
 }
 
-// UserRole represents the role of a user in the system
+// ensureID generates an ID unless one is set, so that the models work on
+// both PostgreSQL and SQLite without a database default.
+func ensureID(id *uuid.UUID) {
+	if *id == uuid.Nil {
+		*id = uuid.New()
+	}
+}
+
+// UserRole represents the role of a user in the system. What a role may do
+// is decided by the RBAC policy.
 type UserRole string
 
 const (
-	UserRoleAdmin UserRole = "admin"
-	UserRoleUser  UserRole = "user"
+	UserRoleLearner   UserRole = "learner"
+	UserRoleTeacher   UserRole = "teacher"
+	UserRoleModerator UserRole = "moderator"
+	UserRoleAdmin     UserRole = "admin"
+
+	// UserRoleUser is the former name of UserRoleLearner. It is still
+	// accepted as input and found in access tokens issued before the rename,
+	// but no longer stored.
+	UserRoleUser UserRole = "user"
 )
 
+// UserRoles lists the valid roles, from least to most privileged.
+var UserRoles = []UserRole{UserRoleLearner, UserRoleTeacher, UserRoleModerator, UserRoleAdmin}
+
 // IsValid checks if the user role is valid
 func (r UserRole) IsValid() bool {
 	switch r {
-	case UserRoleAdmin, UserRoleUser:
+	case UserRoleLearner, UserRoleTeacher, UserRoleModerator, UserRoleAdmin:
 		return true
 	default:
 		return false
 	}
 }
 
+// Canonical returns the current name of the role, mapping the legacy
+// UserRoleUser to UserRoleLearner.
+func (r UserRole) Canonical() UserRole {
+	if r == UserRoleUser {
+		return UserRoleLearner
+	}
+	return r
+}
+
 // String returns the string representation of the role
 func (r UserRole) String() string {
 	return string(r)
@@ -9,6 +9,9 @@ func TestUserRoleIsValid(t *testing.T) {
 	if UserRole("unknown").IsValid() {
 		t.Fatalf("unknown should be invalid")
 	}
+	if UserRoleUser.IsValid() || !UserRoleUser.Canonical().IsValid() {
+		t.Fatalf("the legacy user role should only be valid as learner")
+	}
 }
 
 func TestLanguageLevelIsValid(t *testing.T) {
@@ -2,8 +2,11 @@ package models
 
 // UserView is a small DTO used across modules where a full user model is not required.
 type UserView struct {
-	ID    string      `json:"id"`
-	Name  string      `json:"name"`
-	Email string      `json:"email"`
-	Role  interface{} `json:"role"`
+	ID    string   `json:"id"`
+	Name  string   `json:"name"`
+	Email string   `json:"email"`
+	Role  UserRole `json:"role"`
+	// Language is the language emails to the user are written in
+	Language      string `json:"language"`
+	EmailVerified bool   `json:"email_verified"`
 }